@@ -4,10 +4,13 @@ import (
 	"errors"
 	"fmt"
 	"log/slog"
+	"math"
+	"net"
 	"os"
 	"os/signal"
 	"runtime"
 	"runtime/pprof"
+	"strings"
 	"syscall"
 
 	"github.com/urfave/cli"
@@ -16,10 +19,15 @@ import (
 	"github.com/valerio/go-jeebie/jeebie/backend/headless"
 	"github.com/valerio/go-jeebie/jeebie/backend/sdl2"
 	"github.com/valerio/go-jeebie/jeebie/backend/terminal"
+	"github.com/valerio/go-jeebie/jeebie/console"
+	"github.com/valerio/go-jeebie/jeebie/cpu"
+	"github.com/valerio/go-jeebie/jeebie/gdb"
 	"github.com/valerio/go-jeebie/jeebie/input"
 	"github.com/valerio/go-jeebie/jeebie/input/action"
 	"github.com/valerio/go-jeebie/jeebie/input/event"
 	"github.com/valerio/go-jeebie/jeebie/timing"
+	"github.com/valerio/go-jeebie/jeebie/trace"
+	"github.com/valerio/go-jeebie/jeebie/video"
 )
 
 func main() {
@@ -64,9 +72,115 @@ func main() {
 			Name:  "debug",
 			Usage: "Enable debug information display",
 		},
+		cli.StringFlag{
+			Name:  "save-dir",
+			Usage: "Directory for battery save files (default: next to the ROM)",
+		},
+		cli.IntFlag{
+			Name:  "rewind-budget",
+			Usage: "Memory budget for rewind history in MiB (0 = disabled)",
+			Value: 32,
+		},
+		cli.IntFlag{
+			Name:  "rewind-interval",
+			Usage: "Take a rewind snapshot every N frames",
+			Value: 2,
+		},
+		cli.StringFlag{
+			Name:  "ppu",
+			Usage: "PPU renderer to use (scanline, fifo)",
+			Value: "scanline",
+		},
+		cli.StringFlag{
+			Name:  "boot-rom",
+			Usage: "Boot ROM to run before the cartridge (DMG/MGB/SGB: 256 bytes, CGB: 2304 bytes)",
+		},
+		cli.BoolFlag{
+			Name:  "ignore-checksum",
+			Usage: "Load cartridges with an invalid header checksum",
+		},
+		cli.StringFlag{
+			Name:  "model",
+			Usage: "Hardware model (auto, dmg0, dmg, mgb, sgb, cgb)",
+			Value: "auto",
+		},
+		cli.StringFlag{
+			Name:  "gdb",
+			Usage: "Start a GDB remote debugging server on this port or address (e.g. 2345 or localhost:2345)",
+		},
+		cli.StringFlag{
+			Name:  "serial",
+			Usage: "Device connected to the serial port (log, printer, script)",
+			Value: "log",
+		},
+		cli.StringFlag{
+			Name:  "printer-dir",
+			Usage: "Directory for images printed with --serial=printer (default: current directory)",
+		},
+		cli.StringFlag{
+			Name:  "serial-script",
+			Usage: "File of bytes replayed by --serial=script as a link cable partner",
+		},
+		cli.StringFlag{
+			Name:  "serial-record",
+			Usage: "Write the bytes sent to --serial=script to file",
+		},
+		cli.StringFlag{
+			Name:  "link-listen",
+			Usage: "Wait for another emulator to connect a link cable on this port or address (e.g. 5555 or localhost:5555)",
+		},
+		cli.StringFlag{
+			Name:  "link-connect",
+			Usage: "Connect a link cable to an emulator started with --link-listen at this address",
+		},
+		cli.StringFlag{
+			Name:  "symbols",
+			Usage: "RGBDS symbol file for debugging (default: <rom>.sym next to the ROM, if any)",
+		},
+		cli.BoolFlag{
+			Name:  "debugger",
+			Usage: "Start paused with an interactive debugger console on stdin (needs the sdl2 backend)",
+		},
+		cli.IntFlag{
+			Name:  "reverse-budget",
+			Usage: "Memory budget in MiB for stepping back in the debugger, 0 disables it (default: 64 with --debugger or --gdb, otherwise 0)",
+		},
+		cli.StringFlag{
+			Name:  "trace",
+			Usage: "Write a gameboy-doctor style trace of executed instructions to file",
+		},
+		cli.StringFlag{
+			Name:  "trace-pc",
+			Usage: "Only trace instructions in this address range (e.g. 0x4000-0x7FFF)",
+		},
+		cli.IntFlag{
+			Name:  "trace-bank",
+			Usage: "Only trace instructions running from this ROM bank (-1 = any)",
+			Value: trace.AnyBank,
+		},
+		cli.StringFlag{
+			Name:  "trace-frames",
+			Usage: "Only trace instructions in this frame range (e.g. 60-120, or 60- until exit)",
+		},
+		cli.StringFlag{
+			Name:  "cdl",
+			Usage: "Record how ROM bytes are used (code, operand, data, DMA) to a code/data log file, adding to it if it exists",
+		},
+		cli.StringFlag{
+			Name:  "coverage",
+			Usage: "Write lcov coverage of the ROM's symbols to file on exit (needs a symbol file)",
+		},
+		cli.StringFlag{
+			Name:  "game-profile",
+			Usage: "Write a pprof profile of the cycles spent in the game's functions to file on exit",
+		},
+		cli.StringFlag{
+			Name:  "game-profile-report",
+			Usage: "Write a flat report of the cycles spent in the game's functions to file on exit",
+		},
 		cli.StringFlag{
 			Name:  "cpuprofile",
-			Usage: "Write CPU profile to file",
+			Usage: "Write a Go CPU profile of the emulator to file",
 		},
 		cli.StringFlag{
 			Name:  "memprofile",
@@ -74,6 +188,7 @@ func main() {
 		},
 	}
 	app.Action = runEmulator
+	app.Commands = []cli.Command{testCommand}
 
 	err := app.Run(os.Args)
 	if err != nil {
@@ -108,6 +223,7 @@ func runEmulator(c *cli.Context) error {
 
 	var romPath string
 	var emu jeebie.Emulator
+	var debugConsole *console.Console
 	var err error
 
 	if testPattern {
@@ -123,10 +239,79 @@ func runEmulator(c *cli.Context) error {
 			}
 		}
 
-		emu, err = jeebie.NewWithFile(romPath)
+		renderer, err := parseRenderer(c.String("ppu"))
+		if err != nil {
+			return err
+		}
+		model, err := cpu.ParseModel(c.String("model"))
 		if err != nil {
 			return err
 		}
+
+		opts := []jeebie.Option{
+			jeebie.WithSaveDir(c.String("save-dir")),
+			jeebie.WithRewind(c.Int("rewind-budget")<<20, c.Int("rewind-interval")),
+			jeebie.WithRenderer(renderer),
+			jeebie.WithModel(model),
+			jeebie.WithBootROM(c.String("boot-rom")),
+			jeebie.WithIgnoreChecksum(c.Bool("ignore-checksum")),
+			jeebie.WithSymbols(c.String("symbols")),
+			jeebie.WithCDL(c.String("cdl")),
+			jeebie.WithCoverage(c.String("coverage")),
+			jeebie.WithProfile(c.String("game-profile"), c.String("game-profile-report")),
+		}
+		reverseBudget := c.Int("reverse-budget")
+		if !c.IsSet("reverse-budget") && (c.Bool("debugger") || c.String("gdb") != "") {
+			// checkpoints slow emulation down, only keep them when debugging
+			reverseBudget = 64
+		}
+		opts = append(opts, jeebie.WithReverse(reverseBudget<<20))
+		serialOpt, closeSerial, err := parseSerial(c)
+		if err != nil {
+			return err
+		}
+		defer closeSerial()
+		if serialOpt != nil {
+			opts = append(opts, serialOpt)
+		}
+		if tracePath := c.String("trace"); tracePath != "" {
+			filter, err := parseTraceFilter(c)
+			if err != nil {
+				return err
+			}
+			f, err := os.Create(tracePath)
+			if err != nil {
+				return fmt.Errorf("could not create trace file: %v", err)
+			}
+			defer f.Close()
+			opts = append(opts, jeebie.WithTrace(f, filter))
+		}
+
+		dmg, err := jeebie.NewWithFile(romPath, opts...)
+		if err != nil {
+			return err
+		}
+		defer func() {
+			if err := dmg.Close(); err != nil {
+				slog.Error("Failed to close emulator", "error", err)
+			}
+		}()
+
+		if c.Bool("debugger") {
+			if c.Bool("headless") || c.String("backend") == "terminal" {
+				return errors.New("--debugger reads commands from the terminal, use it with --backend=sdl2")
+			}
+			dmg.Pause()
+			debugConsole = console.New(dmg, os.Stdout)
+		}
+		if address := c.String("gdb"); address != "" {
+			server, err := startGDBServer(address, dmg)
+			if err != nil {
+				return err
+			}
+			defer server.Close()
+		}
+		emu = dmg
 	}
 
 	emulatorBackend, err := createBackend(c, romPath)
@@ -139,10 +324,26 @@ func runEmulator(c *cli.Context) error {
 	sigChan := make(chan os.Signal, 1)
 	signal.Notify(sigChan, os.Interrupt, syscall.SIGTERM)
 	go func() {
-		<-sigChan
-		running = false
+		for sig := range sigChan {
+			// with the debugger console, Ctrl-C stops execution as in gdb
+			if debugConsole != nil && sig == os.Interrupt {
+				debugConsole.Interrupt()
+				continue
+			}
+			running = false
+			return
+		}
 	}()
 
+	if debugConsole != nil {
+		go func() {
+			if err := debugConsole.Run(os.Stdin); err != nil {
+				slog.Error("Debugger console stopped", "error", err)
+			}
+			running = false
+		}()
+	}
+
 	config := backend.BackendConfig{
 		Title:         "Jeebie",
 		Scale:         2,
@@ -233,6 +434,141 @@ func createBackend(c *cli.Context, romPath string) (backend.Backend, error) {
 	}
 }
 
+// startGDBServer listens for GDB clients on address, on localhost if only a
+// port is given.
+func startGDBServer(address string, dmg *jeebie.DMG) (*gdb.Server, error) {
+	if !strings.Contains(address, ":") {
+		address = "localhost:" + address
+	}
+	server, err := gdb.Listen(address, dmg)
+	if err != nil {
+		return nil, fmt.Errorf("could not start GDB server: %v", err)
+	}
+	go func() {
+		if err := server.Serve(); err != nil {
+			slog.Error("GDB server stopped", "error", err)
+		}
+	}()
+	slog.Info("GDB server listening", "addr", server.Addr())
+	return server, nil
+}
+
+// parseSerial returns the option connecting the serial device selected with
+// --serial or the link cable flags, or nil for the default log sink. The
+// returned function closes files opened for the device.
+func parseSerial(c *cli.Context) (jeebie.Option, func(), error) {
+	noop := func() {}
+	linked := c.String("link-listen") != "" || c.String("link-connect") != ""
+	device := c.String("serial")
+	if linked && c.IsSet("serial") {
+		return nil, noop, errors.New("--serial can't be used with a link cable")
+	}
+	if linked {
+		conn, err := connectLink(c.String("link-listen"), c.String("link-connect"))
+		if err != nil {
+			return nil, noop, err
+		}
+		return jeebie.WithLinkCable(conn), noop, nil
+	}
+
+	switch device {
+	case "log":
+		return nil, noop, nil
+	case "printer":
+		return jeebie.WithPrinter(c.String("printer-dir")), noop, nil
+	case "script":
+		var script []byte
+		if path := c.String("serial-script"); path != "" {
+			var err error
+			if script, err = os.ReadFile(path); err != nil {
+				return nil, noop, fmt.Errorf("could not read serial script: %v", err)
+			}
+		}
+		path := c.String("serial-record")
+		if path == "" {
+			return jeebie.WithSerialScript(script, nil), noop, nil
+		}
+		f, err := os.Create(path)
+		if err != nil {
+			return nil, noop, fmt.Errorf("could not create serial record file: %v", err)
+		}
+		return jeebie.WithSerialScript(script, f), func() { f.Close() }, nil
+	}
+	return nil, noop, fmt.Errorf("unsupported serial device: %s (available: log, printer, script)", device)
+}
+
+// connectLink makes the link cable connection for --link-listen or
+// --link-connect, returning nil if neither is set. Listening waits for the
+// other emulator to connect.
+func connectLink(listen, connect string) (net.Conn, error) {
+	switch {
+	case listen != "" && connect != "":
+		return nil, errors.New("--link-listen and --link-connect are mutually exclusive")
+	case listen != "":
+		if !strings.Contains(listen, ":") {
+			listen = "localhost:" + listen
+		}
+		ln, err := net.Listen("tcp", listen)
+		if err != nil {
+			return nil, fmt.Errorf("could not listen for link cable: %v", err)
+		}
+		defer ln.Close()
+		slog.Info("Waiting for link cable connection", "addr", ln.Addr())
+		conn, err := ln.Accept()
+		if err != nil {
+			return nil, fmt.Errorf("could not accept link cable: %v", err)
+		}
+		slog.Info("Link cable connected", "peer", conn.RemoteAddr())
+		return conn, nil
+	case connect != "":
+		if !strings.Contains(connect, ":") {
+			connect = "localhost:" + connect
+		}
+		conn, err := net.Dial("tcp", connect)
+		if err != nil {
+			return nil, fmt.Errorf("could not connect link cable: %v", err)
+		}
+		slog.Info("Link cable connected", "peer", conn.RemoteAddr())
+		return conn, nil
+	}
+	return nil, nil
+}
+
+// parseTraceFilter builds the trace filter from the --trace-* flags.
+func parseTraceFilter(c *cli.Context) (trace.Filter, error) {
+	filter := trace.All
+	if pc := c.String("trace-pc"); pc != "" {
+		first, last, err := trace.ParseRange(pc, 0xFFFF)
+		if err != nil {
+			return filter, fmt.Errorf("--trace-pc: %v", err)
+		}
+		filter.PCStart, filter.PCEnd = uint16(first), uint16(last)
+	}
+	if frames := c.String("trace-frames"); frames != "" {
+		first, last, err := trace.ParseRange(frames, math.MaxUint64)
+		if err != nil {
+			return filter, fmt.Errorf("--trace-frames: %v", err)
+		}
+		filter.FirstFrame, filter.LastFrame = first, last
+	}
+	filter.Bank = c.Int("trace-bank")
+	if filter.Bank < trace.AnyBank {
+		return filter, fmt.Errorf("--trace-bank: invalid bank %d", filter.Bank)
+	}
+	return filter, nil
+}
+
+func parseRenderer(name string) (video.Renderer, error) {
+	switch name {
+	case "scanline":
+		return video.RendererScanline, nil
+	case "fifo":
+		return video.RendererFIFO, nil
+	default:
+		return 0, fmt.Errorf("unsupported ppu: %s (available: scanline, fifo)", name)
+	}
+}
+
 func handleEvent(emu jeebie.Emulator, b backend.Backend, evt backend.InputEvent, running *bool) {
 	info := action.GetInfo(evt.Action)
 
@@ -247,7 +583,13 @@ func handleEvent(emu jeebie.Emulator, b backend.Backend, evt backend.InputEvent,
 		emu.HandleAction(evt.Action, evt.Type == event.Press || evt.Type == event.Hold)
 
 	case action.CategoryEmulator:
-		// Emulator controls only respond to Press events
+		// Held emulator controls (rewind) stay active until released
+		if action.IsHeld(evt.Action) {
+			emu.HandleAction(evt.Action, evt.Type == event.Press || evt.Type == event.Hold)
+			return
+		}
+
+		// Other emulator controls only respond to Press events
 		if evt.Type == event.Press {
 			emu.HandleAction(evt.Action, true)
 			if evt.Action == action.EmulatorPauseToggle {
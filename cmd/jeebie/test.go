@@ -0,0 +1,129 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+
+	"github.com/urfave/cli"
+	"github.com/valerio/go-jeebie/jeebie"
+	"github.com/valerio/go-jeebie/jeebie/cpu"
+	"github.com/valerio/go-jeebie/jeebie/testrunner"
+)
+
+var testCommand = cli.Command{
+	Name:      "test",
+	Usage:     "Run test ROMs headless and report the results",
+	ArgsUsage: "<directory, ROM or manifest>",
+	Flags: []cli.Flag{
+		cli.StringFlag{
+			Name:  "junit",
+			Usage: "Write a JUnit XML report to this file",
+		},
+		cli.StringFlag{
+			Name:  "json",
+			Usage: "Write a JSON report to this file",
+		},
+		cli.StringFlag{
+			Name:  "screenshot-dir",
+			Usage: "Save the final frame of every test as PNG in this directory",
+		},
+		cli.IntFlag{
+			Name:  "parallel",
+			Usage: "Number of ROMs to run at once (0 = number of CPUs)",
+		},
+		cli.IntFlag{
+			Name:  "max-frames",
+			Usage: "Frames to run a test before timing out, unless the manifest sets a limit",
+			Value: testrunner.DefaultMaxFrames,
+		},
+		cli.StringFlag{
+			Name:  "ppu",
+			Usage: "PPU renderer to use (scanline, fifo)",
+			Value: "scanline",
+		},
+		cli.StringFlag{
+			Name:  "model",
+			Usage: "Hardware model (auto, dmg0, dmg, mgb, sgb, cgb)",
+			Value: "auto",
+		},
+	},
+	Action: runTests,
+}
+
+func runTests(c *cli.Context) error {
+	if c.NArg() == 0 {
+		cli.ShowCommandHelp(c, "test")
+		return errors.New("no test ROMs provided")
+	}
+
+	cases, err := testrunner.Discover(c.Args().Get(0))
+	if err != nil {
+		return err
+	}
+	if len(cases) == 0 {
+		return errors.New("no test ROMs found")
+	}
+
+	renderer, err := parseRenderer(c.String("ppu"))
+	if err != nil {
+		return err
+	}
+	model, err := cpu.ParseModel(c.String("model"))
+	if err != nil {
+		return err
+	}
+
+	// serial output is part of the report, don't log it line by line
+	slog.SetLogLoggerLevel(slog.LevelWarn)
+
+	results, err := testrunner.Run(cases, testrunner.Options{
+		Parallel:      c.Int("parallel"),
+		MaxFrames:     uint64(c.Int("max-frames")),
+		ScreenshotDir: c.String("screenshot-dir"),
+		EmulatorOptions: []jeebie.Option{
+			jeebie.WithRenderer(renderer),
+			jeebie.WithModel(model),
+		},
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, r := range results {
+		fmt.Printf("%-8s %s (%d frames)\n", r.Status, r.Name, r.Frames)
+	}
+	summary := testrunner.Summarize(results)
+	fmt.Printf("\n%d passed, %d failed, %d timed out, %d unknown, %d errors\n",
+		summary.Passed, summary.Failed, summary.Timeout, summary.Unknown, summary.Errors)
+
+	if path := c.String("junit"); path != "" {
+		if err := writeReport(path, results, testrunner.WriteJUnit); err != nil {
+			return err
+		}
+	}
+	if path := c.String("json"); path != "" {
+		if err := writeReport(path, results, testrunner.WriteJSON); err != nil {
+			return err
+		}
+	}
+
+	if summary.Passed != summary.Total {
+		return fmt.Errorf("%d of %d tests did not pass", summary.Total-summary.Passed, summary.Total)
+	}
+	return nil
+}
+
+func writeReport(path string, results []testrunner.Result, write func(io.Writer, []testrunner.Result) error) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := write(f, results); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
@@ -26,6 +26,36 @@ const (
 	WY uint16 = 0xFF4A
 	// Window X Position register.
 	WX uint16 = 0xFF4B
+	// BOOT unmaps the boot ROM when written with a non-zero value.
+	BOOT uint16 = 0xFF50
+)
+
+// Game Boy Color registers, only functional in CGB mode.
+// Reference: https://gbdev.io/pandocs/CGB_Registers.html
+const (
+	// KEY1 prepares a CPU speed switch (bit 0), performed by STOP. Bit 7 is the current speed.
+	KEY1 uint16 = 0xFF4D
+	// VBK selects the VRAM bank (0-1) mapped at 0x8000-0x9FFF.
+	VBK uint16 = 0xFF4F
+	// HDMA1-HDMA4 are the source (high, low) and destination (high, low) of a VRAM DMA transfer.
+	HDMA1 uint16 = 0xFF51
+	HDMA2 uint16 = 0xFF52
+	HDMA3 uint16 = 0xFF53
+	HDMA4 uint16 = 0xFF54
+	// HDMA5 starts a VRAM DMA transfer: length in 16 byte blocks (minus 1), bit 7 = HBlank mode.
+	HDMA5 uint16 = 0xFF55
+	// BCPS selects the byte of background palette RAM accessed by BCPD (bit 7 = auto-increment).
+	BCPS uint16 = 0xFF68
+	// BCPD reads or writes background palette RAM.
+	BCPD uint16 = 0xFF69
+	// OCPS selects the byte of object palette RAM accessed by OCPD (bit 7 = auto-increment).
+	OCPS uint16 = 0xFF6A
+	// OCPD reads or writes object palette RAM.
+	OCPD uint16 = 0xFF6B
+	// OPRI selects object priority mode: 0 = by OAM index (CGB), 1 = by X coordinate (DMG).
+	OPRI uint16 = 0xFF6C
+	// SVBK selects the WRAM bank (1-7) mapped at 0xD000-0xDFFF.
+	SVBK uint16 = 0xFF70
 )
 
 // Audio/Sound registers - APU (Audio Processing Unit)
@@ -0,0 +1,37 @@
+package addr
+
+import "strings"
+
+// registerNames maps I/O register names to their addresses.
+var registerNames = map[string]uint16{
+	"P1": P1, "SB": SB, "SC": SC,
+	"DIV": DIV, "TIMA": TIMA, "TMA": TMA, "TAC": TAC,
+	"IF": IF, "IE": IE,
+	"NR10": NR10, "NR11": NR11, "NR12": NR12, "NR13": NR13, "NR14": NR14,
+	"NR21": NR21, "NR22": NR22, "NR23": NR23, "NR24": NR24,
+	"NR30": NR30, "NR31": NR31, "NR32": NR32, "NR33": NR33, "NR34": NR34,
+	"NR41": NR41, "NR42": NR42, "NR43": NR43, "NR44": NR44,
+	"NR50": NR50, "NR51": NR51, "NR52": NR52,
+	"LCDC": LCDC, "STAT": STAT, "SCY": SCY, "SCX": SCX, "LY": LY, "LYC": LYC,
+	"DMA": DMA, "BGP": BGP, "OBP0": OBP0, "OBP1": OBP1, "WY": WY, "WX": WX,
+	"KEY1": KEY1, "VBK": VBK, "BOOT": BOOT,
+	"HDMA1": HDMA1, "HDMA2": HDMA2, "HDMA3": HDMA3, "HDMA4": HDMA4, "HDMA5": HDMA5,
+	"BCPS": BCPS, "BCPD": BCPD, "OCPS": OCPS, "OCPD": OCPD, "OPRI": OPRI, "SVBK": SVBK,
+}
+
+// Register returns the address of the I/O register with the given name (case
+// insensitive), like "LCDC" or "NR52".
+func Register(name string) (uint16, bool) {
+	address, ok := registerNames[strings.ToUpper(name)]
+	return address, ok
+}
+
+// RegisterName returns the name of the I/O register at address, if any.
+func RegisterName(address uint16) (string, bool) {
+	for name, a := range registerNames {
+		if a == address {
+			return name, true
+		}
+	}
+	return "", false
+}
@@ -13,7 +13,7 @@ import (
 // CH1 (square+sweep), CH2 (square), CH3 (wave), CH4 (noise), all mixed to stereo output.
 // This is basically a bunch of counters and timers that tick at certain frequency steps!
 type APU struct {
-	isCGB bool // TODO: CGB behaves differently in some cases
+	isCGB bool // CGB behaves differently in some cases, see SetCGB
 
 	// state, this is information derived from registers/memory.
 	enabled           bool
@@ -138,6 +138,11 @@ func New() *APU {
 	return apu
 }
 
+// SetCGB switches between DMG and CGB specific APU behavior.
+func (a *APU) SetCGB(enabled bool) {
+	a.isCGB = enabled
+}
+
 // Tick advances the APU by CPU T-cycles.
 func (a *APU) Tick(cycles int) {
 	if !a.enabled {
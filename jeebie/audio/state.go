@@ -0,0 +1,87 @@
+package audio
+
+import "github.com/valerio/go-jeebie/jeebie/state"
+
+// SerializeState saves or restores the full APU state: registers, wave RAM,
+// frame sequencer and per-channel generators.
+// Host-side settings (sample rate, debug mutes) are not part of the state, and
+// buffered PCM output is dropped on load.
+func (a *APU) SerializeState(s *state.Serializer) {
+	s.Bool(&a.isCGB)
+	s.Bool(&a.enabled)
+	for i := range a.ch {
+		a.ch[i].serializeState(s)
+	}
+	s.Bool(&a.vinLeft)
+	s.Bool(&a.vinRight)
+	s.Uint8(&a.volLeft)
+	s.Uint8(&a.volRight)
+	s.Int16(&a.vinSample)
+
+	s.Int64(&a.mixLeftAcc)
+	s.Int64(&a.mixRightAcc)
+	s.Int(&a.mixAccumCycles)
+	s.Float64(&a.pcmCycleAcc)
+
+	s.Int(&a.step)
+	s.Int(&a.cycles)
+
+	for _, r := range []*uint8{
+		&a.NR10, &a.NR11, &a.NR12, &a.NR13, &a.NR14,
+		&a.NR21, &a.NR22, &a.NR23, &a.NR24,
+		&a.NR30, &a.NR31, &a.NR32, &a.NR33, &a.NR34,
+		&a.NR41, &a.NR42, &a.NR43, &a.NR44,
+		&a.NR50, &a.NR51, &a.NR52,
+	} {
+		s.Uint8(r)
+	}
+	s.Bytes(a.waveRAM[:])
+
+	if s.Loading() {
+		a.pcmBuffer = a.pcmBuffer[:0]
+		a.pcmCursor = 0
+	}
+}
+
+func (ch *Channel) serializeState(s *state.Serializer) {
+	s.Bool(&ch.enabled)
+	s.Bool(&ch.left)
+	s.Bool(&ch.right)
+
+	s.Uint8(&ch.duty)
+	s.Uint8(&ch.timer)
+	s.Uint16(&ch.length)
+	s.Uint8(&ch.volume)
+
+	s.Uint8(&ch.envelopePace)
+	s.Bool(&ch.envelopeUp)
+	s.Uint8(&ch.envelopeCounter)
+	s.Bool(&ch.envelopeLatched)
+
+	s.Uint16(&ch.period)
+	s.Bool(&ch.trigger)
+	s.Bool(&ch.lengthEnable)
+	s.Int(&ch.freqTimer)
+	s.Uint8(&ch.dutyStep)
+
+	s.Uint8(&ch.sweepPeriod)
+	s.Bool(&ch.sweepDown)
+	s.Uint8(&ch.sweepStep)
+	s.Bool(&ch.sweepEnabled)
+	s.Uint8(&ch.sweepTimer)
+	s.Uint16(&ch.shadowFreq)
+	s.Bool(&ch.sweepNegUsed)
+
+	s.Uint8(&ch.waveIndex)
+	s.Uint8(&ch.waveSample)
+	s.Bool(&ch.waveReadable)
+	s.Bool(&ch.waveReadDelayed)
+	s.Int(&ch.noiseTimer)
+
+	s.Bool(&ch.dacEnabled)
+
+	s.Uint16(&ch.lfsr)
+	s.Bool(&ch.use7bitLFSR)
+	s.Uint8(&ch.shift)
+	s.Uint8(&ch.divider)
+}
@@ -4,6 +4,7 @@ package sdl2
 
 import (
 	"fmt"
+	"slices"
 	"unsafe"
 
 	"github.com/valerio/go-jeebie/jeebie/debug"
@@ -50,8 +51,17 @@ type DebugWindow struct {
 	// Cached formatted strings to avoid sprintf on every frame
 	cachedDisasmLines []string // Cached disassembly text
 	cachedPC          uint16   // PC value when cache was created
+	cachedBank        int      // ROM bank when cache was created
+	cachedDisasmLabel string   // Panel label, with the current function
 	disasmCacheValid  bool     // Whether cached disasm is still valid
 
+	// Cached backtrace, rebuilt when PC, bank or the call stack change
+	cachedBacktrace     []string
+	cachedBacktracePC   uint16
+	cachedBacktraceBank int
+	cachedCallStack     []debug.CallFrame
+	backtraceCacheValid bool
+
 	needsUpdate bool
 }
 
@@ -151,6 +161,7 @@ func (dw *DebugWindow) Render() error {
 	dw.renderBackgroundPanel()
 	dw.renderPalettePanel()
 	dw.renderDisassemblyPanel()
+	dw.renderBacktracePanel()
 
 	if dw.audioData != nil {
 		dw.renderAudioPanel()
@@ -364,8 +375,6 @@ func (dw *DebugWindow) renderPalettePanel() {
 }
 
 func (dw *DebugWindow) renderDisassemblyPanel() {
-	dw.renderPanelLabel(10, 350, "Disassembly")
-
 	panelRect := &sdl.Rect{10, 375, 620, 410}
 	dw.renderer.SetDrawColor(40, 40, 40, 255)
 	dw.renderer.FillRect(panelRect)
@@ -373,14 +382,16 @@ func (dw *DebugWindow) renderDisassemblyPanel() {
 	dw.renderer.DrawRect(panelRect)
 
 	if dw.debugData == nil || dw.debugData.CPU == nil || dw.debugData.Memory == nil {
+		dw.renderPanelLabel(10, 350, "Disassembly")
 		DrawText(dw.renderer, "No debug data available", 20, 390, 1, 100, 100, 100)
 		return
 	}
 
 	pc := dw.debugData.CPU.PC
+	syms, bank := dw.debugData.Symbols, dw.debugData.ROMBank
 
-	// Only update disassembly if PC changed or cache is invalid
-	if !dw.disasmCacheValid || dw.cachedPC != pc {
+	// Only update disassembly if PC or the mapped bank changed, or cache is invalid
+	if !dw.disasmCacheValid || dw.cachedPC != pc || dw.cachedBank != bank {
 		disasmLines := debug.CreateDisassemblyWithBuffer(dw.debugData.Memory, pc, maxDisasmLines, dw.disasmBuffer)
 
 		// Clear and rebuild cache
@@ -397,13 +408,23 @@ func (dw *DebugWindow) renderDisassemblyPanel() {
 			} else {
 				dw.cachedDisasmLines = append(dw.cachedDisasmLines, "")
 			}
-			text := fmt.Sprintf("%04X: %s", line.Address, line.Instruction)
+			text := fmt.Sprintf("%04X: %s", line.Address, line.Symbolize(syms, bank))
 			dw.cachedDisasmLines = append(dw.cachedDisasmLines, text)
 		}
 
+		// Show the function containing PC when there are symbols
+		dw.cachedDisasmLabel = "Disassembly"
+		if syms != nil {
+			if function := syms.Describe(pc, bank); function != "" {
+				dw.cachedDisasmLabel += " - " + function
+			}
+		}
+
 		dw.cachedPC = pc
+		dw.cachedBank = bank
 		dw.disasmCacheValid = true
 	}
+	dw.renderPanelLabel(10, 350, dw.cachedDisasmLabel)
 
 	// Render cached lines
 	y := int32(385)
@@ -438,6 +459,9 @@ func (dw *DebugWindow) renderDisassemblyPanel() {
 	switch dw.debugData.DebuggerState {
 	case debug.DebuggerPaused:
 		statusText = "PAUSED - SPACE: resume | N: step | F: frame"
+		if dw.debugData.BreakReason != nil {
+			statusText = "PAUSED (" + dw.debugData.BreakReason.String() + ") - SPACE: resume"
+		}
 		statusR, statusG, statusB = 255, 150, 150
 	case debug.DebuggerStepInstruction:
 		statusText = "STEPPING - N: next step | SPACE: resume"
@@ -453,6 +477,48 @@ func (dw *DebugWindow) renderDisassemblyPanel() {
 	DrawText(dw.renderer, statusText, 20, statusY, 1, statusR, statusG, statusB)
 }
 
+// renderBacktracePanel shows the shadow call stack, innermost frame first.
+func (dw *DebugWindow) renderBacktracePanel() {
+	dw.renderPanelLabel(990, 175, "Backtrace")
+
+	panelRect := &sdl.Rect{990, 200, 280, 200}
+	dw.renderer.SetDrawColor(40, 40, 40, 255)
+	dw.renderer.FillRect(panelRect)
+	dw.renderer.SetDrawColor(100, 100, 100, 255)
+	dw.renderer.DrawRect(panelRect)
+
+	if dw.debugData == nil || dw.debugData.CPU == nil {
+		return
+	}
+
+	pc, bank, stack := dw.debugData.CPU.PC, dw.debugData.ROMBank, dw.debugData.CallStack
+	if !dw.backtraceCacheValid || dw.cachedBacktracePC != pc || dw.cachedBacktraceBank != bank ||
+		!slices.Equal(dw.cachedCallStack, stack) {
+		dw.cachedBacktrace = debug.Backtrace(pc, bank, stack, dw.debugData.Symbols)
+		dw.cachedBacktracePC = pc
+		dw.cachedBacktraceBank = bank
+		dw.cachedCallStack = append(dw.cachedCallStack[:0], stack...)
+		dw.backtraceCacheValid = true
+	}
+
+	const maxLines = 12
+	y := int32(210)
+	for i, line := range dw.cachedBacktrace {
+		if i == maxLines-1 && len(dw.cachedBacktrace) > maxLines {
+			line = fmt.Sprintf("... %d more", len(dw.cachedBacktrace)-i)
+		}
+		r, g, b := uint8(180), uint8(180), uint8(180)
+		if i == 0 {
+			r, g, b = 255, 255, 100
+		}
+		DrawText(dw.renderer, line, 1000, y, 1, r, g, b)
+		y += 16
+		if i == maxLines-1 {
+			break
+		}
+	}
+}
+
 func (dw *DebugWindow) renderSmallSpriteTile(tile video.Tile, x, y int32) {
 	// Clear the buffer (only non-zero values)
 	for i := range dw.spriteTileBuffer {
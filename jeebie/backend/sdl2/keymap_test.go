@@ -0,0 +1,27 @@
+//go:build sdl2
+
+package sdl2
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/valerio/go-jeebie/jeebie/input/action"
+	"github.com/valerio/go-jeebie/jeebie/input/event"
+	"github.com/veandco/go-sdl2/sdl"
+)
+
+func TestRewindKey(t *testing.T) {
+	assert.Equal(t, action.EmulatorRewind, keyMapping[sdl.K_BACKSPACE])
+
+	b := New()
+	down := b.handleKeyDown(sdl.K_BACKSPACE, 0)
+	if assert.Len(t, down, 1) {
+		assert.Equal(t, action.EmulatorRewind, down[0].Action)
+		assert.Equal(t, event.Press, down[0].Type)
+	}
+	up := b.handleKeyUp(sdl.K_BACKSPACE)
+	if assert.Len(t, up, 1) {
+		assert.Equal(t, event.Release, up[0].Type, "rewinding stops when the key is released")
+	}
+}
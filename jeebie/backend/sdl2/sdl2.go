@@ -269,6 +269,9 @@ var sdlKeyNameMap = map[sdl.Keycode]string{
 	sdl.K_F3:     "F3",
 	sdl.K_F4:     "F4",
 	sdl.K_F5:     "F5",
+	sdl.K_F6:     "F6",
+	sdl.K_F7:     "F7",
+	sdl.K_F8:     "F8",
 	sdl.K_F9:     "F9",
 	sdl.K_F10:    "F10",
 	sdl.K_F11:    "F11",
@@ -282,6 +285,12 @@ var sdlKeyNameMap = map[sdl.Keycode]string{
 	sdl.K_t:      "t",
 	sdl.K_f:      "f",
 	sdl.K_n:      "n",
+	sdl.K_u:      "u",
+	sdl.K_b:      "b",
+
+	sdl.K_BACKSPACE: "Backspace",
+	sdl.K_COMMA:     ",",
+	sdl.K_LESS:      "<",
 }
 
 // buildKeyMapping creates the key mapping by using default mappings
@@ -335,10 +344,8 @@ func (s *Backend) handleKeyDown(key sdl.Keycode, repeat uint8) []backend.InputEv
 
 func (s *Backend) handleKeyUp(key sdl.Keycode) []backend.InputEvent {
 	if act, exists := keyMapping[key]; exists {
-		// Only trigger Release events for Game Boy controls
-		switch act {
-		case action.GBButtonA, action.GBButtonB, action.GBButtonStart, action.GBButtonSelect,
-			action.GBDPadUp, action.GBDPadDown, action.GBDPadLeft, action.GBDPadRight:
+		// Only trigger Release events for held controls (Game Boy buttons, rewind)
+		if action.IsHeld(act) {
 			return []backend.InputEvent{{Action: act, Type: event.Release}}
 		}
 	}
@@ -388,9 +395,11 @@ func (s *Backend) gbColorToRGBA(gbColor uint32) (r, g, b, a uint8) {
 		return display.GrayscaleBlack, display.GrayscaleBlack, display.GrayscaleBlack, display.FullAlpha
 	}
 
-	// For any non-standard colors, extract the red channel and convert to grayscale
-	red := uint8((gbColor >> display.RGBARShift) & display.RGBAColorMask)
-	return red, red, red, display.FullAlpha
+	// Any other color (e.g. CGB palettes) is already RGBA
+	r = uint8((gbColor >> display.RGBARShift) & display.RGBAColorMask)
+	g = uint8((gbColor >> display.RGBAGShift) & display.RGBAColorMask)
+	b = uint8((gbColor >> display.RGBABShift) & display.RGBAColorMask)
+	return r, g, b, display.FullAlpha
 }
 
 // generateTestPattern creates different test patterns
@@ -76,6 +76,14 @@ var fontData = map[rune][7]uint8{
 	'-': {0x00, 0x00, 0x00, 0x78, 0x00, 0x00, 0x00},
 	'.': {0x00, 0x00, 0x00, 0x00, 0x00, 0x60, 0x60},
 	':': {0x00, 0x60, 0x60, 0x00, 0x60, 0x60, 0x00},
+	',': {0x00, 0x00, 0x00, 0x00, 0x60, 0x20, 0x40},
+	'_': {0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0xF8},
+	'+': {0x00, 0x20, 0x20, 0xF8, 0x20, 0x20, 0x00},
+	'#': {0x50, 0x50, 0xF8, 0x50, 0xF8, 0x50, 0x50},
+	'<': {0x10, 0x20, 0x40, 0x80, 0x40, 0x20, 0x10},
+	'>': {0x40, 0x20, 0x10, 0x08, 0x10, 0x20, 0x40},
+	'(': {0x10, 0x20, 0x40, 0x40, 0x40, 0x20, 0x10},
+	')': {0x40, 0x20, 0x10, 0x10, 0x10, 0x20, 0x40},
 }
 
 func DrawText(renderer *sdl.Renderer, text string, x, y int32, scale int32, r, g, b uint8) {
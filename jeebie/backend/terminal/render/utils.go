@@ -14,7 +14,12 @@ func PixelToShade(pixel uint32) int {
 	case 0xFFFFFFFF:
 		return 3 // White
 	default:
-		return 0
+		// Other colors (e.g. CGB palettes): quantize luminance to 4 shades
+		r := (pixel >> 24) & 0xFF
+		g := (pixel >> 16) & 0xFF
+		b := (pixel >> 8) & 0xFF
+		luma := (299*r + 587*g + 114*b) / 1000
+		return int(luma * 4 / 256)
 	}
 }
 
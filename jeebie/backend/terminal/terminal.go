@@ -27,12 +27,13 @@ const (
 	scaleY    = 1
 	frameTime = time.Second / 60
 
-	gameAreaWidth  = width * scaleX
-	gameAreaHeight = height * scaleY
-	registerHeight = 12
-	disasmHeight   = 9
-	minTermWidth   = 80
-	minTermHeight  = 24
+	gameAreaWidth   = width * scaleX
+	gameAreaHeight  = height * scaleY
+	registerHeight  = 12
+	disasmHeight    = 9
+	backtraceHeight = 5
+	minTermWidth    = 80
+	minTermHeight   = 24
 )
 
 // Backend implements the Backend interface using tcell for terminal rendering
@@ -141,8 +142,8 @@ func (t *Backend) Update(frame *video.FrameBuffer) ([]backend.InputEvent, error)
 	for act, lastPressed := range t.keyStates {
 		info := action.GetInfo(act)
 
-		// Skip non-game inputs (they're handled via eventQueue)
-		if info.Category != action.CategoryGameInput {
+		// Skip non-held inputs (they're handled via eventQueue)
+		if !action.IsHeld(act) {
 			continue
 		}
 
@@ -269,8 +270,7 @@ func (t *Backend) processKeyEvent(ev *tcell.EventKey, now time.Time) {
 		if act == action.EmulatorQuit {
 			t.running = false
 		}
-		info := action.GetInfo(act)
-		if info.Category == action.CategoryGameInput {
+		if action.IsHeld(act) {
 			// For game inputs, clear other directional inputs if this is a d-pad action
 			if act == action.GBDPadUp || act == action.GBDPadDown ||
 				act == action.GBDPadLeft || act == action.GBDPadRight {
@@ -305,6 +305,9 @@ var tcellKeyNameMap = map[tcell.Key]string{
 	tcell.KeyF3:     "F3",
 	tcell.KeyF4:     "F4",
 	tcell.KeyF5:     "F5",
+	tcell.KeyF6:     "F6",
+	tcell.KeyF7:     "F7",
+	tcell.KeyF8:     "F8",
 	tcell.KeyF9:     "F9",
 	tcell.KeyF10:    "F10",
 	tcell.KeyF11:    "F11",
@@ -325,6 +328,10 @@ var tcellRuneNameMap = map[rune]string{
 	'f': "f",
 	'i': "i",
 	'n': "n",
+	'u': "u",
+	'b': "b",
+	'<': "<",
+	',': ",",
 	'q': "q",
 	' ': "Space",
 	't': "t",
@@ -376,11 +383,11 @@ var runeMapping = buildRuneMapping()
 func (t *Backend) processRuneKey(r rune, now time.Time) {
 	// Handle mapped runes
 	if act, exists := runeMapping[r]; exists {
-		// Check if this is a game input that needs state tracking
+		// Check if this is a held input that needs state tracking
 		info := action.GetInfo(act)
 		slog.Debug("Key event (rune)", "rune", string(r), "action", info.Description, "category", info.Category)
 
-		if info.Category == action.CategoryGameInput {
+		if action.IsHeld(act) {
 			// For game inputs using WASD, clear other directional inputs
 			if act == action.GBDPadUp || act == action.GBDPadDown ||
 				act == action.GBDPadLeft || act == action.GBDPadRight {
@@ -454,9 +461,11 @@ func (t *Backend) render(frame *video.FrameBuffer) {
 		t.drawRegisters(rightPanelX, 1, rightPanelWidth, termHeight)
 		disasmY := registerHeight + 2
 		t.drawDisassembly(rightPanelX, disasmY, rightPanelWidth, termHeight)
+		backtraceY := disasmY + disasmHeight + 1
+		t.drawBacktrace(rightPanelX, backtraceY, rightPanelWidth, termHeight)
 	}
 
-	logsY := registerHeight + disasmHeight + 3
+	logsY := registerHeight + disasmHeight + backtraceHeight + 4
 	if !t.config.ShowDebug {
 		logsY = 1
 	}
@@ -475,6 +484,7 @@ func (t *Backend) drawBorders(termWidth, termHeight, dividerX int) {
 
 	registerEndY := registerHeight + 1
 	disasmEndY := registerEndY + disasmHeight + 1
+	backtraceEndY := disasmEndY + backtraceHeight + 1
 
 	if registerEndY < termHeight && t.config.ShowDebug {
 		for x := dividerX + 1; x < termWidth; x++ {
@@ -490,6 +500,13 @@ func (t *Backend) drawBorders(termWidth, termHeight, dividerX int) {
 		t.screen.SetContent(dividerX, disasmEndY, '├', nil, borderStyle)
 	}
 
+	if backtraceEndY < termHeight && t.config.ShowDebug {
+		for x := dividerX + 1; x < termWidth; x++ {
+			t.screen.SetContent(x, backtraceEndY, '─', nil, borderStyle)
+		}
+		t.screen.SetContent(dividerX, backtraceEndY, '├', nil, borderStyle)
+	}
+
 	var title string
 	if t.config.TestPattern {
 		patternNames := []string{"Checkerboard", "Gradient", "Stripes", "Diagonal"}
@@ -512,16 +529,25 @@ func (t *Backend) drawBorders(termWidth, termHeight, dividerX int) {
 			}
 		}
 
-		if registerEndY+1 < termHeight {
+		if registerEndY < termHeight {
 			title = " Disassembly "
 			for i, ch := range title {
 				if startX+i < termWidth {
-					t.screen.SetContent(startX+i, disasmEndY+1, ch, nil, titleStyle)
+					t.screen.SetContent(startX+i, registerEndY, ch, nil, titleStyle)
 				}
 			}
 		}
 
-		if disasmEndY+1 < termHeight {
+		if disasmEndY < termHeight {
+			title = " Backtrace "
+			for i, ch := range title {
+				if startX+i < termWidth {
+					t.screen.SetContent(startX+i, disasmEndY, ch, nil, titleStyle)
+				}
+			}
+		}
+
+		if backtraceEndY < termHeight {
 			levelStr := "INFO"
 			switch t.logLevel {
 			case slog.LevelDebug:
@@ -534,7 +560,7 @@ func (t *Backend) drawBorders(termWidth, termHeight, dividerX int) {
 			title = fmt.Sprintf(" Logs [%s] (-/+ filter) ", levelStr)
 			for i, ch := range title {
 				if startX+i < termWidth {
-					t.screen.SetContent(startX+i, disasmEndY+1, ch, nil, titleStyle)
+					t.screen.SetContent(startX+i, backtraceEndY, ch, nil, titleStyle)
 				}
 			}
 		}
@@ -620,6 +646,9 @@ func (t *Backend) drawRegisters(startX, startY, width, termHeight int) {
 	switch debugData.DebuggerState {
 	case debug.DebuggerPaused:
 		statusStr = "PAUSED"
+		if debugData.BreakReason != nil {
+			statusStr += " - " + debugData.BreakReason.String()
+		}
 	case debug.DebuggerStepInstruction:
 		statusStr = "STEP"
 	case debug.DebuggerStepFrame:
@@ -680,6 +709,11 @@ func (t *Backend) drawDisassembly(startX, startY, width, termHeight int) {
 
 	lines := t.createSimpleDisassembly(debugData.Memory, pc)
 
+	var syms disasm.Symbolizer
+	if debugData.Symbols != nil {
+		syms = debugData.Symbols
+	}
+
 	style := tcell.StyleDefault.Foreground(tcell.ColorGreen)
 	currentStyle := tcell.StyleDefault.Foreground(tcell.ColorYellow).Bold(true)
 
@@ -694,18 +728,14 @@ func (t *Backend) drawDisassembly(startX, startY, width, termHeight int) {
 			break
 		}
 
-		line := fmt.Sprintf(" 0x%04X: %s", disasmLine.address, disasmLine.instruction)
-
-		if disasmLine.address == pc {
-			line = "→" + line[1:]
-		}
+		line := disasm.FormatSymbolizedLine(disasmLine, disasmLine.Address == pc, syms, debugData.ROMBank)
 
 		if len(line) > width {
 			line = line[:width]
 		}
 
 		useStyle := style
-		if disasmLine.address == pc {
+		if disasmLine.Address == pc {
 			useStyle = currentStyle
 		}
 
@@ -722,32 +752,77 @@ func (t *Backend) drawDisassembly(startX, startY, width, termHeight int) {
 	}
 }
 
-type simpleDisasmLine struct {
-	address     uint16
-	instruction string
+// drawBacktrace shows the shadow call stack, innermost frame first.
+func (t *Backend) drawBacktrace(startX, startY, width, termHeight int) {
+	if t.debugProvider == nil {
+		return
+	}
+
+	debugData := t.debugProvider.ExtractDebugData()
+	if debugData == nil || debugData.CPU == nil {
+		return
+	}
+
+	if width <= 0 || startY >= termHeight {
+		return
+	}
+
+	lines := debug.Backtrace(debugData.CPU.PC, debugData.ROMBank, debugData.CallStack, debugData.Symbols)
+	if len(lines) > backtraceHeight {
+		// keep the innermost frames, noting how many are hidden
+		hidden := len(lines) - backtraceHeight + 1
+		lines = append(lines[:backtraceHeight-1], fmt.Sprintf("... %d more", hidden))
+	}
+
+	style := tcell.StyleDefault.Foreground(tcell.ColorAqua)
+	for i, line := range lines {
+		y := startY + i
+		if y >= termHeight {
+			break
+		}
+
+		x := startX
+		for j, ch := range line {
+			if j >= width || x >= startX+width {
+				break
+			}
+			t.screen.SetContent(x, y, ch, nil, style)
+			x++
+		}
+	}
+}
+
+// snapshotDisasmLine disassembles the instruction at offset in snapshot.
+func snapshotDisasmLine(snapshot *debug.MemorySnapshot, offset int) disasm.DisassemblyLine {
+	address := snapshot.StartAddr + uint16(offset)
+	instruction, length := disasm.DisassembleBytes(snapshot.Bytes, offset)
+	target, hasTarget := disasm.BranchTarget(address, snapshot.Bytes[offset:])
+	return disasm.DisassemblyLine{
+		Address:     address,
+		Instruction: instruction,
+		Length:      length,
+		Target:      target,
+		HasTarget:   hasTarget,
+	}
 }
 
-func (t *Backend) createSimpleDisassembly(snapshot *debug.MemorySnapshot, pc uint16) []simpleDisasmLine {
+func (t *Backend) createSimpleDisassembly(snapshot *debug.MemorySnapshot, pc uint16) []disasm.DisassemblyLine {
 	pcOffset := -1
 	if pc >= snapshot.StartAddr && pc < snapshot.StartAddr+uint16(len(snapshot.Bytes)) {
 		pcOffset = int(pc - snapshot.StartAddr)
 	}
 
 	if pcOffset < 0 {
-		lines := []simpleDisasmLine{}
+		lines := []disasm.DisassemblyLine{}
 		for i := 0; i < len(snapshot.Bytes) && len(lines) < disasmHeight; {
-			addr := snapshot.StartAddr + uint16(i)
-			instruction, length := disasm.DisassembleBytes(snapshot.Bytes, i)
-			lines = append(lines, simpleDisasmLine{
-				address:     addr,
-				instruction: instruction,
-			})
-			i += length
+			line := snapshotDisasmLine(snapshot, i)
+			lines = append(lines, line)
+			i += line.Length
 		}
 		return lines
 	}
 
-	allLines := []simpleDisasmLine{}
+	allLines := []disasm.DisassemblyLine{}
 
 	backwardBytes := 30
 	startOffset := pcOffset - backwardBytes
@@ -756,23 +831,18 @@ func (t *Backend) createSimpleDisassembly(snapshot *debug.MemorySnapshot, pc uin
 	}
 
 	for i := startOffset; i < len(snapshot.Bytes); {
-		addr := snapshot.StartAddr + uint16(i)
-		instruction, length := disasm.DisassembleBytes(snapshot.Bytes, i)
-
-		allLines = append(allLines, simpleDisasmLine{
-			address:     addr,
-			instruction: instruction,
-		})
+		line := snapshotDisasmLine(snapshot, i)
+		allLines = append(allLines, line)
 
-		i += length
-		if addr > pc && len(allLines) > disasmHeight*2 {
+		i += line.Length
+		if line.Address > pc && len(allLines) > disasmHeight*2 {
 			break
 		}
 	}
 
 	pcIndex := -1
 	for i, line := range allLines {
-		if line.address == pc {
+		if line.Address == pc {
 			pcIndex = i
 			break
 		}
@@ -0,0 +1,44 @@
+package jeebie
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/valerio/go-jeebie/jeebie/cpu"
+)
+
+func TestBootROMHandsOverToCartridge(t *testing.T) {
+	dir := t.TempDir()
+	rom := writeTestROM(t, dir, 0x00, 0x00)
+
+	// LD A,1; LDH (0x50),A, then the cartridge's NOPs lead to 0x0100
+	boot := make([]byte, 0x100)
+	copy(boot, []byte{0x3E, 0x01, 0xE0, 0x50})
+	bootPath := filepath.Join(dir, "boot.bin")
+	require.NoError(t, os.WriteFile(bootPath, boot, 0o644))
+
+	dmg, err := NewWithFile(rom, WithBootROM(bootPath))
+	require.NoError(t, err)
+	assert.Equal(t, uint16(0x0000), dmg.bus.CPU.GetPC())
+	assert.Equal(t, uint8(0x3E), dmg.bus.MMU.Read(0x0000))
+
+	for range 300 {
+		dmg.bus.TickInstruction()
+	}
+	assert.False(t, dmg.bus.MMU.BootROMMapped())
+	assert.Equal(t, uint16(0x0100), dmg.bus.CPU.GetPC(), "stuck in the cartridge's JR -2")
+}
+
+func TestModelSelection(t *testing.T) {
+	rom := writeTestROM(t, t.TempDir(), 0x00, 0x00)
+
+	dmg, err := NewWithFile(rom, WithModel(cpu.ModelMGB))
+	require.NoError(t, err)
+	assert.Equal(t, uint8(0xFF), dmg.bus.CPU.GetA())
+
+	_, err = NewWithFile(rom, WithBootROM(filepath.Join(t.TempDir(), "missing.bin")))
+	assert.Error(t, err)
+}
@@ -29,10 +29,25 @@ func (b *Bus) Read(address uint16) byte {
 	return b.MMU.Read(address)
 }
 
+// Peek reads memory ignoring the PPU access restrictions, see video.PeekBus.
+func (b *Bus) Peek(address uint16) byte {
+	return b.MMU.Peek(address)
+}
+
 func (b *Bus) Write(address uint16, value byte) {
 	b.MMU.Write(address, value)
 }
 
+// SetPPUStatus sets the STAT bits owned by the PPU, see video.StatusBus.
+func (b *Bus) SetPPUStatus(status byte) {
+	b.MMU.SetPPUStatus(status)
+}
+
+// ROMBank returns the ROM bank mapped at 0x4000-0x7FFF.
+func (b *Bus) ROMBank() int {
+	return b.MMU.ROMBank()
+}
+
 // Tick advances components by the given number of cycles
 // Called by opcodes during execution for precise timer/serial timing
 func (b *Bus) Tick(cycles int) {
@@ -42,15 +57,55 @@ func (b *Bus) Tick(cycles int) {
 // TickInstruction executes one CPU instruction and ticks all components
 // Returns the number of cycles consumed
 func (b *Bus) TickInstruction() int {
+	b.MMU.ArmWatches(true)
 	cycles := b.CPU.Exec()
+	b.MMU.ArmWatches(false)
+
+	// DMA transfers (CGB HDMA) stall the CPU, while everything else keeps running
+	if stall := b.MMU.TakeStallCycles(); stall > 0 {
+		b.MMU.Tick(stall)
+		cycles += stall
+	}
+
+	// In double speed mode the CPU and timers run twice as fast, while GPU and
+	// APU keep their pace: they only see half of the CPU cycles.
+	if b.MMU.DoubleSpeed() {
+		cycles /= 2
+	}
 
-	// Tick GPU and APU with full cycle count
 	b.GPU.Tick(cycles)
 	b.MMU.APU.Tick(cycles)
 
 	return cycles
 }
 
+// The methods below give the CPU and GPU access to Game Boy Color hardware,
+// see cpu.colorBus and video.ColorBus.
+
+func (b *Bus) CGBMode() bool {
+	return b.MMU.CGBMode()
+}
+
+func (b *Bus) SwitchSpeed() bool {
+	return b.MMU.SwitchSpeed()
+}
+
+func (b *Bus) ReadVRAM(bank uint8, address uint16) byte {
+	return b.MMU.ReadVRAM(bank, address)
+}
+
+func (b *Bus) BGPaletteColor(palette, index uint8) uint16 {
+	return b.MMU.BGPaletteColor(palette, index)
+}
+
+func (b *Bus) OBJPaletteColor(palette, index uint8) uint16 {
+	return b.MMU.OBJPaletteColor(palette, index)
+}
+
+func (b *Bus) HBlank() {
+	b.MMU.HBlank()
+}
+
 func (b *Bus) RequestInterrupt(interrupt addr.Interrupt) {
 	b.MMU.RequestInterrupt(interrupt)
 }
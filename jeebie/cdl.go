@@ -0,0 +1,72 @@
+package jeebie
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"os"
+
+	"github.com/valerio/go-jeebie/jeebie/cdl"
+)
+
+// startCDL starts code/data logging when WithCDL or WithCoverage was given,
+// continuing the log saved at the CDL path if there is one.
+func (e *DMG) startCDL() error {
+	if e.cdlPath == "" && e.coveragePath == "" {
+		return nil
+	}
+	if e.coveragePath != "" && e.symbols == nil {
+		slog.Warn("Coverage needs a symbol file, it won't be written")
+	}
+
+	cart := e.bus.MMU.Cartridge()
+	e.cdl = cdl.New(cart.ROMSize(), cart.Checksum())
+	if e.cdlPath != "" {
+		saved, err := cdl.Load(e.cdlPath)
+		switch {
+		case errors.Is(err, fs.ErrNotExist):
+		case err != nil:
+			return err
+		default:
+			if err := e.cdl.Merge(saved); err != nil {
+				return fmt.Errorf("%s: %w", e.cdlPath, err)
+			}
+		}
+	}
+
+	e.bus.CPU.SetFetchHook(e.cdl.Fetch)
+	e.bus.MMU.SetROMHook(e.cdl.Access)
+	return nil
+}
+
+// CDL returns the code/data log, or nil if it isn't recorded.
+func (e *DMG) CDL() *cdl.Log {
+	return e.cdl
+}
+
+// saveCDL writes the code/data log and coverage, if enabled.
+func (e *DMG) saveCDL() error {
+	if e.cdl == nil {
+		return nil
+	}
+
+	if e.cdlPath != "" {
+		if err := e.cdl.Save(e.cdlPath); err != nil {
+			return fmt.Errorf("writing code/data log: %w", err)
+		}
+		s := e.cdl.Summary()
+		slog.Info("Saved code/data log", "path", e.cdlPath,
+			"code", s.Code, "operand", s.Operand, "data", s.Data, "dma", s.DMA, "unused", s.Unused)
+	}
+
+	if e.coveragePath != "" && e.symbols != nil {
+		if err := writeFile(e.coveragePath, func(f *os.File) error {
+			return e.cdl.WriteLCOV(f, e.symbols, e.symbolPath)
+		}); err != nil {
+			return fmt.Errorf("writing coverage: %w", err)
+		}
+		slog.Info("Saved coverage", "path", e.coveragePath)
+	}
+	return nil
+}
@@ -0,0 +1,221 @@
+// Package cdl records how each byte of a cartridge ROM is used while a game
+// runs: executed as an opcode, read as an operand or data, or copied by DMA.
+// Such a code/data log (CDL) tells code from data for disassembly and ROM
+// hacking, and gives test coverage for homebrew ROMs.
+//
+// A log is saved as a 16 byte header followed by one byte of flags per ROM
+// byte, in ROM file order (bank * 0x4000 + offset in bank):
+//
+//	offset  size  content
+//	0       4     magic "GBCD"
+//	4       2     format version, 1 (little endian)
+//	6       2     reserved, zero
+//	8       4     CRC-32 of the ROM (little endian)
+//	12      4     ROM size in bytes (little endian)
+//	16      n     flags, see Flags
+//
+// Unused flag bits are reserved and zero.
+package cdl
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/valerio/go-jeebie/jeebie/cpu"
+	"github.com/valerio/go-jeebie/jeebie/memory"
+)
+
+// Flags tell how a ROM byte was used.
+type Flags uint8
+
+const (
+	Code    Flags = 1 << iota // executed as an opcode, or the byte after a 0xCB prefix
+	Operand                   // read as an immediate operand of an executed instruction
+	Data                      // read by the CPU as data
+	DMA                       // copied by OAM DMA or CGB HDMA, e.g. tile and sprite data
+)
+
+const (
+	magic      = "GBCD"
+	version    = 1
+	headerSize = 16
+	bankSize   = 0x4000
+)
+
+// ErrOtherROM is returned when loading or merging a log of another ROM.
+var ErrOtherROM = errors.New("cdl: log is for a different ROM")
+
+// Log holds the flags of every ROM byte.
+type Log struct {
+	crc   uint32
+	flags []Flags
+
+	// the instruction byte the CPU is about to read, see Fetch
+	fetchAddress uint16
+	fetchKind    cpu.FetchKind
+}
+
+// New returns an empty log for a ROM of romSize bytes with the given CRC-32.
+func New(romSize int, crc uint32) *Log {
+	return &Log{crc: crc, flags: make([]Flags, romSize)}
+}
+
+// Size returns the ROM size covered by the log.
+func (l *Log) Size() int {
+	return len(l.flags)
+}
+
+// Flags returns the flags of the byte at offset in the ROM file.
+func (l *Log) Flags(offset int) Flags {
+	return l.flags[offset]
+}
+
+// Mark adds flags to the byte at address, with bank mapped there.
+func (l *Log) Mark(address uint16, bank int, flags Flags) {
+	l.flags[l.offset(address, bank)] |= flags
+}
+
+// offset returns the ROM file offset of a ROM address, wrapping around banks
+// past the end of the ROM like the MBCs do.
+func (l *Log) offset(address uint16, bank int) int {
+	return (bank*bankSize + int(address%bankSize)) % len(l.flags)
+}
+
+// Fetch records that the CPU is about to read an instruction byte at address.
+// It is a cpu.FetchHook.
+func (l *Log) Fetch(address uint16, kind cpu.FetchKind) {
+	l.fetchAddress, l.fetchKind = address, kind
+}
+
+// Access records a ROM read. It is a memory.ROMHook.
+func (l *Log) Access(address uint16, bank int, access memory.ROMAccess) {
+	flags := Data
+	switch {
+	case access == memory.ROMDMA:
+		flags = DMA
+	case l.fetchKind == cpu.FetchOpcode && address == l.fetchAddress:
+		flags = Code
+	case l.fetchKind == cpu.FetchOperand && address == l.fetchAddress:
+		flags = Operand
+	}
+	if access == memory.ROMRead {
+		l.fetchKind = 0
+	}
+	l.Mark(address, bank, flags)
+}
+
+// Merge adds the flags of other, a log of the same ROM.
+func (l *Log) Merge(other *Log) error {
+	if other.crc != l.crc || len(other.flags) != len(l.flags) {
+		return ErrOtherROM
+	}
+	for i, f := range other.flags {
+		l.flags[i] |= f
+	}
+	return nil
+}
+
+// Summary counts ROM bytes by usage.
+type Summary struct {
+	Code, Operand, Data, DMA int
+	// Unused bytes have no flags
+	Unused int
+}
+
+// Summary counts bytes with each flag.
+func (l *Log) Summary() Summary {
+	var s Summary
+	for _, f := range l.flags {
+		if f&Code != 0 {
+			s.Code++
+		}
+		if f&Operand != 0 {
+			s.Operand++
+		}
+		if f&Data != 0 {
+			s.Data++
+		}
+		if f&DMA != 0 {
+			s.DMA++
+		}
+		if f == 0 {
+			s.Unused++
+		}
+	}
+	return s
+}
+
+// WriteTo writes the log in the format described in the package docs.
+func (l *Log) WriteTo(w io.Writer) (int64, error) {
+	header := make([]byte, headerSize)
+	copy(header, magic)
+	binary.LittleEndian.PutUint16(header[4:], version)
+	binary.LittleEndian.PutUint32(header[8:], l.crc)
+	binary.LittleEndian.PutUint32(header[12:], uint32(len(l.flags)))
+
+	bw := bufio.NewWriter(w)
+	bw.Write(header)
+	for _, f := range l.flags {
+		bw.WriteByte(byte(f))
+	}
+	if err := bw.Flush(); err != nil {
+		return 0, err
+	}
+	return int64(headerSize + len(l.flags)), nil
+}
+
+// Read reads a log written by WriteTo.
+func Read(r io.Reader) (*Log, error) {
+	header := make([]byte, headerSize)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return nil, fmt.Errorf("cdl: reading header: %w", err)
+	}
+	if string(header[:4]) != magic {
+		return nil, errors.New("cdl: not a code/data log")
+	}
+	if v := binary.LittleEndian.Uint16(header[4:]); v != version {
+		return nil, fmt.Errorf("cdl: unsupported version %d", v)
+	}
+
+	size := binary.LittleEndian.Uint32(header[12:])
+	if size > 8<<20 {
+		return nil, fmt.Errorf("cdl: invalid ROM size %d", size)
+	}
+	data := make([]byte, size)
+	if _, err := io.ReadFull(r, data); err != nil {
+		return nil, fmt.Errorf("cdl: reading flags: %w", err)
+	}
+
+	l := New(int(size), binary.LittleEndian.Uint32(header[8:]))
+	for i, b := range data {
+		l.flags[i] = Flags(b)
+	}
+	return l, nil
+}
+
+// Load reads the log at path.
+func Load(path string) (*Log, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return Read(f)
+}
+
+// Save writes the log to path.
+func (l *Log) Save(path string) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if _, err := l.WriteTo(f); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
@@ -0,0 +1,107 @@
+package cdl
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/valerio/go-jeebie/jeebie/cpu"
+	"github.com/valerio/go-jeebie/jeebie/memory"
+	"github.com/valerio/go-jeebie/jeebie/symbols"
+)
+
+func TestAccess(t *testing.T) {
+	l := New(0x10000, 0x1234)
+
+	l.Fetch(0x0150, cpu.FetchOpcode)
+	l.Access(0x0150, 0, memory.ROMRead)
+	l.Fetch(0x0151, cpu.FetchOperand)
+	l.Access(0x0151, 0, memory.ROMRead)
+	l.Access(0x0152, 0, memory.ROMRead)
+	l.Access(0x4000, 2, memory.ROMDMA)
+	l.Fetch(0xC000, cpu.FetchOpcode) // code in WRAM isn't reported by the MMU
+	l.Access(0x7FFF, 3, memory.ROMRead)
+
+	assert.Equal(t, Code, l.Flags(0x0150))
+	assert.Equal(t, Operand, l.Flags(0x0151))
+	assert.Equal(t, Data, l.Flags(0x0152))
+	assert.Equal(t, DMA, l.Flags(0x8000), "bank 2 starts at 0x8000 in the ROM file")
+	assert.Equal(t, Data, l.Flags(0xFFFF))
+
+	l.Access(0x4000, 6, memory.ROMRead)
+	assert.Equal(t, DMA|Data, l.Flags(0x8000), "banks past the end wrap around")
+	l.Access(0x0010, 1, memory.ROMRead)
+	assert.Equal(t, Data, l.Flags(0x4010), "a bank mapped at 0x0000, as on multicarts")
+
+	assert.Equal(t, Summary{Code: 1, Operand: 1, Data: 4, DMA: 1, Unused: 0x10000 - 6}, l.Summary())
+}
+
+func TestReadWrite(t *testing.T) {
+	l := New(0x8000, 0xCAFEBABE)
+	l.Mark(0x0100, 0, Code)
+	l.Mark(0x7FFF, 1, Data|DMA)
+
+	var buf bytes.Buffer
+	n, err := l.WriteTo(&buf)
+	require.NoError(t, err)
+	assert.Equal(t, int64(16+0x8000), n)
+	assert.Equal(t, []byte{'G', 'B', 'C', 'D', 1, 0, 0, 0, 0xBE, 0xBA, 0xFE, 0xCA, 0x00, 0x80, 0x00, 0x00}, buf.Bytes()[:16])
+
+	read, err := Read(bytes.NewReader(buf.Bytes()))
+	require.NoError(t, err)
+	assert.Equal(t, l.flags, read.flags)
+	assert.Equal(t, uint32(0xCAFEBABE), read.crc)
+
+	other := New(0x8000, 0xCAFEBABE)
+	other.Mark(0x0101, 0, Operand)
+	require.NoError(t, read.Merge(other))
+	assert.Equal(t, Operand, read.Flags(0x0101))
+	assert.ErrorIs(t, read.Merge(New(0x8000, 1)), ErrOtherROM)
+
+	_, err = Read(strings.NewReader("GBCX" + strings.Repeat("\x00", 12)))
+	assert.Error(t, err)
+	_, err = Read(bytes.NewReader(buf.Bytes()[:100]))
+	assert.Error(t, err, "truncated")
+}
+
+func TestWriteLCOV(t *testing.T) {
+	syms, err := symbols.Parse(strings.NewReader(`
+00:0150 Main
+00:0158 Main.loop
+00:0160 Unused
+00:0170 Tiles
+01:4000 Bank1Func
+01:4000 Bank1Func.start
+00:C000 wVariable
+`))
+	require.NoError(t, err)
+
+	l := New(0x8000, 0)
+	l.Mark(0x0150, 0, Code)
+	l.Mark(0x0151, 0, Operand)
+	l.Mark(0x0170, 0, DMA)
+	l.Mark(0x4002, 1, Code)
+
+	var buf bytes.Buffer
+	require.NoError(t, l.WriteLCOV(&buf, syms, "game.sym"))
+	assert.Equal(t, `TN:
+SF:game.sym
+FN:2,Main
+FN:4,Unused
+FN:6,Bank1Func
+FNDA:1,Main
+FNDA:0,Unused
+FNDA:1,Bank1Func
+FNF:3
+FNH:2
+DA:2,1
+DA:3,0
+DA:4,0
+DA:6,1
+LF:4
+LH:2
+end_of_record
+`, buf.String())
+}
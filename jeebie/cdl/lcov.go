@@ -0,0 +1,131 @@
+package cdl
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"sort"
+
+	"github.com/valerio/go-jeebie/jeebie/symbols"
+)
+
+// region is the ROM code between a label and the next one.
+type region struct {
+	symbol     symbols.Symbol
+	start, end int // ROM file offsets, end excluded
+}
+
+// regions splits the ROM at the labels of syms, only global ones if global is
+// set, preferring global labels when several share an offset. Regions end at
+// the next label or at the end of their bank.
+func (l *Log) regions(syms *symbols.Table, global bool) []region {
+	var regions []region
+	for _, s := range syms.Symbols() {
+		if s.Address >= 0x8000 || (global && s.Local()) {
+			continue
+		}
+		bank := s.Bank
+		if s.Address < bankSize {
+			bank = 0
+		}
+		start := int(s.Address)
+		if s.Address >= bankSize {
+			start = bank*bankSize + int(s.Address-bankSize)
+		}
+		if start >= len(l.flags) {
+			continue
+		}
+		regions = append(regions, region{symbol: s, start: start})
+	}
+	sort.SliceStable(regions, func(i, j int) bool {
+		return regions[i].start < regions[j].start
+	})
+
+	unique := regions[:0]
+	for _, r := range regions {
+		if n := len(unique); n > 0 && unique[n-1].start == r.start {
+			if unique[n-1].symbol.Local() && !r.symbol.Local() {
+				unique[n-1] = r
+			}
+			continue
+		}
+		unique = append(unique, r)
+	}
+	for i := range unique {
+		end := (unique[i].start/bankSize + 1) * bankSize
+		if i+1 < len(unique) && unique[i+1].start < end {
+			end = unique[i+1].start
+		}
+		unique[i].end = min(end, len(l.flags))
+	}
+	return unique
+}
+
+// usage returns the flags of all bytes in r.
+func (l *Log) usage(r region) Flags {
+	var used Flags
+	for _, f := range l.flags[r.start:r.end] {
+		used |= f
+	}
+	return used
+}
+
+// WriteLCOV writes coverage in the lcov tracefile format, for tools like
+// "lcov --summary" or genhtml. Each label of syms counts as a line, at its
+// line in the symbol file named by source, and global labels are functions.
+// A label is covered if any byte up to the next label was executed. Labels
+// whose bytes were only read as data or copied by DMA are data rather than
+// code and left out.
+func (l *Log) WriteLCOV(w io.Writer, syms *symbols.Table, source string) error {
+	bw := bufio.NewWriter(w)
+	fmt.Fprintf(bw, "TN:\nSF:%s\n", source)
+
+	// functions
+	found, hit := 0, 0
+	var counts []string
+	for _, r := range l.regions(syms, true) {
+		used := l.usage(r)
+		if isData(used) {
+			continue
+		}
+		count := 0
+		if used&Code != 0 {
+			count = 1
+			hit++
+		}
+		found++
+		fmt.Fprintf(bw, "FN:%d,%s\n", r.symbol.Line, r.symbol.Name)
+		counts = append(counts, fmt.Sprintf("FNDA:%d,%s\n", count, r.symbol.Name))
+	}
+	for _, c := range counts {
+		bw.WriteString(c)
+	}
+	fmt.Fprintf(bw, "FNF:%d\nFNH:%d\n", found, hit)
+
+	// lines, in symbol file order
+	regions := l.regions(syms, false)
+	sort.SliceStable(regions, func(i, j int) bool {
+		return regions[i].symbol.Line < regions[j].symbol.Line
+	})
+	found, hit = 0, 0
+	for _, r := range regions {
+		used := l.usage(r)
+		if isData(used) {
+			continue
+		}
+		count := 0
+		if used&Code != 0 {
+			count = 1
+			hit++
+		}
+		found++
+		fmt.Fprintf(bw, "DA:%d,%d\n", r.symbol.Line, count)
+	}
+	fmt.Fprintf(bw, "LF:%d\nLH:%d\nend_of_record\n", found, hit)
+	return bw.Flush()
+}
+
+// isData reports whether bytes with the used flags hold data, not code.
+func isData(used Flags) bool {
+	return used&(Code|Operand) == 0 && used&(Data|DMA) != 0
+}
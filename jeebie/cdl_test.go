@@ -0,0 +1,58 @@
+package jeebie
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/valerio/go-jeebie/jeebie/cdl"
+)
+
+func TestCDL(t *testing.T) {
+	dir := t.TempDir()
+	romPath := writeTestROM(t, dir, 0x00, 0x00)
+	symPath := filepath.Join(dir, "test.sym")
+	require.NoError(t, os.WriteFile(symPath, []byte("00:0100 Main\n00:0150 Unused\n"), 0644))
+	cdlPath := filepath.Join(dir, "test.cdl")
+	lcovPath := filepath.Join(dir, "test.info")
+
+	dmg, err := NewWithFile(romPath, WithSaveDir(dir), WithCDL(cdlPath), WithCoverage(lcovPath))
+	require.NoError(t, err)
+	require.NoError(t, dmg.RunUntilFrame())
+
+	log := dmg.CDL()
+	require.NotNil(t, log)
+	assert.Equal(t, 0x8000, log.Size())
+	assert.Equal(t, cdl.Code, log.Flags(0x100))
+	assert.Equal(t, cdl.Operand, log.Flags(0x101))
+	assert.Zero(t, log.Flags(0x150))
+	require.NoError(t, dmg.Close())
+
+	saved, err := cdl.Load(cdlPath)
+	require.NoError(t, err)
+	assert.Equal(t, cdl.Code, saved.Flags(0x100))
+
+	lcov, err := os.ReadFile(lcovPath)
+	require.NoError(t, err)
+	assert.Contains(t, string(lcov), "SF:"+symPath+"\n")
+	assert.Contains(t, string(lcov), "FNDA:1,Main\n")
+	assert.Contains(t, string(lcov), "FNDA:0,Unused\n")
+
+	// a new session continues the saved log
+	dmg, err = NewWithFile(romPath, WithSaveDir(dir), WithCDL(cdlPath))
+	require.NoError(t, err)
+	assert.Equal(t, cdl.Code, dmg.CDL().Flags(0x100))
+	require.NoError(t, dmg.Close())
+}
+
+func TestCDLOtherROM(t *testing.T) {
+	dir := t.TempDir()
+	romPath := writeTestROM(t, dir, 0x00, 0x00)
+	cdlPath := filepath.Join(dir, "test.cdl")
+	require.NoError(t, cdl.New(0x8000, 0x1234).Save(cdlPath))
+
+	_, err := NewWithFile(romPath, WithSaveDir(dir), WithCDL(cdlPath))
+	assert.ErrorIs(t, err, cdl.ErrOtherROM)
+}
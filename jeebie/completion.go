@@ -0,0 +1,190 @@
+package jeebie
+
+import (
+	"bytes"
+	"log/slog"
+)
+
+// TestStatus is the state of a test ROM as seen by a CompletionDetector.
+type TestStatus int
+
+const (
+	TestRunning TestStatus = iota // no result yet
+	TestPassed
+	TestFailed
+)
+
+func (s TestStatus) String() string {
+	switch s {
+	case TestPassed:
+		return "passed"
+	case TestFailed:
+		return "failed"
+	default:
+		return "running"
+	}
+}
+
+// TestResult is the result a test ROM reported, and which detector saw it.
+type TestResult struct {
+	Status   TestStatus
+	Detector string
+	Message  string // text output by the test, if any
+}
+
+// CompletionDetector recognizes the way a family of test ROMs reports its
+// result. Detectors added to a DMG are checked once per frame.
+type CompletionDetector interface {
+	// Check returns a result with status TestRunning until the test has finished.
+	Check(e *DMG) TestResult
+}
+
+// InstructionDetector is a CompletionDetector that also needs to see every
+// instruction, e.g. to catch a breakpoint opcode. CheckInstruction is called
+// before each instruction executes.
+type InstructionDetector interface {
+	CompletionDetector
+	CheckInstruction(e *DMG) TestResult
+}
+
+// DefaultCompletionDetectors returns detectors for the Mooneye and Blargg test
+// ROM conventions.
+func DefaultCompletionDetectors() []CompletionDetector {
+	return []CompletionDetector{
+		MooneyeDetector{},
+		BlarggSerialDetector{},
+		BlarggMemoryDetector{},
+	}
+}
+
+// DetectTestResult returns the first result reported by detectors, or a
+// result with status TestRunning if none has seen one.
+func DetectTestResult(e *DMG, detectors ...CompletionDetector) TestResult {
+	for _, d := range detectors {
+		if result := d.Check(e); result.Status != TestRunning {
+			return result
+		}
+	}
+	return TestResult{}
+}
+
+// MooneyeDetector recognizes Mooneye test ROMs, which execute LD B,B as a
+// debug breakpoint with B, C, D, E, H, L set to 3, 5, 8, 13, 21, 34 on success
+// and to 0x42 on failure.
+type MooneyeDetector struct{}
+
+// CheckInstruction reports a result when the next instruction is LD B,B.
+func (d MooneyeDetector) CheckInstruction(e *DMG) TestResult {
+	return d.Check(e)
+}
+
+// Check reports a result only when the next instruction is LD B,B, so other
+// code leaving the same values in the registers isn't taken for a result.
+func (MooneyeDetector) Check(e *DMG) TestResult {
+	c := e.bus.CPU
+	if e.bus.MMU.Peek(c.GetPC()) != 0x40 {
+		return TestResult{}
+	}
+	regs := [6]uint8{c.GetB(), c.GetC(), c.GetD(), c.GetE(), c.GetH(), c.GetL()}
+	switch regs {
+	case [6]uint8{3, 5, 8, 13, 21, 34}:
+		return TestResult{Status: TestPassed, Detector: "mooneye"}
+	case [6]uint8{0x42, 0x42, 0x42, 0x42, 0x42, 0x42}:
+		return TestResult{Status: TestFailed, Detector: "mooneye"}
+	}
+	return TestResult{}
+}
+
+// BlarggSerialDetector recognizes Blargg test ROMs that print "Passed" or
+// "Failed" over the serial port.
+type BlarggSerialDetector struct{}
+
+func (BlarggSerialDetector) Check(e *DMG) TestResult {
+	output := e.SerialOutput()
+	result := TestResult{Detector: "blargg-serial", Message: string(output)}
+	switch {
+	case bytes.Contains(output, []byte("Passed")):
+		result.Status = TestPassed
+	case bytes.Contains(output, []byte("Failed")):
+		result.Status = TestFailed
+	}
+	return result
+}
+
+// Blargg's memory protocol: a status byte at 0xA000, the signature at
+// 0xA001-0xA003 and a zero-terminated text at 0xA004.
+const (
+	blarggStatusAddress = 0xA000
+	blarggTextAddress   = 0xA004
+	blarggStatusRunning = 0x80
+	blarggMaxTextLength = 0x1000
+)
+
+var blarggSignature = [3]uint8{0xDE, 0xB0, 0x61}
+
+// BlarggMemoryDetector recognizes Blargg test ROMs that report through
+// cartridge RAM: once the signature is present, status 0x80 means running, 0
+// passed, and anything else is a failure code.
+type BlarggMemoryDetector struct{}
+
+func (BlarggMemoryDetector) Check(e *DMG) TestResult {
+	mmu := e.bus.MMU
+	for i, b := range blarggSignature {
+		if mmu.Peek(blarggStatusAddress+1+uint16(i)) != b {
+			return TestResult{}
+		}
+	}
+
+	status := mmu.Peek(blarggStatusAddress)
+	if status == blarggStatusRunning {
+		return TestResult{}
+	}
+
+	var text []byte
+	for i := range uint16(blarggMaxTextLength) {
+		b := mmu.Peek(blarggTextAddress + i)
+		if b == 0 {
+			break
+		}
+		text = append(text, b)
+	}
+
+	result := TestResult{Status: TestFailed, Detector: "blargg-memory", Message: string(text)}
+	if status == 0 {
+		result.Status = TestPassed
+	}
+	return result
+}
+
+// AddCompletionDetector checks d while the emulator runs. Once a detector
+// reports a result it is kept in TestResult and IsTestComplete returns true.
+func (e *DMG) AddCompletionDetector(d CompletionDetector) {
+	e.detectors = append(e.detectors, d)
+	if id, ok := d.(InstructionDetector); ok {
+		e.instructionDetectors = append(e.instructionDetectors, id)
+	}
+}
+
+// TestResult returns the result reported by the completion detectors so far.
+func (e *DMG) TestResult() TestResult {
+	return e.testResult
+}
+
+// checkInstructionDetectors runs the per-instruction detectors until one of
+// them reports a result.
+func (e *DMG) checkInstructionDetectors() {
+	if e.testResult.Status != TestRunning {
+		return
+	}
+	for _, d := range e.instructionDetectors {
+		if result := d.CheckInstruction(e); result.Status != TestRunning {
+			e.setTestResult(result)
+			return
+		}
+	}
+}
+
+func (e *DMG) setTestResult(result TestResult) {
+	e.testResult = result
+	slog.Debug("Test completion: result reported", "detector", result.Detector, "status", result.Status)
+}
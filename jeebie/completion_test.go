@@ -0,0 +1,114 @@
+package jeebie
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/valerio/go-jeebie/jeebie/internal/testutil"
+)
+
+// newCodeDMG creates an emulator running code from 0x150, after the header.
+func newCodeDMG(t *testing.T, cartType uint8, code []byte, opts ...Option) *DMG {
+	t.Helper()
+
+	dir := t.TempDir()
+	// 8KB RAM, ignored without an MBC
+	path := testutil.WriteROM(t, filepath.Join(dir, "test.gb"), cartType, 0x02, map[uint16][]byte{0x150: code})
+	dmg, err := NewWithFile(path, append([]Option{WithSaveDir(dir)}, opts...)...)
+	require.NoError(t, err)
+	return dmg
+}
+
+func TestMooneyeDetector(t *testing.T) {
+	tests := []struct {
+		name   string
+		regs   [6]uint8
+		status TestStatus
+	}{
+		{"pass", [6]uint8{3, 5, 8, 13, 21, 34}, TestPassed},
+		{"fail", [6]uint8{0x42, 0x42, 0x42, 0x42, 0x42, 0x42}, TestFailed},
+		{"other breakpoint", [6]uint8{1, 2, 3, 4, 5, 6}, TestRunning},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := tt.regs
+			dmg := newCodeDMG(t, 0x00, []byte{
+				0x06, r[0], 0x0E, r[1], 0x16, r[2], 0x1E, r[3], 0x26, r[4], 0x2E, r[5],
+				0x40,       // LD B, B
+				0x18, 0xFE, // JR -2
+			})
+			dmg.AddCompletionDetector(MooneyeDetector{})
+			dmg.ConfigureCompletionDetection(20, 5)
+			dmg.RunUntilComplete()
+
+			assert.Equal(t, tt.status, dmg.TestResult().Status)
+			if tt.status != TestRunning {
+				assert.Equal(t, "mooneye", dmg.TestResult().Detector)
+				assert.Equal(t, uint64(1), dmg.GetFrameCount(), "should stop on the frame with the breakpoint")
+			}
+		})
+	}
+}
+
+func TestMooneyeDetectorChecksOnlyAtBreakpoint(t *testing.T) {
+	dmg := newCodeDMG(t, 0x00, []byte{
+		0x01, 0x05, 0x03, // LD BC, 0x0305
+		0x11, 0x0D, 0x08, // LD DE, 0x080D
+		0x21, 0x22, 0x15, // LD HL, 0x1522
+		0x18, 0xFE, // JR -2
+	})
+	require.NoError(t, dmg.RunUntilFrame())
+
+	assert.Equal(t, TestRunning, MooneyeDetector{}.CheckInstruction(dmg).Status)
+	assert.Equal(t, TestRunning, MooneyeDetector{}.Check(dmg).Status, "signature without the breakpoint")
+}
+
+func TestBlarggSerialDetector(t *testing.T) {
+	var code []byte
+	for _, ch := range []byte("cpu\nFailed #3\n") {
+		code = append(code, 0x3E, ch, 0xE0, 0x01, 0x3E, 0x81, 0xE0, 0x02)
+	}
+	dmg := newCodeDMG(t, 0x00, append(code, 0x18, 0xFE))
+	dmg.AddCompletionDetector(BlarggSerialDetector{})
+	dmg.RunUntilComplete()
+
+	result := dmg.TestResult()
+	assert.Equal(t, TestFailed, result.Status)
+	assert.Equal(t, "blargg-serial", result.Detector)
+	assert.Equal(t, "cpu\nFailed #3\n", result.Message)
+}
+
+func TestBlarggMemoryDetector(t *testing.T) {
+	dmg := newCodeDMG(t, 0x03, []byte{0x18, 0xFE}) // MBC1+RAM+BATTERY
+	mmu := dmg.bus.MMU
+	mmu.Write(0x0000, 0x0A)
+
+	assert.Equal(t, TestRunning, BlarggMemoryDetector{}.Check(dmg).Status, "no signature")
+
+	mmu.Write(0xA000, 0x80)
+	for i, b := range []byte{0xDE, 0xB0, 0x61} {
+		mmu.Write(0xA001+uint16(i), b)
+	}
+	assert.Equal(t, TestRunning, BlarggMemoryDetector{}.Check(dmg).Status, "still running")
+
+	for i, b := range []byte("Passed\n\x00") {
+		mmu.Write(0xA004+uint16(i), b)
+	}
+	mmu.Write(0xA000, 0x00)
+	result := BlarggMemoryDetector{}.Check(dmg)
+	assert.Equal(t, TestPassed, result.Status)
+	assert.Equal(t, "Passed\n", result.Message)
+
+	mmu.Write(0xA000, 0x02)
+	assert.Equal(t, TestFailed, BlarggMemoryDetector{}.Check(dmg).Status)
+}
+
+func TestDetectTestResultWithoutResult(t *testing.T) {
+	dmg := newCodeDMG(t, 0x00, []byte{0x18, 0xFE})
+	require.NoError(t, dmg.RunUntilFrame())
+
+	assert.Equal(t, TestResult{}, DetectTestResult(dmg, DefaultCompletionDetectors()...))
+}
@@ -0,0 +1,660 @@
+package console
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/valerio/go-jeebie/jeebie"
+	"github.com/valerio/go-jeebie/jeebie/addr"
+	"github.com/valerio/go-jeebie/jeebie/debug"
+	"github.com/valerio/go-jeebie/jeebie/disasm"
+	"github.com/valerio/go-jeebie/jeebie/memory"
+	"github.com/valerio/go-jeebie/jeebie/symbols"
+)
+
+var errRunning = errors.New("the emulator is running, interrupt it first")
+
+type command struct {
+	name    string
+	aliases []string
+	usage   string
+	help    string
+	run     func(c *Console, format, args string) error
+}
+
+var commands []command
+
+func init() {
+	commands = []command{
+		{"break", []string{"b"}, "break [bank:]<address> [if <condition>]",
+			"Stop before the instruction at address runs. The bank only applies to 0x4000-0x7FFF.", cmdBreak},
+		{"watch", nil, "watch <address>[..<end>] [if <condition>]",
+			"Stop after an instruction writes the address or range, e.g. \"watch LCDC\".", watchCommand(memory.WatchWrite)},
+		{"rwatch", nil, "rwatch <address>[..<end>] [if <condition>]",
+			"Stop after an instruction reads the address or range.", watchCommand(memory.WatchRead)},
+		{"awatch", nil, "awatch <address>[..<end>] [if <condition>]",
+			"Stop after an instruction reads or writes the address or range.", watchCommand(memory.WatchRead | memory.WatchWrite)},
+		{"delete", []string{"d"}, "delete [id...]",
+			"Delete breakpoints and watchpoints, all of them without arguments.", cmdDelete},
+		{"continue", []string{"c"}, "continue",
+			"Resume execution until a breakpoint, watchpoint or Ctrl-C.", cmdContinue},
+		{"step", []string{"s", "stepi", "si"}, "step [count]",
+			"Execute count instructions, 1 by default.", cmdStep},
+		{"next", []string{"n", "nexti", "ni"}, "next [count]",
+			"Like step, but run over CALL and RST instructions.", cmdNext},
+		{"finish", nil, "finish",
+			"Run until the current function returns.", cmdFinish},
+		{"frame", []string{"f"}, "frame [count]",
+			"Run count video frames, 1 by default.", cmdFrame},
+		{"step-back", []string{"rs", "rsi", "reverse-stepi"}, "step-back [count]",
+			"Undo the last count instructions, 1 by default.", cmdStepBack},
+		{"step-back-frame", []string{"rf"}, "step-back-frame [count]",
+			"Go back to the start of the frame, count frames back, 1 by default.", cmdStepBackFrame},
+		{"reverse-continue", []string{"rc"}, "reverse-continue",
+			"Run backwards to the previous breakpoint or watchpoint hit.", cmdReverseContinue},
+		{"x", nil, "x[/<count><b|w|i>] <address>",
+			"Examine memory as bytes (b), words (w) or instructions (i), 16 bytes by default.", cmdExamine},
+		{"set", nil, "set <register>|[<address>] <value>",
+			"Set a register or a byte of memory, e.g. \"set a 0x3f\" or \"set [HL] 0\".", cmdSet},
+		{"disas", []string{"disassemble"}, "disas [address] [count]",
+			"Disassemble count instructions (10 by default) at address (PC by default).", cmdDisas},
+		{"bt", []string{"backtrace"}, "bt",
+			"Show the call stack, tracked from calls, returns and interrupts.", cmdBacktrace},
+		{"info", []string{"i"}, "info registers|break|io",
+			"Show the registers, the breakpoints and watchpoints, or the I/O registers.", cmdInfo},
+		{"help", []string{"h", "?"}, "help", "Show this help.", cmdHelp},
+		{"quit", []string{"q", "exit"}, "quit", "Leave the debugger.", nil},
+	}
+}
+
+func lookupCommand(name string) (command, bool) {
+	name = strings.ToLower(name)
+	for _, cmd := range commands {
+		if cmd.name == name {
+			return cmd, true
+		}
+		for _, alias := range cmd.aliases {
+			if alias == name {
+				return cmd, true
+			}
+		}
+	}
+	return command{}, false
+}
+
+func cmdHelp(c *Console, _, _ string) error {
+	for _, cmd := range commands {
+		fmt.Fprintf(c.out, "  %-44s %s\n", cmd.usage, cmd.help)
+	}
+	fmt.Fprintln(c.out, "Addresses and values are expressions like \"$C000\", \"HL+2\" or \"[SP]\", or I/O register names.")
+	fmt.Fprintln(c.out, "An empty line repeats the last command.")
+	return nil
+}
+
+func cmdBreak(c *Console, _, args string) error {
+	location, cond, err := c.splitCondition(args)
+	if err != nil {
+		return err
+	}
+	bp := jeebie.Breakpoint{Bank: jeebie.AnyBank, Condition: cond}
+	if bank, address, ok := strings.Cut(location, ":"); ok {
+		if bp.Bank, err = debug.ParseNumber(strings.TrimSpace(bank)); err != nil {
+			return err
+		}
+		location = address
+	}
+	if sym, ok := c.lookupSymbol(location); ok {
+		// labels in the switchable bank only break in their own bank
+		bp.Address = sym.Address
+		if bp.Bank == jeebie.AnyBank && sym.Address >= 0x4000 && sym.Address <= 0x7FFF {
+			bp.Bank = sym.Bank
+		}
+	} else if bp.Address, err = c.eval(location); err != nil {
+		return err
+	}
+	id := c.dmg.SetBreakpoint(bp)
+	fmt.Fprintf(c.out, "Breakpoint %d at %s.\n", id, c.describe(bp.Address, bp.Bank))
+	return nil
+}
+
+// watchCommand returns the command setting watchpoints for the given access.
+func watchCommand(access uint8) func(c *Console, format, args string) error {
+	return func(c *Console, _, args string) error {
+		return c.setWatchpoint(access, args)
+	}
+}
+
+func (c *Console) setWatchpoint(access uint8, args string) error {
+	location, cond, err := c.splitCondition(args)
+	if err != nil {
+		return err
+	}
+	wp := jeebie.Watchpoint{Access: access, Condition: cond}
+	start, end, isRange := strings.Cut(location, "..")
+	if wp.Start, err = c.eval(start); err != nil {
+		return err
+	}
+	wp.End = wp.Start
+	if isRange {
+		if wp.End, err = c.eval(end); err != nil {
+			return err
+		}
+		if wp.End < wp.Start {
+			return fmt.Errorf("end 0x%04X is before start 0x%04X", wp.End, wp.Start)
+		}
+	}
+	id := c.dmg.SetWatchpoint(wp)
+	fmt.Fprintf(c.out, "Watchpoint %d on %s.\n", id, describeRange(wp.Start, wp.End))
+	return nil
+}
+
+func cmdDelete(c *Console, _, args string) error {
+	if args == "" {
+		for _, bp := range c.dmg.Breakpoints() {
+			c.dmg.DeleteBreakpoint(bp.ID)
+		}
+		for _, wp := range c.dmg.Watchpoints() {
+			c.dmg.DeleteBreakpoint(wp.ID)
+		}
+		return nil
+	}
+	for _, field := range strings.Fields(args) {
+		id, err := strconv.Atoi(field)
+		if err != nil {
+			return fmt.Errorf("invalid id %q", field)
+		}
+		if !c.dmg.DeleteBreakpoint(id) {
+			return fmt.Errorf("no breakpoint or watchpoint %d", id)
+		}
+	}
+	return nil
+}
+
+func cmdContinue(c *Console, _, _ string) error {
+	c.dmg.Resume()
+	c.waitForStop()
+	c.printStop()
+	return nil
+}
+
+func cmdStep(c *Console, _, args string) error {
+	count, err := parseCount(args, 1)
+	if err != nil {
+		return err
+	}
+	if !c.dmg.Paused() {
+		return errRunning
+	}
+	for range count {
+		c.dmg.StepInstruction()
+		if c.dmg.BreakReason() != nil || c.interrupted.Load() {
+			break
+		}
+	}
+	c.printStop()
+	return nil
+}
+
+func cmdNext(c *Console, _, args string) error {
+	count, err := parseCount(args, 1)
+	if err != nil {
+		return err
+	}
+	if !c.dmg.Paused() {
+		return errRunning
+	}
+	for range count {
+		regs := c.dmg.ReadRegisters()
+		next := c.dmg.Disassemble(regs.PC, 1)[0]
+		if !isCall(c.dmg.ReadMemory(regs.PC, 1)[0]) {
+			c.dmg.StepInstruction()
+			if c.dmg.BreakReason() != nil || c.interrupted.Load() {
+				break
+			}
+			continue
+		}
+
+		// the call returns to the next instruction with the stack where it was,
+		// which also covers conditional calls that aren't taken
+		returnAddress := regs.PC + uint16(next.Length)
+		reached := c.stepUntil(func(after debug.CPUState, _ uint8) bool {
+			return after.PC == returnAddress && after.SP >= regs.SP
+		})
+		if !reached {
+			break
+		}
+	}
+	c.printStop()
+	return nil
+}
+
+func cmdFinish(c *Console, _, _ string) error {
+	if !c.dmg.Paused() {
+		return errRunning
+	}
+	sp := c.dmg.ReadRegisters().SP
+	c.stepUntil(func(after debug.CPUState, opcode uint8) bool {
+		// returns from nested calls leave SP at or below where it started
+		return isReturn(opcode) && after.SP > sp
+	})
+	c.printStop()
+	return nil
+}
+
+func cmdFrame(c *Console, _, args string) error {
+	count, err := parseCount(args, 1)
+	if err != nil {
+		return err
+	}
+	if !c.dmg.Paused() {
+		return errRunning
+	}
+	for range count {
+		c.dmg.StepFrame()
+		if c.dmg.BreakReason() != nil || c.interrupted.Load() {
+			break
+		}
+	}
+	c.printStop()
+	return nil
+}
+
+// errNoHistory is returned when stepping back past the recorded history.
+var errNoHistory = errors.New("no more history to go back to")
+
+func cmdStepBack(c *Console, _, args string) error {
+	return c.stepBack(args, c.dmg.StepBack)
+}
+
+func cmdStepBackFrame(c *Console, _, args string) error {
+	return c.stepBack(args, c.dmg.StepBackFrame)
+}
+
+// stepBack calls back count times, as parsed from args.
+func (c *Console) stepBack(args string, back func() bool) error {
+	count, err := parseCount(args, 1)
+	if err != nil {
+		return err
+	}
+	if !c.dmg.Paused() {
+		return errRunning
+	}
+	for range count {
+		if !back() {
+			c.printLocation()
+			return errNoHistory
+		}
+	}
+	c.printLocation()
+	return nil
+}
+
+func cmdReverseContinue(c *Console, _, _ string) error {
+	if !c.dmg.Paused() {
+		return errRunning
+	}
+	if !c.dmg.ReverseContinue() {
+		fmt.Fprintln(c.out, "Reached the start of the history.")
+	}
+	c.printStop()
+	return nil
+}
+
+func cmdExamine(c *Console, format, args string) error {
+	count, unit := 16, byte('b')
+	if format != "" {
+		if last := format[len(format)-1]; last < '0' || last > '9' {
+			unit = last
+			format = format[:len(format)-1]
+		}
+		if format != "" {
+			n, err := strconv.Atoi(format)
+			if err != nil || n <= 0 {
+				return fmt.Errorf("invalid count %q", format)
+			}
+			count = n
+		}
+	}
+	if args == "" {
+		return errors.New("missing address")
+	}
+	address, err := c.eval(args)
+	if err != nil {
+		return err
+	}
+
+	switch unit {
+	case 'b':
+		data := c.dmg.ReadMemory(address, count)
+		for i := 0; i < len(data); i += 16 {
+			row := data[i:min(i+16, len(data))]
+			fmt.Fprintf(c.out, "0x%04X: % X\n", address+uint16(i), row)
+		}
+	case 'w':
+		data := c.dmg.ReadMemory(address, count*2)
+		for i := 0; i < count; i += 8 {
+			fmt.Fprintf(c.out, "0x%04X:", address+uint16(i*2))
+			for j := i; j < min(i+8, count); j++ {
+				fmt.Fprintf(c.out, " %04X", uint16(data[j*2])|uint16(data[j*2+1])<<8)
+			}
+			fmt.Fprintln(c.out)
+		}
+	case 'i':
+		c.printDisassembly(address, count)
+	default:
+		return fmt.Errorf("unknown format %q, use b, w or i", unit)
+	}
+	return nil
+}
+
+func cmdSet(c *Console, _, args string) error {
+	if location, ok := strings.CutPrefix(args, "["); ok {
+		return setMemory(c, location)
+	}
+	name, value, ok := strings.Cut(args, " ")
+	if !ok {
+		return errors.New("usage: set <register>|[<address>] <value>")
+	}
+	v, err := c.eval(value)
+	if err != nil {
+		return err
+	}
+	regs := c.dmg.ReadRegisters()
+	if !setRegister(&regs, name, v) {
+		return fmt.Errorf("unknown register %q", name)
+	}
+	c.dmg.WriteRegisters(regs)
+	return nil
+}
+
+// setMemory writes a byte for "set [<address>] <value>", given what follows
+// the opening bracket. Like the GDB server, it writes through the debugger, so
+// VRAM and OAM are writable in any PPU mode and ROM is patched in place.
+func setMemory(c *Console, args string) error {
+	location, value, ok := strings.Cut(args, "]")
+	if !ok || strings.TrimSpace(value) == "" {
+		return errors.New("usage: set [<address>] <value>")
+	}
+	address, err := c.eval(location)
+	if err != nil {
+		return err
+	}
+	v, err := c.eval(value)
+	if err != nil {
+		return err
+	}
+	if v > 0xFF {
+		return fmt.Errorf("value 0x%X does not fit in a byte", v)
+	}
+	c.dmg.WriteMemory(address, []byte{byte(v)})
+	return nil
+}
+
+func cmdDisas(c *Console, _, args string) error {
+	address := c.dmg.ReadRegisters().PC
+	count := 10
+	fields := strings.Fields(args)
+	if len(fields) > 2 {
+		return errors.New("usage: disas [address] [count]")
+	}
+	var err error
+	if len(fields) > 0 {
+		if address, err = c.eval(fields[0]); err != nil {
+			return err
+		}
+	}
+	if len(fields) > 1 {
+		if count, err = parseCount(fields[1], count); err != nil {
+			return err
+		}
+	}
+	c.printDisassembly(address, count)
+	return nil
+}
+
+func cmdBacktrace(c *Console, _, _ string) error {
+	pc := c.dmg.ReadRegisters().PC
+	for _, line := range debug.Backtrace(pc, c.dmg.ROMBank(), c.dmg.CallStack(), c.dmg.Symbols()) {
+		fmt.Fprintln(c.out, line)
+	}
+	return nil
+}
+
+func cmdInfo(c *Console, _, args string) error {
+	switch strings.ToLower(args) {
+	case "r", "reg", "registers":
+		regs := c.dmg.ReadRegisters()
+		ime := "off"
+		if regs.IME {
+			ime = "on"
+		}
+		fmt.Fprintf(c.out, "AF 0x%02X%02X  [%s]\n", regs.A, regs.F, flagString(regs.F))
+		fmt.Fprintf(c.out, "BC 0x%02X%02X  DE 0x%02X%02X  HL 0x%02X%02X\n", regs.B, regs.C, regs.D, regs.E, regs.H, regs.L)
+		fmt.Fprintf(c.out, "SP 0x%04X  PC 0x%04X  IME %s\n", regs.SP, regs.PC, ime)
+	case "b", "break", "breakpoints", "watch", "watchpoints":
+		bps, wps := c.dmg.Breakpoints(), c.dmg.Watchpoints()
+		if len(bps) == 0 && len(wps) == 0 {
+			fmt.Fprintln(c.out, "No breakpoints or watchpoints.")
+		}
+		for _, bp := range bps {
+			bank := "any bank"
+			if bp.Bank != jeebie.AnyBank {
+				bank = fmt.Sprintf("bank %d", bp.Bank)
+			}
+			fmt.Fprintf(c.out, "%-3d breakpoint %s (%s)%s\n", bp.ID, c.describe(bp.Address, bp.Bank), bank, conditionSuffix(bp.Condition))
+		}
+		for _, wp := range wps {
+			fmt.Fprintf(c.out, "%-3d %-10s %s%s\n", wp.ID, watchKind(wp.Access), describeRange(wp.Start, wp.End), conditionSuffix(wp.Condition))
+		}
+	case "io":
+		column := 0
+		for address := uint32(0xFF00); address <= 0xFFFF; address++ {
+			name, ok := addr.RegisterName(uint16(address))
+			if !ok {
+				continue
+			}
+			value := c.dmg.ReadMemory(uint16(address), 1)[0]
+			fmt.Fprintf(c.out, "%-5s %04X = %02X   ", name, address, value)
+			if column++; column%4 == 0 {
+				fmt.Fprintln(c.out)
+			}
+		}
+		if column%4 != 0 {
+			fmt.Fprintln(c.out)
+		}
+	default:
+		return errors.New("usage: info registers|break|io")
+	}
+	return nil
+}
+
+// eval evaluates an address or value: an I/O register name, a label or an
+// expression.
+func (c *Console) eval(s string) (uint16, error) {
+	s = strings.TrimSpace(s)
+	if address, ok := addr.Register(s); ok {
+		return address, nil
+	}
+	expr, err := debug.ParseConditionWithSymbols(s, c.symbolAddress)
+	if err != nil {
+		return 0, err
+	}
+	regs := c.dmg.ReadRegisters()
+	return uint16(expr.Value(&regs, memoryReader{c.dmg})), nil
+}
+
+func (c *Console) lookupSymbol(name string) (symbols.Symbol, bool) {
+	if syms := c.dmg.Symbols(); syms != nil {
+		return syms.Lookup(strings.TrimSpace(name))
+	}
+	return symbols.Symbol{}, false
+}
+
+func (c *Console) symbolAddress(name string) (uint16, bool) {
+	sym, ok := c.lookupSymbol(name)
+	return sym.Address, ok
+}
+
+// describe formats an address with the label or function it is in, if any.
+// bank is the ROM bank to look labels up in, or jeebie.AnyBank for the
+// mapped one.
+func (c *Console) describe(address uint16, bank int) string {
+	syms := c.dmg.Symbols()
+	if syms == nil {
+		return fmt.Sprintf("0x%04X", address)
+	}
+	if bank == jeebie.AnyBank {
+		bank = c.dmg.ROMBank()
+	}
+	if name := syms.Describe(address, bank); name != "" {
+		return fmt.Sprintf("0x%04X <%s>", address, name)
+	}
+	return fmt.Sprintf("0x%04X", address)
+}
+
+func (c *Console) printDisassembly(address uint16, count int) {
+	pc := c.dmg.ReadRegisters().PC
+	for _, line := range c.dmg.Disassemble(address, count) {
+		marker := "  "
+		if line.Address == pc {
+			marker = "=>"
+		}
+		fmt.Fprintf(c.out, "%s 0x%04X:  %s\n", marker, line.Address, c.symbolize(line))
+	}
+}
+
+// symbolize returns the instruction of line with labels, if there are symbols.
+func (c *Console) symbolize(line disasm.DisassemblyLine) string {
+	syms := c.dmg.Symbols()
+	if syms == nil {
+		return line.Instruction
+	}
+	return disasm.SymbolizeInstruction(line, syms, c.dmg.ROMBank())
+}
+
+// splitCondition splits "<location> if <condition>".
+func (c *Console) splitCondition(args string) (string, *debug.Condition, error) {
+	location, source, ok := strings.Cut(args, " if ")
+	if strings.TrimSpace(location) == "" {
+		return "", nil, errors.New("missing address")
+	}
+	if !ok {
+		return location, nil, nil
+	}
+	cond, err := debug.ParseConditionWithSymbols(source, c.symbolAddress)
+	return location, cond, err
+}
+
+func parseCount(s string, def int) (int, error) {
+	if s == "" {
+		return def, nil
+	}
+	n, err := strconv.Atoi(s)
+	if err != nil || n <= 0 {
+		return 0, fmt.Errorf("invalid count %q", s)
+	}
+	return n, nil
+}
+
+// isCall reports whether opcode is a CALL or RST, which push a return address.
+func isCall(opcode uint8) bool {
+	return isCallNN(opcode) || isRST(opcode)
+}
+
+// isCallNN reports whether opcode is a conditional or unconditional CALL nn.
+func isCallNN(opcode uint8) bool {
+	switch opcode {
+	case 0xCD, 0xC4, 0xCC, 0xD4, 0xDC:
+		return true
+	}
+	return false
+}
+
+func isRST(opcode uint8) bool {
+	return opcode&0xC7 == 0xC7
+}
+
+// isReturn reports whether opcode is a RET, RETI or conditional RET.
+func isReturn(opcode uint8) bool {
+	switch opcode {
+	case 0xC9, 0xD9, 0xC0, 0xC8, 0xD0, 0xD8:
+		return true
+	}
+	return false
+}
+
+func setRegister(regs *debug.CPUState, name string, value uint16) bool {
+	switch strings.ToUpper(name) {
+	case "A":
+		regs.A = uint8(value)
+	case "F":
+		regs.F = uint8(value)
+	case "B":
+		regs.B = uint8(value)
+	case "C":
+		regs.C = uint8(value)
+	case "D":
+		regs.D = uint8(value)
+	case "E":
+		regs.E = uint8(value)
+	case "H":
+		regs.H = uint8(value)
+	case "L":
+		regs.L = uint8(value)
+	case "AF":
+		regs.A, regs.F = uint8(value>>8), uint8(value)
+	case "BC":
+		regs.B, regs.C = uint8(value>>8), uint8(value)
+	case "DE":
+		regs.D, regs.E = uint8(value>>8), uint8(value)
+	case "HL":
+		regs.H, regs.L = uint8(value>>8), uint8(value)
+	case "SP":
+		regs.SP = value
+	case "PC":
+		regs.PC = value
+	default:
+		return false
+	}
+	return true
+}
+
+func flagString(f uint8) string {
+	flags := []byte("ZNHC")
+	for i := range flags {
+		if f&(0x80>>i) == 0 {
+			flags[i] = '-'
+		}
+	}
+	return string(flags)
+}
+
+func watchKind(access uint8) string {
+	switch access {
+	case memory.WatchRead:
+		return "rwatch"
+	case memory.WatchWrite:
+		return "watch"
+	default:
+		return "awatch"
+	}
+}
+
+func describeRange(start, end uint16) string {
+	if start == end {
+		if name, ok := addr.RegisterName(start); ok {
+			return fmt.Sprintf("0x%04X (%s)", start, name)
+		}
+		return fmt.Sprintf("0x%04X", start)
+	}
+	return fmt.Sprintf("0x%04X..0x%04X", start, end)
+}
+
+func conditionSuffix(cond *debug.Condition) string {
+	if cond == nil {
+		return ""
+	}
+	return " if " + cond.String()
+}
@@ -0,0 +1,149 @@
+// Package console implements an interactive command line debugger, with
+// gdb-like commands to set breakpoints and watchpoints, step through code and
+// inspect registers and memory.
+//
+// The console runs on its own goroutine next to the emulation loop, and
+// controls the emulator through the concurrency-safe debugger methods of
+// *jeebie.DMG.
+package console
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/valerio/go-jeebie/jeebie"
+	"github.com/valerio/go-jeebie/jeebie/bit"
+	"github.com/valerio/go-jeebie/jeebie/debug"
+)
+
+const (
+	prompt = "(jeebie) "
+	// pollInterval is how often a continuing emulator is checked for a stop.
+	pollInterval = 5 * time.Millisecond
+)
+
+// Console reads debugger commands and prints their results.
+type Console struct {
+	dmg *jeebie.DMG
+	out io.Writer
+
+	// last is the previous command, repeated when an empty line is entered
+	last        string
+	interrupted atomic.Bool
+}
+
+// New creates a console controlling dmg, writing output to out.
+func New(dmg *jeebie.DMG, out io.Writer) *Console {
+	return &Console{dmg: dmg, out: out}
+}
+
+// Run reads commands from in until the quit command or the end of input.
+func (c *Console) Run(in io.Reader) error {
+	scanner := bufio.NewScanner(in)
+	for {
+		fmt.Fprint(c.out, prompt)
+		if !scanner.Scan() {
+			fmt.Fprintln(c.out)
+			return scanner.Err()
+		}
+		if c.Execute(scanner.Text()) {
+			return nil
+		}
+	}
+}
+
+// Execute runs a single command line, reporting whether it was quit.
+func (c *Console) Execute(line string) (quit bool) {
+	line = strings.TrimSpace(line)
+	if line == "" {
+		line = c.last
+	}
+	if line == "" {
+		return false
+	}
+	c.last = line
+
+	name, args, _ := strings.Cut(line, " ")
+	// x/16b style commands carry their format after a slash
+	name, format, _ := strings.Cut(name, "/")
+	cmd, ok := lookupCommand(name)
+	if !ok {
+		fmt.Fprintf(c.out, "Unknown command %q, try \"help\".\n", name)
+		return false
+	}
+	if cmd.name == "quit" {
+		return true
+	}
+
+	c.interrupted.Store(false)
+	if err := cmd.run(c, format, strings.TrimSpace(args)); err != nil {
+		fmt.Fprintf(c.out, "%s: %v\n", cmd.name, err)
+	}
+	return false
+}
+
+// Interrupt stops a running continue, next, finish or frame command, like
+// Ctrl-C in gdb. Without one running, it pauses the emulator.
+func (c *Console) Interrupt() {
+	c.interrupted.Store(true)
+	c.dmg.Pause()
+}
+
+// waitForStop waits until the emulator pauses, by a breakpoint, watchpoint or
+// interrupt.
+func (c *Console) waitForStop() {
+	ticker := time.NewTicker(pollInterval)
+	defer ticker.Stop()
+	for range ticker.C {
+		if c.dmg.Paused() {
+			return
+		}
+	}
+}
+
+// stepUntil single-steps until done returns true, stopping early at
+// breakpoints, watchpoints and interrupts. done gets the registers after each
+// instruction and its opcode. stepUntil reports whether done was reached.
+func (c *Console) stepUntil(done func(after debug.CPUState, opcode uint8) bool) bool {
+	for {
+		opcode := c.dmg.ReadMemory(c.dmg.ReadRegisters().PC, 1)[0]
+		c.dmg.StepInstruction()
+		if done(c.dmg.ReadRegisters(), opcode) {
+			return true
+		}
+		if c.dmg.BreakReason() != nil || c.interrupted.Load() {
+			return false
+		}
+	}
+}
+
+// printStop prints why execution stopped and the next instruction.
+func (c *Console) printStop() {
+	if reason := c.dmg.BreakReason(); reason != nil {
+		fmt.Fprintf(c.out, "Stopped at %s.\n", reason)
+	} else if c.interrupted.Load() {
+		fmt.Fprintln(c.out, "Interrupted.")
+	}
+	c.printLocation()
+}
+
+func (c *Console) printLocation() {
+	pc := c.dmg.ReadRegisters().PC
+	line := c.dmg.Disassemble(pc, 1)[0]
+	fmt.Fprintf(c.out, "=> 0x%04X:  %s\n", line.Address, c.symbolize(line))
+}
+
+// memoryReader evaluates expressions against the emulator's memory.
+type memoryReader struct{ dmg *jeebie.DMG }
+
+func (m memoryReader) Read(address uint16) uint8 {
+	return m.dmg.ReadMemory(address, 1)[0]
+}
+
+func (m memoryReader) ReadBit(index uint8, address uint16) bool {
+	return bit.IsSet(index, m.Read(address))
+}
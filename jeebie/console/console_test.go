@@ -0,0 +1,189 @@
+package console
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/valerio/go-jeebie/jeebie"
+	"github.com/valerio/go-jeebie/jeebie/internal/testutil"
+)
+
+// program calls a function storing 0x42 to 0xC000, in a loop.
+var program = map[uint16][]byte{
+	0x150: {
+		0xCD, 0x60, 0x01, // 0x150: CALL 0x160
+		0x04,       // 0x153: INC B
+		0x18, 0xFA, // 0x154: JR -6
+	},
+	0x160: {
+		0x3E, 0x42, // 0x160: LD A, 0x42
+		0xEA, 0x00, 0xC0, // 0x162: LD (0xC000), A
+		0xC9, // 0x165: RET
+	},
+}
+
+// newTestConsole returns a console for a paused emulator at the start of
+// program, with the given symbol file next to the ROM unless empty.
+func newTestConsole(t *testing.T, sym string, opts ...jeebie.Option) (*Console, *jeebie.DMG, *bytes.Buffer) {
+	t.Helper()
+
+	dir := t.TempDir()
+	path := testutil.WriteROM(t, filepath.Join(dir, "test.gb"), 0x00, 0x00, program)
+	if sym != "" {
+		require.NoError(t, os.WriteFile(filepath.Join(dir, "test.sym"), []byte(sym), 0644))
+	}
+	dmg, err := jeebie.NewWithFile(path, append(opts, jeebie.WithSaveDir(dir))...)
+	require.NoError(t, err)
+
+	dmg.Pause()
+	dmg.StepInstruction() // JP 0x150
+	out := &bytes.Buffer{}
+	return New(dmg, out), dmg, out
+}
+
+// run emulates frames in the background, as the main loop does.
+func run(t *testing.T, dmg *jeebie.DMG) {
+	stop := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for {
+			select {
+			case <-stop:
+				return
+			default:
+			}
+			require.NoError(t, dmg.RunUntilFrame())
+			time.Sleep(10 * time.Microsecond)
+		}
+	}()
+	t.Cleanup(func() {
+		close(stop)
+		<-done
+	})
+}
+
+// exec runs a command, returning its output.
+func exec(c *Console, out *bytes.Buffer, line string) string {
+	out.Reset()
+	c.Execute(line)
+	return out.String()
+}
+
+func TestBreakAndContinue(t *testing.T) {
+	c, dmg, out := newTestConsole(t, "")
+	run(t, dmg)
+
+	assert.Equal(t, "Breakpoint 1 at 0x0153.\n", exec(c, out, "break 0x153"))
+	assert.Equal(t, "Breakpoint 2 at 0x0162.\n", exec(c, out, "b $160+2 if A == 0x42"))
+	assert.Contains(t, exec(c, out, "info break"), "2   breakpoint 0x0162 (any bank) if A == 0x42")
+
+	assert.Equal(t, "Stopped at breakpoint 2 at 0x0162.\n=> 0x0162:  LD (0xC000), A\n", exec(c, out, "continue"))
+	assert.Equal(t, "Stopped at breakpoint 1 at 0x0153.\n=> 0x0153:  INC B\n", exec(c, out, "c"))
+	assert.Equal(t, uint16(0x0153), dmg.ReadRegisters().PC)
+
+	exec(c, out, "delete")
+	assert.Equal(t, "No breakpoints or watchpoints.\n", exec(c, out, "info break"))
+}
+
+func TestWatch(t *testing.T) {
+	c, dmg, out := newTestConsole(t, "")
+	run(t, dmg)
+
+	assert.Equal(t, "Watchpoint 1 on 0xC000.\n", exec(c, out, "watch 0xC000"))
+	assert.Equal(t, "Watchpoint 2 on 0xFF40 (LCDC).\n", exec(c, out, "awatch LCDC"))
+	assert.Equal(t, "Watchpoint 3 on 0xD000..0xD0FF.\n", exec(c, out, "rwatch $D000..$D0FF"))
+	assert.Equal(t, "Stopped at watchpoint 1: write 0x42 to 0xC000.\n=> 0x0165:  RET\n", exec(c, out, "continue"))
+
+	assert.Contains(t, exec(c, out, "delete 4"), "no breakpoint or watchpoint 4")
+	exec(c, out, "delete 1 2 3")
+	assert.Empty(t, dmg.Watchpoints())
+}
+
+func TestStepping(t *testing.T) {
+	c, dmg, out := newTestConsole(t, "")
+
+	assert.Equal(t, "=> 0x0153:  INC B\n", exec(c, out, "next"))
+	assert.Equal(t, uint8(0x42), dmg.ReadRegisters().A)
+
+	assert.Equal(t, "=> 0x0150:  CALL 0x0160\n", exec(c, out, "next 2"))
+	assert.Equal(t, "=> 0x0160:  LD A, 0x42\n", exec(c, out, "step"))
+	assert.Equal(t, "=> 0x0162:  LD (0xC000), A\n", exec(c, out, ""), "an empty line repeats the command")
+
+	assert.Equal(t, "#0  0x0162\n#1  0x0153\n", exec(c, out, "bt"))
+	assert.Equal(t, "=> 0x0153:  INC B\n", exec(c, out, "finish"))
+
+	// stepping stops at breakpoints
+	exec(c, out, "break 0x165")
+	assert.Equal(t, "Stopped at breakpoint 1 at 0x0165.\n=> 0x0165:  RET\n", exec(c, out, "next 3"))
+
+	exec(c, out, "delete")
+	exec(c, out, "frame")
+	assert.Equal(t, uint64(1), dmg.GetFrameCount())
+}
+
+func TestInspect(t *testing.T) {
+	c, dmg, out := newTestConsole(t, "")
+
+	exec(c, out, "set a 0x3f")
+	exec(c, out, "set hl $C000")
+	exec(c, out, "set pc 0x160")
+	regs := dmg.ReadRegisters()
+	assert.Equal(t, uint8(0x3F), regs.A)
+	assert.Equal(t, uint16(0x0160), regs.PC)
+	assert.Contains(t, exec(c, out, "info registers"), "BC 0x0013  DE 0x00D8  HL 0xC000\nSP 0xFFFE  PC 0x0160  IME off\n")
+
+	dmg.WriteMemory(0xC000, []byte{1, 2, 3, 4})
+	assert.Equal(t, "0xC000: 01 02 03 04\n", exec(c, out, "x/4b HL"))
+	assert.Equal(t, "0xC000: 0201 0403\n", exec(c, out, "x/2w $C000"))
+	exec(c, out, "set [HL+1] 0x99")
+	assert.Equal(t, []byte{0x99}, dmg.ReadMemory(0xC001, 1))
+	exec(c, out, "set [$2000] 5")
+	assert.Equal(t, []byte{5}, dmg.ReadMemory(0x2000, 1), "ROM is patched")
+	assert.Equal(t, "set: value 0x100 does not fit in a byte\n", exec(c, out, "set [$C000] $100"))
+	assert.Equal(t, "=> 0x0160:  LD A, 0x42\n   0x0162:  LD (0xC000), A\n", exec(c, out, "x/2i pc"))
+	assert.Equal(t, "   0x0150:  CALL 0x0160\n   0x0153:  INC B\n", exec(c, out, "disas 0x150 2"))
+
+	assert.Contains(t, exec(c, out, "info io"), "LCDC  FF40 = 91")
+	assert.Equal(t, "Unknown command \"frob\", try \"help\".\n", exec(c, out, "frob"))
+	assert.Equal(t, "set: unknown register \"q\"\n", exec(c, out, "set q 1"))
+	assert.True(t, strings.HasPrefix(exec(c, out, "help"), "  break"))
+	assert.True(t, c.Execute("quit"))
+}
+
+func TestSymbols(t *testing.T) {
+	c, dmg, out := newTestConsole(t, "00:0150 Main\n00:0153 Main.next\n00:0160 Store\n00:C000 wValue\n")
+	require.NotNil(t, dmg.Symbols())
+
+	assert.Equal(t, "Breakpoint 1 at 0x0162 <Store+0x2>.\n", exec(c, out, "break Store+2"))
+	assert.Equal(t, "Breakpoint 2 at 0x0153 <Main.next>.\n", exec(c, out, "break Main.next"))
+	assert.Equal(t, "Watchpoint 3 on 0xC000.\n", exec(c, out, "watch wValue"))
+	exec(c, out, "delete 2 3")
+
+	assert.Equal(t, "=> 0x0150:  Main: CALL 0x0160 <Store>\n   0x0153:  Main.next: INC B\n   0x0154:  JR 0xFA <Main>\n", exec(c, out, "disas Main 3"))
+	exec(c, out, "step")
+	exec(c, out, "step")
+	assert.Equal(t, "#0  0x0162 <Store+0x2>\n#1  0x0153 <Main.next>\n", exec(c, out, "bt"))
+}
+
+func TestReverse(t *testing.T) {
+	c, _, out := newTestConsole(t, "", jeebie.WithReverse(1<<20))
+
+	exec(c, out, "step 3")
+	assert.Equal(t, "=> 0x0162:  LD (0xC000), A\n", exec(c, out, "step-back"))
+	assert.Equal(t, "=> 0x0150:  CALL 0x0160\n", exec(c, out, "rs 2"))
+
+	exec(c, out, "watch 0xC000")
+	exec(c, out, "step 3") // stops at the watchpoint
+	assert.Equal(t, "=> 0x0160:  LD A, 0x42\n", exec(c, out, "step 4"))
+	assert.Equal(t, "Stopped at watchpoint 1: write 0x42 to 0xC000.\n=> 0x0165:  RET\n", exec(c, out, "rc"))
+	assert.Equal(t, "Reached the start of the history.\n=> 0x0100:  JP 0x0150\n", exec(c, out, "reverse-continue"))
+	assert.Equal(t, "=> 0x0100:  JP 0x0150\nstep-back: no more history to go back to\n", exec(c, out, "step-back"))
+	assert.Equal(t, "=> 0x0100:  JP 0x0150\nstep-back-frame: no more history to go back to\n", exec(c, out, "rf"))
+}
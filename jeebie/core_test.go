@@ -1,9 +1,13 @@
 package jeebie
 
 import (
+	"os"
+	"path/filepath"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/valerio/go-jeebie/jeebie/memory"
 )
 
 func TestExtractDebugData_NilComponents(t *testing.T) {
@@ -110,3 +114,29 @@ func TestExtractDebugData_SnapshotAddressCalculation(t *testing.T) {
 		})
 	}
 }
+
+func TestNewWithFile_CartridgeErrors(t *testing.T) {
+	dir := t.TempDir()
+
+	truncated := filepath.Join(dir, "truncated.gb")
+	require.NoError(t, os.WriteFile(truncated, make([]byte, 0x100), 0644))
+	_, err := NewWithFile(truncated)
+	assert.ErrorIs(t, err, memory.ErrTruncatedROM)
+
+	unsupported := writeTestROM(t, t.TempDir(), 0xFE, 0x00)
+	_, err = NewWithFile(unsupported)
+	assert.ErrorIs(t, err, memory.ErrUnsupportedMBC)
+
+	badHeader := writeTestROM(t, dir, 0x00, 0x00)
+	data, err := os.ReadFile(badHeader)
+	require.NoError(t, err)
+	data[0x14D]++
+	require.NoError(t, os.WriteFile(badHeader, data, 0644))
+
+	_, err = NewWithFile(badHeader)
+	assert.ErrorIs(t, err, memory.ErrHeaderChecksum)
+
+	dmg, err := NewWithFile(badHeader, WithIgnoreChecksum(true))
+	require.NoError(t, err)
+	assert.NotNil(t, dmg)
+}
@@ -0,0 +1,137 @@
+package cpu
+
+// maxCallDepth bounds the shadow call stack, dropping the outermost frames of
+// runaway recursion or code that never returns.
+const maxCallDepth = 128
+
+// CallKind is how a call frame was entered.
+type CallKind uint8
+
+const (
+	CallInstruction CallKind = iota + 1 // CALL and CALL cc
+	CallRST                             // RST n
+	CallInterrupt                       // interrupt dispatch
+)
+
+// CallFrame is an entry of the shadow call stack.
+type CallFrame struct {
+	Kind CallKind
+	// Caller is the address of the CALL or RST, or of the interrupted
+	// instruction.
+	Caller uint16
+	// Target is the called function or interrupt handler.
+	Target uint16
+	// Return is the return address pushed on the stack, at SP.
+	Return uint16
+	SP     uint16
+	// Bank is the ROM bank mapped at 0x4000-0x7FFF when the call was made,
+	// or -1 if the bus doesn't bank ROM.
+	Bank int
+}
+
+// bankedBus is implemented by buses with banked cartridge ROM.
+type bankedBus interface {
+	ROMBank() int
+}
+
+// callKinds maps opcodes to the call frames they enter, with 0 for opcodes
+// that aren't calls.
+var callKinds = func() (kinds [256]CallKind) {
+	for _, op := range []uint8{0xC4, 0xCC, 0xCD, 0xD4, 0xDC} {
+		kinds[op] = CallInstruction
+	}
+	for op := 0xC7; op <= 0xFF; op += 8 {
+		kinds[op] = CallRST
+	}
+	return kinds
+}()
+
+func isReturn(opcode uint8) bool {
+	switch opcode {
+	case 0xC0, 0xC8, 0xC9, 0xD0, 0xD8, 0xD9:
+		return true
+	}
+	return false
+}
+
+// trackCall updates the shadow call stack after the instruction at pc ran
+// with the stack pointer at sp. Taken calls push 2 bytes and taken returns
+// pop 2 bytes, which tells them apart from untaken conditional ones.
+func (c *CPU) trackCall(opcode uint8, pc, sp uint16) {
+	if kind := callKinds[opcode]; kind != 0 && c.sp == sp-2 {
+		ret := pc + 3
+		if kind == CallRST {
+			ret = pc + 1
+		}
+		c.enterCall(kind, pc, ret)
+	} else if isReturn(opcode) && c.sp == sp+2 {
+		c.leaveCall(sp)
+	}
+}
+
+// enterCall pushes a frame for a call from caller, which pushed ret on the
+// stack and jumped to the current PC.
+func (c *CPU) enterCall(kind CallKind, caller, ret uint16) {
+	sp := c.sp
+	// frames at or below the new one were abandoned, e.g. by reloading SP
+	for len(c.callStack) > 0 && c.callStack[len(c.callStack)-1].SP <= sp {
+		c.callStack = c.callStack[:len(c.callStack)-1]
+	}
+	if len(c.callStack) == maxCallDepth {
+		c.callStack = append(c.callStack[:0], c.callStack[1:]...)
+	}
+
+	bank := -1
+	if b, ok := c.bus.(bankedBus); ok {
+		bank = b.ROMBank()
+	}
+	c.callStack = append(c.callStack, CallFrame{
+		Kind:   kind,
+		Caller: caller,
+		Target: c.pc,
+		Return: ret,
+		SP:     sp,
+		Bank:   bank,
+	})
+}
+
+// leaveCall pops the frame returned from by a return with the stack pointer
+// at sp. Frames pushed below sp were left without a return, e.g. by popping
+// the return address, and are dropped. A return above the innermost frame
+// isn't from a tracked call, like a PUSH then RET jump, and is ignored.
+func (c *CPU) leaveCall(sp uint16) {
+	for len(c.callStack) > 0 {
+		top := c.callStack[len(c.callStack)-1]
+		if top.SP > sp {
+			return
+		}
+		c.callStack = c.callStack[:len(c.callStack)-1]
+		if top.SP == sp {
+			return
+		}
+	}
+}
+
+// CallStack returns a copy of the shadow call stack, outermost frame first.
+// It is rebuilt heuristically from calls, returns and interrupts, so code
+// manipulating the stack directly can leave stale frames until they return.
+func (c *CPU) CallStack() []CallFrame {
+	return c.AppendCallStack(nil)
+}
+
+// AppendCallStack appends the shadow call stack to dst, outermost frame
+// first, so callers can reuse a slice.
+func (c *CPU) AppendCallStack(dst []CallFrame) []CallFrame {
+	return append(dst, c.callStack...)
+}
+
+// RestoreCallStack replaces the shadow call stack with frames, outermost
+// first, e.g. to restore the one saved with a state.
+func (c *CPU) RestoreCallStack(frames []CallFrame) {
+	c.callStack = append(c.callStack[:0], frames...)
+}
+
+// ResetCallStack forgets all call frames, e.g. after restoring a state.
+func (c *CPU) ResetCallStack() {
+	c.callStack = c.callStack[:0]
+}
@@ -0,0 +1,106 @@
+package cpu
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/valerio/go-jeebie/jeebie/addr"
+	"github.com/valerio/go-jeebie/jeebie/memory"
+)
+
+// newCodeCPU returns a CPU running code copied to WRAM at 0xC000.
+func newCodeCPU(code map[uint16][]byte) (*CPU, *memory.MMU) {
+	mmu := memory.New()
+	for address, bytes := range code {
+		for i, b := range bytes {
+			mmu.Write(address+uint16(i), b)
+		}
+	}
+	cpu := New(mmu)
+	cpu.pc = 0xC000
+	cpu.sp = 0xDFFE
+	return cpu, mmu
+}
+
+func frameTargets(cpu *CPU) []uint16 {
+	var targets []uint16
+	for _, f := range cpu.CallStack() {
+		targets = append(targets, f.Target)
+	}
+	return targets
+}
+
+func TestCallStack(t *testing.T) {
+	cpu, _ := newCodeCPU(map[uint16][]byte{
+		0xC000: {0xCD, 0x10, 0xC0},       // CALL 0xC010
+		0xC010: {0xC4, 0x30, 0xC0, 0xCF}, // CALL NZ, 0xC030 (not taken); RST 0x08
+	})
+	cpu.f = uint8(zeroFlag)
+
+	cpu.Exec()
+	require.Len(t, cpu.CallStack(), 1)
+	assert.Equal(t, CallFrame{Kind: CallInstruction, Caller: 0xC000, Target: 0xC010, Return: 0xC003, SP: 0xDFFC, Bank: 1}, cpu.CallStack()[0])
+
+	cpu.Exec()
+	assert.Len(t, cpu.CallStack(), 1, "untaken calls don't enter a frame")
+
+	cpu.Exec()
+	assert.Equal(t, []uint16{0xC010, 0x0008}, frameTargets(cpu))
+	assert.Equal(t, CallRST, cpu.CallStack()[1].Kind)
+	assert.Equal(t, uint16(0xC014), cpu.CallStack()[1].Return)
+}
+
+func TestCallStackReturns(t *testing.T) {
+	cpu, mmu := newCodeCPU(map[uint16][]byte{
+		0xC000: {0xCD, 0x10, 0xC0, 0x00}, // CALL 0xC010; NOP
+		0xC010: {0xCD, 0x20, 0xC0, 0xC9}, // CALL 0xC020; RET
+		0xC020: {0x00, 0xC9},             // NOP; RET
+		0xC040: {0xD9},                   // RETI
+	})
+
+	for range 2 {
+		cpu.Exec()
+	}
+	assert.Equal(t, []uint16{0xC010, 0xC020}, frameTargets(cpu))
+
+	cpu.interruptsEnabled = true
+	mmu.Write(addr.IE, 0x01)
+	mmu.Write(addr.IF, 0x01)
+	cpu.handleInterrupts()
+	frames := cpu.CallStack()
+	require.Len(t, frames, 3)
+	assert.Equal(t, CallInterrupt, frames[2].Kind)
+	assert.Equal(t, uint16(0x0040), frames[2].Target)
+	assert.Equal(t, uint16(0xC020), frames[2].Return)
+
+	// return from the handler, then both calls
+	cpu.pc = 0xC040
+	cpu.Exec()
+	assert.Equal(t, []uint16{0xC010, 0xC020}, frameTargets(cpu))
+	cpu.Exec() // NOP
+	cpu.Exec()
+	cpu.Exec()
+	assert.Empty(t, cpu.CallStack())
+	assert.Equal(t, uint16(0xC003), cpu.pc)
+}
+
+func TestCallStackManipulation(t *testing.T) {
+	cpu, _ := newCodeCPU(map[uint16][]byte{
+		0xC000: {0xCD, 0x10, 0xC0},       // CALL 0xC010
+		0xC010: {0xE1, 0xCD, 0x20, 0xC0}, // POP HL (drop the return address); CALL 0xC020
+		0xC020: {0xE5, 0xC9},             // PUSH HL; RET (a jump, not a return)
+	})
+
+	cpu.Exec()
+	cpu.Exec()
+	cpu.Exec()
+	assert.Equal(t, []uint16{0xC020}, frameTargets(cpu), "the abandoned frame is replaced")
+
+	cpu.Exec()
+	cpu.Exec()
+	assert.Equal(t, []uint16{0xC020}, frameTargets(cpu), "returns above the innermost frame are ignored")
+
+	cpu.ResetCallStack()
+	assert.Empty(t, cpu.CallStack())
+}
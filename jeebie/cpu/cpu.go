@@ -13,6 +13,13 @@ type Bus interface {
 	Tick(cycles int)
 }
 
+// colorBus is implemented by buses with Game Boy Color hardware.
+type colorBus interface {
+	CGBMode() bool
+	// SwitchSpeed performs a pending double speed switch, returning true if it happened.
+	SwitchSpeed() bool
+}
+
 // Flag is one of the 4 possible flags used in the flag register (high part of AF)
 type Flag uint8
 
@@ -54,10 +61,30 @@ type CPU struct {
 	// advance PC). Set by HALT, cleared after the affected instruction.
 	haltBug bool
 
+	// trace is called before each instruction, nil when tracing is disabled
+	trace TraceHook
+	// fetch is called before instruction bytes are read, nil when disabled
+	fetch FetchHook
+	// profile is called after each instruction, nil when profiling is disabled
+	profile ProfileHook
+
+	// shadow call stack, see CallStack
+	callStack []CallFrame
+
 	bus Bus
 }
 
-func initializeMemory(bus Bus) {
+// TraceHook is called with the CPU state before each instruction executes,
+// after any interrupt has been dispatched to its handler.
+type TraceHook func(c *CPU)
+
+// SetTraceHook installs a hook called before every instruction, or removes it
+// when hook is nil.
+func (c *CPU) SetTraceHook(hook TraceHook) {
+	c.trace = hook
+}
+
+func initializeMemory(bus Bus, model Model) {
 	bus.Write(addr.P1, 0xCF)
 	bus.Write(addr.TIMA, 0x00)
 	bus.Write(addr.TMA, 0x00)
@@ -91,30 +118,96 @@ func initializeMemory(bus Bus) {
 	bus.Write(0xFF23, 0xBF) //    ; NR30
 	bus.Write(0xFF24, 0x77) //    ; NR50
 	bus.Write(0xFF25, 0xF3) //    ; NR51
-	bus.Write(0xFF26, 0xF1) //    ; NR52  -- should be 0xF0 on SGB
+	if model == ModelSGB {
+		bus.Write(0xFF26, 0xF0) // ; NR52
+	} else {
+		bus.Write(0xFF26, 0xF1) // ; NR52
+	}
 }
 
-// New returns an initialized CPU instance
+// New returns an initialized CPU instance, in the state left by the boot ROM
+// of the hardware matching the bus (CGB or DMG).
 func New(bus Bus) *CPU {
-	initializeMemory(bus)
+	return NewWithModel(bus, ModelAuto)
+}
+
+// NewWithModel returns a CPU in the state left by the boot ROM of model,
+// ready to run the cartridge from 0x0100.
+func NewWithModel(bus Bus, model Model) *CPU {
+	if model == ModelAuto {
+		model = ModelDMG
+		if cb, ok := bus.(colorBus); ok && cb.CGBMode() {
+			model = ModelCGB
+		}
+	}
+
+	initializeMemory(bus, model)
 
 	cpu := &CPU{
 		bus: bus,
 	}
 
-	cpu.setAF(0x01B0)
-	cpu.setBC(0x0013)
-	cpu.setDE(0x00D8)
-	cpu.setHL(0x014D)
+	regs := postBootRegisters[model]
+	cpu.setAF(regs[0])
+	cpu.setBC(regs[1])
+	cpu.setDE(regs[2])
+	cpu.setHL(regs[3])
 	cpu.sp = 0xFFFE
 	cpu.pc = 0x0100
 
 	return cpu
 }
 
+// NewAtPowerOn returns a CPU as it is at power on: all registers cleared and
+// PC at 0, where a boot ROM is expected to be mapped.
+func NewAtPowerOn(bus Bus) *CPU {
+	return &CPU{
+		bus: bus,
+	}
+}
+
+// FetchKind is the part of an instruction being read.
+type FetchKind uint8
+
+const (
+	FetchOpcode  FetchKind = iota + 1 // opcode, including the byte after a 0xCB prefix
+	FetchOperand                      // immediate operand
+)
+
+// FetchHook is called right before the CPU reads an instruction byte at
+// address, so the read can be told apart from data reads on the bus.
+type FetchHook func(address uint16, kind FetchKind)
+
+// SetFetchHook installs a hook called before every instruction byte read, or
+// removes it when hook is nil.
+func (c *CPU) SetFetchHook(hook FetchHook) {
+	c.fetch = hook
+}
+
+// ProfileHook is called after each instruction executes with the address it
+// started at and the cycles it took, including the dispatch of an interrupt
+// right before it. The call stack is still the one the instruction ran in.
+type ProfileHook func(pc uint16, cycles int)
+
+// SetProfileHook installs a hook called after every instruction, or removes
+// it when hook is nil.
+func (c *CPU) SetProfileHook(hook ProfileHook) {
+	c.profile = hook
+}
+
+// fetchByte reads an instruction byte.
+func (c *CPU) fetchByte(address uint16, kind FetchKind) uint8 {
+	if c.fetch != nil {
+		c.fetch(address, kind)
+	}
+	return c.bus.Read(address)
+}
+
 // Exec executes a single CPU instruction without ticking components.
 // Returns the amount of cycles that execution has taken.
 func (c *CPU) Exec() int {
+	start := c.cycles
+
 	// Check for interrupts - this may wake from HALT
 	interruptPending := c.handleInterrupts()
 
@@ -133,6 +226,11 @@ func (c *CPU) Exec() int {
 		}
 	}
 
+	if c.trace != nil {
+		c.trace(c)
+	}
+
+	pc, sp := c.pc, c.sp
 	instruction := Decode(c)
 
 	// Previous instruction triggered the halt bug, we have to skip the first PC increment,
@@ -147,6 +245,12 @@ func (c *CPU) Exec() int {
 
 	cycles := instruction(c)
 	c.cycles += uint64(cycles)
+	if c.profile != nil {
+		c.profile(pc, int(c.cycles-start))
+	}
+	if bit.High(c.currentOpcode) != 0xCB {
+		c.trackCall(uint8(c.currentOpcode), pc, sp)
+	}
 
 	// Clear halt bug flag IF we skipped the first PC increment this instruction.
 	if skipFirstPCInc {
@@ -191,8 +295,10 @@ func (c *CPU) handleInterrupts() bool {
 			c.bus.Write(addr.IF, bit.Clear(i, firedInterrupts))
 
 			// move PC to interrupt handler address
+			ret := c.pc
 			c.pushStack(c.pc)
 			c.pc = address
+			c.enterCall(CallInterrupt, ret, ret)
 
 			// add cycles equivalent to a JMP.
 			c.cycles += 20
@@ -210,22 +316,21 @@ func (c *CPU) handleInterrupts() bool {
 
 // peekImmediate returns the byte at the memory address pointed by the PC
 // this value is known as immediate ('n' in mnemonics), some opcodes use it as a parameter
-func (c CPU) peekImmediate() uint8 {
-	n := c.bus.Read(c.pc)
-	return n
+func (c *CPU) peekImmediate() uint8 {
+	return c.fetchByte(c.pc, FetchOperand)
 }
 
 // peekImmediateWord returns the two bytes at the memory address pointed by PC and PC+1
 // this value is known as immediate ('nn' in mnemonics), some opcodes use it as a parameter
-func (c CPU) peekImmediateWord() uint16 {
-	low := c.bus.Read(c.pc)
-	high := c.bus.Read(c.pc + 1)
+func (c *CPU) peekImmediateWord() uint16 {
+	low := c.fetchByte(c.pc, FetchOperand)
+	high := c.fetchByte(c.pc+1, FetchOperand)
 	return bit.Combine(high, low)
 }
 
 // peekSignedImmediate returns signed byte value at the memory address pointed by PC
 // this value is known as immediate ('*' in mnemonics), some opcodes use it as a parameter
-func (c CPU) peekSignedImmediate() int8 {
+func (c *CPU) peekSignedImmediate() int8 {
 	return int8(c.peekImmediate())
 }
 
@@ -236,7 +341,7 @@ func (c *CPU) readImmediate() uint8 {
 	// During the halt bug, the first operand byte re-reads the opcode byte (offset 0).
 	if c.haltBug {
 		offset := uint16(0)
-		n = c.bus.Read(c.pc + offset)
+		n = c.fetchByte(c.pc+offset, FetchOperand)
 		// Even under the halt bug, operand reads still advance PC
 		c.pc++
 	} else {
@@ -253,8 +358,8 @@ func (c *CPU) readImmediateWord() uint16 {
 
 	// During the halt bug, the first operand byte re-reads the opcode byte.
 	if c.haltBug {
-		low := c.bus.Read(c.pc + 0)
-		high := c.bus.Read(c.pc + 1)
+		low := c.fetchByte(c.pc+0, FetchOperand)
+		high := c.fetchByte(c.pc+1, FetchOperand)
 		nn = bit.Combine(high, low)
 		// Even under the halt bug, operand reads still advance PC
 		c.pc += 2
@@ -272,7 +377,7 @@ func (c *CPU) readSignedImmediate() int8 {
 
 	// During the halt bug, the first operand byte re-reads the opcode byte.
 	if c.haltBug {
-		n = int8(c.bus.Read(c.pc + 0))
+		n = int8(c.fetchByte(c.pc+0, FetchOperand))
 		// Even under the halt bug, operand reads still advance PC
 		c.pc++
 	} else {
@@ -363,6 +468,14 @@ func (c *CPU) GetSP() uint16     { return c.sp }
 func (c *CPU) GetPC() uint16     { return c.pc }
 func (c *CPU) GetCycles() uint64 { return c.cycles }
 
+// Debug setter methods for remote debuggers
+func (c *CPU) SetAF(value uint16) { c.setAF(value) }
+func (c *CPU) SetBC(value uint16) { c.setBC(value) }
+func (c *CPU) SetDE(value uint16) { c.setDE(value) }
+func (c *CPU) SetHL(value uint16) { c.setHL(value) }
+func (c *CPU) SetSP(value uint16) { c.sp = value }
+func (c *CPU) SetPC(value uint16) { c.pc = value }
+
 // Interrupt state getters
 func (c *CPU) GetIME() bool   { return c.interruptsEnabled }
 func (c *CPU) IsHalted() bool { return c.halted }
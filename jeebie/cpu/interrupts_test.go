@@ -155,3 +155,51 @@ func TestInterruptTiming(t *testing.T) {
 		assert.Equal(t, uint64(20), cpu.cycles-startCycles)
 	})
 }
+
+func TestTraceHook(t *testing.T) {
+	mmu := memory.New()
+	cpu := New(mmu)
+	cpu.pc = 0xC000 // NOPs in WRAM
+
+	var traced []uint16
+	cpu.SetTraceHook(func(c *CPU) { traced = append(traced, c.GetPC()) })
+
+	cpu.Exec()
+	cpu.interruptsEnabled = true
+	mmu.Write(addr.IE, 0x01)
+	mmu.Write(addr.IF, 0x01)
+	cpu.Exec()
+	// the dispatch isn't an instruction, the handler's first one is traced
+	assert.Equal(t, []uint16{0xC000, 0x0040}, traced)
+
+	cpu.halted = true
+	cpu.Exec()
+	assert.Len(t, traced, 2, "no instruction runs while halted")
+
+	cpu.SetTraceHook(nil)
+	cpu.halted = false
+	cpu.Exec()
+	assert.Len(t, traced, 2)
+}
+
+func TestProfileHook(t *testing.T) {
+	mmu := memory.New()
+	cpu := New(mmu)
+	cpu.pc = 0xC000 // NOPs in WRAM
+
+	type sample struct {
+		pc     uint16
+		cycles int
+	}
+	var samples []sample
+	cpu.SetProfileHook(func(pc uint16, cycles int) { samples = append(samples, sample{pc, cycles}) })
+
+	cpu.Exec()
+	cpu.interruptsEnabled = true
+	mmu.Write(addr.IE, 0x01)
+	mmu.Write(addr.IF, 0x01)
+	cpu.Exec()
+	// the dispatch is counted with the handler's first instruction, RST 38
+	// as there's no cartridge
+	assert.Equal(t, []sample{{0xC000, 4}, {0x0040, 20 + 16}}, samples)
+}
@@ -12,18 +12,17 @@ type Opcode func(*CPU) int
 // Decode retrieves the instruction identified by the value pointed at by the PC.
 // Note: PC must be incremented separately, this is so we can handle the "HALT bug".
 func Decode(c *CPU) Opcode {
-	// peek PC+1|PC
-	instr := c.peekImmediateWord()
-	high, low := bit.High(instr), bit.Low(instr)
+	opcode := c.fetchByte(c.pc, FetchOpcode)
 
 	// 0xCB is only ever used as a prefix for the next byte.
-	if low == 0xCB {
-		c.currentOpcode = bit.Combine(0xCB, high)
-		return opcodesCB[high]
+	if opcode == 0xCB {
+		cb := c.fetchByte(c.pc+1, FetchOpcode)
+		c.currentOpcode = bit.Combine(0xCB, cb)
+		return opcodesCB[cb]
 	}
 
-	c.currentOpcode = bit.Combine(0, low)
-	return opcodes[low]
+	c.currentOpcode = bit.Combine(0, opcode)
+	return opcodes[opcode]
 }
 
 var opcodes = [...]Opcode{
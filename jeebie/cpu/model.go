@@ -0,0 +1,58 @@
+package cpu
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Model is a Game Boy hardware revision. Without a boot ROM, it determines the
+// register values the CPU starts with, i.e. those the model's boot ROM leaves
+// behind. Games use them to detect the hardware they run on.
+type Model int
+
+const (
+	// ModelAuto picks ModelCGB for Game Boy Color games, ModelDMG otherwise.
+	ModelAuto Model = iota
+	ModelDMG0
+	ModelDMG
+	ModelMGB
+	ModelSGB
+	ModelCGB
+)
+
+var modelNames = map[Model]string{
+	ModelAuto: "auto",
+	ModelDMG0: "dmg0",
+	ModelDMG:  "dmg",
+	ModelMGB:  "mgb",
+	ModelSGB:  "sgb",
+	ModelCGB:  "cgb",
+}
+
+func (m Model) String() string {
+	if name, ok := modelNames[m]; ok {
+		return name
+	}
+	return fmt.Sprintf("Model(%d)", int(m))
+}
+
+// ParseModel returns the model with the given name (case insensitive), as
+// returned by Model.String.
+func ParseModel(name string) (Model, error) {
+	for model, n := range modelNames {
+		if strings.EqualFold(name, n) {
+			return model, nil
+		}
+	}
+	return ModelAuto, fmt.Errorf("unknown model: %s (available: auto, dmg0, dmg, mgb, sgb, cgb)", name)
+}
+
+// postBootRegisters are AF, BC, DE and HL as left by each model's boot ROM.
+// Reference: https://gbdev.io/pandocs/Power_Up_Sequence.html#cpu-registers
+var postBootRegisters = map[Model][4]uint16{
+	ModelDMG0: {0x0100, 0xFF13, 0x00C1, 0x8403},
+	ModelDMG:  {0x01B0, 0x0013, 0x00D8, 0x014D},
+	ModelMGB:  {0xFFB0, 0x0013, 0x00D8, 0x014D},
+	ModelSGB:  {0x0100, 0x0014, 0x0000, 0xC060},
+	ModelCGB:  {0x1180, 0x0000, 0xFF56, 0x000D},
+}
@@ -0,0 +1,50 @@
+package cpu
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/valerio/go-jeebie/jeebie/memory"
+)
+
+func TestPostBootRegisters(t *testing.T) {
+	tests := []struct {
+		model Model
+		af    uint16
+		hl    uint16
+	}{
+		{ModelDMG0, 0x0100, 0x8403},
+		{ModelDMG, 0x01B0, 0x014D},
+		{ModelMGB, 0xFFB0, 0x014D},
+		{ModelSGB, 0x0100, 0xC060},
+		{ModelCGB, 0x1180, 0x000D},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.model.String(), func(t *testing.T) {
+			cpu := NewWithModel(memory.New(), tt.model)
+			assert.Equal(t, tt.af, cpu.getAF())
+			assert.Equal(t, tt.hl, cpu.getHL())
+			assert.Equal(t, uint16(0x0100), cpu.pc)
+			assert.Equal(t, uint16(0xFFFE), cpu.sp)
+		})
+	}
+}
+
+func TestAutoModel(t *testing.T) {
+	mmu := memory.New()
+	assert.Equal(t, uint16(0x01B0), New(mmu).getAF())
+
+	mmu.SetCGBMode(true)
+	assert.Equal(t, uint16(0x1180), New(mmu).getAF())
+}
+
+func TestParseModel(t *testing.T) {
+	model, err := ParseModel("MGB")
+	require.NoError(t, err)
+	assert.Equal(t, ModelMGB, model)
+
+	_, err = ParseModel("gba")
+	assert.Error(t, err)
+}
@@ -139,6 +139,11 @@ func opcode0x0F(cpu *CPU) int {
 // STOP
 // #0x10:
 func opcode0x10(cpu *CPU) int {
+	// on CGB, STOP is also used to switch CPU speed (after arming it via KEY1)
+	if cb, ok := cpu.bus.(colorBus); ok && cb.SwitchSpeed() {
+		cpu.bus.Tick(4)
+		return 4
+	}
 	cpu.stopped = true
 	cpu.bus.Tick(4)
 	return 4
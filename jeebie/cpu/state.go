@@ -0,0 +1,30 @@
+package cpu
+
+import "github.com/valerio/go-jeebie/jeebie/state"
+
+// SerializeState saves or restores registers and execution flags. The shadow
+// call stack isn't saved, restoring a state resets it.
+func (c *CPU) SerializeState(s *state.Serializer) {
+	if s.Loading() {
+		c.ResetCallStack()
+	}
+
+	s.Uint8(&c.a)
+	s.Uint8(&c.f)
+	s.Uint8(&c.b)
+	s.Uint8(&c.c)
+	s.Uint8(&c.d)
+	s.Uint8(&c.e)
+	s.Uint8(&c.h)
+	s.Uint8(&c.l)
+	s.Uint16(&c.sp)
+	s.Uint16(&c.pc)
+
+	s.Bool(&c.interruptsEnabled)
+	s.Bool(&c.eiPending)
+	s.Uint16(&c.currentOpcode)
+	s.Bool(&c.stopped)
+	s.Uint64(&c.cycles)
+	s.Bool(&c.halted)
+	s.Bool(&c.haltBug)
+}
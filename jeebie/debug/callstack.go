@@ -0,0 +1,69 @@
+package debug
+
+import (
+	"fmt"
+
+	"github.com/valerio/go-jeebie/jeebie/symbols"
+)
+
+// CallKind is how a call frame was entered.
+type CallKind int
+
+const (
+	CallInstruction CallKind = iota // CALL and CALL cc
+	CallRST                         // RST n
+	CallInterrupt                   // interrupt dispatch
+)
+
+// CallFrame is an entry of the shadow call stack tracked by the CPU.
+type CallFrame struct {
+	Kind   CallKind
+	Caller uint16 // address of the CALL or RST, or of the interrupted instruction
+	Target uint16 // called function or interrupt handler
+	Return uint16 // return address
+	SP     uint16 // where the return address is on the stack
+	Bank   int    // ROM bank mapped at 0x4000-0x7FFF when called
+}
+
+var interruptNames = map[uint16]string{
+	0x40: "VBlank",
+	0x48: "STAT",
+	0x50: "Timer",
+	0x58: "Serial",
+	0x60: "Joypad",
+}
+
+// Backtrace formats a gdb-style backtrace, innermost frame first: frame #0
+// is the current PC and each following frame is where a call on stack (listed
+// outermost first) returns to. Addresses are labeled with syms, if not nil.
+func Backtrace(pc uint16, romBank int, stack []CallFrame, syms *symbols.Table) []string {
+	lines := make([]string, 0, len(stack)+1)
+	lines = append(lines, "#0  "+describeAddress(pc, romBank, syms))
+	for i := len(stack) - 1; i >= 0; i-- {
+		frame := stack[i]
+		line := fmt.Sprintf("#%d  %s", len(lines), describeAddress(frame.Return, frame.Bank, syms))
+		switch frame.Kind {
+		case CallRST:
+			line += fmt.Sprintf("  (RST 0x%02X)", frame.Target)
+		case CallInterrupt:
+			name, ok := interruptNames[frame.Target]
+			if !ok {
+				name = fmt.Sprintf("0x%04X", frame.Target)
+			}
+			line += fmt.Sprintf("  (%s interrupt)", name)
+		}
+		lines = append(lines, line)
+	}
+	return lines
+}
+
+// describeAddress formats address with its label, like "0x0153 <Main.next>".
+func describeAddress(address uint16, romBank int, syms *symbols.Table) string {
+	s := fmt.Sprintf("0x%04X", address)
+	if syms != nil {
+		if label := syms.Describe(address, romBank); label != "" {
+			s += " <" + label + ">"
+		}
+	}
+	return s
+}
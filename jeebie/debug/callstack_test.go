@@ -0,0 +1,34 @@
+package debug
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/valerio/go-jeebie/jeebie/symbols"
+)
+
+func TestBacktrace(t *testing.T) {
+	stack := []CallFrame{
+		{Kind: CallInstruction, Caller: 0x0150, Target: 0x4000, Return: 0x0153, SP: 0xDFFC, Bank: 2},
+		{Kind: CallRST, Caller: 0x4010, Target: 0x0008, Return: 0x4011, SP: 0xDFFA, Bank: 2},
+		{Kind: CallInterrupt, Caller: 0x0009, Target: 0x0040, Return: 0x0009, SP: 0xDFF8, Bank: 2},
+	}
+
+	assert.Equal(t, []string{
+		"#0  0x0041",
+		"#1  0x0009  (VBlank interrupt)",
+		"#2  0x4011  (RST 0x08)",
+		"#3  0x0153",
+	}, Backtrace(0x0041, 2, stack, nil))
+
+	syms, err := symbols.Parse(strings.NewReader("00:0008 Wait\n00:0040 VBlank\n00:0150 Main\n02:4000 LoadLevel\n03:4000 Other\n"))
+	require.NoError(t, err)
+	assert.Equal(t, []string{
+		"#0  0x0041 <VBlank+0x1>",
+		"#1  0x0009 <Wait+0x1>  (VBlank interrupt)",
+		"#2  0x4011 <LoadLevel+0x11>  (RST 0x08)",
+		"#3  0x0153 <Main+0x3>",
+	}, Backtrace(0x0041, 3, stack, syms), "frames use the bank mapped when called")
+}
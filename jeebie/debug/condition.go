@@ -0,0 +1,364 @@
+package debug
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// Condition is a boolean expression over CPU registers and memory, used to
+// make breakpoints and watchpoints conditional. For example:
+//
+//	A == 0x3F && [HL] != 0
+//	PC >= $4000 || !(B & 0x80)
+//
+// Operands are registers (A, F, B, C, D, E, H, L, AF, BC, DE, HL, SP, PC),
+// numbers (decimal, 0x or $ prefixed hex) and memory bytes ([address]).
+// Operators, by increasing precedence: ||, &&, comparisons (== != < <= > >=),
+// + - & |, and unary !. Any non-zero value is true.
+type Condition struct {
+	source string
+	root   condNode
+}
+
+// SymbolLookup resolves a label to its address.
+type SymbolLookup func(name string) (uint16, bool)
+
+// ParseCondition parses a condition expression.
+func ParseCondition(source string) (*Condition, error) {
+	return ParseConditionWithSymbols(source, nil)
+}
+
+// ParseConditionWithSymbols parses a condition expression that may refer to
+// labels, like "[wPlayerX] > 0x80", resolved with lookup.
+func ParseConditionWithSymbols(source string, lookup SymbolLookup) (*Condition, error) {
+	tokens, err := tokenizeCondition(source)
+	if err != nil {
+		return nil, err
+	}
+	p := &condParser{tokens: tokens, lookup: lookup}
+	root, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if p.pos < len(p.tokens) {
+		return nil, fmt.Errorf("unexpected %q in condition", p.tokens[p.pos])
+	}
+	return &Condition{source: strings.TrimSpace(source), root: root}, nil
+}
+
+// String returns the source of the condition.
+func (c *Condition) String() string {
+	return c.source
+}
+
+// Eval evaluates the condition. Memory is read through mem, which should have
+// no side effects.
+func (c *Condition) Eval(cpu *CPUState, mem MemoryReader) bool {
+	return c.root.eval(cpu, mem) != 0
+}
+
+// Value evaluates the expression as a number rather than a boolean, e.g. for
+// addresses like "HL+2".
+func (c *Condition) Value(cpu *CPUState, mem MemoryReader) int {
+	return c.root.eval(cpu, mem)
+}
+
+type condNode interface {
+	eval(cpu *CPUState, mem MemoryReader) int
+}
+
+type condNumber int
+
+func (n condNumber) eval(*CPUState, MemoryReader) int { return int(n) }
+
+type condRegister string
+
+func (r condRegister) eval(cpu *CPUState, _ MemoryReader) int {
+	value, _ := RegisterValue(cpu, string(r))
+	return int(value)
+}
+
+type condMemory struct{ address condNode }
+
+func (m condMemory) eval(cpu *CPUState, mem MemoryReader) int {
+	return int(mem.Read(uint16(m.address.eval(cpu, mem))))
+}
+
+type condNot struct{ operand condNode }
+
+func (n condNot) eval(cpu *CPUState, mem MemoryReader) int {
+	return boolInt(n.operand.eval(cpu, mem) == 0)
+}
+
+type condBinary struct {
+	op          string
+	left, right condNode
+}
+
+func (b condBinary) eval(cpu *CPUState, mem MemoryReader) int {
+	l := b.left.eval(cpu, mem)
+	// short-circuit, so conditions can guard memory reads
+	switch b.op {
+	case "&&":
+		return boolInt(l != 0 && b.right.eval(cpu, mem) != 0)
+	case "||":
+		return boolInt(l != 0 || b.right.eval(cpu, mem) != 0)
+	}
+
+	r := b.right.eval(cpu, mem)
+	switch b.op {
+	case "==":
+		return boolInt(l == r)
+	case "!=":
+		return boolInt(l != r)
+	case "<":
+		return boolInt(l < r)
+	case "<=":
+		return boolInt(l <= r)
+	case ">":
+		return boolInt(l > r)
+	case ">=":
+		return boolInt(l >= r)
+	case "+":
+		return (l + r) & 0xFFFF
+	case "-":
+		return (l - r) & 0xFFFF
+	case "&":
+		return l & r
+	case "|":
+		return l | r
+	}
+	panic("debug: unknown condition operator " + b.op)
+}
+
+func boolInt(b bool) int {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+// RegisterValue returns the value of a register by name (case insensitive):
+// A, F, B, C, D, E, H, L, AF, BC, DE, HL, SP or PC.
+func RegisterValue(cpu *CPUState, name string) (uint16, bool) {
+	switch strings.ToUpper(name) {
+	case "A":
+		return uint16(cpu.A), true
+	case "F":
+		return uint16(cpu.F), true
+	case "B":
+		return uint16(cpu.B), true
+	case "C":
+		return uint16(cpu.C), true
+	case "D":
+		return uint16(cpu.D), true
+	case "E":
+		return uint16(cpu.E), true
+	case "H":
+		return uint16(cpu.H), true
+	case "L":
+		return uint16(cpu.L), true
+	case "AF":
+		return uint16(cpu.A)<<8 | uint16(cpu.F), true
+	case "BC":
+		return uint16(cpu.B)<<8 | uint16(cpu.C), true
+	case "DE":
+		return uint16(cpu.D)<<8 | uint16(cpu.E), true
+	case "HL":
+		return uint16(cpu.H)<<8 | uint16(cpu.L), true
+	case "SP":
+		return cpu.SP, true
+	case "PC":
+		return cpu.PC, true
+	}
+	return 0, false
+}
+
+// ParseNumber parses a decimal, 0x or $ prefixed hex number.
+func ParseNumber(s string) (int, error) {
+	var v uint64
+	var err error
+	switch {
+	case strings.HasPrefix(s, "$"):
+		v, err = strconv.ParseUint(s[1:], 16, 16)
+	case strings.HasPrefix(s, "0x"), strings.HasPrefix(s, "0X"):
+		v, err = strconv.ParseUint(s[2:], 16, 16)
+	default:
+		v, err = strconv.ParseUint(s, 10, 16)
+	}
+	if err != nil {
+		return 0, fmt.Errorf("invalid number %q", s)
+	}
+	return int(v), nil
+}
+
+var conditionOperators = []string{"&&", "||", "==", "!=", "<=", ">=", "<", ">", "!", "+", "-", "&", "|", "(", ")", "[", "]"}
+
+func tokenizeCondition(source string) ([]string, error) {
+	var tokens []string
+	for i := 0; i < len(source); {
+		c := rune(source[i])
+		if unicode.IsSpace(c) {
+			i++
+			continue
+		}
+
+		if c == '$' || unicode.IsLetter(c) || unicode.IsDigit(c) {
+			start := i
+			i++
+			for i < len(source) && isIdentifierChar(rune(source[i])) {
+				i++
+			}
+			tokens = append(tokens, source[start:i])
+			continue
+		}
+
+		matched := false
+		for _, op := range conditionOperators {
+			if strings.HasPrefix(source[i:], op) {
+				tokens = append(tokens, op)
+				i += len(op)
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			return nil, fmt.Errorf("unexpected %q in condition", c)
+		}
+	}
+	if len(tokens) == 0 {
+		return nil, fmt.Errorf("empty condition")
+	}
+	return tokens, nil
+}
+
+// isIdentifierChar reports whether c can be part of a register, number or
+// label, like "Main.loop" or "wPlayer_X".
+func isIdentifierChar(c rune) bool {
+	return unicode.IsLetter(c) || unicode.IsDigit(c) || c == '_' || c == '.'
+}
+
+type condParser struct {
+	tokens []string
+	pos    int
+	lookup SymbolLookup
+}
+
+func (p *condParser) peek() string {
+	if p.pos < len(p.tokens) {
+		return p.tokens[p.pos]
+	}
+	return ""
+}
+
+func (p *condParser) accept(ops ...string) (string, bool) {
+	tok := p.peek()
+	for _, op := range ops {
+		if tok == op {
+			p.pos++
+			return op, true
+		}
+	}
+	return "", false
+}
+
+// parseBinary parses a left associative chain of operators from ops, with
+// operands parsed by next.
+func (p *condParser) parseBinary(next func() (condNode, error), ops ...string) (condNode, error) {
+	left, err := next()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		op, ok := p.accept(ops...)
+		if !ok {
+			return left, nil
+		}
+		right, err := next()
+		if err != nil {
+			return nil, err
+		}
+		left = condBinary{op: op, left: left, right: right}
+	}
+}
+
+func (p *condParser) parseOr() (condNode, error) {
+	return p.parseBinary(p.parseAnd, "||")
+}
+
+func (p *condParser) parseAnd() (condNode, error) {
+	return p.parseBinary(p.parseComparison, "&&")
+}
+
+func (p *condParser) parseComparison() (condNode, error) {
+	left, err := p.parseSum()
+	if err != nil {
+		return nil, err
+	}
+	op, ok := p.accept("==", "!=", "<=", ">=", "<", ">")
+	if !ok {
+		return left, nil
+	}
+	right, err := p.parseSum()
+	if err != nil {
+		return nil, err
+	}
+	return condBinary{op: op, left: left, right: right}, nil
+}
+
+func (p *condParser) parseSum() (condNode, error) {
+	return p.parseBinary(p.parseUnary, "+", "-", "&", "|")
+}
+
+func (p *condParser) parseUnary() (condNode, error) {
+	if _, ok := p.accept("!"); ok {
+		operand, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return condNot{operand}, nil
+	}
+	return p.parsePrimary()
+}
+
+func (p *condParser) parsePrimary() (condNode, error) {
+	tok := p.peek()
+	if tok == "" {
+		return nil, fmt.Errorf("unexpected end of condition")
+	}
+	p.pos++
+
+	switch tok {
+	case "(", "[":
+		inner, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		closing := ")"
+		if tok == "[" {
+			closing = "]"
+		}
+		if _, ok := p.accept(closing); !ok {
+			return nil, fmt.Errorf("missing %q in condition", closing)
+		}
+		if tok == "[" {
+			return condMemory{inner}, nil
+		}
+		return inner, nil
+	}
+
+	if _, ok := RegisterValue(&CPUState{}, tok); ok {
+		return condRegister(strings.ToUpper(tok)), nil
+	}
+	if n, err := ParseNumber(tok); err == nil {
+		return condNumber(n), nil
+	}
+	if p.lookup != nil {
+		if address, ok := p.lookup(tok); ok {
+			return condNumber(address), nil
+		}
+	}
+	return nil, fmt.Errorf("unexpected %q in condition", tok)
+}
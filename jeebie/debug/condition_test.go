@@ -0,0 +1,80 @@
+package debug
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/valerio/go-jeebie/jeebie/memory"
+)
+
+func TestCondition(t *testing.T) {
+	mmu := memory.New()
+	mmu.Write(0xC000, 0x12)
+	mmu.Write(0xC001, 0x80)
+	cpu := &CPUState{A: 0x3F, F: 0x80, B: 0x01, H: 0xC0, L: 0x00, SP: 0xFFFE, PC: 0x4123}
+
+	tests := []struct {
+		source string
+		want   bool
+	}{
+		{"A == 0x3F && [HL] != 0", true},
+		{"A == 0x3F && [HL] == 0", false},
+		{"a == 63", true},
+		{"HL == $C000", true},
+		{"[HL+1] & 0x80", true},
+		{"[HL + B] == 0x80", true},
+		{"!(B & 0x80)", true},
+		{"PC >= $4000 && PC <= $7FFF", true},
+		{"SP < 0xFFFE || F == 0", false},
+		{"A - 0x40 == 0xFFFF", true},
+		{"1 || 0 && 0", true},
+		{"(1 || 0) && 0", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.source, func(t *testing.T) {
+			cond, err := ParseCondition(tt.source)
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, cond.Eval(cpu, mmu))
+		})
+	}
+}
+
+func TestConditionValue(t *testing.T) {
+	cond, err := ParseCondition("HL + 2")
+	require.NoError(t, err)
+	assert.Equal(t, 0xC002, cond.Value(&CPUState{H: 0xC0}, memory.New()))
+}
+
+func TestConditionSymbols(t *testing.T) {
+	mmu := memory.New()
+	mmu.Write(0xC010, 0x90)
+	lookup := func(name string) (uint16, bool) {
+		if name == "wPlayer.x" {
+			return 0xC010, true
+		}
+		return 0, false
+	}
+
+	cond, err := ParseConditionWithSymbols("[wPlayer.x] > 0x80 && PC != wPlayer.x", lookup)
+	require.NoError(t, err)
+	assert.True(t, cond.Eval(&CPUState{}, mmu))
+
+	_, err = ParseConditionWithSymbols("[wPlayer.y] > 0x80", lookup)
+	assert.Error(t, err)
+}
+
+func TestConditionErrors(t *testing.T) {
+	for _, source := range []string{"", "A ==", "[HL", "(A == 1", "A == 1)", "X == 1", "0x10000", "A = 1", "A == 1 == 1"} {
+		_, err := ParseCondition(source)
+		assert.Error(t, err, source)
+	}
+}
+
+func TestParseNumber(t *testing.T) {
+	for source, want := range map[string]int{"42": 42, "0x2A": 42, "0X2a": 42, "$2A": 42, "$FFFF": 0xFFFF} {
+		n, err := ParseNumber(source)
+		require.NoError(t, err, source)
+		assert.Equal(t, want, n, source)
+	}
+}
@@ -1,6 +1,11 @@
 package debug
 
-import "github.com/valerio/go-jeebie/jeebie/video"
+import (
+	"fmt"
+
+	"github.com/valerio/go-jeebie/jeebie/symbols"
+	"github.com/valerio/go-jeebie/jeebie/video"
+)
 
 // CPUState contains all CPU register information for debugging
 type CPUState struct {
@@ -35,6 +40,35 @@ const (
 	DebuggerStepFrame
 )
 
+// BreakKind is what stopped execution at a breakpoint or watchpoint.
+type BreakKind int
+
+const (
+	BreakExecute BreakKind = iota // execution breakpoint
+	BreakRead                     // memory read watchpoint
+	BreakWrite                    // memory write watchpoint
+)
+
+// BreakReason describes the breakpoint or watchpoint that paused execution.
+type BreakReason struct {
+	Kind    BreakKind
+	ID      int    // breakpoint or watchpoint ID
+	Address uint16 // breakpoint address or watched address accessed
+	Value   uint8  // value read or written, for watchpoints
+	PC      uint16 // PC after the break
+}
+
+func (r BreakReason) String() string {
+	switch r.Kind {
+	case BreakRead:
+		return fmt.Sprintf("watchpoint %d: read 0x%02X from 0x%04X", r.ID, r.Value, r.Address)
+	case BreakWrite:
+		return fmt.Sprintf("watchpoint %d: write 0x%02X to 0x%04X", r.ID, r.Value, r.Address)
+	default:
+		return fmt.Sprintf("breakpoint %d at 0x%04X", r.ID, r.Address)
+	}
+}
+
 // Data contains all debug information needed by debug displays
 type Data struct {
 	OAM             *OAMData
@@ -49,4 +83,8 @@ type Data struct {
 	BackgroundVis   *BackgroundVisualizer
 	PaletteVis      *PaletteVisualizer
 	LayerBuffers    *video.RenderLayers // Pre-rendered layer framebuffers from GPU
+	BreakReason     *BreakReason        // Why execution is paused, nil if not by a breakpoint
+	Symbols         *symbols.Table      // Labels from the ROM's symbol file, nil without one
+	ROMBank         int                 // ROM bank mapped at 0x4000-0x7FFF, for symbol lookups
+	CallStack       []CallFrame         // Shadow call stack, outermost frame first
 }
@@ -2,12 +2,29 @@ package debug
 
 import (
 	"github.com/valerio/go-jeebie/jeebie/disasm"
+	"github.com/valerio/go-jeebie/jeebie/symbols"
 )
 
 type DisasmLine struct {
 	Address     uint16
 	Instruction string
 	IsCurrent   bool
+	Target      uint16 // Destination of a JP nn, JR, CALL or RST, if HasTarget
+	HasTarget   bool
+}
+
+// Symbolize returns the instruction with labels for its address and branch
+// target, see disasm.SymbolizeInstruction. syms may be nil.
+func (l DisasmLine) Symbolize(syms *symbols.Table, romBank int) string {
+	if syms == nil {
+		return l.Instruction
+	}
+	return disasm.SymbolizeInstruction(disasm.DisassemblyLine{
+		Address:     l.Address,
+		Instruction: l.Instruction,
+		Target:      l.Target,
+		HasTarget:   l.HasTarget,
+	}, syms, romBank)
 }
 
 // DisasmBuffer holds pre-allocated buffers for disassembly lines
@@ -23,6 +40,20 @@ func NewDisasmBuffer(maxLines int) *DisasmBuffer {
 	}
 }
 
+// snapshotLine disassembles the instruction at offset in snapshot, returning
+// it with its length.
+func snapshotLine(snapshot *MemorySnapshot, offset int) (DisasmLine, int) {
+	addr := snapshot.StartAddr + uint16(offset)
+	instruction, length := disasm.DisassembleBytes(snapshot.Bytes, offset)
+	target, hasTarget := disasm.BranchTarget(addr, snapshot.Bytes[offset:])
+	return DisasmLine{
+		Address:     addr,
+		Instruction: instruction,
+		Target:      target,
+		HasTarget:   hasTarget,
+	}, length
+}
+
 func CreateDisassembly(snapshot *MemorySnapshot, pc uint16, maxLines int) []DisasmLine {
 	buf := NewDisasmBuffer(maxLines)
 	return CreateDisassemblyWithBuffer(snapshot, pc, maxLines, buf)
@@ -44,13 +75,8 @@ func CreateDisassemblyWithBuffer(snapshot *MemorySnapshot, pc uint16, maxLines i
 		// Reset buffer and reuse it
 		buf.Lines = buf.Lines[:0]
 		for i := 0; i < len(snapshot.Bytes) && len(buf.Lines) < maxLines-1; {
-			addr := snapshot.StartAddr + uint16(i)
-			instruction, length := disasm.DisassembleBytes(snapshot.Bytes, i)
-			buf.Lines = append(buf.Lines, DisasmLine{
-				Address:     addr,
-				Instruction: instruction,
-				IsCurrent:   false,
-			})
+			line, length := snapshotLine(snapshot, i)
+			buf.Lines = append(buf.Lines, line)
 			i += length
 		}
 		// Add a special line indicating PC is outside snapshot
@@ -72,14 +98,10 @@ func CreateDisassemblyWithBuffer(snapshot *MemorySnapshot, pc uint16, maxLines i
 	}
 
 	for i := startOffset; i < len(snapshot.Bytes); {
-		addr := snapshot.StartAddr + uint16(i)
-		instruction, length := disasm.DisassembleBytes(snapshot.Bytes, i)
-
-		buf.AllLines = append(buf.AllLines, DisasmLine{
-			Address:     addr,
-			Instruction: instruction,
-			IsCurrent:   addr == pc,
-		})
+		line, length := snapshotLine(snapshot, i)
+		addr := line.Address
+		line.IsCurrent = addr == pc
+		buf.AllLines = append(buf.AllLines, line)
 
 		i += length
 		if addr > pc && len(buf.AllLines) > maxLines*2 {
@@ -36,21 +36,7 @@ func TakeSnapshot(frame *video.FrameBuffer, isTestPattern bool, testPatternType
 
 // SaveFramePNGToDir saves a framebuffer as PNG with timestamp to a specific directory
 func SaveFramePNGToDir(frame *video.FrameBuffer, baseName, directory string) error {
-	frameData := frame.ToSlice()
-
-	// Convert framebuffer to RGBA
-	pixels := make([]byte, video.FramebufferWidth*video.FramebufferHeight*display.RGBABytesPerPixel)
-	for i, gbPixel := range frameData {
-		idx := i * display.RGBABytesPerPixel
-		r, g, b, a := gbPixelToRGBA(gbPixel)
-		pixels[idx] = byte(r)
-		pixels[idx+1] = byte(g)
-		pixels[idx+2] = byte(b)
-		pixels[idx+3] = byte(a)
-	}
-
-	img := image.NewRGBA(image.Rect(0, 0, video.FramebufferWidth, video.FramebufferHeight))
-	copy(img.Pix, pixels)
+	img := frameToRGBA(frame)
 
 	timestamp := time.Now().Format("20060102_150405")
 	filename := fmt.Sprintf("%s_%s.png", baseName, timestamp)
@@ -82,6 +68,31 @@ func SaveFramePNGToDir(frame *video.FrameBuffer, baseName, directory string) err
 	return nil
 }
 
+// SaveFramePNG saves a framebuffer as a color PNG at the given path.
+func SaveFramePNG(frame *video.FrameBuffer, path string) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	return png.Encode(file, frameToRGBA(frame))
+}
+
+// frameToRGBA converts a framebuffer to an RGBA image.
+func frameToRGBA(frame *video.FrameBuffer) *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, video.FramebufferWidth, video.FramebufferHeight))
+	for i, gbPixel := range frame.ToSlice() {
+		idx := i * display.RGBABytesPerPixel
+		r, g, b, a := gbPixelToRGBA(gbPixel)
+		img.Pix[idx] = byte(r)
+		img.Pix[idx+1] = byte(g)
+		img.Pix[idx+2] = byte(b)
+		img.Pix[idx+3] = byte(a)
+	}
+	return img
+}
+
 // SaveFrameGrayPNG saves a framebuffer as a grayscale PNG (used in integration tests)
 func SaveFrameGrayPNG(frame *video.FrameBuffer, filepath string) error {
 	img := image.NewGray(image.Rect(0, 0, video.FramebufferWidth, video.FramebufferHeight))
@@ -130,8 +141,10 @@ func gbPixelToRGBA(gbPixel uint32) (r, g, b, a uint32) {
 	case uint32(video.BlackColor):
 		return display.GrayscaleBlack, display.GrayscaleBlack, display.GrayscaleBlack, display.FullAlpha
 	default:
-		// For any non-standard colors, extract the red channel and convert to grayscale
-		red := uint32((gbPixel >> display.RGBARShift) & display.RGBAColorMask)
-		return red, red, red, display.FullAlpha
+		// Any other color (e.g. CGB palettes) is already RGBA
+		r = (gbPixel >> display.RGBARShift) & display.RGBAColorMask
+		g = (gbPixel >> display.RGBAGShift) & display.RGBAColorMask
+		b = (gbPixel >> display.RGBABShift) & display.RGBAColorMask
+		return r, g, b, display.FullAlpha
 	}
 }
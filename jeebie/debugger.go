@@ -0,0 +1,371 @@
+package jeebie
+
+import (
+	"log/slog"
+
+	"github.com/valerio/go-jeebie/jeebie/debug"
+	"github.com/valerio/go-jeebie/jeebie/disasm"
+	"github.com/valerio/go-jeebie/jeebie/memory"
+)
+
+// The methods in this file let a debugger running on another goroutine, like
+// the GDB server, control the emulator. They take execMutex, so they wait for
+// the current frame to finish and are safe to call while RunUntilFrame runs.
+
+// Pause stops execution at the next instruction boundary, as the pause
+// action does.
+func (e *DMG) Pause() {
+	e.SetDebuggerState(DebuggerPaused)
+}
+
+// Resume continues execution after a pause or a breakpoint.
+func (e *DMG) Resume() {
+	e.SetDebuggerState(DebuggerRunning)
+}
+
+// Paused reports whether execution is stopped, by a pause or a breakpoint.
+func (e *DMG) Paused() bool {
+	e.debuggerMutex.RLock()
+	defer e.debuggerMutex.RUnlock()
+	return e.debuggerState == DebuggerPaused
+}
+
+// StepInstruction executes a single instruction. It should only be called
+// while paused. If the instruction hits a watchpoint or lands on a
+// breakpoint, BreakReason reports it.
+func (e *DMG) StepInstruction() {
+	e.execMutex.Lock()
+	defer e.execMutex.Unlock()
+
+	e.clearBreakReason()
+	e.bus.TickInstruction()
+	e.instructionCount++
+	if !e.stopOnWatchpoint() && len(e.breakpoints) > 0 {
+		e.skipBreakpoint = false
+		e.hitBreakpoint()
+	}
+	e.recordReverse()
+}
+
+// StepFrame executes instructions for a frame's worth of cycles, or until a
+// watchpoint or breakpoint is hit. It should only be called while paused.
+func (e *DMG) StepFrame() {
+	e.execMutex.Lock()
+	defer e.execMutex.Unlock()
+
+	e.clearBreakReason()
+	defer e.recordReverse()
+	if e.stepFrame() {
+		e.frameCount++
+	}
+}
+
+// stepFrame executes instructions for a frame's worth of cycles, returning
+// false if it stopped early on a watchpoint or breakpoint. The breakpoint at
+// the starting PC is not checked, as when stepping an instruction.
+func (e *DMG) stepFrame() bool {
+	for total := 0; total < 70224; {
+		total += e.bus.TickInstruction()
+		e.instructionCount++
+		if e.stopOnWatchpoint() {
+			return false
+		}
+		if len(e.breakpoints) > 0 {
+			e.skipBreakpoint = false
+			if e.hitBreakpoint() {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// Disassemble disassembles count instructions starting at address.
+func (e *DMG) Disassemble(address uint16, count int) []disasm.DisassemblyLine {
+	e.execMutex.Lock()
+	defer e.execMutex.Unlock()
+	return disasm.DisassembleRange(address, count, e.bus.MMU)
+}
+
+// ReadRegisters returns the CPU registers.
+func (e *DMG) ReadRegisters() debug.CPUState {
+	e.execMutex.Lock()
+	defer e.execMutex.Unlock()
+	return *e.CPUState()
+}
+
+// CallStack returns the calls the CPU is in, outermost first, see
+// cpu.CPU.CallStack.
+func (e *DMG) CallStack() []debug.CallFrame {
+	e.execMutex.Lock()
+	defer e.execMutex.Unlock()
+	return e.callStack()
+}
+
+// WriteRegisters sets A, F, B, C, D, E, H, L, SP and PC from regs.
+func (e *DMG) WriteRegisters(regs debug.CPUState) {
+	e.execMutex.Lock()
+	defer e.execMutex.Unlock()
+
+	c := e.bus.CPU
+	c.SetAF(uint16(regs.A)<<8 | uint16(regs.F))
+	c.SetBC(uint16(regs.B)<<8 | uint16(regs.C))
+	c.SetDE(uint16(regs.D)<<8 | uint16(regs.E))
+	c.SetHL(uint16(regs.H)<<8 | uint16(regs.L))
+	c.SetSP(regs.SP)
+	c.SetPC(regs.PC)
+	e.checkpointReverse()
+}
+
+// ReadMemory reads length bytes starting at address, wrapping around at the
+// end of the address space. Reads have no side effects and ignore PPU and DMA
+// access restrictions.
+func (e *DMG) ReadMemory(address uint16, length int) []byte {
+	e.execMutex.Lock()
+	defer e.execMutex.Unlock()
+
+	data := make([]byte, length)
+	for i := range data {
+		data[i] = e.bus.MMU.Peek(address + uint16(i))
+	}
+	return data
+}
+
+// WriteMemory writes data starting at address, wrapping around at the end of
+// the address space. Like ReadMemory, it ignores PPU and DMA access
+// restrictions, and writes to 0x0000-0x7FFF patch the mapped ROM banks rather
+// than switching banks.
+func (e *DMG) WriteMemory(address uint16, data []byte) {
+	e.execMutex.Lock()
+	defer e.execMutex.Unlock()
+
+	for i, b := range data {
+		e.bus.MMU.Poke(address+uint16(i), b)
+	}
+	e.checkpointReverse()
+}
+
+// AnyBank makes a breakpoint match whichever ROM bank is mapped.
+const AnyBank = -1
+
+// Breakpoint pauses execution before the instruction at Address runs.
+type Breakpoint struct {
+	ID      int
+	Address uint16
+	// Bank is the ROM bank the breakpoint is in, for addresses in the
+	// switchable bank at 0x4000-0x7FFF, or AnyBank. It is ignored elsewhere.
+	Bank int
+	// Condition, if set, must hold for the breakpoint to pause.
+	Condition *debug.Condition
+}
+
+// Watchpoint pauses execution after an instruction reads or writes an address
+// between Start and End, inclusive. Watching a single I/O register, like
+// addr.LCDC, breaks whenever the game touches it.
+type Watchpoint struct {
+	ID         int
+	Start, End uint16
+	// Access is memory.WatchRead, memory.WatchWrite or both.
+	Access uint8
+	// Condition, if set, must hold for the watchpoint to pause. It is
+	// evaluated when the access happens, in the middle of the instruction.
+	Condition *debug.Condition
+}
+
+// SetBreakpoint adds a breakpoint, returning its ID. The ID in bp is ignored.
+func (e *DMG) SetBreakpoint(bp Breakpoint) int {
+	e.execMutex.Lock()
+	defer e.execMutex.Unlock()
+
+	e.nextBreakID++
+	bp.ID = e.nextBreakID
+	e.breakpoints = append(e.breakpoints, bp)
+	return bp.ID
+}
+
+// SetWatchpoint adds a watchpoint, returning its ID. The ID in wp is ignored.
+func (e *DMG) SetWatchpoint(wp Watchpoint) int {
+	e.execMutex.Lock()
+	defer e.execMutex.Unlock()
+
+	e.nextBreakID++
+	wp.ID = e.nextBreakID
+	e.watchpoints = append(e.watchpoints, wp)
+	e.updateWatches()
+	return wp.ID
+}
+
+// DeleteBreakpoint removes the breakpoint or watchpoint with the given ID,
+// reporting whether it existed.
+func (e *DMG) DeleteBreakpoint(id int) bool {
+	e.execMutex.Lock()
+	defer e.execMutex.Unlock()
+
+	for i, bp := range e.breakpoints {
+		if bp.ID == id {
+			e.breakpoints = append(e.breakpoints[:i], e.breakpoints[i+1:]...)
+			return true
+		}
+	}
+	for i, wp := range e.watchpoints {
+		if wp.ID == id {
+			e.watchpoints = append(e.watchpoints[:i], e.watchpoints[i+1:]...)
+			e.updateWatches()
+			return true
+		}
+	}
+	return false
+}
+
+// Breakpoints returns the breakpoints in the order they were added.
+func (e *DMG) Breakpoints() []Breakpoint {
+	e.execMutex.Lock()
+	defer e.execMutex.Unlock()
+	return append([]Breakpoint(nil), e.breakpoints...)
+}
+
+// Watchpoints returns the watchpoints in the order they were added.
+func (e *DMG) Watchpoints() []Watchpoint {
+	e.execMutex.Lock()
+	defer e.execMutex.Unlock()
+	return append([]Watchpoint(nil), e.watchpoints...)
+}
+
+// AddBreakpoint adds an unconditional breakpoint at address in any bank,
+// unless there already is one.
+func (e *DMG) AddBreakpoint(address uint16) {
+	for _, bp := range e.Breakpoints() {
+		if bp.Address == address && bp.Bank == AnyBank && bp.Condition == nil {
+			return
+		}
+	}
+	e.SetBreakpoint(Breakpoint{Address: address, Bank: AnyBank})
+}
+
+// RemoveBreakpoint removes the breakpoints at address, if any.
+func (e *DMG) RemoveBreakpoint(address uint16) {
+	e.execMutex.Lock()
+	defer e.execMutex.Unlock()
+
+	kept := e.breakpoints[:0]
+	for _, bp := range e.breakpoints {
+		if bp.Address != address {
+			kept = append(kept, bp)
+		}
+	}
+	e.breakpoints = kept
+}
+
+// BreakReason returns the breakpoint or watchpoint that paused execution, or
+// nil if execution isn't paused by one.
+func (e *DMG) BreakReason() *debug.BreakReason {
+	e.debuggerMutex.RLock()
+	defer e.debuggerMutex.RUnlock()
+
+	if e.debuggerState != DebuggerPaused || e.breakReason == nil {
+		return nil
+	}
+	reason := *e.breakReason
+	return &reason
+}
+
+// hitBreakpoint checks for a breakpoint at PC, pausing if there is one. The
+// first instruction after a stop is never checked, so resuming from a
+// breakpoint doesn't stop at it again.
+func (e *DMG) hitBreakpoint() bool {
+	if e.skipBreakpoint {
+		e.skipBreakpoint = false
+		return false
+	}
+
+	reason, ok := e.matchBreakpoint()
+	if ok {
+		e.stopAt(reason)
+	}
+	return ok
+}
+
+// matchBreakpoint returns the breakpoint at PC whose condition holds, if any.
+func (e *DMG) matchBreakpoint() (debug.BreakReason, bool) {
+	pc := e.bus.CPU.GetPC()
+	for _, bp := range e.breakpoints {
+		if bp.Address != pc {
+			continue
+		}
+		if bp.Bank != AnyBank && pc >= 0x4000 && pc <= 0x7FFF && bp.Bank != e.bus.MMU.ROMBank() {
+			continue
+		}
+		if !e.conditionHolds(bp.Condition) {
+			continue
+		}
+		return debug.BreakReason{Kind: debug.BreakExecute, ID: bp.ID, Address: pc}, true
+	}
+	return debug.BreakReason{}, false
+}
+
+// onWatch is the MMU watch hook. The access happens in the middle of an
+// instruction, so it only records the hit, and execution stops once the
+// instruction completes, see stopOnWatchpoint.
+func (e *DMG) onWatch(address uint16, value byte, write bool) {
+	if e.pendingBreak != nil {
+		return
+	}
+	access, kind := memory.WatchRead, debug.BreakRead
+	if write {
+		access, kind = memory.WatchWrite, debug.BreakWrite
+	}
+	for _, wp := range e.watchpoints {
+		if address < wp.Start || address > wp.End || wp.Access&access == 0 {
+			continue
+		}
+		if !e.conditionHolds(wp.Condition) {
+			continue
+		}
+		e.pendingBreak = &debug.BreakReason{Kind: kind, ID: wp.ID, Address: address, Value: value}
+		return
+	}
+}
+
+// stopOnWatchpoint pauses execution if the last instruction hit a watchpoint.
+func (e *DMG) stopOnWatchpoint() bool {
+	if e.pendingBreak == nil {
+		return false
+	}
+	reason := *e.pendingBreak
+	e.pendingBreak = nil
+	e.stopAt(reason)
+	return true
+}
+
+// stopAt pauses execution, recording why.
+func (e *DMG) stopAt(reason debug.BreakReason) {
+	reason.PC = e.bus.CPU.GetPC()
+	slog.Debug("Debugger break", "reason", reason.String())
+
+	e.debuggerMutex.Lock()
+	e.debuggerState = DebuggerPaused
+	e.breakReason = &reason
+	e.debuggerMutex.Unlock()
+	e.skipBreakpoint = true
+}
+
+func (e *DMG) clearBreakReason() {
+	e.debuggerMutex.Lock()
+	e.breakReason = nil
+	e.debuggerMutex.Unlock()
+}
+
+func (e *DMG) conditionHolds(cond *debug.Condition) bool {
+	return cond == nil || cond.Eval(e.CPUState(), e.bus.MMU.DebugView())
+}
+
+// updateWatches sets the addresses watched by the MMU from the watchpoints.
+func (e *DMG) updateWatches() {
+	mmu := e.bus.MMU
+	mmu.ClearWatches()
+	for _, wp := range e.watchpoints {
+		for address := uint32(wp.Start); address <= uint32(wp.End); address++ {
+			mmu.WatchAddress(uint16(address), wp.Access|mmu.WatchFlags(uint16(address)))
+		}
+	}
+}
@@ -0,0 +1,185 @@
+package jeebie
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/valerio/go-jeebie/jeebie/addr"
+	"github.com/valerio/go-jeebie/jeebie/debug"
+	"github.com/valerio/go-jeebie/jeebie/memory"
+)
+
+func TestBreakpoints(t *testing.T) {
+	dmg := newCodeDMG(t, 0x00, []byte{
+		0x00,       // 0x150: NOP
+		0x04,       // 0x151: INC B
+		0x18, 0xFC, // 0x152: JR -4
+	})
+	dmg.AddBreakpoint(0x151)
+	dmg.AddBreakpoint(0x151)
+	require.Len(t, dmg.Breakpoints(), 1)
+	assert.Equal(t, uint16(0x151), dmg.Breakpoints()[0].Address)
+
+	require.NoError(t, dmg.RunUntilFrame())
+	assert.True(t, dmg.Paused())
+	regs := dmg.ReadRegisters()
+	assert.Equal(t, uint16(0x151), regs.PC)
+	b := regs.B
+	reason := dmg.ExtractDebugData().BreakReason
+	require.NotNil(t, reason)
+	assert.Equal(t, debug.BreakExecute, reason.Kind)
+	assert.Equal(t, uint16(0x151), reason.Address)
+
+	// paused frames don't run anything
+	require.NoError(t, dmg.RunUntilFrame())
+	assert.Equal(t, uint16(0x151), dmg.ReadRegisters().PC)
+
+	// resuming runs the instruction at the breakpoint, then stops on the next pass
+	dmg.Resume()
+	require.NoError(t, dmg.RunUntilFrame())
+	assert.True(t, dmg.Paused())
+	regs = dmg.ReadRegisters()
+	assert.Equal(t, uint16(0x151), regs.PC)
+	assert.Equal(t, b+1, regs.B)
+
+	dmg.StepInstruction()
+	assert.Equal(t, uint16(0x152), dmg.ReadRegisters().PC)
+
+	dmg.RemoveBreakpoint(0x151)
+	assert.Empty(t, dmg.Breakpoints())
+	dmg.Resume()
+	require.NoError(t, dmg.RunUntilFrame())
+	assert.False(t, dmg.Paused())
+	assert.Nil(t, dmg.BreakReason())
+}
+
+func TestStepFrameStopsAtBreakpoint(t *testing.T) {
+	dmg := newCodeDMG(t, 0x00, []byte{
+		0x00,       // 0x150: NOP
+		0x04,       // 0x151: INC B
+		0x18, 0xFC, // 0x152: JR -4
+	})
+	dmg.Pause()
+	dmg.AddBreakpoint(0x151)
+
+	dmg.StepFrame()
+	assert.Equal(t, uint16(0x151), dmg.ReadRegisters().PC)
+	assert.Equal(t, uint64(0), dmg.GetFrameCount())
+	reason := dmg.BreakReason()
+	require.NotNil(t, reason)
+	assert.Equal(t, debug.BreakExecute, reason.Kind)
+
+	// the next step leaves the breakpoint and stops on it again a loop later
+	b := dmg.ReadRegisters().B
+	dmg.StepFrame()
+	regs := dmg.ReadRegisters()
+	assert.Equal(t, uint16(0x151), regs.PC)
+	assert.Equal(t, b+1, regs.B)
+}
+
+func TestConditionalBreakpoint(t *testing.T) {
+	dmg := newCodeDMG(t, 0x00, []byte{
+		0x21, 0x00, 0xC0, // 0x150: LD HL, 0xC000
+		0x3E, 0x00, // 0x153: LD A, 0
+		0x3C,       // 0x155: INC A
+		0x77,       // 0x156: LD (HL), A
+		0x18, 0xFC, // 0x157: JR -4
+	})
+	cond, err := debug.ParseCondition("A == 0x3F && [HL] != 0")
+	require.NoError(t, err)
+	id := dmg.SetBreakpoint(Breakpoint{Address: 0x156, Bank: AnyBank, Condition: cond})
+
+	require.NoError(t, dmg.RunUntilFrame())
+	require.True(t, dmg.Paused())
+	regs := dmg.ReadRegisters()
+	assert.Equal(t, uint16(0x156), regs.PC)
+	assert.Equal(t, uint8(0x3F), regs.A)
+	assert.Equal(t, []byte{0x3E}, dmg.ReadMemory(0xC000, 1))
+	assert.Equal(t, id, dmg.BreakReason().ID)
+
+	assert.True(t, dmg.DeleteBreakpoint(id))
+	assert.False(t, dmg.DeleteBreakpoint(id))
+}
+
+func TestBreakpointBank(t *testing.T) {
+	code := []byte{0xC3, 0x00, 0x40} // JP 0x4000
+	other := newCodeDMG(t, 0x01, code)
+	other.SetBreakpoint(Breakpoint{Address: 0x4000, Bank: 3})
+	require.NoError(t, other.RunUntilFrame())
+	assert.False(t, other.Paused(), "bank 3 is not mapped")
+
+	dmg := newCodeDMG(t, 0x01, code)
+	dmg.SetBreakpoint(Breakpoint{Address: 0x4000, Bank: 1})
+	require.NoError(t, dmg.RunUntilFrame())
+	assert.True(t, dmg.Paused())
+	assert.Equal(t, uint16(0x4000), dmg.ReadRegisters().PC)
+}
+
+func TestWatchpoints(t *testing.T) {
+	dmg := newCodeDMG(t, 0x00, []byte{
+		0x3E, 0x91, // 0x150: LD A, 0x91
+		0xE0, 0x40, // 0x152: LDH (LCDC), A
+		0xFA, 0x10, 0xC0, // 0x154: LD A, (0xC010)
+		0x18, 0xFE, // 0x157: JR -2
+	})
+	lcdc := dmg.SetWatchpoint(Watchpoint{Start: addr.LCDC, End: addr.LCDC, Access: memory.WatchWrite})
+	wram := dmg.SetWatchpoint(Watchpoint{Start: 0xC000, End: 0xC0FF, Access: memory.WatchRead})
+
+	// the PPU reads LCDC all the time, but only the write by the CPU breaks
+	require.NoError(t, dmg.RunUntilFrame())
+	require.True(t, dmg.Paused())
+	assert.Equal(t, &debug.BreakReason{Kind: debug.BreakWrite, ID: lcdc, Address: addr.LCDC, Value: 0x91, PC: 0x154}, dmg.BreakReason())
+
+	dmg.Resume()
+	require.NoError(t, dmg.RunUntilFrame())
+	require.True(t, dmg.Paused())
+	assert.Equal(t, &debug.BreakReason{Kind: debug.BreakRead, ID: wram, Address: 0xC010, Value: dmg.ReadMemory(0xC010, 1)[0], PC: 0x157}, dmg.BreakReason())
+
+	assert.True(t, dmg.DeleteBreakpoint(wram))
+	assert.Len(t, dmg.Watchpoints(), 1)
+	dmg.Resume()
+	require.NoError(t, dmg.RunUntilFrame())
+	assert.False(t, dmg.Paused())
+}
+
+func TestRemoteRegistersAndMemory(t *testing.T) {
+	dmg := newCodeDMG(t, 0x00, []byte{0x18, 0xFE})
+
+	regs := dmg.ReadRegisters()
+	regs.A, regs.F = 0x12, 0xFF
+	regs.H, regs.L = 0xC0, 0x00
+	regs.PC = 0x0150
+	dmg.WriteRegisters(regs)
+
+	regs = dmg.ReadRegisters()
+	assert.Equal(t, uint8(0x12), regs.A)
+	assert.Equal(t, uint8(0xF0), regs.F, "the low bits of F are always zero")
+	assert.Equal(t, uint8(0xC0), regs.H)
+	assert.Equal(t, uint16(0x0150), regs.PC)
+
+	dmg.WriteMemory(0xC000, []byte{1, 2, 3})
+	assert.Equal(t, []byte{1, 2, 3}, dmg.ReadMemory(0xC000, 3))
+	assert.Equal(t, []byte{0x18, 0xFE}, dmg.ReadMemory(0x0150, 2))
+}
+
+func TestCallStack(t *testing.T) {
+	code := make([]byte, 0x11)
+	copy(code, []byte{
+		0xCD, 0x60, 0x01, // 0x150: CALL 0x160
+		0x18, 0xFB, // 0x153: JR -5
+	})
+	code[0x10] = 0xC9 // 0x160: RET
+	dmg := newCodeDMG(t, 0x00, code)
+
+	dmg.Pause()
+	dmg.StepInstruction() // JP 0x150
+	dmg.StepInstruction() // CALL 0x160
+	stack := dmg.CallStack()
+	require.Len(t, stack, 1)
+	assert.Equal(t, debug.CallFrame{Kind: debug.CallInstruction, Caller: 0x150, Target: 0x160, Return: 0x153, SP: 0xFFFC, Bank: 1}, stack[0])
+	assert.Equal(t, stack, dmg.ExtractDebugData().CallStack)
+
+	dmg.StepInstruction() // RET
+	assert.Empty(t, dmg.CallStack())
+}
@@ -14,6 +14,8 @@ type DisassemblyLine struct {
 	Address     uint16
 	Instruction string
 	Length      int
+	Target      uint16 // Destination of a JP nn, JR, CALL or RST, if HasTarget
+	HasTarget   bool
 }
 
 // DisassembleAt disassembles the instruction at the given program counter
@@ -70,10 +72,18 @@ func DisassembleAt(pc uint16, mmu *memory.MMU) DisassemblyLine {
 		instruction = template
 	}
 
+	code := make([]uint8, 0, 3)
+	for i := 0; i < length && int(pc)+i <= 0xFFFF; i++ {
+		code = append(code, mmu.Read(pc+uint16(i)))
+	}
+	target, hasTarget := BranchTarget(pc, code)
+
 	return DisassemblyLine{
 		Address:     pc,
 		Instruction: instruction,
 		Length:      length,
+		Target:      target,
+		HasTarget:   hasTarget,
 	}
 }
 
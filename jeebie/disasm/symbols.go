@@ -0,0 +1,66 @@
+package disasm
+
+import "fmt"
+
+// Symbolizer names code addresses, e.g. with labels from a symbol file.
+// romBank is the ROM bank mapped at 0x4000-0x7FFF.
+type Symbolizer interface {
+	Label(address uint16, romBank int) (string, bool)
+}
+
+// BranchTarget returns the address a JP nn, JR, CALL or RST instruction
+// transfers control to. code holds the instruction bytes, starting with the
+// opcode at address.
+func BranchTarget(address uint16, code []uint8) (uint16, bool) {
+	if len(code) == 0 {
+		return 0, false
+	}
+	opcode := code[0]
+	switch opcode {
+	case 0xC3, 0xC2, 0xCA, 0xD2, 0xDA, // JP
+		0xCD, 0xC4, 0xCC, 0xD4, 0xDC: // CALL
+		if len(code) < 3 {
+			return 0, false
+		}
+		return uint16(code[2])<<8 | uint16(code[1]), true
+	case 0x18, 0x20, 0x28, 0x30, 0x38: // JR
+		if len(code) < 2 {
+			return 0, false
+		}
+		return address + 2 + uint16(int8(code[1])), true
+	}
+	if opcode&0xC7 == 0xC7 { // RST
+		return uint16(opcode & 0x38), true
+	}
+	return 0, false
+}
+
+// FormatSymbolizedLine formats a disassembly line like FormatDisassemblyLine,
+// with the label at its address and the label of its branch target, e.g.
+// " 0x0150: Main: CALL 0x0160 <ReadInput>".
+func FormatSymbolizedLine(line DisassemblyLine, isCurrentPC bool, syms Symbolizer, romBank int) string {
+	prefix := " "
+	if isCurrentPC {
+		prefix = "→"
+	}
+	return fmt.Sprintf("%s0x%04X: %s", prefix, line.Address, SymbolizeInstruction(line, syms, romBank))
+}
+
+// SymbolizeInstruction returns the instruction of line prefixed with the
+// label at its address, if any, and followed by the label of its branch
+// target.
+func SymbolizeInstruction(line DisassemblyLine, syms Symbolizer, romBank int) string {
+	text := line.Instruction
+	if syms == nil {
+		return text
+	}
+	if line.HasTarget {
+		if label, ok := syms.Label(line.Target, romBank); ok {
+			text += " <" + label + ">"
+		}
+	}
+	if label, ok := syms.Label(line.Address, romBank); ok {
+		text = label + ": " + text
+	}
+	return text
+}
@@ -0,0 +1,50 @@
+package disasm
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBranchTarget(t *testing.T) {
+	tests := []struct {
+		name    string
+		address uint16
+		code    []uint8
+		target  uint16
+		ok      bool
+	}{
+		{"JP", 0x0100, []uint8{0xC3, 0x50, 0x01}, 0x0150, true},
+		{"CALL NZ", 0x0200, []uint8{0xC4, 0x00, 0x40}, 0x4000, true},
+		{"JR back", 0x0154, []uint8{0x18, 0xFA}, 0x0150, true},
+		{"JR Z forward", 0x0150, []uint8{0x28, 0x10}, 0x0162, true},
+		{"RST 38H", 0x1234, []uint8{0xFF}, 0x0038, true},
+		{"JP (HL)", 0x0150, []uint8{0xE9}, 0, false},
+		{"truncated", 0x0150, []uint8{0xCD, 0x00}, 0, false},
+		{"LD", 0x0150, []uint8{0x3E, 0x42}, 0, false},
+	}
+	for _, tt := range tests {
+		target, ok := BranchTarget(tt.address, tt.code)
+		assert.Equal(t, tt.ok, ok, tt.name)
+		assert.Equal(t, tt.target, target, tt.name)
+	}
+}
+
+type mapSymbolizer map[uint16]string
+
+func (m mapSymbolizer) Label(address uint16, _ int) (string, bool) {
+	label, ok := m[address]
+	return label, ok
+}
+
+func TestFormatSymbolizedLine(t *testing.T) {
+	syms := mapSymbolizer{0x0150: "Main", 0x0160: "ReadInput"}
+
+	call := DisassemblyLine{Address: 0x0150, Instruction: "CALL 0x0160", Length: 3, Target: 0x0160, HasTarget: true}
+	assert.Equal(t, "→0x0150: Main: CALL 0x0160 <ReadInput>", FormatSymbolizedLine(call, true, syms, 1))
+	assert.Equal(t, " 0x0150: CALL 0x0160", FormatSymbolizedLine(call, false, nil, 1))
+
+	jr := DisassemblyLine{Address: 0x0154, Instruction: "JR 0xFA", Length: 2, Target: 0x0150, HasTarget: true}
+	assert.Equal(t, "JR 0xFA <Main>", SymbolizeInstruction(jr, syms, 1))
+	assert.Equal(t, FormatDisassemblyLine(jr, false), FormatSymbolizedLine(jr, false, mapSymbolizer{}, 1))
+}
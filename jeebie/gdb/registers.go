@@ -0,0 +1,74 @@
+package gdb
+
+import (
+	"encoding/hex"
+	"fmt"
+	"strings"
+
+	"github.com/valerio/go-jeebie/jeebie/debug"
+)
+
+// numRegisters is the number of registers in the 'g' packet: AF, BC, DE, HL,
+// SP and PC.
+const numRegisters = 6
+
+func registerPairs(regs debug.CPUState) [numRegisters]uint16 {
+	return [numRegisters]uint16{
+		uint16(regs.A)<<8 | uint16(regs.F),
+		uint16(regs.B)<<8 | uint16(regs.C),
+		uint16(regs.D)<<8 | uint16(regs.E),
+		uint16(regs.H)<<8 | uint16(regs.L),
+		regs.SP,
+		regs.PC,
+	}
+}
+
+func fromRegisterPairs(pairs [numRegisters]uint16) debug.CPUState {
+	return debug.CPUState{
+		A: uint8(pairs[0] >> 8), F: uint8(pairs[0]),
+		B: uint8(pairs[1] >> 8), C: uint8(pairs[1]),
+		D: uint8(pairs[2] >> 8), E: uint8(pairs[2]),
+		H: uint8(pairs[3] >> 8), L: uint8(pairs[3]),
+		SP: pairs[4],
+		PC: pairs[5],
+	}
+}
+
+// encodeRegisters formats the registers for a 'g' reply.
+func encodeRegisters(regs debug.CPUState) string {
+	var sb strings.Builder
+	for _, word := range registerPairs(regs) {
+		sb.WriteString(encodeWord(word))
+	}
+	return sb.String()
+}
+
+// decodeRegisters parses the payload of a 'G' packet. Clients may send fewer
+// registers than the target has, the rest keep their value from current.
+func decodeRegisters(payload string, current debug.CPUState) (debug.CPUState, error) {
+	pairs := registerPairs(current)
+	if len(payload)%4 != 0 || len(payload)/4 > numRegisters {
+		return current, fmt.Errorf("invalid register data %q", payload)
+	}
+	for i := 0; i*4 < len(payload); i++ {
+		word, err := decodeWord(payload[i*4 : i*4+4])
+		if err != nil {
+			return current, err
+		}
+		pairs[i] = word
+	}
+	return fromRegisterPairs(pairs), nil
+}
+
+// encodeWord formats a 16-bit value in target byte order, little endian.
+func encodeWord(word uint16) string {
+	return hex.EncodeToString([]byte{uint8(word), uint8(word >> 8)})
+}
+
+func decodeWord(s string) (uint16, error) {
+	b, err := hex.DecodeString(s)
+	if err != nil || len(b) != 2 {
+		return 0, fmt.Errorf("invalid register value %q", s)
+	}
+	return uint16(b[0]) | uint16(b[1])<<8, nil
+}
@@ -0,0 +1,534 @@
+// Package gdb implements a GDB remote serial protocol server, so that GDB (or
+// any other RSP client) can debug code running in the emulator.
+//
+// The Game Boy CPU has no GDB architecture of its own. Registers are exposed
+// as six 16-bit little endian pairs, AF, BC, DE, HL, SP and PC, which matches
+// the first registers of GDB's z80 target and is described to the client in
+// target.xml.
+package gdb
+
+import (
+	"bufio"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/valerio/go-jeebie/jeebie/debug"
+)
+
+// Target is the emulator being debugged. Its methods are called from the
+// server's goroutine, so they must be safe for concurrent use with the
+// emulation loop; *jeebie.DMG implements it.
+type Target interface {
+	Pause()
+	Resume()
+	Paused() bool
+	StepInstruction()
+	ReadRegisters() debug.CPUState
+	WriteRegisters(regs debug.CPUState)
+	ReadMemory(address uint16, length int) []byte
+	WriteMemory(address uint16, data []byte)
+	AddBreakpoint(address uint16)
+	RemoveBreakpoint(address uint16)
+}
+
+// ReverseTarget is implemented by targets that can run backwards, for GDB's
+// reverse-stepi and reverse-continue. Both report false when they stopped at
+// the start of the recorded history.
+type ReverseTarget interface {
+	StepBack() bool
+	ReverseContinue() bool
+}
+
+const (
+	// pollInterval is how often a continuing target is checked for a stop.
+	pollInterval = 5 * time.Millisecond
+	// maxPacketSize is advertised to the client, in bytes of packet data.
+	maxPacketSize = 0x4000
+
+	sigINT  = 2
+	sigTRAP = 5
+
+	interruptByte = 0x03
+)
+
+const targetXML = `<?xml version="1.0"?>
+<!DOCTYPE target SYSTEM "gdb-target.dtd">
+<target version="1.0">
+  <architecture>z80</architecture>
+  <feature name="org.gnu.gdb.z80.cpu">
+    <reg name="af" bitsize="16" type="int"/>
+    <reg name="bc" bitsize="16" type="int"/>
+    <reg name="de" bitsize="16" type="int"/>
+    <reg name="hl" bitsize="16" type="int"/>
+    <reg name="sp" bitsize="16" type="data_ptr"/>
+    <reg name="pc" bitsize="16" type="code_ptr"/>
+  </feature>
+</target>
+`
+
+// Server accepts GDB connections on a TCP address, one client at a time.
+type Server struct {
+	target   Target
+	listener net.Listener
+
+	mu     sync.Mutex
+	conn   net.Conn
+	closed bool
+}
+
+// Listen starts listening on address, e.g. "localhost:2345". Call Serve to
+// accept clients.
+func Listen(address string, target Target) (*Server, error) {
+	l, err := net.Listen("tcp", address)
+	if err != nil {
+		return nil, err
+	}
+	return &Server{target: target, listener: l}, nil
+}
+
+// Addr returns the address the server is listening on.
+func (s *Server) Addr() net.Addr {
+	return s.listener.Addr()
+}
+
+// Serve accepts and handles clients until Close is called.
+func (s *Server) Serve() error {
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			s.mu.Lock()
+			closed := s.closed
+			s.mu.Unlock()
+			if closed {
+				return nil
+			}
+			return err
+		}
+
+		s.mu.Lock()
+		s.conn = conn
+		s.mu.Unlock()
+
+		slog.Info("GDB client connected", "addr", conn.RemoteAddr())
+		newSession(conn, s.target).run()
+		conn.Close()
+		slog.Info("GDB client disconnected", "addr", conn.RemoteAddr())
+
+		s.mu.Lock()
+		s.conn = nil
+		s.mu.Unlock()
+	}
+}
+
+// Close stops the server and disconnects the current client, if any.
+func (s *Server) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.closed = true
+	if s.conn != nil {
+		s.conn.Close()
+	}
+	return s.listener.Close()
+}
+
+// event is something received from the client: a packet or an interrupt.
+type event struct {
+	packet    string
+	interrupt bool
+}
+
+// session is a single client connection.
+type session struct {
+	conn   io.ReadWriter
+	target Target
+	events chan event
+	done   chan struct{}
+	noAck  atomic.Bool // set by the session, read by readEvents
+}
+
+func newSession(conn io.ReadWriter, target Target) *session {
+	return &session{conn: conn, target: target, events: make(chan event), done: make(chan struct{})}
+}
+
+func (s *session) run() {
+	go s.readEvents()
+	defer close(s.done)
+
+	// the target is stopped while a client is attached, until it continues
+	s.target.Pause()
+	defer s.target.Resume()
+
+	for ev := range s.events {
+		if ev.interrupt {
+			// already stopped, nothing to interrupt
+			continue
+		}
+		if done := s.handle(ev.packet); done {
+			return
+		}
+	}
+}
+
+// readEvents parses the byte stream from the client into events, acking
+// packets as required. The channel is closed when the connection ends.
+func (s *session) readEvents() {
+	defer close(s.events)
+
+	r := bufio.NewReader(s.conn)
+	for {
+		b, err := r.ReadByte()
+		if err != nil {
+			return
+		}
+
+		switch b {
+		case interruptByte:
+			if !s.emit(event{interrupt: true}) {
+				return
+			}
+		case '$':
+			data, err := r.ReadString('#')
+			if err != nil {
+				return
+			}
+			data = data[:len(data)-1]
+			sum := make([]byte, 2)
+			if _, err := io.ReadFull(r, sum); err != nil {
+				return
+			}
+
+			if !s.noAck.Load() {
+				expected, err := strconv.ParseUint(string(sum), 16, 8)
+				if err != nil || uint8(expected) != checksum(data) {
+					s.conn.Write([]byte("-"))
+					continue
+				}
+				s.conn.Write([]byte("+"))
+			}
+			if !s.emit(event{packet: data}) {
+				return
+			}
+		default:
+			// acks from the client ('+', '-') and noise between packets
+		}
+	}
+}
+
+// emit passes ev to the session, returning false once the session has ended.
+func (s *session) emit(ev event) bool {
+	select {
+	case s.events <- ev:
+		return true
+	case <-s.done:
+		return false
+	}
+}
+
+// handle answers a single packet. It returns true when the session should end.
+func (s *session) handle(packet string) bool {
+	if packet == "" {
+		s.send("")
+		return false
+	}
+
+	cmd, args := packet[0], packet[1:]
+	switch cmd {
+	case '?':
+		s.sendStop(sigTRAP)
+	case 'g':
+		s.send(encodeRegisters(s.target.ReadRegisters()))
+	case 'G':
+		regs, err := decodeRegisters(args, s.target.ReadRegisters())
+		if err != nil {
+			s.sendError(err)
+			return false
+		}
+		s.target.WriteRegisters(regs)
+		s.send("OK")
+	case 'p':
+		s.readRegister(args)
+	case 'P':
+		s.writeRegister(args)
+	case 'm':
+		s.readMemory(args)
+	case 'M':
+		s.writeMemory(args)
+	case 'Z', 'z':
+		s.breakpoint(cmd == 'Z', args)
+	case 'c':
+		if err := s.setPC(args); err != nil {
+			s.sendError(err)
+			return false
+		}
+		s.resume()
+	case 's':
+		if err := s.setPC(args); err != nil {
+			s.sendError(err)
+			return false
+		}
+		s.target.StepInstruction()
+		s.sendStop(sigTRAP)
+	case 'b':
+		s.reverse(args)
+	case 'H':
+		// a single thread, any thread selection is fine
+		s.send("OK")
+	case 'T':
+		s.send("OK")
+	case 'q':
+		s.query(args)
+	case 'Q':
+		if args == "StartNoAckMode" {
+			s.send("OK")
+			s.noAck.Store(true)
+			return false
+		}
+		s.send("")
+	case 'D':
+		s.send("OK")
+		return true
+	case 'k':
+		return true
+	default:
+		// an empty reply tells the client the packet isn't supported
+		s.send("")
+	}
+	return false
+}
+
+func (s *session) query(args string) {
+	switch {
+	case strings.HasPrefix(args, "Supported"):
+		features := fmt.Sprintf("PacketSize=%x;qXfer:features:read+;QStartNoAckMode+", maxPacketSize)
+		if _, ok := s.target.(ReverseTarget); ok {
+			features += ";ReverseStep+;ReverseContinue+"
+		}
+		s.send(features)
+	case args == "Attached":
+		s.send("1")
+	case args == "C":
+		s.send("QC1")
+	case args == "fThreadInfo":
+		s.send("m1")
+	case args == "sThreadInfo":
+		s.send("l")
+	case strings.HasPrefix(args, "Xfer:features:read:target.xml:"):
+		s.sendXfer(targetXML, strings.TrimPrefix(args, "Xfer:features:read:target.xml:"))
+	default:
+		s.send("")
+	}
+}
+
+// sendXfer answers a qXfer read of content, with args "offset,length".
+func (s *session) sendXfer(content, args string) {
+	offset, length, err := parseRange(args)
+	if err != nil {
+		s.sendError(err)
+		return
+	}
+	if offset >= len(content) {
+		s.send("l")
+		return
+	}
+	end := min(offset+length, len(content))
+	prefix := "m"
+	if end == len(content) {
+		prefix = "l"
+	}
+	s.send(prefix + content[offset:end])
+}
+
+// resume continues the target until it stops on its own, e.g. on a
+// breakpoint or a pause from the UI, or the client interrupts it.
+func (s *session) resume() {
+	s.target.Resume()
+
+	ticker := time.NewTicker(pollInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case ev, ok := <-s.events:
+			if !ok {
+				return
+			}
+			if ev.interrupt {
+				s.target.Pause()
+				s.sendStop(sigINT)
+				return
+			}
+			// clients shouldn't send packets while the target runs
+			slog.Debug("GDB packet ignored while running", "packet", ev.packet)
+		case <-ticker.C:
+			if s.target.Paused() {
+				s.sendStop(sigTRAP)
+				return
+			}
+		}
+	}
+}
+
+// setPC handles the optional resume address of 'c' and 's'.
+func (s *session) setPC(args string) error {
+	if args == "" {
+		return nil
+	}
+	pc, err := strconv.ParseUint(args, 16, 16)
+	if err != nil {
+		return err
+	}
+	regs := s.target.ReadRegisters()
+	regs.PC = uint16(pc)
+	s.target.WriteRegisters(regs)
+	return nil
+}
+
+func (s *session) readRegister(args string) {
+	n, err := strconv.ParseUint(args, 16, 8)
+	if err != nil || n >= numRegisters {
+		s.sendError(errors.New("invalid register"))
+		return
+	}
+	pairs := registerPairs(s.target.ReadRegisters())
+	s.send(encodeWord(pairs[n]))
+}
+
+func (s *session) writeRegister(args string) {
+	reg, value, ok := strings.Cut(args, "=")
+	n, err := strconv.ParseUint(reg, 16, 8)
+	if !ok || err != nil || n >= numRegisters {
+		s.sendError(errors.New("invalid register"))
+		return
+	}
+	word, err := decodeWord(value)
+	if err != nil {
+		s.sendError(err)
+		return
+	}
+	pairs := registerPairs(s.target.ReadRegisters())
+	pairs[n] = word
+	s.target.WriteRegisters(fromRegisterPairs(pairs))
+	s.send("OK")
+}
+
+func (s *session) readMemory(args string) {
+	address, length, err := parseRange(args)
+	if err != nil {
+		s.sendError(err)
+		return
+	}
+	length = min(length, maxPacketSize/2)
+	s.send(hex.EncodeToString(s.target.ReadMemory(uint16(address), length)))
+}
+
+func (s *session) writeMemory(args string) {
+	header, payload, ok := strings.Cut(args, ":")
+	if !ok {
+		s.sendError(errors.New("missing data"))
+		return
+	}
+	address, length, err := parseRange(header)
+	if err != nil {
+		s.sendError(err)
+		return
+	}
+	data, err := hex.DecodeString(payload)
+	if err != nil || len(data) != length {
+		s.sendError(errors.New("invalid data"))
+		return
+	}
+	s.target.WriteMemory(uint16(address), data)
+	s.send("OK")
+}
+
+// breakpoint handles Z/z packets. Software and hardware breakpoints are the
+// same thing here, watchpoints aren't supported.
+func (s *session) breakpoint(insert bool, args string) {
+	fields := strings.Split(args, ",")
+	if len(fields) < 2 || (fields[0] != "0" && fields[0] != "1") {
+		s.send("")
+		return
+	}
+	address, err := strconv.ParseUint(fields[1], 16, 16)
+	if err != nil {
+		s.sendError(err)
+		return
+	}
+	if insert {
+		s.target.AddBreakpoint(uint16(address))
+	} else {
+		s.target.RemoveBreakpoint(uint16(address))
+	}
+	s.send("OK")
+}
+
+// reverse handles the bs (step back) and bc (reverse continue) packets.
+func (s *session) reverse(args string) {
+	target, ok := s.target.(ReverseTarget)
+	if !ok || (args != "s" && args != "c") {
+		s.send("")
+		return
+	}
+	var stopped bool
+	if args == "s" {
+		stopped = target.StepBack()
+	} else {
+		stopped = target.ReverseContinue()
+	}
+	if !stopped {
+		// tells the client the recorded history ran out
+		s.send(fmt.Sprintf("T%02xreplaylog:begin;", sigTRAP))
+		return
+	}
+	s.sendStop(sigTRAP)
+}
+
+func (s *session) sendStop(signal int) {
+	s.send(fmt.Sprintf("S%02x", signal))
+}
+
+func (s *session) sendError(err error) {
+	slog.Debug("GDB request failed", "error", err)
+	s.send("E01")
+}
+
+func (s *session) send(data string) {
+	packet := fmt.Sprintf("$%s#%02x", data, checksum(data))
+	if _, err := io.WriteString(s.conn, packet); err != nil {
+		slog.Debug("GDB write failed", "error", err)
+	}
+}
+
+func checksum(data string) uint8 {
+	var sum uint8
+	for i := 0; i < len(data); i++ {
+		sum += data[i]
+	}
+	return sum
+}
+
+// parseRange parses "addr,length" with both values in hex.
+func parseRange(args string) (int, int, error) {
+	a, l, ok := strings.Cut(args, ",")
+	if !ok {
+		return 0, 0, fmt.Errorf("invalid range %q", args)
+	}
+	address, err := strconv.ParseUint(a, 16, 32)
+	if err != nil {
+		return 0, 0, err
+	}
+	length, err := strconv.ParseUint(l, 16, 32)
+	if err != nil {
+		return 0, 0, err
+	}
+	return int(address), int(length), nil
+}
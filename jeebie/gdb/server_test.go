@@ -0,0 +1,275 @@
+package gdb
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/valerio/go-jeebie/jeebie/debug"
+)
+
+// fakeTarget runs "instructions" that just increment PC, stopping at
+// breakpoints, whenever it isn't paused.
+type fakeTarget struct {
+	mu          sync.Mutex
+	paused      bool
+	regs        debug.CPUState
+	memory      [0x10000]byte
+	breakpoints map[uint16]bool
+}
+
+func newFakeTarget() *fakeTarget {
+	return &fakeTarget{breakpoints: make(map[uint16]bool)}
+}
+
+func (t *fakeTarget) run(stop <-chan struct{}) {
+	for {
+		select {
+		case <-stop:
+			return
+		default:
+		}
+		t.mu.Lock()
+		if !t.paused {
+			t.regs.PC++
+			if t.breakpoints[t.regs.PC] {
+				t.paused = true
+			}
+		}
+		t.mu.Unlock()
+		time.Sleep(10 * time.Microsecond)
+	}
+}
+
+func (t *fakeTarget) Pause()           { t.mu.Lock(); t.paused = true; t.mu.Unlock() }
+func (t *fakeTarget) Resume()          { t.mu.Lock(); t.paused = false; t.mu.Unlock() }
+func (t *fakeTarget) Paused() bool     { t.mu.Lock(); defer t.mu.Unlock(); return t.paused }
+func (t *fakeTarget) StepInstruction() { t.mu.Lock(); t.regs.PC++; t.mu.Unlock() }
+func (t *fakeTarget) AddBreakpoint(a uint16) {
+	t.mu.Lock()
+	t.breakpoints[a] = true
+	t.mu.Unlock()
+}
+func (t *fakeTarget) RemoveBreakpoint(a uint16) {
+	t.mu.Lock()
+	delete(t.breakpoints, a)
+	t.mu.Unlock()
+}
+func (t *fakeTarget) ReadRegisters() debug.CPUState {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.regs
+}
+func (t *fakeTarget) WriteRegisters(regs debug.CPUState) {
+	t.mu.Lock()
+	t.regs = regs
+	t.mu.Unlock()
+}
+func (t *fakeTarget) ReadMemory(address uint16, length int) []byte {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	data := make([]byte, length)
+	for i := range data {
+		data[i] = t.memory[address+uint16(i)]
+	}
+	return data
+}
+func (t *fakeTarget) WriteMemory(address uint16, data []byte) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for i, b := range data {
+		t.memory[address+uint16(i)] = b
+	}
+}
+
+type client struct {
+	t    *testing.T
+	conn net.Conn
+	r    *bufio.Reader
+}
+
+func connect(t *testing.T, target Target) *client {
+	t.Helper()
+	server, err := Listen("127.0.0.1:0", target)
+	require.NoError(t, err)
+	go server.Serve()
+	t.Cleanup(func() { server.Close() })
+
+	conn, err := net.Dial("tcp", server.Addr().String())
+	require.NoError(t, err)
+	conn.SetDeadline(time.Now().Add(5 * time.Second))
+	return &client{t: t, conn: conn, r: bufio.NewReader(conn)}
+}
+
+// request sends a packet and returns the reply, skipping acks.
+func (c *client) request(data string) string {
+	c.t.Helper()
+	_, err := fmt.Fprintf(c.conn, "$%s#%02x", data, checksum(data))
+	require.NoError(c.t, err)
+	return c.reply()
+}
+
+func (c *client) reply() string {
+	c.t.Helper()
+	for {
+		b, err := c.r.ReadByte()
+		require.NoError(c.t, err)
+		if b != '$' {
+			continue
+		}
+		data, err := c.r.ReadString('#')
+		require.NoError(c.t, err)
+		_, err = c.r.Discard(2)
+		require.NoError(c.t, err)
+		return data[:len(data)-1]
+	}
+}
+
+func TestRegisters(t *testing.T) {
+	target := newFakeTarget()
+	target.regs = debug.CPUState{A: 0x01, F: 0xB0, B: 0x00, C: 0x13, D: 0x00, E: 0xD8, H: 0x01, L: 0x4D, SP: 0xFFFE, PC: 0x0100}
+	c := connect(t, target)
+
+	assert.Equal(t, "S05", c.request("?"))
+	assert.True(t, target.Paused(), "attaching stops the target")
+	assert.Equal(t, "b0011300d8004d01feff0001", c.request("g"))
+	assert.Equal(t, "0001", c.request("p5"))
+
+	assert.Equal(t, "OK", c.request("P5=5001"))
+	assert.Equal(t, uint16(0x0150), target.ReadRegisters().PC)
+
+	assert.Equal(t, "OK", c.request("G00ff"))
+	regs := target.ReadRegisters()
+	assert.Equal(t, uint8(0xFF), regs.A)
+	assert.Equal(t, uint8(0x00), regs.F)
+	assert.Equal(t, uint8(0x13), regs.C, "registers not sent keep their value")
+
+	assert.Equal(t, "E01", c.request("p6"))
+}
+
+func TestMemory(t *testing.T) {
+	target := newFakeTarget()
+	c := connect(t, target)
+
+	assert.Equal(t, "OK", c.request("MC000,3:0a0b0c"))
+	assert.Equal(t, []byte{0x0A, 0x0B, 0x0C}, target.ReadMemory(0xC000, 3))
+	assert.Equal(t, "000a0b0c00", c.request("mbfff,5"))
+	assert.Equal(t, "E01", c.request("MC000,2:0a"))
+}
+
+func TestStepAndContinue(t *testing.T) {
+	target := newFakeTarget()
+	stop := make(chan struct{})
+	defer close(stop)
+	go target.run(stop)
+	c := connect(t, target)
+
+	assert.Equal(t, "S05", c.request("?"))
+	require.Equal(t, "OK", c.request("P5=0002"))
+	assert.Equal(t, "S05", c.request("s"))
+	assert.Equal(t, uint16(0x0201), target.ReadRegisters().PC)
+
+	assert.Equal(t, "OK", c.request("Z0,210,1"))
+	assert.Equal(t, "S05", c.request("c"))
+	assert.Equal(t, uint16(0x0210), target.ReadRegisters().PC)
+	assert.Equal(t, "OK", c.request("z0,210,1"))
+
+	// continue without breakpoints, then interrupt
+	_, err := fmt.Fprintf(c.conn, "$c#%02x", checksum("c"))
+	require.NoError(t, err)
+	time.Sleep(20 * time.Millisecond)
+	_, err = c.conn.Write([]byte{interruptByte})
+	require.NoError(t, err)
+	assert.Equal(t, "S02", c.reply())
+	assert.True(t, target.Paused())
+	assert.Greater(t, target.ReadRegisters().PC, uint16(0x0210))
+}
+
+func TestStopFromOutside(t *testing.T) {
+	target := newFakeTarget()
+	c := connect(t, target)
+	assert.Equal(t, "S05", c.request("?"))
+
+	// e.g. the pause key in the UI while the client waits on a continue
+	go func() {
+		time.Sleep(20 * time.Millisecond)
+		target.Pause()
+	}()
+	assert.Equal(t, "S05", c.request("c"))
+}
+
+func TestQueries(t *testing.T) {
+	c := connect(t, newFakeTarget())
+
+	assert.Contains(t, c.request("qSupported:multiprocess+"), "qXfer:features:read+")
+	assert.Equal(t, "1", c.request("qAttached"))
+	assert.Equal(t, "", c.request("vMustReplyEmpty"))
+
+	xml := c.request("qXfer:features:read:target.xml:0,20")
+	assert.Equal(t, "m"+targetXML[:0x20], xml)
+	rest := c.request(fmt.Sprintf("qXfer:features:read:target.xml:20,%x", len(targetXML)))
+	assert.Equal(t, "l"+targetXML[0x20:], rest)
+
+	assert.Equal(t, "OK", c.request("QStartNoAckMode"))
+	assert.Equal(t, "1", c.request("qAttached"))
+}
+
+func TestDetachResumes(t *testing.T) {
+	target := newFakeTarget()
+	c := connect(t, target)
+	assert.Equal(t, "S05", c.request("?"))
+	assert.Equal(t, "OK", c.request("D"))
+
+	assert.Eventually(t, func() bool { return !target.Paused() }, time.Second, time.Millisecond)
+}
+
+// reverseTarget is a fakeTarget whose history starts at PC 0x0100.
+type reverseTarget struct {
+	*fakeTarget
+}
+
+func (t reverseTarget) StepBack() bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.regs.PC == 0x0100 {
+		return false
+	}
+	t.regs.PC--
+	return true
+}
+
+func (t reverseTarget) ReverseContinue() bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for t.regs.PC > 0x0100 {
+		t.regs.PC--
+		if t.breakpoints[t.regs.PC] {
+			return true
+		}
+	}
+	return false
+}
+
+func TestReverse(t *testing.T) {
+	assert.Equal(t, "", connect(t, newFakeTarget()).request("bs"), "not supported without history")
+
+	target := reverseTarget{newFakeTarget()}
+	target.regs.PC = 0x0110
+	c := connect(t, target)
+	assert.Contains(t, c.request("qSupported"), "ReverseStep+;ReverseContinue+")
+
+	assert.Equal(t, "S05", c.request("bs"))
+	assert.Equal(t, uint16(0x010F), target.ReadRegisters().PC)
+
+	assert.Equal(t, "OK", c.request("Z0,108,1"))
+	assert.Equal(t, "S05", c.request("bc"))
+	assert.Equal(t, uint16(0x0108), target.ReadRegisters().PC)
+	assert.Equal(t, "T05replaylog:begin;", c.request("bc"))
+	assert.Equal(t, uint16(0x0100), target.ReadRegisters().PC)
+	assert.Equal(t, "T05replaylog:begin;", c.request("bs"))
+}
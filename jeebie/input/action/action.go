@@ -21,8 +21,14 @@ const (
 	EmulatorPauseToggle
 	EmulatorStepFrame
 	EmulatorStepInstruction
+	EmulatorStepBackFrame
+	EmulatorStepBackInstruction
 	EmulatorTestPatternCycle
 	EmulatorQuit
+	EmulatorSaveState
+	EmulatorLoadState
+	EmulatorNextStateSlot
+	EmulatorRewind
 
 	// Audio debugging
 	AudioToggleChannel1
@@ -72,14 +78,20 @@ var actionInfoMap = map[Action]ActionInfo{
 	GBDPadRight:    {Action: GBDPadRight, Category: CategoryGameInput, Debounce: false, Description: "D-Pad Right"},
 
 	// Emulator features
-	EmulatorDebugToggle:      {Action: EmulatorDebugToggle, Category: CategoryDebug, Debounce: true, Description: "Toggle debug display"},
-	EmulatorDebugUpdate:      {Action: EmulatorDebugUpdate, Category: CategoryDebug, Debounce: false, Description: "Update debug display"},
-	EmulatorSnapshot:         {Action: EmulatorSnapshot, Category: CategoryBackend, Debounce: true, Description: "Take snapshot"},
-	EmulatorPauseToggle:      {Action: EmulatorPauseToggle, Category: CategoryEmulator, Debounce: true, Description: "Toggle pause"},
-	EmulatorStepFrame:        {Action: EmulatorStepFrame, Category: CategoryEmulator, Debounce: true, Description: "Step one frame"},
-	EmulatorStepInstruction:  {Action: EmulatorStepInstruction, Category: CategoryEmulator, Debounce: true, Description: "Step one instruction"},
-	EmulatorTestPatternCycle: {Action: EmulatorTestPatternCycle, Category: CategoryBackend, Debounce: true, Description: "Cycle test patterns"},
-	EmulatorQuit:             {Action: EmulatorQuit, Category: CategoryEmulator, Debounce: true, Description: "Quit"},
+	EmulatorDebugToggle:         {Action: EmulatorDebugToggle, Category: CategoryDebug, Debounce: true, Description: "Toggle debug display"},
+	EmulatorDebugUpdate:         {Action: EmulatorDebugUpdate, Category: CategoryDebug, Debounce: false, Description: "Update debug display"},
+	EmulatorSnapshot:            {Action: EmulatorSnapshot, Category: CategoryBackend, Debounce: true, Description: "Take snapshot"},
+	EmulatorPauseToggle:         {Action: EmulatorPauseToggle, Category: CategoryEmulator, Debounce: true, Description: "Toggle pause"},
+	EmulatorStepFrame:           {Action: EmulatorStepFrame, Category: CategoryEmulator, Debounce: true, Description: "Step one frame"},
+	EmulatorStepInstruction:     {Action: EmulatorStepInstruction, Category: CategoryEmulator, Debounce: true, Description: "Step one instruction"},
+	EmulatorStepBackFrame:       {Action: EmulatorStepBackFrame, Category: CategoryEmulator, Debounce: true, Description: "Step back one frame"},
+	EmulatorStepBackInstruction: {Action: EmulatorStepBackInstruction, Category: CategoryEmulator, Debounce: true, Description: "Step back one instruction"},
+	EmulatorTestPatternCycle:    {Action: EmulatorTestPatternCycle, Category: CategoryBackend, Debounce: true, Description: "Cycle test patterns"},
+	EmulatorQuit:                {Action: EmulatorQuit, Category: CategoryEmulator, Debounce: true, Description: "Quit"},
+	EmulatorSaveState:           {Action: EmulatorSaveState, Category: CategoryEmulator, Debounce: true, Description: "Save state to current slot"},
+	EmulatorLoadState:           {Action: EmulatorLoadState, Category: CategoryEmulator, Debounce: true, Description: "Load state from current slot"},
+	EmulatorNextStateSlot:       {Action: EmulatorNextStateSlot, Category: CategoryEmulator, Debounce: true, Description: "Select next save state slot"},
+	EmulatorRewind:              {Action: EmulatorRewind, Category: CategoryEmulator, Debounce: false, Description: "Rewind (hold)"},
 
 	// Audio debugging
 	AudioToggleChannel1: {Action: AudioToggleChannel1, Category: CategoryAudio, Debounce: true, Description: "Toggle audio channel 1"},
@@ -97,6 +109,12 @@ var actionInfoMap = map[Action]ActionInfo{
 	DebugLogLevelDecrease: {Action: DebugLogLevelDecrease, Category: CategoryDebug, Debounce: true, Description: "Log level down"},
 }
 
+// IsHeld reports whether an action stays active for as long as its key is held,
+// rather than triggering once. Backends report Hold and Release events for these.
+func IsHeld(a Action) bool {
+	return GetInfo(a).Category == CategoryGameInput || a == EmulatorRewind
+}
+
 // GetInfo returns metadata for an action
 func GetInfo(a Action) ActionInfo {
 	if info, ok := actionInfoMap[a]; ok {
@@ -30,6 +30,11 @@ var DefaultKeyMap = map[string]action.Action{
 	"f":      action.EmulatorStepFrame, // Alternative key for step frame
 	"i":      action.EmulatorStepInstruction,
 	"n":      action.EmulatorStepInstruction, // Alternative key for step instruction
+	"u":      action.EmulatorStepBackInstruction,
+	"b":      action.EmulatorStepBackFrame,
+	"F6":     action.EmulatorSaveState,
+	"F7":     action.EmulatorLoadState,
+	"F8":     action.EmulatorNextStateSlot,
 	"F9":     action.EmulatorSnapshot,
 	"F10":    action.EmulatorDebugToggle,
 	"F11":    action.EmulatorDebugUpdate,
@@ -37,6 +42,11 @@ var DefaultKeyMap = map[string]action.Action{
 	"Escape": action.EmulatorQuit,
 	"q":      action.EmulatorQuit,
 
+	// Rewind, while the key is held
+	"Backspace": action.EmulatorRewind,
+	"<":         action.EmulatorRewind,
+	",":         action.EmulatorRewind, // Alternative without shift
+
 	// Audio debug controls
 	"F1": action.AudioToggleChannel1,
 	"F2": action.AudioToggleChannel2,
@@ -26,9 +26,7 @@ func NewHandler() *Handler {
 func (h *Handler) ProcessEvent(evt backend.InputEvent) bool {
 
 	// TODO: better split of GB action vs debugger/emulator actions. GB is not debounced.
-	switch evt.Action {
-	case action.GBButtonA, action.GBButtonB, action.GBButtonStart, action.GBButtonSelect,
-		action.GBDPadUp, action.GBDPadDown, action.GBDPadLeft, action.GBDPadRight:
+	if action.IsHeld(evt.Action) {
 		return true
 	}
 
@@ -39,6 +39,13 @@ func TestHandler_Debouncing(t *testing.T) {
 			timeBetween:    10 * time.Millisecond,
 			expectDebounce: false,
 		},
+		{
+			name:           "Rewind rapid press - should not debounce",
+			action:         action.EmulatorRewind,
+			eventType:      event.Press,
+			timeBetween:    10 * time.Millisecond,
+			expectDebounce: false,
+		},
 		{
 			name:           "UI action release event - should not debounce",
 			action:         action.EmulatorDebugToggle,
@@ -122,3 +129,11 @@ func TestHandler_HoldEventType(t *testing.T) {
 		assert.True(t, handler.ProcessEvent(evt), "Hold event should always pass")
 	}
 }
+
+func TestDefaultRewindKeys(t *testing.T) {
+	for _, key := range []string{"Backspace", "<", ","} {
+		act, ok := GetDefaultMapping(key)
+		assert.True(t, ok, key)
+		assert.Equal(t, action.EmulatorRewind, act, key)
+	}
+}
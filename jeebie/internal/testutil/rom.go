@@ -0,0 +1,43 @@
+// Package testutil holds helpers shared by the emulator tests.
+package testutil
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// ROM returns a 32KB ROM image with a valid header checksum, declaring
+// cartType and ramSize (header bytes 0x147 and 0x149). code maps addresses to
+// the bytes placed there. The entry point at 0x100 jumps past the header to
+// 0x150, unless code has an entry for 0x100.
+func ROM(cartType, ramSize uint8, code map[uint16][]byte) []byte {
+	rom := make([]byte, 0x8000)
+	if _, ok := code[0x100]; !ok {
+		copy(rom[0x100:], []byte{0xC3, 0x50, 0x01}) // JP 0x150
+	}
+	for address, b := range code {
+		copy(rom[address:], b)
+	}
+	rom[0x147] = cartType
+	rom[0x149] = ramSize
+	checksum := uint8(0)
+	for _, b := range rom[0x134:0x14D] {
+		checksum = checksum - b - 1
+	}
+	rom[0x14D] = checksum
+	return rom
+}
+
+// WriteROM writes a ROM built by ROM to path, creating its directory, and
+// returns path.
+func WriteROM(t testing.TB, path string, cartType, ramSize uint8, code map[uint16][]byte) string {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, ROM(cartType, ramSize, code), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
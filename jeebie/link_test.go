@@ -0,0 +1,62 @@
+package jeebie
+
+import (
+	"bytes"
+	"net"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// linkProgram sends sb over the link cable with the given SC value, waits for
+// the transfer to complete and stores the received byte at 0xC000.
+func linkProgram(sb, sc byte) []byte {
+	return []byte{
+		0x3E, sb, // 0x150: LD A, sb
+		0xE0, 0x01, // 0x152: LDH (SB), A
+		0x3E, sc, // 0x154: LD A, sc
+		0xE0, 0x02, // 0x156: LDH (SC), A
+		0xF0, 0x02, // 0x158: LDH A, (SC)
+		0xCB, 0x7F, // 0x15A: BIT 7, A
+		0x20, 0xFA, // 0x15C: JR NZ, -6
+		0xF0, 0x01, // 0x15E: LDH A, (SB)
+		0xEA, 0x00, 0xC0, // 0x160: LD (0xC000), A
+		0x18, 0xFE, // 0x163: JR -2
+	}
+}
+
+func TestLinkCable(t *testing.T) {
+	c1, c2 := net.Pipe()
+	master := newCodeDMG(t, 0x00, linkProgram(0x42, 0x81), WithLinkCable(c1))
+	slave := newCodeDMG(t, 0x00, linkProgram(0x99, 0x80), WithLinkCable(c2))
+	defer master.Close()
+	defer slave.Close()
+
+	var wg sync.WaitGroup
+	for _, dmg := range []*DMG{master, slave} {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for range 3 {
+				require.NoError(t, dmg.RunUntilFrame())
+			}
+		}()
+	}
+	wg.Wait()
+
+	assert.Equal(t, byte(0x99), master.ReadMemory(0xC000, 1)[0])
+	assert.Equal(t, byte(0x42), slave.ReadMemory(0xC000, 1)[0])
+}
+
+func TestSerialScript(t *testing.T) {
+	var received bytes.Buffer
+	dmg := newCodeDMG(t, 0x00, linkProgram(0x42, 0x80), WithSerialScript([]byte{0x99}, &received))
+	defer dmg.Close()
+
+	require.NoError(t, dmg.RunUntilFrame())
+	assert.Equal(t, byte(0x99), dmg.ReadMemory(0xC000, 1)[0])
+	assert.Equal(t, []byte{0x42}, received.Bytes())
+	assert.Equal(t, []byte{0x42}, dmg.SerialOutput())
+}
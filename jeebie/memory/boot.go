@@ -0,0 +1,43 @@
+package memory
+
+import (
+	"errors"
+	"fmt"
+)
+
+const (
+	dmgBootROMSize = 0x100
+	cgbBootROMSize = 0x900
+)
+
+// ErrBootROMSize is returned when a boot ROM is neither a DMG/MGB/SGB (256
+// bytes) nor a CGB (2304 bytes) image.
+var ErrBootROMSize = errors.New("boot ROM must be 256 or 2304 bytes")
+
+// LoadBootROM maps a boot ROM over the cartridge, until the boot ROM disables
+// itself by writing to 0xFF50. A CGB boot ROM covers 0x0000-0x00FF and
+// 0x0200-0x08FF, leaving the cartridge header at 0x0100-0x01FF visible.
+func (m *MMU) LoadBootROM(data []byte) error {
+	if len(data) != dmgBootROMSize && len(data) != cgbBootROMSize {
+		return fmt.Errorf("%w, got %d", ErrBootROMSize, len(data))
+	}
+	m.bootROM = data
+	m.bootROMMapped = true
+	return nil
+}
+
+// BootROMMapped reports whether the boot ROM is currently mapped.
+func (m *MMU) BootROMMapped() bool {
+	return m.bootROMMapped
+}
+
+// readBootROM returns the boot ROM byte at address, if it covers it.
+func (m *MMU) readBootROM(address uint16) (byte, bool) {
+	if !m.bootROMMapped || int(address) >= len(m.bootROM) {
+		return 0, false
+	}
+	if address >= 0x100 && address < 0x200 {
+		return 0, false
+	}
+	return m.bootROM[address], true
+}
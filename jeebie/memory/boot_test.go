@@ -0,0 +1,48 @@
+package memory
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/valerio/go-jeebie/jeebie/addr"
+	"github.com/valerio/go-jeebie/jeebie/internal/testutil"
+)
+
+func TestBootROMMapping(t *testing.T) {
+	rom := testutil.ROM(0x00, 0x00, map[uint16][]byte{
+		0x0000: {0xC3},
+		0x0100: {0x00},
+		0x0200: {0x22},
+	})
+	cart, err := NewCartridgeWithData(rom)
+	require.NoError(t, err)
+	m, err := NewWithCartridge(cart)
+	require.NoError(t, err)
+
+	boot := make([]byte, cgbBootROMSize)
+	boot[0x0000] = 0x31
+	boot[0x0100] = 0xEE
+	boot[0x0200] = 0x11
+	assert.NoError(t, m.LoadBootROM(boot))
+
+	assert.True(t, m.BootROMMapped())
+	assert.Equal(t, uint8(0x31), m.Read(0x0000))
+	assert.Equal(t, uint8(0x00), m.Read(0x0100), "cartridge header stays visible")
+	assert.Equal(t, uint8(0x11), m.Read(0x0200))
+
+	m.Write(addr.BOOT, 0x00)
+	assert.True(t, m.BootROMMapped(), "writing zero does not unmap")
+
+	m.Write(addr.BOOT, 0x01)
+	assert.False(t, m.BootROMMapped())
+	assert.Equal(t, uint8(0xC3), m.Read(0x0000))
+	assert.Equal(t, uint8(0x22), m.Read(0x0200))
+	assert.Equal(t, uint8(0xFF), m.Read(addr.BOOT))
+}
+
+func TestBootROMSize(t *testing.T) {
+	m := New()
+	assert.ErrorIs(t, m.LoadBootROM(make([]byte, 0x200)), ErrBootROMSize)
+	assert.NoError(t, m.LoadBootROM(make([]byte, dmgBootROMSize)))
+}
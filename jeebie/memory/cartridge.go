@@ -1,6 +1,9 @@
 package memory
 
 import (
+	"errors"
+	"fmt"
+	"hash/crc32"
 	"log/slog"
 
 	"github.com/valerio/go-jeebie/jeebie/bit"
@@ -24,8 +27,35 @@ const (
 	versionNumberAddress    = 0x14C
 	headerChecksumAddress   = 0x14D
 	globalChecksumAddress   = 0x14E
+	headerEnd               = 0x150
+
+	// minROMSize is the smallest ROM chip, two 16KB banks.
+	minROMSize = 0x8000
+)
+
+var (
+	// ErrTruncatedROM is returned when the ROM is too short to hold a header.
+	ErrTruncatedROM = errors.New("ROM is too short to contain a cartridge header")
+	// ErrHeaderChecksum is returned when the header checksum (0x14D) does not match.
+	ErrHeaderChecksum = errors.New("invalid cartridge header checksum")
+	// ErrUnsupportedMBC is matched by UnsupportedMBCError.
+	ErrUnsupportedMBC = errors.New("unsupported cartridge type")
 )
 
+// UnsupportedMBCError is returned for cartridge types (0x147) that have no
+// memory bank controller implementation.
+type UnsupportedMBCError struct {
+	CartType uint8
+}
+
+func (e *UnsupportedMBCError) Error() string {
+	return fmt.Sprintf("%v: 0x%02X", ErrUnsupportedMBC, e.CartType)
+}
+
+func (e *UnsupportedMBCError) Is(target error) bool {
+	return target == ErrUnsupportedMBC
+}
+
 type MBCType int
 
 const (
@@ -55,6 +85,7 @@ type Cartridge struct {
 	hasRumble      bool
 	hasBattery     bool
 	ramBankCount   uint8
+	crc            uint32 // CRC-32 of the whole ROM, identifies the cartridge
 }
 
 // NewCartridge creates an empty cartridge, useful only for debugging purposes.
@@ -65,7 +96,16 @@ func NewCartridge() *Cartridge {
 }
 
 // NewCartridgeWithData initializes a new Cartridge from a slice of bytes.
-func NewCartridgeWithData(bytes []byte) *Cartridge {
+//
+// If the header checksum is wrong, the cartridge is returned along with
+// ErrHeaderChecksum, so callers can choose to run it anyway (e.g. homebrew
+// with a sloppy header). ROMs shorter than a ROM chip or than their header
+// declares are padded with 0xFF, what an unconnected bus reads.
+func NewCartridgeWithData(bytes []byte) (*Cartridge, error) {
+	if len(bytes) < headerEnd {
+		return nil, fmt.Errorf("%w: %d bytes", ErrTruncatedROM, len(bytes))
+	}
+
 	// load cartridge title and clean it up (remove null bytes and other non-printable chars)
 	titleBytes := bytes[titleAddress : titleAddress+titleLength]
 	title := cleanGameboyTitle(titleBytes)
@@ -81,14 +121,26 @@ func NewCartridgeWithData(bytes []byte) *Cartridge {
 	version := bytes[versionNumberAddress]
 
 	mbcType := getMBCType(cartType)
+	if mbcType == MBC1Type && isMBC1Multicart(bytes) {
+		// multicarts have a regular MBC1 header
+		mbcType = MBC1MultiType
+		slog.Info("Detected MBC1 multicart")
+	}
 	hasRTC := hasRealTimeClock(cartType)
 	hasRumble := hasRumble(cartType)
 	hasBattery := hasBattery(cartType)
 
 	ramBankCount := getRAMBankCount(ramSize, mbcType)
 
-	data := make([]byte, len(bytes))
+	size := paddedROMSize(len(bytes), romSize)
+	if size != len(bytes) {
+		slog.Warn("ROM size does not match a ROM chip, padding it", "size", len(bytes), "padded", size, "declared", declaredROMSize(romSize))
+	}
+	data := make([]byte, size)
 	copy(data, bytes)
+	for i := len(bytes); i < size; i++ {
+		data[i] = 0xFF
+	}
 
 	cart := &Cartridge{
 		data:           data,
@@ -106,17 +158,61 @@ func NewCartridgeWithData(bytes []byte) *Cartridge {
 		hasRumble:      hasRumble,
 		hasBattery:     hasBattery,
 		ramBankCount:   ramBankCount,
+		crc:            crc32.ChecksumIEEE(bytes),
 	}
 
-	isValid := isValidCheckSum(bytes[titleAddress:globalChecksumAddress])
-	if !isValid {
-		slog.Error("Cartridge has invalid checksum", "globalChecksum", cart.globalChecksum, "headerChecksum", cart.headerChecksum)
-		panic("Cartridge has invalid checksum.")
+	if !isValidCheckSum(bytes[titleAddress:globalChecksumAddress]) {
+		return cart, fmt.Errorf("%w (title %q, header checksum 0x%02X)", ErrHeaderChecksum, cart.title, bytes[headerChecksumAddress])
 	}
 
 	slog.Info("Cartridge loaded", "title", cart.title)
 
-	return cart
+	return cart, nil
+}
+
+// declaredROMSize returns the ROM size in bytes for the header code at 0x148,
+// or 0 for unknown codes.
+func declaredROMSize(code uint8) int {
+	switch {
+	case code <= 0x08:
+		return minROMSize << code
+	case code == 0x52:
+		return 72 * 0x4000
+	case code == 0x53:
+		return 80 * 0x4000
+	case code == 0x54:
+		return 96 * 0x4000
+	}
+	return 0
+}
+
+// paddedROMSize returns the size of a ROM image of n bytes once padded to
+// whole 16KB banks, to at least the smallest ROM chip, and to the size
+// declared by the header code at 0x148.
+func paddedROMSize(n int, code uint8) int {
+	size := max(n, minROMSize, declaredROMSize(code))
+	return (size + 0x3FFF) &^ 0x3FFF
+}
+
+// Title returns the cleaned up title from the cartridge header.
+func (c *Cartridge) Title() string {
+	return c.title
+}
+
+// IsCGB returns true if the cartridge supports Game Boy Color features.
+func (c *Cartridge) IsCGB() bool {
+	return c.isCGB
+}
+
+// ROMSize returns the size of the ROM in bytes.
+func (c *Cartridge) ROMSize() int {
+	return len(c.data)
+}
+
+// Checksum returns the CRC-32 of the whole ROM. Unlike the header checksums,
+// it reliably tells apart different dumps/revisions of a game.
+func (c *Cartridge) Checksum() uint32 {
+	return c.crc
 }
 
 func getRAMBankCount(ramSize uint8, mbcType MBCType) uint8 {
@@ -131,6 +227,8 @@ func getRAMBankCount(ramSize uint8, mbcType MBCType) uint8 {
 		return 1
 	case 0x04:
 		return 16
+	case 0x05:
+		return 8
 	}
 
 	return 4
@@ -153,6 +251,30 @@ func getMBCType(cartType uint8) MBCType {
 	return MBCUnknownType
 }
 
+// nintendoLogo is the logo in every cartridge header, checked by the boot ROM.
+var nintendoLogo = []byte{
+	0xCE, 0xED, 0x66, 0x66, 0xCC, 0x0D, 0x00, 0x0B, 0x03, 0x73, 0x00, 0x83, 0x00, 0x0C, 0x00, 0x0D,
+	0x00, 0x08, 0x11, 0x1F, 0x88, 0x89, 0x00, 0x0E, 0xDC, 0xCC, 0x6E, 0xE6, 0xDD, 0xDD, 0xD9, 0x99,
+	0xBB, 0xBB, 0x67, 0x63, 0x6E, 0x0E, 0xEC, 0xCC, 0xDD, 0xDC, 0x99, 0x9F, 0xBB, 0xB9, 0x33, 0x3E,
+}
+
+// multicartGameSize is the size of a game on an MBC1 multicart.
+const multicartGameSize = 0x40000
+
+// isMBC1Multicart reports whether rom holds several games with their own
+// header at 256KB boundaries, as MBC1 multicarts do. Plain MBC1 games of the
+// same size only have a header in the first bank.
+func isMBC1Multicart(rom []byte) bool {
+	games := 0
+	for offset := 0; offset+multicartGameSize <= len(rom); offset += multicartGameSize {
+		logo := rom[offset+logoAddress : offset+logoAddress+len(nintendoLogo)]
+		if string(logo) == string(nintendoLogo) {
+			games++
+		}
+	}
+	return games >= 2
+}
+
 func hasRumble(cartType uint8) bool {
 	switch cartType {
 	case 0x1C:
@@ -165,8 +287,7 @@ func hasRumble(cartType uint8) bool {
 
 func hasRealTimeClock(cartType uint8) bool {
 	switch cartType {
-	case 0x0F:
-	case 0x10:
+	case 0x0F, 0x10:
 		return true
 	}
 	return false
@@ -174,19 +295,7 @@ func hasRealTimeClock(cartType uint8) bool {
 
 func hasBattery(cartType uint8) bool {
 	switch cartType {
-	case 0x03:
-	case 0x06:
-	case 0x09:
-	case 0x0D:
-	case 0x0F:
-	case 0x10:
-	case 0x13:
-	case 0x17:
-	case 0x1B:
-	case 0x1E:
-	case 0x22:
-	case 0xFD:
-	case 0xFF:
+	case 0x03, 0x06, 0x09, 0x0D, 0x0F, 0x10, 0x13, 0x17, 0x1B, 0x1E, 0x22, 0xFD, 0xFF:
 		return true
 	}
 
@@ -0,0 +1,84 @@
+package memory
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/valerio/go-jeebie/jeebie/internal/testutil"
+)
+
+func newTestROM(cartType uint8) []byte {
+	return testutil.ROM(cartType, 0x00, nil)
+}
+
+func TestCartridgeLoadErrors(t *testing.T) {
+	t.Run("truncated", func(t *testing.T) {
+		cart, err := NewCartridgeWithData(make([]byte, 0x14F))
+		assert.ErrorIs(t, err, ErrTruncatedROM)
+		assert.Nil(t, cart)
+	})
+
+	t.Run("short ROMs are padded", func(t *testing.T) {
+		cart, err := NewCartridgeWithData(newTestROM(0x01)[:0x4100])
+		require.NoError(t, err, "shorter than the smallest ROM")
+		assert.Equal(t, 0x8000, cart.ROMSize())
+		assert.Equal(t, uint8(0xFF), cart.data[0x4100])
+
+		rom := newTestROM(0x01)
+		rom[romSizeAddress] = 0x02 // 128KB
+		rom[headerChecksumAddress] -= 0x02
+		cart, err = NewCartridgeWithData(rom)
+		require.NoError(t, err, "shorter than the header declares")
+		assert.Equal(t, 0x20000, cart.ROMSize())
+		assert.Equal(t, uint8(0xFF), cart.data[0x1FFFF])
+	})
+
+	t.Run("header checksum", func(t *testing.T) {
+		rom := newTestROM(0x00)
+		rom[headerChecksumAddress]++
+
+		cart, err := NewCartridgeWithData(rom)
+		assert.ErrorIs(t, err, ErrHeaderChecksum)
+		require.NotNil(t, cart, "the cartridge is still usable")
+
+		_, err = NewWithCartridge(cart)
+		assert.NoError(t, err)
+	})
+
+	t.Run("unsupported MBC", func(t *testing.T) {
+		cart, err := NewCartridgeWithData(newTestROM(0xFE)) // HuC3
+		require.NoError(t, err)
+
+		_, err = NewWithCartridge(cart)
+		assert.ErrorIs(t, err, ErrUnsupportedMBC)
+
+		var mbcErr *UnsupportedMBCError
+		require.True(t, errors.As(err, &mbcErr))
+		assert.Equal(t, uint8(0xFE), mbcErr.CartType)
+		assert.EqualError(t, err, "unsupported cartridge type: 0xFE")
+	})
+}
+
+func TestMBC1MulticartDetection(t *testing.T) {
+	newMulticart := func(games int) []byte {
+		rom := make([]byte, 0x100000)
+		copy(rom, newTestROM(0x01))
+		for game := range games {
+			copy(rom[game*multicartGameSize+logoAddress:], nintendoLogo)
+		}
+		return rom
+	}
+
+	cart, err := NewCartridgeWithData(newMulticart(4))
+	require.NoError(t, err)
+	assert.Equal(t, MBCType(MBC1MultiType), cart.mbcType)
+	mmu, err := NewWithCartridge(cart)
+	require.NoError(t, err)
+	assert.IsType(t, &MBC1M{}, mmu.mbc)
+
+	cart, err = NewCartridgeWithData(newMulticart(1))
+	require.NoError(t, err)
+	assert.Equal(t, MBCType(MBC1Type), cart.mbcType, "a single header is a plain MBC1 game")
+}
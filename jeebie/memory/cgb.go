@@ -0,0 +1,258 @@
+package memory
+
+import (
+	"github.com/valerio/go-jeebie/jeebie/addr"
+	"github.com/valerio/go-jeebie/jeebie/bit"
+)
+
+const (
+	vramBankSize = 0x2000
+	wramBankSize = 0x1000
+
+	// hdmaBlockCycles is how long the CPU is stalled for each 16 byte block
+	// of a VRAM DMA transfer, in single speed T-cycles.
+	hdmaBlockCycles = 32
+)
+
+// cgbState holds the Game Boy Color only registers and memory.
+// VRAM and WRAM banking is always active; in DMG mode the banks are simply
+// never switched.
+type cgbState struct {
+	enabled bool
+
+	vramBank uint8 // VBK
+	wramBank uint8 // SVBK, 1-7
+
+	bgPalette  [64]byte // 8 palettes, 4 colors, 2 bytes (RGB555) per color
+	objPalette [64]byte
+	bcps       uint8
+	ocps       uint8
+	opri       uint8
+
+	doubleSpeed   bool
+	speedArmed    bool // KEY1 bit 0
+	hdmaSource    uint16
+	hdmaDest      uint16
+	hdmaActive    bool  // HBlank DMA in progress
+	hdmaRemaining uint8 // blocks left minus one, as read back from HDMA5
+
+	stallCycles int // CPU cycles owed to a DMA transfer, see TakeStallCycles
+}
+
+// SetCGBMode enables or disables Game Boy Color hardware.
+func (m *MMU) SetCGBMode(enabled bool) {
+	m.cgb.enabled = enabled
+	m.cgb.vramBank = 0
+	m.cgb.wramBank = 1
+	m.APU.SetCGB(enabled)
+}
+
+// CGBMode reports whether Game Boy Color hardware is enabled.
+func (m *MMU) CGBMode() bool {
+	return m.cgb.enabled
+}
+
+// DoubleSpeed reports whether the CPU is running in CGB double speed mode.
+func (m *MMU) DoubleSpeed() bool {
+	return m.cgb.doubleSpeed
+}
+
+// SwitchSpeed performs a CPU speed switch if one was requested through KEY1.
+// It is called when executing STOP, and returns true if the speed changed.
+func (m *MMU) SwitchSpeed() bool {
+	if !m.cgb.enabled || !m.cgb.speedArmed {
+		return false
+	}
+	m.cgb.speedArmed = false
+	m.cgb.doubleSpeed = !m.cgb.doubleSpeed
+	return true
+}
+
+// TakeStallCycles returns the CPU cycles spent in DMA transfers since the last
+// call, which the CPU could not use to execute instructions.
+func (m *MMU) TakeStallCycles() int {
+	cycles := m.cgb.stallCycles
+	m.cgb.stallCycles = 0
+	return cycles
+}
+
+// ReadVRAM reads from a specific VRAM bank, regardless of VBK.
+// Used by the GPU, which can access both banks at once.
+func (m *MMU) ReadVRAM(bank uint8, address uint16) byte {
+	return m.vram[int(bank&1)*vramBankSize+int(address-0x8000)]
+}
+
+// BGPaletteColor returns a color of a background palette, as RGB555.
+func (m *MMU) BGPaletteColor(palette, index uint8) uint16 {
+	i := (palette&7)*8 + (index&3)*2
+	return bit.Combine(m.cgb.bgPalette[i+1], m.cgb.bgPalette[i])
+}
+
+// OBJPaletteColor returns a color of an object palette, as RGB555.
+func (m *MMU) OBJPaletteColor(palette, index uint8) uint16 {
+	i := (palette&7)*8 + (index&3)*2
+	return bit.Combine(m.cgb.objPalette[i+1], m.cgb.objPalette[i])
+}
+
+// HBlank is signaled by the GPU at the start of each HBlank, and copies the
+// next block of an HBlank DMA transfer.
+func (m *MMU) HBlank() {
+	if !m.cgb.hdmaActive {
+		return
+	}
+
+	m.hdmaCopyBlock()
+	if m.cgb.hdmaRemaining == 0 {
+		m.cgb.hdmaActive = false
+		m.cgb.hdmaRemaining = 0x7F
+		return
+	}
+	m.cgb.hdmaRemaining--
+}
+
+func (m *MMU) vramOffset(address uint16) int {
+	return int(m.cgb.vramBank)*vramBankSize + int(address-0x8000)
+}
+
+// wramOffset maps 0xC000-0xDFFF to the WRAM backing store. 0xC000-0xCFFF is
+// always bank 0, 0xD000-0xDFFF is the bank selected by SVBK.
+func (m *MMU) wramOffset(address uint16) int {
+	if address < 0xD000 {
+		return int(address - 0xC000)
+	}
+	return int(m.cgb.wramBank)*wramBankSize + int(address-0xD000)
+}
+
+// isCGBRegister reports whether address is a register handled by readCGB/writeCGB.
+func isCGBRegister(address uint16) bool {
+	switch address {
+	case addr.KEY1, addr.VBK, addr.HDMA1, addr.HDMA2, addr.HDMA3, addr.HDMA4, addr.HDMA5,
+		addr.BCPS, addr.BCPD, addr.OCPS, addr.OCPD, addr.OPRI, addr.SVBK:
+		return true
+	}
+	return false
+}
+
+func (m *MMU) readCGB(address uint16) byte {
+	switch address {
+	case addr.KEY1:
+		value := uint8(0x7E)
+		if m.cgb.doubleSpeed {
+			value |= 0x80
+		}
+		if m.cgb.speedArmed {
+			value |= 0x01
+		}
+		return value
+	case addr.VBK:
+		return 0xFE | m.cgb.vramBank
+	case addr.HDMA5:
+		if m.cgb.hdmaActive {
+			return m.cgb.hdmaRemaining
+		}
+		return 0x80 | m.cgb.hdmaRemaining
+	case addr.BCPS:
+		return m.cgb.bcps | 0x40
+	case addr.BCPD:
+		return m.cgb.bgPalette[m.cgb.bcps&0x3F]
+	case addr.OCPS:
+		return m.cgb.ocps | 0x40
+	case addr.OCPD:
+		return m.cgb.objPalette[m.cgb.ocps&0x3F]
+	case addr.OPRI:
+		return 0xFE | m.cgb.opri
+	case addr.SVBK:
+		return 0xF8 | m.cgb.wramBank
+	}
+	return 0xFF
+}
+
+func (m *MMU) writeCGB(address uint16, value byte) {
+	switch address {
+	case addr.KEY1:
+		m.cgb.speedArmed = bit.IsSet(0, value)
+	case addr.VBK:
+		m.cgb.vramBank = value & 0x01
+	case addr.HDMA1:
+		m.cgb.hdmaSource = uint16(value)<<8 | m.cgb.hdmaSource&0x00FF
+	case addr.HDMA2:
+		m.cgb.hdmaSource = m.cgb.hdmaSource&0xFF00 | uint16(value&0xF0)
+	case addr.HDMA3:
+		m.cgb.hdmaDest = uint16(value&0x1F)<<8 | m.cgb.hdmaDest&0x00FF
+	case addr.HDMA4:
+		m.cgb.hdmaDest = m.cgb.hdmaDest&0xFF00 | uint16(value&0xF0)
+	case addr.HDMA5:
+		m.startHDMA(value)
+	case addr.BCPS:
+		m.cgb.bcps = value & 0xBF
+	case addr.BCPD:
+		m.cgb.bgPalette[m.cgb.bcps&0x3F] = value
+		m.cgb.bcps = autoIncrementPaletteIndex(m.cgb.bcps)
+	case addr.OCPS:
+		m.cgb.ocps = value & 0xBF
+	case addr.OCPD:
+		m.cgb.objPalette[m.cgb.ocps&0x3F] = value
+		m.cgb.ocps = autoIncrementPaletteIndex(m.cgb.ocps)
+	case addr.OPRI:
+		m.cgb.opri = value & 0x01
+	case addr.SVBK:
+		m.cgb.wramBank = value & 0x07
+		if m.cgb.wramBank == 0 {
+			m.cgb.wramBank = 1
+		}
+	}
+}
+
+// autoIncrementPaletteIndex advances a BCPS/OCPS index after a data write,
+// if bit 7 is set. The index wraps within the 64 bytes of palette RAM.
+func autoIncrementPaletteIndex(spec uint8) uint8 {
+	if !bit.IsSet(7, spec) {
+		return spec
+	}
+	return 0x80 | (spec+1)&0x3F
+}
+
+// startHDMA handles a write to HDMA5. With bit 7 clear the whole transfer
+// happens at once (general purpose DMA), with bit 7 set one block is copied
+// per HBlank, starting right away if the PPU is already in HBlank. Writing
+// bit 7 clear during an HBlank DMA cancels it.
+func (m *MMU) startHDMA(value byte) {
+	blocks := value & 0x7F
+
+	if m.cgb.hdmaActive && !bit.IsSet(7, value) {
+		// the remaining length can still be read back, with bit 7 set
+		m.cgb.hdmaActive = false
+		return
+	}
+
+	if bit.IsSet(7, value) {
+		m.cgb.hdmaActive = true
+		m.cgb.hdmaRemaining = blocks
+		if m.lcdEnabled() && m.memory[addr.STAT]&0x03 == 0 {
+			m.HBlank()
+		}
+		return
+	}
+
+	for range int(blocks) + 1 {
+		m.hdmaCopyBlock()
+	}
+	m.cgb.hdmaRemaining = 0x7F
+}
+
+// hdmaCopyBlock copies 16 bytes to VRAM and advances source and destination.
+func (m *MMU) hdmaCopyBlock() {
+	for range 16 {
+		value := m.read(m.cgb.hdmaSource)
+		m.logROM(m.cgb.hdmaSource, ROMDMA)
+		m.vram[m.vramOffset(0x8000|m.cgb.hdmaDest&0x1FFF)] = value
+		m.cgb.hdmaSource++
+		m.cgb.hdmaDest++
+	}
+
+	cycles := hdmaBlockCycles
+	if m.cgb.doubleSpeed {
+		cycles *= 2
+	}
+	m.cgb.stallCycles += cycles
+}
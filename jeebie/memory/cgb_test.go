@@ -0,0 +1,156 @@
+package memory
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/valerio/go-jeebie/jeebie/addr"
+)
+
+func newCGBMMU() *MMU {
+	m := New()
+	m.SetCGBMode(true)
+	return m
+}
+
+func TestCGBVRAMBanking(t *testing.T) {
+	m := newCGBMMU()
+
+	m.Write(0x8000, 0x11)
+	m.Write(addr.VBK, 1)
+	assert.Equal(t, uint8(0xFF), m.Read(addr.VBK))
+	assert.Equal(t, uint8(0x00), m.Read(0x8000))
+	m.Write(0x8000, 0x22)
+
+	assert.Equal(t, uint8(0x11), m.ReadVRAM(0, 0x8000))
+	assert.Equal(t, uint8(0x22), m.ReadVRAM(1, 0x8000))
+
+	m.Write(addr.VBK, 0)
+	assert.Equal(t, uint8(0x11), m.Read(0x8000))
+}
+
+func TestCGBWRAMBanking(t *testing.T) {
+	m := newCGBMMU()
+
+	m.Write(0xC000, 0xAA)
+	m.Write(0xD000, 0x01)
+	m.Write(addr.SVBK, 5)
+	assert.Equal(t, uint8(0x00), m.Read(0xD000))
+	m.Write(0xD000, 0x05)
+	assert.Equal(t, uint8(0xAA), m.Read(0xC000), "bank 0 is fixed")
+	assert.Equal(t, uint8(0x05), m.Read(0xF000), "echo RAM follows the selected bank")
+
+	m.Write(addr.SVBK, 0)
+	assert.Equal(t, uint8(0xF9), m.Read(addr.SVBK), "bank 0 selects bank 1")
+	assert.Equal(t, uint8(0x01), m.Read(0xD000))
+}
+
+func TestCGBRegistersIgnoredInDMGMode(t *testing.T) {
+	m := New()
+
+	m.Write(0x8000, 0x11)
+	m.Write(addr.VBK, 1)
+	assert.Equal(t, uint8(0x11), m.Read(0x8000))
+}
+
+func TestCGBPaletteAutoIncrement(t *testing.T) {
+	m := newCGBMMU()
+
+	// palette 1, color 2, auto-increment
+	m.Write(addr.BCPS, 0x80|0x0C)
+	m.Write(addr.BCPD, 0x1F) // red, low byte
+	m.Write(addr.BCPD, 0x7C) // blue, high byte
+	assert.Equal(t, uint8(0x80|0x0E|0x40), m.Read(addr.BCPS))
+	assert.Equal(t, uint16(0x7C1F), m.BGPaletteColor(1, 2))
+
+	// no auto-increment, index wraps at 64 bytes
+	m.Write(addr.OCPS, 0x3F)
+	m.Write(addr.OCPD, 0x12)
+	m.Write(addr.OCPD, 0x34)
+	assert.Equal(t, uint8(0x34), m.Read(addr.OCPD))
+	assert.Equal(t, uint16(0x3400), m.OBJPaletteColor(7, 3))
+}
+
+func TestCGBGeneralPurposeDMA(t *testing.T) {
+	m := newCGBMMU()
+	for i := range uint16(0x20) {
+		m.Write(0xC100+i, uint8(i))
+	}
+
+	m.Write(addr.VBK, 1)
+	m.Write(addr.HDMA1, 0xC1)
+	m.Write(addr.HDMA2, 0x00)
+	m.Write(addr.HDMA3, 0x80) // only the low 13 bits count
+	m.Write(addr.HDMA4, 0x40)
+	m.Write(addr.HDMA5, 0x01) // 2 blocks
+
+	for i := range uint16(0x20) {
+		assert.Equal(t, uint8(i), m.ReadVRAM(1, 0x8040+i))
+	}
+	assert.Equal(t, uint8(0xFF), m.Read(addr.HDMA5))
+	assert.Equal(t, 2*hdmaBlockCycles, m.TakeStallCycles())
+	assert.Equal(t, 0, m.TakeStallCycles())
+}
+
+func TestCGBHBlankDMA(t *testing.T) {
+	m := newCGBMMU()
+	for i := range uint16(0x30) {
+		m.Write(0xC000+i, uint8(i+1))
+	}
+
+	m.Write(addr.HDMA1, 0xC0)
+	m.Write(addr.HDMA2, 0x00)
+	m.Write(addr.HDMA3, 0x00)
+	m.Write(addr.HDMA4, 0x00)
+	m.Write(addr.HDMA5, 0x82) // 3 blocks, one per HBlank
+
+	assert.Equal(t, uint8(0x02), m.Read(addr.HDMA5))
+	assert.Equal(t, uint8(0), m.ReadVRAM(0, 0x8000))
+
+	m.HBlank()
+	assert.Equal(t, uint8(0x01), m.Read(addr.HDMA5))
+	assert.Equal(t, uint8(1), m.ReadVRAM(0, 0x8000))
+	assert.Equal(t, uint8(0), m.ReadVRAM(0, 0x8010))
+
+	// cancel: the remaining length is kept, with bit 7 set
+	m.Write(addr.HDMA5, 0x00)
+	assert.Equal(t, uint8(0x81), m.Read(addr.HDMA5))
+	m.HBlank()
+	assert.Equal(t, uint8(0), m.ReadVRAM(0, 0x8010))
+}
+
+func TestCGBHBlankDMAStartedInHBlank(t *testing.T) {
+	m := newCGBMMU()
+	for i := range uint16(0x20) {
+		m.Write(0xC000+i, uint8(i+1))
+	}
+	m.Write(addr.LCDC, 0x91)
+	m.SetPPUStatus(0) // HBlank
+
+	m.Write(addr.HDMA1, 0xC0)
+	m.Write(addr.HDMA2, 0x00)
+	m.Write(addr.HDMA3, 0x00)
+	m.Write(addr.HDMA4, 0x00)
+	m.Write(addr.HDMA5, 0x81) // 2 blocks
+
+	// the first block is copied without waiting for the next HBlank
+	assert.Equal(t, uint8(0x00), m.Read(addr.HDMA5))
+	assert.Equal(t, uint8(1), m.ReadVRAM(0, 0x8000))
+	assert.Equal(t, uint8(0), m.ReadVRAM(0, 0x8010))
+
+	m.HBlank()
+	assert.Equal(t, uint8(0xFF), m.Read(addr.HDMA5))
+	assert.Equal(t, uint8(0x11), m.ReadVRAM(0, 0x8010))
+}
+
+func TestCGBSpeedSwitch(t *testing.T) {
+	m := newCGBMMU()
+
+	assert.False(t, m.SwitchSpeed(), "switch needs to be armed first")
+	m.Write(addr.KEY1, 0x01)
+	assert.Equal(t, uint8(0x7F), m.Read(addr.KEY1))
+
+	assert.True(t, m.SwitchSpeed())
+	assert.True(t, m.DoubleSpeed())
+	assert.Equal(t, uint8(0xFE), m.Read(addr.KEY1))
+}
@@ -0,0 +1,99 @@
+package memory
+
+import "github.com/valerio/go-jeebie/jeebie/addr"
+
+const (
+	oamDMALength = 160
+	// oamDMAStartDelay is the setup time between the write to DMA and the
+	// first byte copied, in M-cycles.
+	oamDMAStartDelay = 1
+)
+
+// oamDMA is the state of OAM DMA. A transfer copies one byte per M-cycle from
+// source to OAM. While it runs, the CPU cannot access OAM, and reads from the
+// bus used by the source return the byte being transferred (bus conflict).
+//
+// Writing DMA while a transfer is running restarts it: the old transfer keeps
+// going (and keeps the bus locked) until the new one starts.
+type oamDMA struct {
+	active bool
+	source uint16
+	index  int  // next byte to copy
+	value  byte // last byte copied, seen by the CPU on a bus conflict
+
+	pending       bool // a transfer was requested and waits for the start delay
+	pendingSource uint16
+	delay         int // M-cycles left before the pending transfer starts
+
+	cycles int // T-cycles not yet making a full M-cycle
+}
+
+// startOAMDMA handles a write to the DMA register.
+func (m *MMU) startOAMDMA(value byte) {
+	source := uint16(value) << 8
+	if source >= 0xE000 {
+		// 0xE000-0xFFFF sources read from WRAM, like echo RAM
+		source -= 0x2000
+	}
+
+	m.dma.pending = true
+	m.dma.pendingSource = source
+	m.dma.delay = oamDMAStartDelay
+}
+
+// tickOAMDMA advances OAM DMA by the given T-cycles.
+func (m *MMU) tickOAMDMA(cycles int) {
+	d := &m.dma
+	if !d.active && !d.pending {
+		d.cycles = 0
+		return
+	}
+
+	d.cycles += cycles
+	for d.cycles >= 4 {
+		d.cycles -= 4
+
+		if d.pending {
+			if d.delay > 0 {
+				d.delay--
+			} else {
+				d.pending = false
+				d.active = true
+				d.source = d.pendingSource
+				d.index = 0
+			}
+		}
+
+		if d.active {
+			d.value = m.Peek(d.source + uint16(d.index))
+			m.logROM(d.source+uint16(d.index), ROMDMA)
+			m.memory[addr.OAMStart+uint16(d.index)] = d.value
+			d.index++
+			if d.index == oamDMALength {
+				d.active = false
+			}
+		}
+	}
+}
+
+// OAMDMAActive reports whether an OAM DMA transfer is running.
+func (m *MMU) OAMDMAActive() bool {
+	return m.dma.active
+}
+
+// dmaConflict reports whether a CPU access to address collides with the
+// running OAM DMA transfer. OAM is always unavailable, and so is anything on
+// the same bus as the source: VRAM, or the external bus (cartridge and WRAM).
+func (m *MMU) dmaConflict(address uint16) bool {
+	if !m.dma.active || address >= 0xFF00 {
+		return false
+	}
+	if address >= addr.OAMStart {
+		return true
+	}
+	return isVRAMAddress(address) == isVRAMAddress(m.dma.source)
+}
+
+func isVRAMAddress(address uint16) bool {
+	return address >= 0x8000 && address < 0xA000
+}
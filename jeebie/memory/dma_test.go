@@ -0,0 +1,65 @@
+package memory
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/valerio/go-jeebie/jeebie/addr"
+)
+
+func fillWRAM(m *MMU, base uint16, offset byte) {
+	for i := range uint16(oamDMALength) {
+		m.Write(base+i, byte(i)+offset)
+	}
+}
+
+func TestOAMDMATiming(t *testing.T) {
+	m := New()
+	fillWRAM(m, 0xC000, 0x10)
+	m.Write(0xFF80, 0x42)
+	m.Write(0x8000, 0x24)
+
+	m.Write(addr.DMA, 0xC0)
+	assert.False(t, m.OAMDMAActive(), "transfer starts after the setup delay")
+	assert.Equal(t, uint8(0x00), m.Read(addr.OAMStart))
+
+	m.Tick(4 * (oamDMAStartDelay + 1))
+	assert.True(t, m.OAMDMAActive())
+	assert.Equal(t, uint8(0x10), m.Peek(addr.OAMStart))
+	assert.Equal(t, uint8(0x00), m.Peek(addr.OAMStart+1))
+
+	// CPU bus lockout
+	assert.Equal(t, uint8(0xFF), m.Read(addr.OAMStart))
+	assert.Equal(t, uint8(0x10), m.Read(0xC050), "bus conflict returns the byte being copied")
+	assert.Equal(t, uint8(0x42), m.Read(0xFF80), "HRAM stays accessible")
+	assert.Equal(t, uint8(0x24), m.Read(0x8000), "VRAM is on a separate bus")
+	m.Write(0xC000, 0x99)
+	assert.Equal(t, uint8(0x10), m.Peek(0xC000), "conflicting writes are dropped")
+
+	m.Tick(4 * (oamDMALength - 1))
+	assert.False(t, m.OAMDMAActive())
+	for i := range uint16(oamDMALength) {
+		assert.Equal(t, byte(i)+0x10, m.Read(addr.OAMStart+i))
+	}
+}
+
+func TestOAMDMARestart(t *testing.T) {
+	m := New()
+	fillWRAM(m, 0xC000, 0x10)
+	fillWRAM(m, 0xD000, 0x80)
+
+	m.Write(addr.DMA, 0xC0)
+	m.Tick(4 * (oamDMAStartDelay + 50))
+	m.Write(addr.DMA, 0xD0)
+
+	// the old transfer keeps running during the new one's setup
+	m.Tick(4 * oamDMAStartDelay)
+	assert.True(t, m.OAMDMAActive())
+	assert.Equal(t, uint8(0x10+50), m.Peek(addr.OAMStart+50))
+
+	m.Tick(4 * oamDMALength)
+	assert.False(t, m.OAMDMAActive())
+	for i := range uint16(oamDMALength) {
+		assert.Equal(t, byte(i)+0x80, m.Read(addr.OAMStart+i))
+	}
+}
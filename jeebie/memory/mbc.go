@@ -10,6 +10,61 @@ type MBC interface {
 	Write(addr uint16, value uint8) uint8
 }
 
+// ROMBanker is implemented by memory bank controllers with a switchable ROM
+// bank at 0x4000-0x7FFF.
+type ROMBanker interface {
+	// ROMBank returns the bank mapped at 0x4000-0x7FFF, after wrapping
+	// around the size of the ROM.
+	ROMBank() int
+}
+
+// LowROMBanker is implemented by memory bank controllers that can map a bank
+// other than 0 at 0x0000-0x3FFF.
+type LowROMBanker interface {
+	// LowROMBank returns the bank mapped at 0x0000-0x3FFF.
+	LowROMBank() int
+}
+
+// LowROMBank returns the ROM bank mapped at 0x0000-0x3FFF, usually 0.
+func (m *MMU) LowROMBank() int {
+	if b, ok := m.mbc.(LowROMBanker); ok {
+		return b.LowROMBank()
+	}
+	return 0
+}
+
+// ROMBank returns the ROM bank mapped at 0x4000-0x7FFF, which is always 1 for
+// cartridges without a memory bank controller.
+func (m *MMU) ROMBank() int {
+	if b, ok := m.mbc.(ROMBanker); ok {
+		return b.ROMBank()
+	}
+	return 1
+}
+
+// wrapROMBank returns bank modulo the number of banks in rom, as reads wrap.
+func wrapROMBank(bank int, rom []uint8) int {
+	banks := len(rom) / 0x4000
+	if banks == 0 {
+		return bank
+	}
+	return bank % banks
+}
+
+// readROM reads offset within a 16KB bank of rom. Banks past the end wrap
+// like on a smaller ROM chip, and offsets past the end of a truncated image
+// read as 0xFF.
+func readROM(rom []uint8, bank int, offset uint16) uint8 {
+	if len(rom) == 0 {
+		return 0xFF
+	}
+	i := bank*0x4000%len(rom) + int(offset)
+	if i >= len(rom) {
+		return 0xFF
+	}
+	return rom[i]
+}
+
 // NoMBC represents cartridges with no memory banking capabilities.
 // These are typically smaller games (32KB or less) that fit entirely in the
 // base memory region. The cartridge ROM is directly mapped to 0x0000-0x7FFF
@@ -26,7 +81,11 @@ func NewNoMBC(romData []uint8) *NoMBC {
 }
 
 func (m *NoMBC) Read(addr uint16) uint8 {
-	// For NoMBC, we just read directly from ROM
+	// For NoMBC, we just read directly from ROM; there is no external RAM
+	// and nothing drives the bus past the end of the ROM
+	if int(addr) >= len(m.rom) {
+		return 0xFF
+	}
 	return m.rom[addr]
 }
 
@@ -76,15 +135,10 @@ func (m *MBC1) Read(addr uint16) uint8 {
 	switch {
 	case addr <= 0x3FFF:
 		// ROM Bank 0
-		return m.rom[addr]
+		return readROM(m.rom, 0, addr)
 	case addr >= 0x4000 && addr <= 0x7FFF:
 		// Switchable ROM Bank
-		offset := uint32(m.romBank) * 0x4000
-		if offset >= uint32(len(m.rom)) {
-			// If bank would be out of bounds, wrap around
-			offset = offset % uint32(len(m.rom))
-		}
-		return m.rom[offset+uint32(addr-0x4000)]
+		return readROM(m.rom, int(m.romBank), addr-0x4000)
 	case addr >= 0xA000 && addr <= 0xBFFF:
 		// RAM Bank
 		if !m.ramEnabled || len(m.ram) == 0 {
@@ -143,6 +197,102 @@ func (m *MBC1) Write(addr uint16, value uint8) uint8 {
 	return value
 }
 
+func (m *MBC1) RAM() []uint8         { return m.ram }
+func (m *MBC1) LoadRAM(data []uint8) { copy(m.ram, data) }
+func (m *MBC1) RAMEnabled() bool     { return m.ramEnabled }
+func (m *MBC1) ROMBank() int         { return wrapROMBank(int(m.romBank), m.rom) }
+
+// MBC1M is the MBC1 wired for multicarts, compilations of 256KB games such as
+// Mortal Kombat I & II. Features include:
+//   - Bit 4 of the ROM bank number register is not connected, so it selects
+//     one of 16 banks of the current game
+//   - The upper bank register at 0x4000-0x5FFF selects the game, shifting by
+//     4 bits instead of 5
+//   - In banking mode 1, 0x0000-0x3FFF maps the first bank of the selected
+//     game instead of bank 0
+//   - Optional RAM, banked by the upper register in mode 1
+type MBC1M struct {
+	rom         []uint8
+	ram         []uint8
+	bank1       uint8 // ROM bank number register, 5 bits
+	bank2       uint8 // upper bank register, 2 bits
+	ramEnabled  bool
+	bankingMode uint8
+}
+
+// NewMBC1M creates a new MBC1 multicart controller
+func NewMBC1M(romData []uint8, ramBankCount uint8) *MBC1M {
+	return &MBC1M{
+		rom:   romData,
+		ram:   make([]uint8, uint32(ramBankCount)*0x2000),
+		bank1: 1,
+	}
+}
+
+// LowROMBank returns the bank mapped at 0x0000-0x3FFF.
+func (m *MBC1M) LowROMBank() int {
+	if m.bankingMode == 0 {
+		return 0
+	}
+	return wrapROMBank(int(m.bank2)<<4, m.rom)
+}
+
+// ramOffset returns the offset in RAM of an address in 0xA000-0xBFFF.
+func (m *MBC1M) ramOffset(addr uint16) int {
+	bank := 0
+	if m.bankingMode == 1 {
+		bank = int(m.bank2)
+	}
+	return (bank*0x2000 + int(addr-0xA000)) % len(m.ram)
+}
+
+func (m *MBC1M) Read(addr uint16) uint8 {
+	switch {
+	case addr <= 0x3FFF:
+		return readROM(m.rom, m.LowROMBank(), addr)
+	case addr >= 0x4000 && addr <= 0x7FFF:
+		return readROM(m.rom, m.ROMBank(), addr-0x4000)
+	case addr >= 0xA000 && addr <= 0xBFFF:
+		if !m.ramEnabled || len(m.ram) == 0 {
+			return 0xFF
+		}
+		return m.ram[m.ramOffset(addr)]
+	default:
+		return 0xFF
+	}
+}
+
+func (m *MBC1M) Write(addr uint16, value uint8) uint8 {
+	switch {
+	case addr <= 0x1FFF:
+		m.ramEnabled = (value & 0x0F) == 0x0A
+	case addr >= 0x2000 && addr <= 0x3FFF:
+		// bank 0 is translated to 1 on all 5 bits, so writing 0x10 maps
+		// the first bank of a game
+		m.bank1 = value & 0x1F
+		if m.bank1 == 0 {
+			m.bank1 = 1
+		}
+	case addr >= 0x4000 && addr <= 0x5FFF:
+		m.bank2 = value & 0x03
+	case addr >= 0x6000 && addr <= 0x7FFF:
+		m.bankingMode = value & 0x01
+	case addr >= 0xA000 && addr <= 0xBFFF:
+		if !m.ramEnabled || len(m.ram) == 0 {
+			return 0xFF
+		}
+		m.ram[m.ramOffset(addr)] = value
+	}
+	return value
+}
+
+func (m *MBC1M) RAM() []uint8         { return m.ram }
+func (m *MBC1M) LoadRAM(data []uint8) { copy(m.ram, data) }
+func (m *MBC1M) RAMEnabled() bool     { return m.ramEnabled }
+func (m *MBC1M) ROMBank() int {
+	return wrapROMBank(int(m.bank2)<<4|int(m.bank1&0x0F), m.rom)
+}
+
 // MBC2 is a simpler MBC chip with built-in RAM. Features include:
 //   - Supports up to 256KB ROM (16 16KB banks)
 //   - Built-in 512x4 bits RAM (not external)
@@ -173,14 +323,10 @@ func (m *MBC2) Read(addr uint16) uint8 {
 	switch {
 	case addr <= 0x3FFF:
 		// ROM Bank 0
-		return m.rom[addr]
+		return readROM(m.rom, 0, addr)
 	case addr >= 0x4000 && addr <= 0x7FFF:
 		// Switchable ROM Bank
-		offset := uint32(m.romBank) * 0x4000
-		if offset >= uint32(len(m.rom)) {
-			offset = offset % uint32(len(m.rom))
-		}
-		return m.rom[offset+uint32(addr-0x4000)]
+		return readROM(m.rom, int(m.romBank), addr-0x4000)
 	case addr >= 0xA000 && addr <= 0xA1FF:
 		// Built-in RAM
 		if !m.ramEnabled {
@@ -195,16 +341,12 @@ func (m *MBC2) Read(addr uint16) uint8 {
 
 func (m *MBC2) Write(addr uint16, value uint8) uint8 {
 	switch {
-	case addr <= 0x1FFF:
-		// RAM Enable
+	case addr <= 0x3FFF:
+		// A8 selects between the RAM enable and ROM bank registers
 		if addr&0x0100 == 0 {
-			// Only the lower 4 bits of the address are used
 			m.ramEnabled = (value & 0x0F) == 0x0A
-		}
-	case addr >= 0x2000 && addr <= 0x3FFF:
-		// ROM Bank Number
-		if addr&0x0100 == 1 {
-			// Only the lower 4 bits of the address are used
+		} else {
+			// Only the lower 4 bits are used
 			m.romBank = value & 0x0F
 			if m.romBank == 0 {
 				m.romBank = 1
@@ -225,6 +367,11 @@ func (m *MBC2) Write(addr uint16, value uint8) uint8 {
 	return value
 }
 
+func (m *MBC2) RAM() []uint8         { return m.ram }
+func (m *MBC2) LoadRAM(data []uint8) { copy(m.ram, data) }
+func (m *MBC2) RAMEnabled() bool     { return m.ramEnabled }
+func (m *MBC2) ROMBank() int         { return wrapROMBank(int(m.romBank), m.rom) }
+
 type Clock interface {
 	Now() time.Time
 }
@@ -246,19 +393,23 @@ func (s systemClockFunc) Now() time.Time {
 type MBC3 struct {
 	rom        []uint8
 	ram        []uint8
-	rtc        [5]uint8 // RTC registers
 	romBank    uint8
 	ramBank    uint8
 	ramEnabled bool
 	hasRTC     bool
-	rtcLatch   bool      // Flag to indicate if RTC data is latched
-	clock      Clock     // Clock interface for RTC functionality
-	rtcTime    time.Time // Time when RTC was last updated
+
+	// RTC state, see rtc.go
+	rtc           [5]uint8  // running counters, in register layout
+	latched       [5]uint8  // counters as read by the game
+	latchPrepared bool      // 0x00 was written to the latch register
+	clock         Clock     // Clock interface for RTC functionality
+	rtcTime       time.Time // host time the counters were last advanced to
+	replaying     bool      // the clock is frozen, see SetReplaying
 }
 
 // NewMBC3 creates a new MBC3 controller
 func NewMBC3(romData []uint8, ramBankCount uint8, hasRTC bool, clock Clock) *MBC3 {
-	if hasRTC && clock == nil {
+	if clock == nil {
 		// default to system clock if no clock is provided
 		clock = systemClockFunc(time.Now)
 	}
@@ -270,7 +421,6 @@ func NewMBC3(romData []uint8, ramBankCount uint8, hasRTC bool, clock Clock) *MBC
 		romBank:    1,
 		ramEnabled: false,
 		hasRTC:     hasRTC,
-		rtcLatch:   false,
 		clock:      clock,
 		rtcTime:    clock.Now(),
 	}
@@ -279,29 +429,21 @@ func NewMBC3(romData []uint8, ramBankCount uint8, hasRTC bool, clock Clock) *MBC
 func (m *MBC3) Read(addr uint16) uint8 {
 	switch {
 	case addr <= 0x3FFF:
-		return m.rom[addr]
+		return readROM(m.rom, 0, addr)
 	case addr >= 0x4000 && addr <= 0x7FFF:
-		offset := uint32(m.romBank) * 0x4000
-		if offset >= uint32(len(m.rom)) {
-			offset = offset % uint32(len(m.rom))
-		}
-		return m.rom[offset+uint32(addr-0x4000)]
+		return readROM(m.rom, int(m.romBank), addr-0x4000)
 	case addr >= 0xA000 && addr <= 0xBFFF:
 		if !m.ramEnabled {
 			return 0xFF
 		}
-		if m.ramBank <= 0x03 {
+		if m.ramBank <= 0x03 && len(m.ram) > 0 {
 			offset := uint32(m.ramBank) * 0x2000
 			if offset >= uint32(len(m.ram)) {
 				offset = offset % uint32(len(m.ram))
 			}
 			return m.ram[offset+uint32(addr-0xA000)]
 		} else if m.hasRTC && m.ramBank >= 0x08 && m.ramBank <= 0x0C {
-			if m.rtcLatch {
-				m.updateRTC()
-				m.rtcLatch = false
-			}
-			return m.rtc[m.ramBank-0x08]
+			return m.latched[m.ramBank-0x08]
 		}
 		return 0xFF
 	default:
@@ -322,21 +464,23 @@ func (m *MBC3) Write(addr uint16, value uint8) uint8 {
 	case addr >= 0x4000 && addr <= 0x5FFF:
 		m.ramBank = value
 	case addr >= 0x6000 && addr <= 0x7FFF:
-		if value == 0x00 {
-			m.rtcLatch = true
+		// writing 0x00 then 0x01 latches the clock
+		if m.hasRTC && m.latchPrepared && value == 0x01 {
+			m.latchRTC()
 		}
+		m.latchPrepared = value == 0x00
 	case addr >= 0xA000 && addr <= 0xBFFF:
 		if !m.ramEnabled {
 			return 0xFF
 		}
-		if m.ramBank <= 0x03 {
+		if m.ramBank <= 0x03 && len(m.ram) > 0 {
 			offset := uint32(m.ramBank) * 0x2000
 			if offset >= uint32(len(m.ram)) {
 				offset = offset % uint32(len(m.ram))
 			}
 			m.ram[offset+uint32(addr-0xA000)] = value
 		} else if m.hasRTC && m.ramBank >= 0x08 && m.ramBank <= 0x0C {
-			m.rtc[m.ramBank-0x08] = value
+			m.writeRTC(int(m.ramBank-0x08), value)
 		}
 	case addr >= 0x1A00 && addr <= 0x1FFF:
 		// Commands $1A to $1F are stubs
@@ -344,29 +488,10 @@ func (m *MBC3) Write(addr uint16, value uint8) uint8 {
 	return value
 }
 
-func (m *MBC3) updateRTC() {
-	now := m.clock.Now()
-	duration := now.Sub(m.rtcTime)
-	m.rtcTime = now
-
-	seconds := m.rtc[0] + uint8(duration.Seconds())
-	minutes := m.rtc[1] + uint8(duration.Minutes())
-	hours := m.rtc[2] + uint8(duration.Hours())
-
-	m.rtc[0] = seconds % 60
-	m.rtc[1] = minutes % 60
-	m.rtc[2] = hours % 24
-	// Days are split into two bytes
-	// Handle days overflow
-	daysLow := m.rtc[3] + uint8(duration.Hours()/24)
-	daysHigh := m.rtc[4]
-
-	daysHigh += daysLow / 255
-	daysLow %= 255
-
-	m.rtc[3] = daysLow
-	m.rtc[4] = daysHigh
-}
+func (m *MBC3) RAM() []uint8         { return m.ram }
+func (m *MBC3) LoadRAM(data []uint8) { copy(m.ram, data) }
+func (m *MBC3) RAMEnabled() bool     { return m.ramEnabled }
+func (m *MBC3) ROMBank() int         { return wrapROMBank(int(m.romBank), m.rom) }
 
 // MBC5 is the most advanced MBC chip. Features include:
 // - Supports up to 8MB ROM (512 16KB banks)
@@ -400,15 +525,11 @@ func NewMBC5(romData []uint8, hasRumble bool, ramBankCount uint8) *MBC5 {
 func (m *MBC5) Read(addr uint16) uint8 {
 	switch {
 	case addr <= 0x3FFF:
-		return m.rom[addr]
+		return readROM(m.rom, 0, addr)
 	case addr >= 0x4000 && addr <= 0x7FFF:
-		offset := uint32(m.romBank) * 0x4000
-		if offset >= uint32(len(m.rom)) {
-			offset = offset % uint32(len(m.rom))
-		}
-		return m.rom[offset+uint32(addr-0x4000)]
+		return readROM(m.rom, int(m.romBank), addr-0x4000)
 	case addr >= 0xA000 && addr <= 0xBFFF:
-		if !m.ramEnabled {
+		if !m.ramEnabled || len(m.ram) == 0 {
 			return 0xFF
 		}
 		offset := uint32(m.ramBank) * 0x2000
@@ -432,7 +553,7 @@ func (m *MBC5) Write(addr uint16, value uint8) uint8 {
 	case addr >= 0x4000 && addr <= 0x5FFF:
 		m.ramBank = value & 0x0F
 	case addr >= 0xA000 && addr <= 0xBFFF:
-		if !m.ramEnabled {
+		if !m.ramEnabled || len(m.ram) == 0 {
 			return 0xFF
 		}
 		offset := uint32(m.ramBank) * 0x2000
@@ -443,3 +564,8 @@ func (m *MBC5) Write(addr uint16, value uint8) uint8 {
 	}
 	return value
 }
+
+func (m *MBC5) RAM() []uint8         { return m.ram }
+func (m *MBC5) LoadRAM(data []uint8) { copy(m.ram, data) }
+func (m *MBC5) RAMEnabled() bool     { return m.ramEnabled }
+func (m *MBC5) ROMBank() int         { return wrapROMBank(int(m.romBank), m.rom) }
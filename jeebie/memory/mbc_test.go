@@ -2,6 +2,8 @@ package memory
 
 import (
 	"testing"
+
+	"github.com/valerio/go-jeebie/jeebie/internal/testutil"
 )
 
 func TestMBC1(t *testing.T) {
@@ -197,3 +199,258 @@ func TestMBC1(t *testing.T) {
 		})
 	})
 }
+
+func TestNoMBCExternalRAMReadsOpenBus(t *testing.T) {
+	mbc := NewNoMBC(make([]uint8, 0x8000))
+	if got := mbc.Read(0xA000); got != 0xFF {
+		t.Errorf("Read(0xA000) = 0x%02X; want 0xFF", got)
+	}
+}
+
+func TestTruncatedROMReads(t *testing.T) {
+	rom := make([]uint8, 0x200)
+	rom[0x0100] = 0x42
+	mbcs := map[string]MBC{
+		"MBC1":  NewMBC1(rom, false, 0),
+		"MBC1M": NewMBC1M(rom, 0),
+		"MBC2":  NewMBC2(rom),
+		"MBC3":  NewMBC3(rom, 0, false, nil),
+		"MBC5":  NewMBC5(rom, false, 0),
+	}
+
+	for name, mbc := range mbcs {
+		t.Run(name, func(t *testing.T) {
+			reads := []struct {
+				addr uint16
+				want uint8
+			}{
+				{0x0100, 0x42},
+				{0x0300, 0xFF}, // past the end of the image
+				{0x4100, 0x42}, // banks wrap
+				{0x7FFF, 0xFF},
+			}
+			for _, r := range reads {
+				if got := mbc.Read(r.addr); got != r.want {
+					t.Errorf("Read(0x%04X) = 0x%02X; want 0x%02X", r.addr, got, r.want)
+				}
+			}
+
+			// without RAM either, enabled RAM accesses must not panic
+			mbc.Write(0x0000, 0x0A)
+			mbc.Write(0xA000, 0x12)
+			mbc.Read(0xA000)
+		})
+	}
+}
+
+func TestROMBank(t *testing.T) {
+	mbc := NewMBC5(make([]uint8, 0x10000), false, 0) // 4 banks
+	if got := mbc.ROMBank(); got != 1 {
+		t.Errorf("initial ROMBank() = %d; want 1", got)
+	}
+	mbc.Write(0x2000, 0x06)
+	if got := mbc.ROMBank(); got != 2 {
+		t.Errorf("ROMBank() after selecting bank 6 = %d; want 2 (wrapped)", got)
+	}
+
+	if got := (&MMU{mbc: NewNoMBC(make([]uint8, 0x8000))}).ROMBank(); got != 1 {
+		t.Errorf("ROMBank() without MBC = %d; want 1", got)
+	}
+}
+
+func TestMBC2Registers(t *testing.T) {
+	rom := make([]uint8, 4*0x4000)
+	for i := range rom {
+		rom[i] = uint8(i / 0x4000)
+	}
+	mbc := NewMBC2(rom)
+
+	mbc.Write(0x2100, 0x03) // A8 set: ROM bank
+	if got := mbc.Read(0x4000); got != 3 {
+		t.Errorf("Read(0x4000) after selecting bank 3 = %d; want 3", got)
+	}
+	mbc.Write(0x0100, 0x02)
+	if got := mbc.Read(0x4000); got != 2 {
+		t.Errorf("Read(0x4000) after selecting bank 2 at 0x0100 = %d; want 2", got)
+	}
+	if mbc.RAMEnabled() {
+		t.Error("ROM bank writes should not enable RAM")
+	}
+
+	mbc.Write(0x3000, 0x0A) // A8 clear: RAM enable
+	if !mbc.RAMEnabled() {
+		t.Error("RAM should be enabled by a write to 0x3000")
+	}
+	if got := mbc.Read(0x4000); got != 2 {
+		t.Errorf("RAM enable changed the ROM bank to %d", got)
+	}
+}
+
+func TestPokePatchesROM(t *testing.T) {
+	rom := make([]uint8, 4*0x4000)
+	for i := range rom {
+		rom[i] = uint8(i / 0x4000)
+	}
+	rom[0x147] = 0x01 // MBC1
+	cart, err := NewCartridgeWithData(rom)
+	if cart == nil {
+		t.Fatal(err)
+	}
+	mmu, err := NewWithCartridge(cart)
+	if err != nil {
+		t.Fatal(err)
+	}
+	mmu.Write(0x2000, 0x02)
+
+	mmu.Poke(0x2000, 0x42)
+	mmu.Poke(0x4000, 0x43)
+	if got := mmu.ROMBank(); got != 2 {
+		t.Errorf("ROMBank() after Poke(0x2000) = %d; want 2, banks should not switch", got)
+	}
+	if got := mmu.Peek(0x2000); got != 0x42 {
+		t.Errorf("Peek(0x2000) = 0x%02X; want 0x42", got)
+	}
+	if got := mmu.Peek(0x4000); got != 0x43 {
+		t.Errorf("Peek(0x4000) = 0x%02X; want 0x43", got)
+	}
+	if got := cart.data[2*0x4000]; got != 0x43 {
+		t.Errorf("bank 2 byte 0 = 0x%02X; want 0x43, the mapped bank should be patched", got)
+	}
+}
+
+func TestMMUBatteryRAMTracking(t *testing.T) {
+	newMMU := func(cartType uint8) *MMU {
+		rom := testutil.ROM(cartType, 0x03, nil) // 4 RAM banks
+		cart, err := NewCartridgeWithData(rom)
+		if err != nil {
+			t.Fatal(err)
+		}
+		mmu, err := NewWithCartridge(cart)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return mmu
+	}
+
+	t.Run("no battery", func(t *testing.T) {
+		mmu := newMMU(0x02) // MBC1+RAM
+		if mmu.BatteryRAM() != nil {
+			t.Fatal("BatteryRAM() should be nil for a cartridge without battery")
+		}
+	})
+
+	t.Run("dirty and committed", func(t *testing.T) {
+		mmu := newMMU(0x03) // MBC1+RAM+BATTERY
+		sram := mmu.BatteryRAM()
+		if sram == nil {
+			t.Fatal("BatteryRAM() should not be nil for a battery cartridge")
+		}
+
+		mmu.Write(0x0000, 0x0A)
+		mmu.Write(0xA000, 0x42)
+		dirty, committed := mmu.SRAMStatus()
+		if !dirty || committed {
+			t.Errorf("after write: dirty=%v committed=%v; want dirty=true committed=false", dirty, committed)
+		}
+
+		mmu.Write(0x0000, 0x00)
+		dirty, committed = mmu.SRAMStatus()
+		if !dirty || !committed {
+			t.Errorf("after disable: dirty=%v committed=%v; want both true", dirty, committed)
+		}
+		if sram.RAM()[0] != 0x42 {
+			t.Errorf("RAM()[0] = 0x%02X; want 0x42", sram.RAM()[0])
+		}
+
+		mmu.MarkSRAMSaved()
+		dirty, committed = mmu.SRAMStatus()
+		if dirty || committed {
+			t.Errorf("after save: dirty=%v committed=%v; want both false", dirty, committed)
+		}
+	})
+
+	t.Run("MBC2 RAM disabled at 0x2000-0x3FFF", func(t *testing.T) {
+		mmu := newMMU(0x06)     // MBC2+BATTERY
+		mmu.Write(0x2000, 0x0A) // A8 clear: RAM enable
+		mmu.Write(0xA000, 0x05)
+		mmu.Write(0x3000, 0x00)
+		dirty, committed := mmu.SRAMStatus()
+		if !dirty || !committed {
+			t.Errorf("after disable: dirty=%v committed=%v; want both true", dirty, committed)
+		}
+	})
+
+	t.Run("RTC writes", func(t *testing.T) {
+		cart, err := NewCartridgeWithData(testutil.ROM(0x0F, 0x00, nil)) // MBC3+TIMER+BATTERY, no RAM
+		if err != nil {
+			t.Fatal(err)
+		}
+		mmu, err := NewWithCartridge(cart)
+		if err != nil {
+			t.Fatal(err)
+		}
+		mmu.Write(0x6000, 0x00)
+		mmu.Write(0x6000, 0x01)
+		if dirty, _ := mmu.SRAMStatus(); !dirty {
+			t.Error("latching the clock should make the save dirty")
+		}
+
+		mmu.MarkSRAMSaved()
+		mmu.Write(0x0000, 0x0A)
+		mmu.Write(0x4000, 0x08)
+		mmu.Write(0xA000, 0x12)
+		mmu.Write(0x0000, 0x00)
+		dirty, committed := mmu.SRAMStatus()
+		if !dirty || !committed {
+			t.Errorf("after setting the clock: dirty=%v committed=%v; want both true", dirty, committed)
+		}
+	})
+
+	t.Run("LoadRAM", func(t *testing.T) {
+		mmu := newMMU(0x13) // MBC3+RAM+BATTERY
+		mmu.BatteryRAM().LoadRAM([]uint8{0x11, 0x22})
+		mmu.Write(0x0000, 0x0A)
+		if got := mmu.Read(0xA001); got != 0x22 {
+			t.Errorf("Read(0xA001) = 0x%02X; want 0x22", got)
+		}
+	})
+}
+
+func TestMBC1M(t *testing.T) {
+	// 1MB multicart: 64 banks, each filled with its bank number
+	rom := make([]uint8, 64*0x4000)
+	for i := range rom {
+		rom[i] = uint8(i / 0x4000)
+	}
+	mbc := NewMBC1M(rom, 0)
+
+	tests := []struct {
+		name         string
+		bank1, bank2 uint8
+		mode         uint8
+		low, high    uint8 // banks read at 0x0000 and 0x4000
+	}{
+		{"default", 1, 0, 0, 0, 1},
+		{"bit 4 of the bank number is ignored", 0x12, 0, 0, 0, 2},
+		{"upper bits select the game", 3, 2, 0, 0, 0x23},
+		{"bank 0 of a game", 0x10, 1, 0, 0, 0x10},
+		{"mode 1 maps the game's first bank low", 5, 3, 1, 0x30, 0x35},
+		{"bank 0 translates to 1", 0, 0, 1, 0, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mbc.Write(0x2000, tt.bank1)
+			mbc.Write(0x4000, tt.bank2)
+			mbc.Write(0x6000, tt.mode)
+			if got := mbc.Read(0x0000); got != tt.low {
+				t.Errorf("Read(0x0000) = 0x%02X; want 0x%02X", got, tt.low)
+			}
+			if got := mbc.Read(0x4000); got != tt.high {
+				t.Errorf("Read(0x4000) = 0x%02X; want 0x%02X", got, tt.high)
+			}
+			if got := mbc.ROMBank(); got != int(tt.high) {
+				t.Errorf("ROMBank() = %d; want %d", got, tt.high)
+			}
+		})
+	}
+}
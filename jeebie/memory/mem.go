@@ -38,6 +38,18 @@ const (
 	JoypadStart
 )
 
+// serialRecorder is implemented by serial devices that keep a copy of the
+// data sent by the game, like serial.LogSink.
+type serialRecorder interface {
+	Output() []byte
+}
+
+// serialReplayer is implemented by serial devices with effects outside the
+// emulator, like files or network traffic, see SetReplaying.
+type serialReplayer interface {
+	SetReplaying(replaying bool)
+}
+
 // SerialPort is the minimal interface for a serial device connected to SB/SC.
 // Implementations MUST only accept reads/writes to addr.SB and addr.SC.
 type SerialPort interface {
@@ -52,6 +64,8 @@ type MMU struct {
 	cart      *Cartridge
 	mbc       MBC
 	memory    []byte
+	vram      []byte // 2 banks, only bank 0 is used in DMG mode
+	wram      []byte // 8 banks, only banks 0-1 are used in DMG mode
 	APU       *audio.APU
 	regionMap [256]memRegion
 
@@ -59,7 +73,22 @@ type MMU struct {
 	joypadDpad    uint8 // Actual state of d-pad directions, mapped to low bits of P1
 
 	serial SerialPort
+	rtc    *MBC3 // cartridge clock, nil without one
 	timer  Timer
+	cgb    cgbState
+	dma    oamDMA
+
+	bootROM       []byte
+	bootROMMapped bool
+
+	// debugger watchpoints, see WatchAddress
+	watch watchState
+	// ROM usage logging, see SetROMHook
+	romHook ROMHook
+
+	// battery RAM tracking, see SRAMStatus
+	sramDirty     bool
+	sramCommitted bool
 }
 
 // New creates a new memory unity with default data, i.e. nothing cartridge loaded.
@@ -67,6 +96,8 @@ type MMU struct {
 func New() *MMU {
 	mmu := &MMU{
 		memory:        make([]byte, 0x10000),
+		vram:          make([]byte, 2*vramBankSize),
+		wram:          make([]byte, 8*wramBankSize),
 		cart:          NewCartridge(),
 		APU:           audio.New(),
 		joypadButtons: 0x0F,
@@ -75,13 +106,43 @@ func New() *MMU {
 	mmu.serial = serial.NewLogSink(func() { mmu.RequestInterrupt(addr.SerialInterrupt) })
 	mmu.timer.TimerInterruptHandler = func() { mmu.RequestInterrupt(addr.TimerInterrupt) }
 	mmu.timer.SetSeed(0xABCC)
+	mmu.cgb.wramBank = 1
 	initRegionMap(mmu)
 	return mmu
 }
 
+// SerialOutput returns the data sent over serial so far, if the connected
+// device records it.
+func (m *MMU) SerialOutput() []byte {
+	if r, ok := m.serial.(serialRecorder); ok {
+		return r.Output()
+	}
+	return nil
+}
+
+// SetReplaying tells the serial device and the cartridge clock whether
+// instructions that already ran are being executed again, as when the
+// debugger steps back, so the serial device doesn't repeat its effects
+// outside the emulator and the clock doesn't read the host time.
+func (m *MMU) SetReplaying(replaying bool) {
+	if r, ok := m.serial.(serialReplayer); ok {
+		r.SetReplaying(replaying)
+	}
+	if m.rtc != nil {
+		m.rtc.SetReplaying(replaying)
+	}
+}
+
+// AttachSerial connects dev to the serial port in place of the default
+// serial.LogSink.
+func (m *MMU) AttachSerial(dev SerialPort) {
+	m.serial = dev
+}
+
 // Tick advances any i/o that needs it, if any.
 func (m *MMU) Tick(cycles int) {
 	m.timer.Tick(cycles)
+	m.tickOAMDMA(cycles)
 	if m.serial != nil {
 		m.serial.Tick(cycles)
 	}
@@ -89,7 +150,8 @@ func (m *MMU) Tick(cycles int) {
 
 // NewWithCartridge creates a new memory unit with the provided cartridge data loaded.
 // Equivalent to turning on a Gameboy with a cartridge in.
-func NewWithCartridge(cart *Cartridge) *MMU {
+// Returns an UnsupportedMBCError if the cartridge type is not implemented.
+func NewWithCartridge(cart *Cartridge) (*MMU, error) {
 	mmu := New()
 	mmu.cart = cart
 
@@ -99,20 +161,29 @@ func NewWithCartridge(cart *Cartridge) *MMU {
 	case MBC1Type:
 		mmu.mbc = NewMBC1(cart.data, cart.hasBattery, cart.ramBankCount)
 	case MBC1MultiType:
-		mmu.mbc = NewMBC1(cart.data, cart.hasBattery, cart.ramBankCount) // FIXME: add support for multicart
+		mmu.mbc = NewMBC1M(cart.data, cart.ramBankCount)
 	case MBC2Type:
 		mmu.mbc = NewMBC2(cart.data)
 	case MBC3Type:
-		mmu.mbc = NewMBC3(cart.data, cart.ramBankCount, cart.hasRTC, nil)
+		mbc := NewMBC3(cart.data, cart.ramBankCount, cart.hasRTC, nil)
+		if cart.hasRTC {
+			mmu.rtc = mbc
+		}
+		mmu.mbc = mbc
 	case MBC5Type:
 		mmu.mbc = NewMBC5(cart.data, cart.hasRumble, cart.ramBankCount)
-	case MBCUnknownType:
-		panic("unsupported MBC type: unknown")
 	default:
-		panic(fmt.Sprintf("unsupported MBC type: %d", cart.mbcType))
+		return nil, &UnsupportedMBCError{CartType: cart.cartType}
 	}
 
-	return mmu
+	mmu.SetCGBMode(cart.isCGB)
+
+	return mmu, nil
+}
+
+// Cartridge returns the loaded cartridge.
+func (m *MMU) Cartridge() *Cartridge {
+	return m.cart
 }
 
 func initRegionMap(m *MMU) {
@@ -144,7 +215,7 @@ func initRegionMap(m *MMU) {
 
 // RequestInterrupt sets the interrupt flag (IF register) of the chosen interrupt to 1.
 func (m *MMU) RequestInterrupt(interrupt addr.Interrupt) {
-	interruptFlags := m.Read(addr.IF)
+	interruptFlags := m.Peek(addr.IF)
 
 	var bitPos uint8
 	switch interrupt {
@@ -162,9 +233,9 @@ func (m *MMU) RequestInterrupt(interrupt addr.Interrupt) {
 		panic(fmt.Sprintf("Unknown interrupt: 0x%02X", uint8(interrupt)))
 	}
 
-	newFlags := bit.Set(bitPos, interruptFlags)
-
-	m.Write(addr.IF, newFlags)
+	// set directly rather than through Write: the request comes from the
+	// hardware, not the CPU, so it must not trigger watchpoints
+	m.memory[addr.IF] = bit.Set(bitPos, interruptFlags) | 0xE0
 }
 
 func (m *MMU) ReadBit(index uint8, address uint16) bool {
@@ -181,19 +252,46 @@ func (m *MMU) SetBit(index uint8, address uint16, set bool) {
 	m.Write(address, value)
 }
 
+// Read reads memory as seen by the CPU: VRAM and OAM may be unavailable while
+// the PPU or OAM DMA use them.
 func (m *MMU) Read(address uint16) byte {
+	value := m.read(address)
+	if m.romHook != nil {
+		m.logROM(address, ROMRead)
+	}
+	m.checkWatch(address, value, WatchRead)
+	return value
+}
+
+// read reads memory as the CPU sees it, with the PPU and OAM DMA access
+// restrictions but without reporting the access to watches or hooks.
+func (m *MMU) read(address uint16) byte {
+	if m.accessBlocked(address) {
+		return m.blockedRead(address)
+	}
+	return m.Peek(address)
+}
+
+// Peek reads memory ignoring the PPU and OAM DMA access restrictions. It is
+// used by the GPU, DMA and debugging tools.
+func (m *MMU) Peek(address uint16) byte {
 	switch m.regionMap[address>>8] {
 	case regionROM, regionExtRAM:
+		if value, ok := m.readBootROM(address); ok {
+			return value
+		}
 		if m.mbc == nil {
 			slog.Warn("Reading from ROM/external RAM with no cartridge", "addr", fmt.Sprintf("0x%04X", address))
 			return 0xFF
 		}
 		return m.mbc.Read(address)
-	case regionVRAM, regionWRAM:
-		return m.memory[address]
+	case regionVRAM:
+		return m.vram[m.vramOffset(address)]
+	case regionWRAM:
+		return m.wram[m.wramOffset(address)]
 	case regionEcho:
 		if address <= 0xFDFF {
-			return m.memory[address-0x2000]
+			return m.wram[m.wramOffset(address-0x2000)]
 		}
 		return m.memory[address-0x2000]
 	case regionOAM:
@@ -212,6 +310,12 @@ func (m *MMU) Read(address uint16) byte {
 		if address >= 0xFF10 && address <= 0xFF3F {
 			return m.APU.ReadRegister(address)
 		}
+		if m.cgb.enabled && isCGBRegister(address) {
+			return m.readCGB(address)
+		}
+		if address == addr.BOOT {
+			return 0xFF
+		}
 		// Just in case, we always read the upper 3 bits of IF as 1.
 		// They're not used, but have caused me some headaches when checking for
 		// when the halt bug triggers (IF != 0).
@@ -230,26 +334,65 @@ func (m *MMU) Read(address uint16) byte {
 }
 
 func (m *MMU) Write(address uint16, value byte) {
+	m.checkWatch(address, value, WatchWrite)
+	if m.accessBlocked(address) {
+		return
+	}
+	m.write(address, value)
+}
+
+// Poke writes memory for debugging tools, the counterpart of Peek. It
+// ignores the PPU and OAM DMA access restrictions, and writes to 0x0000-0x7FFF
+// patch the ROM bank mapped there instead of reaching the memory bank
+// controller registers.
+func (m *MMU) Poke(address uint16, value byte) {
+	if m.regionMap[address>>8] == regionROM {
+		m.patchROM(address, value)
+		return
+	}
+	m.write(address, value)
+}
+
+// patchROM changes the byte of the ROM read at address, in the bank mapped
+// there.
+func (m *MMU) patchROM(address uint16, value byte) {
+	if m.cart == nil || len(m.cart.data) == 0 {
+		return
+	}
+	bank := m.LowROMBank()
+	if address >= 0x4000 {
+		bank = m.ROMBank()
+		address -= 0x4000
+	}
+	rom := m.cart.data
+	if i := bank*0x4000%len(rom) + int(address); i < len(rom) {
+		rom[i] = value
+	}
+}
+
+// write writes memory without the access restrictions and without reporting
+// the access to watches.
+func (m *MMU) write(address uint16, value byte) {
 	switch m.regionMap[address>>8] {
 	case regionROM:
 		if m.mbc == nil {
 			slog.Warn("Writing to ROM with no cartridge", "addr", fmt.Sprintf("0x%04X", address), "value", fmt.Sprintf("0x%02X", value))
 			return
 		}
-		m.mbc.Write(address, value)
+		m.writeCartridge(address, value)
 	case regionVRAM:
-		m.memory[address] = value
+		m.vram[m.vramOffset(address)] = value
 	case regionExtRAM:
 		if m.mbc == nil {
 			slog.Warn("Writing to external RAM with no cartridge", "addr", fmt.Sprintf("0x%04X", address), "value", fmt.Sprintf("0x%02X", value))
 			return
 		}
-		m.mbc.Write(address, value)
+		m.writeCartridge(address, value)
 	case regionWRAM:
-		m.memory[address] = value
+		m.wram[m.wramOffset(address)] = value
 	case regionEcho:
 		if address <= 0xFDFF {
-			m.memory[address-0x2000] = value
+			m.wram[m.wramOffset(address-0x2000)] = value
 		}
 	case regionOAM:
 		if address <= 0xFE9F {
@@ -275,19 +418,31 @@ func (m *MMU) Write(address uint16, value byte) {
 			m.APU.WriteRegister(address, value)
 			return
 		}
+		if m.cgb.enabled && isCGBRegister(address) {
+			m.writeCGB(address, value)
+			return
+		}
 		if address == addr.IF {
 			// This goddamn register has its upper 3 bits always set as 1...
 			// Beware if you're trying to match halt bug behavior.
 			m.memory[address] = value | 0xE0
 			return
 		}
+		if address == addr.STAT {
+			// the mode and LYC match bits are set by the PPU, see SetPPUStatus
+			m.memory[address] = value&^ppuStatusMask | m.memory[address]&ppuStatusMask
+			return
+		}
 		if address == addr.DMA {
-			sourceAddr := uint16(value) << 8
-			// DMA transfer copies 160 bytes from source to OAM
-			for i := range uint16(160) {
-				m.memory[0xFE00+i] = m.Read(sourceAddr + i)
-			}
 			m.memory[address] = value
+			m.startOAMDMA(value)
+			return
+		}
+		if address == addr.BOOT {
+			// the boot ROM can only be unmapped, until the next reset
+			if value != 0 {
+				m.bootROMMapped = false
+			}
 			return
 		}
 		if address >= 0xFF80 {
@@ -350,6 +505,14 @@ func (m *MMU) writeJoypad(value uint8) {
 	m.updateJoypadRegister()
 }
 
+// KeyPressed reports whether a joypad key is held down.
+func (m *MMU) KeyPressed(key JoypadKey) bool {
+	if key >= JoypadA {
+		return !bit.IsSet(uint8(key-JoypadA), m.joypadButtons)
+	}
+	return !bit.IsSet(uint8(key), m.joypadDpad)
+}
+
 func (m *MMU) HandleKeyPress(key JoypadKey) {
 	oldButtons := m.joypadButtons
 	oldDpad := m.joypadDpad
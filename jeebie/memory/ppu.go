@@ -0,0 +1,79 @@
+package memory
+
+import (
+	"github.com/valerio/go-jeebie/jeebie/addr"
+	"github.com/valerio/go-jeebie/jeebie/bit"
+)
+
+// PPU modes as reported in STAT bits 1-0.
+const (
+	ppuModeOAMScan = 2
+	ppuModeDrawing = 3
+)
+
+// vramLocked reports whether the PPU owns VRAM, i.e. it is drawing (mode 3).
+// CPU reads then return 0xFF and writes are ignored.
+func (m *MMU) vramLocked() bool {
+	return m.lcdEnabled() && m.memory[addr.STAT]&0x03 == ppuModeDrawing
+}
+
+// oamLocked reports whether the PPU owns OAM, i.e. it is scanning OAM or
+// drawing (modes 2 and 3).
+func (m *MMU) oamLocked() bool {
+	return m.lcdEnabled() && m.memory[addr.STAT]&0x03 >= ppuModeOAMScan
+}
+
+// ppuStatusMask covers the STAT bits owned by the PPU: the mode (bits 1-0)
+// and the LYC match flag (bit 2). They are read-only for the CPU.
+const ppuStatusMask = 0x07
+
+// SetPPUStatus sets the mode and LYC match bits of STAT, which CPU writes
+// leave untouched. It should only be called by the PPU.
+func (m *MMU) SetPPUStatus(status byte) {
+	m.memory[addr.STAT] = m.memory[addr.STAT]&^ppuStatusMask | status&ppuStatusMask
+}
+
+func (m *MMU) lcdEnabled() bool {
+	return bit.IsSet(7, m.memory[addr.LCDC])
+}
+
+// accessBlocked reports whether the CPU is cut off from address, because the
+// PPU or an OAM DMA transfer is using it. Reads then return blockedRead, and
+// writes are ignored.
+func (m *MMU) accessBlocked(address uint16) bool {
+	switch {
+	case m.dmaConflict(address):
+		return true
+	case isVRAMAddress(address):
+		return m.vramLocked()
+	case address >= addr.OAMStart && address <= 0xFE9F:
+		return m.oamLocked()
+	}
+	return false
+}
+
+func (m *MMU) blockedRead(address uint16) byte {
+	if m.dmaConflict(address) && address < addr.OAMStart {
+		return m.dma.value
+	}
+	return 0xFF
+}
+
+// DebugView returns a read-only view of memory for debugging tools, which
+// can see VRAM and OAM regardless of the PPU mode.
+func (m *MMU) DebugView() DebugView {
+	return DebugView{m}
+}
+
+// DebugView reads memory through MMU.Peek.
+type DebugView struct {
+	mmu *MMU
+}
+
+func (v DebugView) Read(address uint16) byte {
+	return v.mmu.Peek(address)
+}
+
+func (v DebugView) ReadBit(index uint8, address uint16) bool {
+	return bit.IsSet(index, v.mmu.Peek(address))
+}
@@ -0,0 +1,84 @@
+package memory
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/valerio/go-jeebie/jeebie/addr"
+)
+
+func TestPPUAccessBlocking(t *testing.T) {
+	tests := []struct {
+		name       string
+		lcdc       byte
+		mode       byte
+		vramLocked bool
+		oamLocked  bool
+	}{
+		{"hblank", 0x91, 0, false, false},
+		{"vblank", 0x91, 1, false, false},
+		{"OAM scan", 0x91, 2, false, true},
+		{"drawing", 0x91, 3, true, true},
+		{"drawing, LCD off", 0x11, 3, false, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := New()
+			m.Write(0x8000, 0x12)
+			m.Write(addr.OAMStart, 0x34)
+
+			m.Write(addr.LCDC, tt.lcdc)
+			m.SetPPUStatus(tt.mode)
+
+			m.Write(0x8000, 0x56)
+			m.Write(addr.OAMStart, 0x78)
+
+			if tt.vramLocked {
+				assert.Equal(t, uint8(0xFF), m.Read(0x8000))
+				assert.Equal(t, uint8(0x12), m.Peek(0x8000), "write should be dropped")
+			} else {
+				assert.Equal(t, uint8(0x56), m.Read(0x8000))
+			}
+
+			if tt.oamLocked {
+				assert.Equal(t, uint8(0xFF), m.Read(addr.OAMStart))
+				assert.Equal(t, uint8(0x34), m.DebugView().Read(addr.OAMStart), "write should be dropped")
+			} else {
+				assert.Equal(t, uint8(0x78), m.Read(addr.OAMStart))
+			}
+		})
+	}
+}
+
+func TestPokeIgnoresAccessBlocking(t *testing.T) {
+	m := New()
+	m.Write(addr.LCDC, 0x91)
+	m.SetPPUStatus(ppuModeDrawing)
+
+	m.Poke(0x8000, 0x56)
+	m.Poke(addr.OAMStart, 0x78)
+	assert.Equal(t, uint8(0x56), m.Peek(0x8000))
+	assert.Equal(t, uint8(0x78), m.Peek(addr.OAMStart))
+}
+
+func TestSTATWriteKeepsPPUBits(t *testing.T) {
+	m := New()
+	m.Write(0x8000, 0x12)
+	m.Write(addr.OAMStart, 0x34)
+	m.Write(addr.LCDC, 0x91)
+	m.SetPPUStatus(0x04 | ppuModeDrawing)
+
+	// a game enabling STAT interrupts can't clear the mode and unlock memory
+	m.Write(addr.STAT, 0x40)
+	assert.Equal(t, uint8(0x44|ppuModeDrawing), m.Read(addr.STAT))
+
+	m.Write(0x8000, 0x56)
+	m.Write(addr.OAMStart, 0x78)
+	assert.Equal(t, uint8(0x12), m.Peek(0x8000), "VRAM stays locked")
+	assert.Equal(t, uint8(0x34), m.DebugView().Read(addr.OAMStart), "OAM stays locked")
+
+	m.SetPPUStatus(0)
+	assert.Equal(t, uint8(0x40), m.Read(addr.STAT))
+	assert.Equal(t, uint8(0x12), m.Read(0x8000))
+}
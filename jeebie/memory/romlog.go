@@ -0,0 +1,36 @@
+package memory
+
+// ROMAccess is how a ROM byte was used, see SetROMHook.
+type ROMAccess uint8
+
+const (
+	ROMRead ROMAccess = iota + 1 // read by the CPU, as an instruction or data
+	ROMDMA                       // copied by OAM DMA or CGB HDMA
+)
+
+// ROMHook is called when cartridge ROM is read, with the address on the bus
+// and the ROM bank mapped there.
+type ROMHook func(address uint16, bank int, access ROMAccess)
+
+// SetROMHook sets the function called on ROM reads by the CPU and DMA, or
+// removes it when hook is nil. Reads through Peek, as done by the PPU and
+// debug tools, aren't reported.
+func (m *MMU) SetROMHook(hook ROMHook) {
+	m.romHook = hook
+}
+
+// logROM reports a ROM read to the ROM hook, unless address isn't in ROM or
+// is covered by the boot ROM.
+func (m *MMU) logROM(address uint16, access ROMAccess) {
+	if m.romHook == nil || address >= 0x8000 || m.mbc == nil {
+		return
+	}
+	if _, ok := m.readBootROM(address); ok {
+		return
+	}
+	bank := m.LowROMBank()
+	if address >= 0x4000 {
+		bank = m.ROMBank()
+	}
+	m.romHook(address, bank, access)
+}
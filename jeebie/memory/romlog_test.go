@@ -0,0 +1,68 @@
+package memory
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/valerio/go-jeebie/jeebie/addr"
+)
+
+func TestROMHook(t *testing.T) {
+	rom := make([]byte, 0x10000) // 4 banks
+	copy(rom, newTestROM(0x01))  // MBC1
+	cart, err := NewCartridgeWithData(rom)
+	require.NoError(t, err)
+	m, err := NewWithCartridge(cart)
+	require.NoError(t, err)
+
+	type access struct {
+		address uint16
+		bank    int
+		access  ROMAccess
+	}
+	var hits []access
+	m.SetROMHook(func(address uint16, bank int, a ROMAccess) {
+		hits = append(hits, access{address, bank, a})
+	})
+
+	m.Read(0x0150)
+	m.Write(0x2000, 0x03)
+	m.Read(0x4010)
+	m.Peek(0x4011)
+	m.Read(0xC000)
+	assert.Equal(t, []access{{0x0150, 0, ROMRead}, {0x4010, 3, ROMRead}}, hits, "only CPU reads of ROM are reported")
+
+	hits = nil
+	m.Write(addr.DMA, 0x40)
+	m.Tick(4 * (oamDMAStartDelay + 2))
+	assert.Equal(t, []access{{0x4000, 3, ROMDMA}, {0x4001, 3, ROMDMA}}, hits)
+
+	hits = nil
+	m.SetROMHook(nil)
+	m.Read(0x0150)
+	assert.Empty(t, hits)
+}
+
+func TestROMHookMulticartLowBank(t *testing.T) {
+	rom := make([]byte, 0x100000)
+	copy(rom, newTestROM(0x01))
+	for game := range 4 {
+		copy(rom[game*multicartGameSize+logoAddress:], nintendoLogo)
+	}
+	cart, err := NewCartridgeWithData(rom)
+	require.NoError(t, err)
+	m, err := NewWithCartridge(cart)
+	require.NoError(t, err)
+
+	var banks []int
+	m.SetROMHook(func(address uint16, bank int, a ROMAccess) {
+		banks = append(banks, bank)
+	})
+
+	m.Read(0x0150)
+	m.Write(0x4000, 0x02) // third game
+	m.Write(0x6000, 0x01) // mode 1 maps its first bank low
+	m.Read(0x0150)
+	assert.Equal(t, []int{0, 0x20}, banks)
+}
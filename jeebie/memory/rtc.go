@@ -0,0 +1,169 @@
+package memory
+
+import (
+	"encoding/binary"
+	"errors"
+	"time"
+)
+
+// MBC3 RTC registers, selected with RAM banks 0x08-0x0C.
+const (
+	rtcSeconds = iota
+	rtcMinutes
+	rtcHours
+	rtcDaysLow
+	rtcDaysHigh
+)
+
+// Bits of the upper days register.
+const (
+	rtcDayHigh = 0x01 // bit 8 of the day counter
+	rtcHalt    = 0x40 // stops the clock
+	rtcCarry   = 0x80 // the day counter overflowed, until cleared by the game
+)
+
+// rtcMasks are the bits each RTC register holds, the others read as 0.
+var rtcMasks = [5]uint8{0x3F, 0x3F, 0x1F, 0xFF, rtcDayHigh | rtcHalt | rtcCarry}
+
+// RTC footer sizes. The footer holds the running and latched registers as
+// 32 bit values, followed by the UNIX time they were saved at. Older
+// versions of VBA wrote a 32 bit timestamp.
+const (
+	rtcFooterSize      = 48
+	rtcFooterSizeShort = 44
+)
+
+// ErrRTCFooter is returned when loading an RTC footer of an unknown size.
+var ErrRTCFooter = errors.New("invalid RTC footer size")
+
+// latchRTC copies the running counters to the registers read by the game.
+func (m *MBC3) latchRTC() {
+	m.updateRTC()
+	m.latched = m.rtc
+}
+
+// writeRTC sets an RTC register. Writing the seconds also restarts the
+// current second.
+func (m *MBC3) writeRTC(register int, value uint8) {
+	m.updateRTC()
+	if register == rtcSeconds {
+		m.rtcTime = m.clock.Now()
+	}
+	value &= rtcMasks[register]
+	m.rtc[register] = value
+	m.latched[register] = value
+}
+
+// SetReplaying freezes the clock while instructions that already ran are
+// executed again, so a replay doesn't read the host time. The counters catch
+// up with it once the replay is over.
+func (m *MBC3) SetReplaying(replaying bool) {
+	m.replaying = replaying
+}
+
+// updateRTC advances the counters by the whole seconds elapsed on the host
+// clock since the last update, unless halted or replaying.
+func (m *MBC3) updateRTC() {
+	if m.replaying {
+		return
+	}
+	now := m.clock.Now()
+	if m.rtc[rtcDaysHigh]&rtcHalt != 0 || now.Before(m.rtcTime) {
+		m.rtcTime = now
+		return
+	}
+	elapsed := int64(now.Sub(m.rtcTime) / time.Second)
+	m.rtcTime = m.rtcTime.Add(time.Duration(elapsed) * time.Second)
+	m.advanceRTC(elapsed)
+}
+
+// advanceRTC adds seconds to the counters.
+func (m *MBC3) advanceRTC(seconds int64) {
+	// counters set out of range by the game count up to their mask first
+	for ; seconds > 0 && !m.rtcInRange(); seconds-- {
+		m.tickRTC()
+	}
+	if seconds == 0 {
+		return
+	}
+
+	r := &m.rtc
+	days := int64(r[rtcDaysLow]) | int64(r[rtcDaysHigh]&rtcDayHigh)<<8
+	total := int64(r[rtcSeconds]) + 60*int64(r[rtcMinutes]) + 3600*int64(r[rtcHours]) + 86400*days + seconds
+	r[rtcSeconds] = uint8(total % 60)
+	r[rtcMinutes] = uint8(total / 60 % 60)
+	r[rtcHours] = uint8(total / 3600 % 24)
+	m.setRTCDays(total / 86400)
+}
+
+// tickRTC adds a second to the counters like the hardware does: a counter
+// only carries into the next one when reaching its limit, and wraps at its
+// mask without carrying if set past it.
+func (m *MBC3) tickRTC() {
+	r := &m.rtc
+	r[rtcSeconds] = (r[rtcSeconds] + 1) & rtcMasks[rtcSeconds]
+	if r[rtcSeconds] != 60 {
+		return
+	}
+	r[rtcSeconds] = 0
+	r[rtcMinutes] = (r[rtcMinutes] + 1) & rtcMasks[rtcMinutes]
+	if r[rtcMinutes] != 60 {
+		return
+	}
+	r[rtcMinutes] = 0
+	r[rtcHours] = (r[rtcHours] + 1) & rtcMasks[rtcHours]
+	if r[rtcHours] != 24 {
+		return
+	}
+	r[rtcHours] = 0
+	m.setRTCDays(int64(r[rtcDaysLow]) | int64(r[rtcDaysHigh]&rtcDayHigh)<<8 + 1)
+}
+
+func (m *MBC3) rtcInRange() bool {
+	return m.rtc[rtcSeconds] < 60 && m.rtc[rtcMinutes] < 60 && m.rtc[rtcHours] < 24
+}
+
+// setRTCDays sets the 9 bit day counter, setting the carry bit if it
+// overflowed.
+func (m *MBC3) setRTCDays(days int64) {
+	if days > 0x1FF {
+		m.rtc[rtcDaysHigh] |= rtcCarry
+		days %= 0x200
+	}
+	m.rtc[rtcDaysLow] = uint8(days)
+	m.rtc[rtcDaysHigh] = m.rtc[rtcDaysHigh]&^rtcDayHigh | uint8(days>>8)&rtcDayHigh
+}
+
+// RTCFooter returns the clock state in the 48 byte footer BGB and VBA-M
+// append to .sav files.
+func (m *MBC3) RTCFooter() []byte {
+	m.updateRTC()
+	footer := make([]byte, rtcFooterSize)
+	for i := range m.rtc {
+		binary.LittleEndian.PutUint32(footer[i*4:], uint32(m.rtc[i]))
+		binary.LittleEndian.PutUint32(footer[20+i*4:], uint32(m.latched[i]))
+	}
+	binary.LittleEndian.PutUint64(footer[40:], uint64(m.rtcTime.Unix()))
+	return footer
+}
+
+// LoadRTCFooter restores the clock from a footer written by RTCFooter or
+// another emulator, advancing it by the time elapsed since it was saved.
+func (m *MBC3) LoadRTCFooter(footer []byte) error {
+	var saved int64
+	switch len(footer) {
+	case rtcFooterSize:
+		saved = int64(binary.LittleEndian.Uint64(footer[40:]))
+	case rtcFooterSizeShort:
+		saved = int64(binary.LittleEndian.Uint32(footer[40:]))
+	default:
+		return ErrRTCFooter
+	}
+	for i := range m.rtc {
+		m.rtc[i] = uint8(binary.LittleEndian.Uint32(footer[i*4:])) & rtcMasks[i]
+		m.latched[i] = uint8(binary.LittleEndian.Uint32(footer[20+i*4:])) & rtcMasks[i]
+	}
+	m.rtcTime = time.Unix(saved, 0)
+	m.updateRTC()
+	return nil
+}
@@ -0,0 +1,146 @@
+package memory
+
+import (
+	"encoding/binary"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeClock struct {
+	now time.Time
+}
+
+func (c *fakeClock) Now() time.Time { return c.now }
+
+func newRTC(t *testing.T) (*MBC3, *fakeClock) {
+	t.Helper()
+	clock := &fakeClock{now: time.Unix(1_700_000_000, 0)}
+	mbc := NewMBC3(make([]uint8, 0x8000), 1, true, clock)
+	mbc.Write(0x0000, 0x0A)
+	return mbc, clock
+}
+
+func latch(m *MBC3) {
+	m.Write(0x6000, 0x00)
+	m.Write(0x6000, 0x01)
+}
+
+// readRTC returns the latched seconds, minutes, hours, days low and high.
+func readRTC(m *MBC3) [5]uint8 {
+	var regs [5]uint8
+	for i := range regs {
+		m.Write(0x4000, uint8(0x08+i))
+		regs[i] = m.Read(0xA000)
+	}
+	return regs
+}
+
+func writeRTC(m *MBC3, register int, value uint8) {
+	m.Write(0x4000, uint8(0x08+register))
+	m.Write(0xA000, value)
+}
+
+func TestRTCLatch(t *testing.T) {
+	mbc, clock := newRTC(t)
+	clock.now = clock.now.Add(26*time.Hour + 3*time.Minute + 4*time.Second + 500*time.Millisecond)
+	assert.Equal(t, [5]uint8{}, readRTC(mbc), "registers only change when latched")
+
+	mbc.Write(0x6000, 0x01)
+	assert.Equal(t, [5]uint8{}, readRTC(mbc), "latching needs 0x00 then 0x01")
+
+	latch(mbc)
+	assert.Equal(t, [5]uint8{4, 3, 2, 1, 0}, readRTC(mbc))
+
+	clock.now = clock.now.Add(500 * time.Millisecond)
+	latch(mbc)
+	assert.Equal(t, uint8(5), readRTC(mbc)[rtcSeconds], "fractions of a second add up")
+}
+
+func TestRTCHalt(t *testing.T) {
+	mbc, clock := newRTC(t)
+	writeRTC(mbc, rtcDaysHigh, rtcHalt)
+	clock.now = clock.now.Add(time.Hour)
+	latch(mbc)
+	assert.Equal(t, [5]uint8{0, 0, 0, 0, rtcHalt}, readRTC(mbc))
+
+	writeRTC(mbc, rtcDaysHigh, 0)
+	clock.now = clock.now.Add(5 * time.Second)
+	latch(mbc)
+	assert.Equal(t, [5]uint8{5, 0, 0, 0, 0}, readRTC(mbc), "time spent halted is not counted")
+}
+
+func TestRTCReplaying(t *testing.T) {
+	mbc, clock := newRTC(t)
+	mbc.SetReplaying(true)
+	clock.now = clock.now.Add(3 * time.Second)
+	latch(mbc)
+	assert.Equal(t, [5]uint8{}, readRTC(mbc), "the clock is frozen during replays")
+
+	mbc.SetReplaying(false)
+	latch(mbc)
+	assert.Equal(t, [5]uint8{3, 0, 0, 0, 0}, readRTC(mbc), "host time catches up after the replay")
+}
+
+func TestRTCDayCarry(t *testing.T) {
+	mbc, clock := newRTC(t)
+	writeRTC(mbc, rtcSeconds, 59)
+	writeRTC(mbc, rtcMinutes, 59)
+	writeRTC(mbc, rtcHours, 23)
+	writeRTC(mbc, rtcDaysLow, 0xFF)
+	writeRTC(mbc, rtcDaysHigh, rtcDayHigh)
+
+	clock.now = clock.now.Add(time.Second)
+	latch(mbc)
+	assert.Equal(t, [5]uint8{0, 0, 0, 0, rtcCarry}, readRTC(mbc), "day 511 wraps to 0 with carry")
+
+	clock.now = clock.now.Add(3 * 24 * time.Hour)
+	latch(mbc)
+	assert.Equal(t, [5]uint8{0, 0, 0, 3, rtcCarry}, readRTC(mbc), "carry stays set")
+
+	writeRTC(mbc, rtcDaysHigh, 0)
+	assert.Equal(t, [5]uint8{0, 0, 0, 3, 0}, readRTC(mbc), "the game clears the carry")
+}
+
+func TestRTCWrite(t *testing.T) {
+	mbc, clock := newRTC(t)
+	writeRTC(mbc, rtcSeconds, 0xFF)
+	writeRTC(mbc, rtcHours, 0xFF)
+	writeRTC(mbc, rtcDaysHigh, 0xFF)
+	assert.Equal(t, [5]uint8{0x3F, 0, 0x1F, 0, 0xC1}, readRTC(mbc), "unused bits are dropped")
+
+	// out of range counters wrap at their mask without carrying
+	writeRTC(mbc, rtcDaysHigh, 0)
+	clock.now = clock.now.Add(time.Second)
+	latch(mbc)
+	assert.Equal(t, [5]uint8{0, 0, 0x1F, 0, 0}, readRTC(mbc))
+
+	clock.now = clock.now.Add(60 * 60 * time.Second)
+	latch(mbc)
+	assert.Equal(t, [5]uint8{0, 0, 0, 0, 0}, readRTC(mbc), "hour 31 wraps to 0 without a day")
+}
+
+func TestRTCFooter(t *testing.T) {
+	mbc, clock := newRTC(t)
+	writeRTC(mbc, rtcMinutes, 10)
+	latch(mbc)
+	footer := mbc.RTCFooter()
+	require.Len(t, footer, rtcFooterSize)
+	assert.Equal(t, uint32(10), binary.LittleEndian.Uint32(footer[4:]))
+	assert.Equal(t, uint32(10), binary.LittleEndian.Uint32(footer[24:]), "latched minutes")
+	assert.Equal(t, uint64(clock.now.Unix()), binary.LittleEndian.Uint64(footer[40:]))
+
+	// loaded two days and an hour later
+	loaded, later := newRTC(t)
+	later.now = clock.now.Add(49 * time.Hour)
+	require.NoError(t, loaded.LoadRTCFooter(footer))
+	assert.Equal(t, [5]uint8{0, 10, 0, 0, 0}, readRTC(loaded), "latched registers are restored")
+	latch(loaded)
+	assert.Equal(t, [5]uint8{0, 10, 1, 2, 0}, readRTC(loaded), "the clock catches up")
+
+	short := append(footer[:40:40], footer[40:44]...)
+	assert.NoError(t, loaded.LoadRTCFooter(short), "32 bit timestamps are accepted")
+	assert.ErrorIs(t, loaded.LoadRTCFooter(footer[:20]), ErrRTCFooter)
+}
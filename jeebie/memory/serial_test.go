@@ -0,0 +1,34 @@
+package memory
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/valerio/go-jeebie/jeebie/addr"
+)
+
+// fakeSerial records accesses to the serial port.
+type fakeSerial struct {
+	writes map[uint16]byte
+	cycles int
+}
+
+func (f *fakeSerial) Write(address uint16, value byte) { f.writes[address] = value }
+func (f *fakeSerial) Read(address uint16) byte         { return f.writes[address] ^ 0xFF }
+func (f *fakeSerial) Tick(cycles int)                  { f.cycles += cycles }
+func (f *fakeSerial) Reset()                           {}
+
+func TestAttachSerial(t *testing.T) {
+	mmu := New()
+	dev := &fakeSerial{writes: make(map[uint16]byte)}
+	mmu.AttachSerial(dev)
+
+	mmu.Write(addr.SB, 0x12)
+	mmu.Write(addr.SC, 0x81)
+	assert.Equal(t, map[uint16]byte{addr.SB: 0x12, addr.SC: 0x81}, dev.writes)
+	assert.Equal(t, byte(0xED), mmu.Read(addr.SB))
+
+	mmu.Tick(16)
+	assert.Equal(t, 16, dev.cycles)
+	assert.Nil(t, mmu.SerialOutput(), "the device doesn't record output")
+}
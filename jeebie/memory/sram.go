@@ -0,0 +1,101 @@
+package memory
+
+// BatteryRAM is implemented by memory bank controllers whose external RAM can
+// be kept across power cycles by a battery on the cartridge.
+// RAM returns the raw SRAM contents, in the same layout other emulators use
+// for .sav files, so saves can be moved between tools.
+type BatteryRAM interface {
+	RAM() []uint8
+	LoadRAM(data []uint8)
+	RAMEnabled() bool
+}
+
+// BatteryRAM returns the battery-backed RAM of the loaded cartridge, or nil if
+// the cartridge has no battery or no external RAM.
+func (m *MMU) BatteryRAM() BatteryRAM {
+	if m.cart == nil || !m.cart.hasBattery {
+		return nil
+	}
+	sram, ok := m.mbc.(BatteryRAM)
+	if !ok || len(sram.RAM()) == 0 {
+		return nil
+	}
+	return sram
+}
+
+// BatteryRTC is implemented by memory bank controllers with a battery-backed
+// real-time clock. Its state is kept as a footer after the RAM in .sav files,
+// in the layout used by BGB and VBA-M.
+type BatteryRTC interface {
+	RTCFooter() []byte
+	LoadRTCFooter(footer []byte) error
+}
+
+// BatteryRTC returns the battery-backed clock of the loaded cartridge, or nil
+// if the cartridge has no battery or no clock.
+func (m *MMU) BatteryRTC() BatteryRTC {
+	if m.cart == nil || !m.cart.hasBattery || !m.cart.hasRTC {
+		return nil
+	}
+	rtc, ok := m.mbc.(BatteryRTC)
+	if !ok {
+		return nil
+	}
+	return rtc
+}
+
+// SRAMStatus reports whether battery RAM was written since the last save
+// (dirty), and whether the game has disabled RAM access after writing to it
+// (committed). Games disable RAM once they are done saving, which makes it the
+// best moment to persist the contents.
+func (m *MMU) SRAMStatus() (dirty, committed bool) {
+	return m.sramDirty, m.sramCommitted
+}
+
+// MarkSRAMSaved resets the tracking reported by SRAMStatus, to be called after
+// the battery RAM contents have been persisted.
+func (m *MMU) MarkSRAMSaved() {
+	m.sramDirty = false
+	m.sramCommitted = false
+}
+
+// writeCartridge writes to the memory bank controller, tracking the writes
+// that concern the battery save, see SRAMStatus.
+func (m *MMU) writeCartridge(address uint16, value byte) {
+	wasEnabled := m.cartRAMEnabled()
+	m.mbc.Write(address, value)
+	m.trackSRAMWrite(address, wasEnabled)
+}
+
+// cartRAMEnabled reports whether the memory bank controller has enabled
+// access to the external RAM and clock registers.
+func (m *MMU) cartRAMEnabled() bool {
+	sram, ok := m.mbc.(BatteryRAM)
+	return ok && sram.RAMEnabled()
+}
+
+// trackSRAMWrite updates dirty/committed state after a write to the
+// cartridge. Writes to external RAM or to the clock make the save dirty, and
+// disabling RAM access commits it. The RAM enable register is decoded by the
+// memory bank controller, as its address range differs between them.
+func (m *MMU) trackSRAMWrite(address uint16, wasEnabled bool) {
+	if m.BatteryRAM() == nil && m.BatteryRTC() == nil {
+		return
+	}
+
+	enabled := m.cartRAMEnabled()
+	switch {
+	case address >= 0xA000 && address <= 0xBFFF:
+		// RAM or clock registers
+		if enabled {
+			m.sramDirty = true
+		}
+	case address >= 0x6000 && address <= 0x7FFF && m.BatteryRTC() != nil:
+		// clock latch
+		m.sramDirty = true
+	case wasEnabled && !enabled:
+		if m.sramDirty {
+			m.sramCommitted = true
+		}
+	}
+}
@@ -0,0 +1,126 @@
+package memory
+
+import (
+	"errors"
+	"time"
+
+	"github.com/valerio/go-jeebie/jeebie/state"
+)
+
+// SerializeState saves or restores memory, joypad, timer, serial, cartridge
+// banking state and the APU.
+// The cartridge ROM itself is not included: states are only valid for the ROM
+// they were taken from.
+func (m *MMU) SerializeState(s *state.Serializer) {
+	s.Bytes(m.memory)
+	s.Bytes(m.vram)
+	s.Bytes(m.wram)
+	m.cgb.SerializeState(s)
+	m.dma.SerializeState(s)
+	s.Bool(&m.bootROMMapped)
+	s.Uint8(&m.joypadButtons)
+	s.Uint8(&m.joypadDpad)
+
+	m.timer.SerializeState(s)
+	serializeOptional(s, m.serial)
+	serializeOptional(s, m.mbc)
+	m.APU.SerializeState(s)
+}
+
+// serializeOptional handles components that may or may not carry state
+// (e.g. NoMBC, or a serial device without transfer state), storing a presence
+// flag so a mismatch is detected on load.
+func serializeOptional(s *state.Serializer, component any) {
+	stateful, ok := component.(state.Serializable)
+	present := ok
+	s.Bool(&present)
+	if present != ok {
+		s.Fail(errors.New("state: component layout mismatch"))
+		return
+	}
+	if ok {
+		stateful.SerializeState(s)
+	}
+}
+
+func (c *cgbState) SerializeState(s *state.Serializer) {
+	s.Bool(&c.enabled)
+	s.Uint8(&c.vramBank)
+	s.Uint8(&c.wramBank)
+	s.Bytes(c.bgPalette[:])
+	s.Bytes(c.objPalette[:])
+	s.Uint8(&c.bcps)
+	s.Uint8(&c.ocps)
+	s.Uint8(&c.opri)
+	s.Bool(&c.doubleSpeed)
+	s.Bool(&c.speedArmed)
+	s.Uint16(&c.hdmaSource)
+	s.Uint16(&c.hdmaDest)
+	s.Bool(&c.hdmaActive)
+	s.Uint8(&c.hdmaRemaining)
+	s.Int(&c.stallCycles)
+}
+
+func (t *Timer) SerializeState(s *state.Serializer) {
+	s.Uint16(&t.systemCounter)
+	s.Bool(&t.lastTimerBitIsSet)
+	s.Int(&t.timaOverflow)
+	s.Uint8(&t.tima)
+	s.Uint8(&t.tma)
+	s.Uint8(&t.tac)
+}
+
+func (m *MBC1) SerializeState(s *state.Serializer) {
+	s.Uint8(&m.romBank)
+	s.Uint8(&m.ramBank)
+	s.Bool(&m.ramEnabled)
+	s.Uint8(&m.bankingMode)
+	s.Bytes(m.ram)
+}
+
+func (m *MBC1M) SerializeState(s *state.Serializer) {
+	s.Uint8(&m.bank1)
+	s.Uint8(&m.bank2)
+	s.Bool(&m.ramEnabled)
+	s.Uint8(&m.bankingMode)
+	s.Bytes(m.ram)
+}
+
+func (m *MBC2) SerializeState(s *state.Serializer) {
+	s.Uint8(&m.romBank)
+	s.Bool(&m.ramEnabled)
+	s.Bytes(m.ram)
+}
+
+func (m *MBC3) SerializeState(s *state.Serializer) {
+	s.Uint8(&m.romBank)
+	s.Uint8(&m.ramBank)
+	s.Bool(&m.ramEnabled)
+	s.Bytes(m.rtc[:])
+	s.Bytes(m.latched[:])
+	s.Bool(&m.latchPrepared)
+
+	rtcTime := m.rtcTime.UnixNano()
+	s.Int64(&rtcTime)
+	m.rtcTime = time.Unix(0, rtcTime)
+
+	s.Bytes(m.ram)
+}
+
+func (m *MBC5) SerializeState(s *state.Serializer) {
+	s.Uint16(&m.romBank)
+	s.Uint8(&m.ramBank)
+	s.Bool(&m.ramEnabled)
+	s.Bytes(m.ram)
+}
+
+func (d *oamDMA) SerializeState(s *state.Serializer) {
+	s.Bool(&d.active)
+	s.Uint16(&d.source)
+	s.Int(&d.index)
+	s.Uint8(&d.value)
+	s.Bool(&d.pending)
+	s.Uint16(&d.pendingSource)
+	s.Int(&d.delay)
+	s.Int(&d.cycles)
+}
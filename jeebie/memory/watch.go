@@ -0,0 +1,63 @@
+package memory
+
+// Access flags for WatchAddress.
+const (
+	WatchRead uint8 = 1 << iota
+	WatchWrite
+)
+
+// WatchHook is called when the CPU reads or writes a watched address, with
+// the value read or written.
+type WatchHook func(address uint16, value byte, write bool)
+
+// watchState backs debugger watchpoints.
+type watchState struct {
+	hook  WatchHook
+	flags []uint8 // per address WatchRead/WatchWrite, nil when nothing is watched
+	armed bool
+}
+
+// SetWatchHook sets the function called on accesses to watched addresses.
+func (m *MMU) SetWatchHook(hook WatchHook) {
+	m.watch.hook = hook
+}
+
+// WatchAddress selects which accesses to address call the watch hook, a
+// combination of WatchRead and WatchWrite. Zero stops watching the address.
+func (m *MMU) WatchAddress(address uint16, flags uint8) {
+	if m.watch.flags == nil {
+		if flags == 0 {
+			return
+		}
+		m.watch.flags = make([]uint8, 0x10000)
+	}
+	m.watch.flags[address] = flags
+}
+
+// WatchFlags returns the accesses to address that call the watch hook.
+func (m *MMU) WatchFlags(address uint16) uint8 {
+	if m.watch.flags == nil {
+		return 0
+	}
+	return m.watch.flags[address]
+}
+
+// ClearWatches stops watching all addresses.
+func (m *MMU) ClearWatches() {
+	m.watch.flags = nil
+}
+
+// ArmWatches enables or disables the watch hook. It is armed only while the
+// CPU executes an instruction, so accesses by the PPU, DMA or debug tools
+// don't trigger watchpoints.
+func (m *MMU) ArmWatches(armed bool) {
+	m.watch.armed = armed
+}
+
+// checkWatch calls the watch hook if the access is watched.
+func (m *MMU) checkWatch(address uint16, value byte, flag uint8) {
+	if !m.watch.armed || m.watch.flags == nil || m.watch.flags[address]&flag == 0 || m.watch.hook == nil {
+		return
+	}
+	m.watch.hook(address, value, flag == WatchWrite)
+}
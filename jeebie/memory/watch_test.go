@@ -0,0 +1,42 @@
+package memory
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/valerio/go-jeebie/jeebie/addr"
+	"github.com/valerio/go-jeebie/jeebie/bit"
+)
+
+func TestWatchHook(t *testing.T) {
+	m := New()
+	type access struct {
+		address uint16
+		value   byte
+		write   bool
+	}
+	var hits []access
+	m.SetWatchHook(func(address uint16, value byte, write bool) {
+		hits = append(hits, access{address, value, write})
+	})
+	m.WatchAddress(0xC000, WatchRead|WatchWrite)
+	m.WatchAddress(addr.IF, WatchWrite)
+
+	m.Write(0xC000, 0x12)
+	assert.Empty(t, hits, "watches only fire while armed")
+
+	m.ArmWatches(true)
+	m.Write(0xC000, 0x34)
+	m.Read(0xC000)
+	m.Read(0xC001)
+	m.Read(addr.IF)
+	m.RequestInterrupt(addr.TimerInterrupt)
+	m.ArmWatches(false)
+
+	assert.Equal(t, []access{{0xC000, 0x34, true}, {0xC000, 0x34, false}}, hits)
+	assert.True(t, bit.IsSet(2, m.Read(addr.IF)), "interrupt requests bypass watches but still set IF")
+	assert.Equal(t, WatchRead|WatchWrite, m.WatchFlags(0xC000))
+
+	m.ClearWatches()
+	assert.Zero(t, m.WatchFlags(0xC000))
+}
@@ -0,0 +1,135 @@
+package jeebie
+
+import (
+	"io"
+
+	"github.com/valerio/go-jeebie/jeebie/cpu"
+	"github.com/valerio/go-jeebie/jeebie/memory"
+	"github.com/valerio/go-jeebie/jeebie/serial"
+	"github.com/valerio/go-jeebie/jeebie/trace"
+	"github.com/valerio/go-jeebie/jeebie/video"
+)
+
+// Option configures optional behavior of an emulator created with NewWithFile.
+type Option func(*DMG)
+
+// WithSaveDir stores battery-backed saves (.sav) in dir instead of next to the ROM.
+// An empty dir keeps the default location.
+func WithSaveDir(dir string) Option {
+	return func(e *DMG) { e.saveDir = dir }
+}
+
+// WithRewind keeps a rewind history of up to budget bytes, taking a snapshot
+// every interval frames. A budget of 0 disables rewinding.
+func WithRewind(budget, interval int) Option {
+	return func(e *DMG) {
+		if budget <= 0 {
+			e.rewind = nil
+			return
+		}
+		if interval < 1 {
+			interval = 1
+		}
+		e.rewind = newRewindBuffer(budget)
+		e.rewindInterval = interval
+	}
+}
+
+// WithReverse keeps a history of up to budget bytes for stepping back in the
+// debugger, see DMG.StepBack. A budget of 0 disables it.
+func WithReverse(budget int) Option {
+	return func(e *DMG) {
+		e.reverse = nil
+		if budget > 0 {
+			e.reverse = newReverseHistory(budget)
+		}
+	}
+}
+
+// WithRenderer selects the PPU implementation. The default is the scanline
+// renderer; video.RendererFIFO is slower but emulates mode 3 dot by dot.
+func WithRenderer(r video.Renderer) Option {
+	return func(e *DMG) { e.renderer = r }
+}
+
+// WithModel selects the emulated hardware model. Without a boot ROM, the CPU
+// starts with the register values left by that model's boot ROM. Older models
+// run Game Boy Color games in DMG mode.
+func WithModel(model cpu.Model) Option {
+	return func(e *DMG) { e.model = model }
+}
+
+// WithBootROM runs the boot ROM at path before the cartridge, starting from
+// PC 0. The boot ROM is unmapped when it writes to 0xFF50.
+func WithBootROM(path string) Option {
+	return func(e *DMG) { e.bootROMPath = path }
+}
+
+// WithIgnoreChecksum loads cartridges even if their header checksum is
+// invalid, instead of failing with memory.ErrHeaderChecksum.
+func WithIgnoreChecksum(ignore bool) Option {
+	return func(e *DMG) { e.ignoreChecksum = ignore }
+}
+
+// WithSymbols loads labels from an RGBDS symbol file at path, instead of
+// looking for one next to the ROM.
+func WithSymbols(path string) Option {
+	return func(e *DMG) { e.symbolPath = path }
+}
+
+// WithTrace writes a gameboy-doctor style line to w for every instruction
+// selected by filter, see package trace. Buffered lines are written on Close.
+func WithTrace(w io.Writer, filter trace.Filter) Option {
+	return func(e *DMG) {
+		e.tracer = trace.NewWriter(w)
+		e.traceFilter = filter
+	}
+}
+
+// WithCDL records how ROM bytes are used in a code/data log saved to path on
+// Close, see package cdl. An existing log at path is added to.
+func WithCDL(path string) Option {
+	return func(e *DMG) { e.cdlPath = path }
+}
+
+// WithCoverage writes lcov coverage of the ROM's symbols to path on Close.
+// It needs a symbol file, see WithSymbols.
+func WithCoverage(path string) Option {
+	return func(e *DMG) { e.coveragePath = path }
+}
+
+// WithProfile profiles the cycles spent in the game's functions, writing a
+// pprof profile to pprofPath and a flat report to reportPath on Close. Either
+// path can be empty.
+func WithProfile(pprofPath, reportPath string) Option {
+	return func(e *DMG) {
+		e.profilePath = pprofPath
+		e.profileReportPath = reportPath
+	}
+}
+
+// WithLinkCable connects the serial port to another emulator over conn, see
+// serial.Link. For two emulators in one process, pass the ends of a
+// net.Pipe and run each on its own goroutine. conn is closed by Close.
+func WithLinkCable(conn io.ReadWriteCloser) Option {
+	return func(e *DMG) {
+		e.newSerial = func(irq func()) memory.SerialPort { return serial.NewLink(conn, irq) }
+	}
+}
+
+// WithPrinter connects a Game Boy Printer to the serial port, saving printed
+// images as PNG files in dir, see serial.Printer.
+func WithPrinter(dir string) Option {
+	return func(e *DMG) {
+		e.newSerial = func(irq func()) memory.SerialPort { return serial.NewPrinter(dir, irq) }
+	}
+}
+
+// WithSerialScript connects a scripted link cable partner answering with the
+// bytes of script, and writing the bytes it receives to received if not nil,
+// see serial.Script.
+func WithSerialScript(script []byte, received io.Writer) Option {
+	return func(e *DMG) {
+		e.newSerial = func(irq func()) memory.SerialPort { return serial.NewScript(script, received, irq) }
+	}
+}
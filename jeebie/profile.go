@@ -0,0 +1,93 @@
+package jeebie
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+
+	"github.com/valerio/go-jeebie/jeebie/profile"
+)
+
+// entryPoint is where the cartridge code starts, the outermost function of
+// the call stack.
+var entryPoint = profile.At(0x0100, 0)
+
+// startProfile installs the CPU profile hook when WithProfile was given.
+func (e *DMG) startProfile() {
+	if e.profilePath == "" && e.profileReportPath == "" {
+		return
+	}
+	e.profiler = profile.New()
+	e.bus.CPU.SetProfileHook(e.profileInstruction)
+}
+
+// profileInstruction records an instruction with the call stack it ran in.
+func (e *DMG) profileInstruction(pc uint16, cycles int) {
+	if e.replaying {
+		return
+	}
+	e.profileCalls = e.bus.CPU.AppendCallStack(e.profileCalls[:0])
+	calls := e.profileCalls
+
+	// the function of each frame is where the frame above it called
+	function := func(i int) profile.Location {
+		if i < 0 {
+			return entryPoint
+		}
+		return profile.At(calls[i].Target, calls[i].Bank)
+	}
+	stack := append(e.profileStack[:0], profile.Frame{
+		PC:       profile.At(pc, e.bus.MMU.ROMBank()),
+		Function: function(len(calls) - 1),
+	})
+	for i := len(calls) - 1; i >= 0; i-- {
+		stack = append(stack, profile.Frame{
+			PC:       profile.At(calls[i].Caller, calls[i].Bank),
+			Function: function(i - 1),
+		})
+	}
+	e.profileStack = stack
+	e.profiler.Add(stack, cycles)
+}
+
+// Profiler returns the game profiler, or nil if it isn't enabled.
+func (e *DMG) Profiler() *profile.Profiler {
+	return e.profiler
+}
+
+// saveProfile writes the pprof profile and flat report, if enabled.
+func (e *DMG) saveProfile() error {
+	if e.profiler == nil {
+		return nil
+	}
+	if e.profilePath != "" {
+		if err := writeFile(e.profilePath, func(f *os.File) error {
+			return e.profiler.WritePprof(f, e.symbols)
+		}); err != nil {
+			return fmt.Errorf("writing profile: %w", err)
+		}
+		slog.Info("Saved game profile", "path", e.profilePath)
+	}
+	if e.profileReportPath != "" {
+		if err := writeFile(e.profileReportPath, func(f *os.File) error {
+			return e.profiler.WriteReport(f, e.symbols)
+		}); err != nil {
+			return fmt.Errorf("writing profile report: %w", err)
+		}
+		slog.Info("Saved game profile report", "path", e.profileReportPath)
+	}
+	return nil
+}
+
+// writeFile creates path and writes it with write.
+func writeFile(path string, write func(f *os.File) error) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := write(f); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
@@ -0,0 +1,194 @@
+package profile
+
+import (
+	"compress/gzip"
+	"io"
+
+	"github.com/valerio/go-jeebie/jeebie/symbols"
+)
+
+// Field numbers of the pprof profile.proto messages used here, see
+// https://github.com/google/pprof/blob/main/proto/profile.proto
+const (
+	profileSampleType  = 1
+	profileSample      = 2
+	profileLocation    = 4
+	profileFunction    = 5
+	profileStringTable = 6
+	profilePeriodType  = 11
+	profilePeriod      = 12
+
+	valueTypeType = 1
+	valueTypeUnit = 2
+
+	sampleLocationID = 1
+	sampleValue      = 2
+
+	locationID      = 1
+	locationAddress = 3
+	locationLine    = 4
+
+	lineFunctionID = 1
+
+	functionID         = 1
+	functionName       = 2
+	functionSystemName = 3
+)
+
+// WritePprof writes the samples as a gzipped pprof profile with instruction
+// and cycle counts, naming functions with syms if not nil. Location
+// addresses are the bank in bits 16 and up and the address in the bank.
+func (p *Profiler) WritePprof(w io.Writer, syms *symbols.Table) error {
+	var strs stringTable
+	var enc protoEncoder
+	enc.message(profileSampleType, func(e *protoEncoder) {
+		e.varintField(valueTypeType, strs.index("instructions"))
+		e.varintField(valueTypeUnit, strs.index("count"))
+	})
+	enc.message(profileSampleType, func(e *protoEncoder) {
+		e.varintField(valueTypeType, strs.index("cycles"))
+		e.varintField(valueTypeUnit, strs.index("count"))
+	})
+
+	type location struct {
+		pc       Location
+		function string
+	}
+	locations := make(map[location]uint64)
+	functions := make(map[string]uint64)
+	var locationOrder []location
+	var functionOrder []string
+
+	ids := make([]uint64, 0, 16)
+	for _, s := range p.samples {
+		ids = ids[:0]
+		for _, f := range s.Stack {
+			name := nameOf(f, syms)
+			if _, ok := functions[name]; !ok {
+				functions[name] = uint64(len(functions) + 1)
+				functionOrder = append(functionOrder, name)
+			}
+			loc := location{f.PC, name}
+			id, ok := locations[loc]
+			if !ok {
+				id = uint64(len(locations) + 1)
+				locations[loc] = id
+				locationOrder = append(locationOrder, loc)
+			}
+			ids = append(ids, id)
+		}
+		enc.message(profileSample, func(e *protoEncoder) {
+			e.packed(sampleLocationID, ids...)
+			e.packed(sampleValue, s.Instructions, s.Cycles)
+		})
+	}
+
+	for _, loc := range locationOrder {
+		enc.message(profileLocation, func(e *protoEncoder) {
+			e.varintField(locationID, locations[loc])
+			e.varintField(locationAddress, uint64(loc.pc.Bank)<<16|uint64(loc.pc.Address))
+			e.message(locationLine, func(e *protoEncoder) {
+				e.varintField(lineFunctionID, functions[loc.function])
+			})
+		})
+	}
+	for _, name := range functionOrder {
+		enc.message(profileFunction, func(e *protoEncoder) {
+			e.varintField(functionID, functions[name])
+			e.varintField(functionName, strs.index(name))
+			e.varintField(functionSystemName, strs.index(name))
+		})
+	}
+
+	enc.message(profilePeriodType, func(e *protoEncoder) {
+		e.varintField(valueTypeType, strs.index("cycles"))
+		e.varintField(valueTypeUnit, strs.index("count"))
+	})
+	enc.varintField(profilePeriod, 1)
+	for _, s := range strs.strings {
+		enc.stringField(profileStringTable, s)
+	}
+
+	zw := gzip.NewWriter(w)
+	if _, err := zw.Write(enc.data); err != nil {
+		return err
+	}
+	return zw.Close()
+}
+
+// stringTable interns the strings of a profile, the first one being empty.
+type stringTable struct {
+	strings []string
+	indices map[string]uint64
+}
+
+func (t *stringTable) index(s string) uint64 {
+	if t.indices == nil {
+		t.strings = []string{""}
+		t.indices = map[string]uint64{"": 0}
+	}
+	i, ok := t.indices[s]
+	if !ok {
+		i = uint64(len(t.strings))
+		t.indices[s] = i
+		t.strings = append(t.strings, s)
+	}
+	return i
+}
+
+// protoEncoder encodes protocol buffer fields, just enough for profiles.
+type protoEncoder struct {
+	data []byte
+}
+
+const (
+	wireVarint = 0
+	wireBytes  = 2
+)
+
+func (e *protoEncoder) varint(x uint64) {
+	for x >= 0x80 {
+		e.data = append(e.data, byte(x)|0x80)
+		x >>= 7
+	}
+	e.data = append(e.data, byte(x))
+}
+
+func (e *protoEncoder) tag(field, wireType int) {
+	e.varint(uint64(field)<<3 | uint64(wireType))
+}
+
+// varintField encodes an integer field, omitted when zero like proto3 does.
+func (e *protoEncoder) varintField(field int, x uint64) {
+	if x == 0 {
+		return
+	}
+	e.tag(field, wireVarint)
+	e.varint(x)
+}
+
+// stringField encodes a string field. Empty strings are kept, as the string
+// table relies on their position.
+func (e *protoEncoder) stringField(field int, s string) {
+	e.tag(field, wireBytes)
+	e.varint(uint64(len(s)))
+	e.data = append(e.data, s...)
+}
+
+func (e *protoEncoder) packed(field int, xs ...uint64) {
+	var inner protoEncoder
+	for _, x := range xs {
+		inner.varint(x)
+	}
+	e.tag(field, wireBytes)
+	e.varint(uint64(len(inner.data)))
+	e.data = append(e.data, inner.data...)
+}
+
+func (e *protoEncoder) message(field int, encode func(e *protoEncoder)) {
+	var inner protoEncoder
+	encode(&inner)
+	e.tag(field, wireBytes)
+	e.varint(uint64(len(inner.data)))
+	e.data = append(e.data, inner.data...)
+}
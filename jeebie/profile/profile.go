@@ -0,0 +1,187 @@
+// Package profile attributes emulated CPU cycles to the game's functions,
+// to find the hot loops of a ROM rather than of the emulator. Samples are
+// taken on every instruction with the shadow call stack of the CPU, and can
+// be written as a flat report or as a pprof profile:
+//
+//	go tool pprof -http=: game.pb.gz
+//
+// Functions are named after the symbol file when there is one, otherwise
+// after the bank and address they were called at, like sub_01_4A20.
+package profile
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"sort"
+
+	"github.com/valerio/go-jeebie/jeebie/symbols"
+)
+
+// Location is a code address and the ROM bank it runs from, 0 outside the
+// switchable bank area.
+type Location struct {
+	Bank    int
+	Address uint16
+}
+
+// At returns the location of address with romBank mapped at 0x4000-0x7FFF.
+func At(address uint16, romBank int) Location {
+	if address < 0x4000 || address > 0x7FFF {
+		romBank = 0
+	}
+	return Location{Bank: romBank, Address: address}
+}
+
+// Frame is a level of the call stack.
+type Frame struct {
+	// PC is the running instruction in the innermost frame, the call site in
+	// the others.
+	PC Location
+	// Function is where the frame's function was called or interrupted to,
+	// naming it when there are no symbols.
+	Function Location
+}
+
+// Sample is the cost of all instructions that ran with the same stack.
+type Sample struct {
+	// Stack is innermost frame first.
+	Stack        []Frame
+	Cycles       uint64
+	Instructions uint64
+}
+
+// Profiler collects samples.
+type Profiler struct {
+	samples []Sample
+	index   map[string]int
+	key     []byte
+}
+
+// New returns an empty profiler.
+func New() *Profiler {
+	return &Profiler{index: make(map[string]int)}
+}
+
+// Add records an instruction that took cycles with stack, innermost frame
+// first. stack is copied the first time it's seen, so callers can reuse it.
+func (p *Profiler) Add(stack []Frame, cycles int) {
+	p.key = p.key[:0]
+	for _, f := range stack {
+		p.key = append(p.key,
+			byte(f.PC.Bank), byte(f.PC.Bank>>8), byte(f.PC.Address), byte(f.PC.Address>>8),
+			byte(f.Function.Bank), byte(f.Function.Bank>>8), byte(f.Function.Address), byte(f.Function.Address>>8))
+	}
+	i, ok := p.index[string(p.key)]
+	if !ok {
+		i = len(p.samples)
+		p.index[string(p.key)] = i
+		p.samples = append(p.samples, Sample{Stack: append([]Frame(nil), stack...)})
+	}
+	p.samples[i].Cycles += uint64(cycles)
+	p.samples[i].Instructions++
+}
+
+// Samples returns the samples in the order their stacks were first seen.
+func (p *Profiler) Samples() []Sample {
+	return p.samples
+}
+
+// Total returns the cycles and instructions of all samples.
+func (p *Profiler) Total() (cycles, instructions uint64) {
+	for _, s := range p.samples {
+		cycles += s.Cycles
+		instructions += s.Instructions
+	}
+	return cycles, instructions
+}
+
+// nameOf names the function of frame f, using syms if not nil.
+func nameOf(f Frame, syms *symbols.Table) string {
+	if syms != nil {
+		if s, ok := syms.Function(f.PC.Address, f.PC.Bank); ok {
+			return s.Name
+		}
+		if name, ok := syms.Label(f.Function.Address, f.Function.Bank); ok {
+			return name
+		}
+	}
+	return fmt.Sprintf("sub_%02X_%04X", f.Function.Bank, f.Function.Address)
+}
+
+// FunctionCost is the cost of a function in a flat report.
+type FunctionCost struct {
+	Name string
+	// Flat counts cycles of the function's own instructions, Cum also those
+	// of the functions it called.
+	Flat, Cum uint64
+}
+
+// Functions returns the cost of every function, most expensive first.
+func (p *Profiler) Functions(syms *symbols.Table) []FunctionCost {
+	byName := make(map[string]*FunctionCost)
+	cost := func(name string) *FunctionCost {
+		c, ok := byName[name]
+		if !ok {
+			c = &FunctionCost{Name: name}
+			byName[name] = c
+		}
+		return c
+	}
+
+	seen := make(map[string]bool)
+	for _, s := range p.samples {
+		clear(seen)
+		for i, f := range s.Stack {
+			name := nameOf(f, syms)
+			c := cost(name)
+			if i == 0 {
+				c.Flat += s.Cycles
+			}
+			// recursive functions are only counted once per sample
+			if !seen[name] {
+				seen[name] = true
+				c.Cum += s.Cycles
+			}
+		}
+	}
+
+	functions := make([]FunctionCost, 0, len(byName))
+	for _, c := range byName {
+		functions = append(functions, *c)
+	}
+	sort.Slice(functions, func(i, j int) bool {
+		a, b := functions[i], functions[j]
+		if a.Flat != b.Flat {
+			return a.Flat > b.Flat
+		}
+		if a.Cum != b.Cum {
+			return a.Cum > b.Cum
+		}
+		return a.Name < b.Name
+	})
+	return functions
+}
+
+// WriteReport writes a flat report of the cycles spent in each function,
+// like "go tool pprof -top", naming functions with syms if not nil.
+func (p *Profiler) WriteReport(w io.Writer, syms *symbols.Table) error {
+	cycles, instructions := p.Total()
+	percent := func(n uint64) float64 {
+		if cycles == 0 {
+			return 0
+		}
+		return float64(n) * 100 / float64(cycles)
+	}
+
+	bw := bufio.NewWriter(w)
+	fmt.Fprintf(bw, "Total: %d cycles in %d instructions, time spent halted excluded\n", cycles, instructions)
+	fmt.Fprintf(bw, "%12s %7s %7s %12s %7s  %s\n", "flat", "flat%", "sum%", "cum", "cum%", "function")
+	var sum uint64
+	for _, f := range p.Functions(syms) {
+		sum += f.Flat
+		fmt.Fprintf(bw, "%12d %6.2f%% %6.2f%% %12d %6.2f%%  %s\n",
+			f.Flat, percent(f.Flat), percent(sum), f.Cum, percent(f.Cum), f.Name)
+	}
+	return bw.Flush()
+}
@@ -0,0 +1,89 @@
+package profile
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/valerio/go-jeebie/jeebie/symbols"
+)
+
+// testProfile has a main loop at 0x0150 calling a function in bank 2, which
+// calls a function in bank 0.
+func testProfile() *Profiler {
+	main := Frame{PC: At(0x0150, 1), Function: At(0x0100, 1)}
+	update := Frame{PC: At(0x4010, 2), Function: At(0x4000, 2)}
+	copyTiles := Frame{PC: At(0x0200, 2), Function: At(0x0200, 2)}
+
+	p := New()
+	p.Add([]Frame{main}, 12)
+	p.Add([]Frame{update, main}, 8)
+	p.Add([]Frame{copyTiles, update, main}, 16)
+	p.Add([]Frame{copyTiles, update, main}, 16)
+	return p
+}
+
+const testSymbols = `
+00:0100 EntryPoint
+02:4000 Update
+02:4010 Update.call
+00:0200 CopyTiles
+`
+
+func TestAt(t *testing.T) {
+	assert.Equal(t, Location{Bank: 0, Address: 0x3FFF}, At(0x3FFF, 5))
+	assert.Equal(t, Location{Bank: 5, Address: 0x4000}, At(0x4000, 5))
+	assert.Equal(t, Location{Bank: 0, Address: 0xC000}, At(0xC000, 5))
+}
+
+func TestProfiler(t *testing.T) {
+	p := testProfile()
+	require.Len(t, p.Samples(), 3)
+	assert.Equal(t, uint64(32), p.Samples()[2].Cycles)
+	assert.Equal(t, uint64(2), p.Samples()[2].Instructions)
+	cycles, instructions := p.Total()
+	assert.Equal(t, uint64(52), cycles)
+	assert.Equal(t, uint64(4), instructions)
+
+	assert.Equal(t, []FunctionCost{
+		{Name: "sub_00_0200", Flat: 32, Cum: 32},
+		{Name: "sub_00_0100", Flat: 12, Cum: 52},
+		{Name: "sub_02_4000", Flat: 8, Cum: 40},
+	}, p.Functions(nil))
+
+	syms, err := symbols.Parse(strings.NewReader(testSymbols))
+	require.NoError(t, err)
+	assert.Equal(t, []FunctionCost{
+		{Name: "CopyTiles", Flat: 32, Cum: 32},
+		{Name: "EntryPoint", Flat: 12, Cum: 52},
+		{Name: "Update", Flat: 8, Cum: 40},
+	}, p.Functions(syms))
+}
+
+func TestWriteReport(t *testing.T) {
+	var out strings.Builder
+	require.NoError(t, testProfile().WriteReport(&out, nil))
+	assert.Equal(t, `Total: 52 cycles in 4 instructions, time spent halted excluded
+        flat   flat%    sum%          cum    cum%  function
+          32  61.54%  61.54%           32  61.54%  sub_00_0200
+          12  23.08%  84.62%           52 100.00%  sub_00_0100
+           8  15.38% 100.00%           40  76.92%  sub_02_4000
+`, out.String())
+}
+
+func TestWritePprof(t *testing.T) {
+	var out bytes.Buffer
+	require.NoError(t, testProfile().WritePprof(&out, nil))
+
+	zr, err := gzip.NewReader(&out)
+	require.NoError(t, err)
+	data, err := io.ReadAll(zr)
+	require.NoError(t, err)
+	for _, s := range []string{"instructions", "cycles", "count", "sub_00_0100", "sub_02_4000", "sub_00_0200"} {
+		assert.Contains(t, string(data), s)
+	}
+}
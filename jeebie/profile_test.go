@@ -0,0 +1,45 @@
+package jeebie
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/valerio/go-jeebie/jeebie/profile"
+)
+
+func TestProfile(t *testing.T) {
+	code := make([]byte, 0x12)
+	copy(code, []byte{
+		0xCD, 0x60, 0x01, // CALL 0x160
+		0x18, 0xFB, // JR -5
+	})
+	copy(code[0x10:], []byte{0x00, 0xC9}) // 0x160: NOP, RET
+	dmg := newCodeDMG(t, 0x00, code)
+
+	reportPath := filepath.Join(t.TempDir(), "profile.txt")
+	WithProfile("", reportPath)(dmg)
+	dmg.startProfile()
+	for range 9 {
+		dmg.bus.TickInstruction()
+	}
+
+	// JP, then twice CALL, NOP, RET, JR
+	functions := dmg.Profiler().Functions(nil)
+	require.Len(t, functions, 2)
+	assert.Equal(t, profile.FunctionCost{Name: "sub_00_0100", Flat: 16 + 2*(24+12), Cum: 16 + 2*(24+4+16+12)}, functions[0])
+	assert.Equal(t, profile.FunctionCost{Name: "sub_00_0160", Flat: 2 * (4 + 16), Cum: 2 * (4 + 16)}, functions[1])
+
+	samples := dmg.Profiler().Samples()
+	assert.Equal(t, []profile.Frame{
+		{PC: profile.At(0x160, 0), Function: profile.At(0x160, 0)},
+		{PC: profile.At(0x150, 0), Function: entryPoint},
+	}, samples[2].Stack)
+
+	require.NoError(t, dmg.Close())
+	report, err := os.ReadFile(reportPath)
+	require.NoError(t, err)
+	assert.Contains(t, string(report), "Total: 128 cycles in 9 instructions")
+}
@@ -0,0 +1,261 @@
+package jeebie
+
+import (
+	"log/slog"
+
+	"github.com/valerio/go-jeebie/jeebie/cpu"
+	"github.com/valerio/go-jeebie/jeebie/debug"
+)
+
+// maxReplay bounds how many instructions are replayed to step back, taking a
+// checkpoint when more ran since the last one, e.g. while single-stepping.
+const maxReplay = 1 << 16
+
+// reverseHistory keeps checkpoints of the machine to step back in time.
+//
+// Execution is deterministic, so the machine at any instruction is rebuilt
+// by restoring the newest checkpoint before it and replaying instructions up
+// to it. Checkpoints are taken at the end of every frame, and whenever the
+// machine changes from the outside, like on joypad input or when a debugger
+// writes memory, so replays never have to reproduce those changes.
+type reverseHistory struct {
+	snapshots *rewindBuffer
+	// checkpoints holds the position of each snapshot, oldest first
+	checkpoints []checkpoint
+}
+
+// checkpoint is where a snapshot was taken.
+type checkpoint struct {
+	// instruction is the instruction count, the position in time
+	instruction uint64
+	frame       uint64
+	// calls is the CPU's shadow call stack, which isn't part of snapshots
+	calls []cpu.CallFrame
+}
+
+func newReverseHistory(budget int) *reverseHistory {
+	return &reverseHistory{snapshots: newRewindBuffer(budget)}
+}
+
+// push adds the newest checkpoint, dropping the oldest ones past the budget.
+func (h *reverseHistory) push(at checkpoint, snapshot []byte) {
+	h.snapshots.push(snapshot)
+	h.checkpoints = append(h.checkpoints, at)
+	if drop := len(h.checkpoints) - h.snapshots.len(); drop > 0 {
+		h.checkpoints = append(h.checkpoints[:0], h.checkpoints[drop:]...)
+	}
+}
+
+// latest returns the newest checkpoint. The history is never empty once the
+// emulator started.
+func (h *reverseHistory) latest() checkpoint {
+	return h.checkpoints[len(h.checkpoints)-1]
+}
+
+// oldest returns the oldest checkpoint, the start of the history.
+func (h *reverseHistory) oldest() checkpoint {
+	return h.checkpoints[0]
+}
+
+// seek drops the checkpoints taken after instruction and returns the newest
+// remaining one, which is kept. instruction must not be before the oldest
+// checkpoint.
+func (h *reverseHistory) seek(instruction uint64) (checkpoint, []byte) {
+	for {
+		n := len(h.checkpoints)
+		at := h.checkpoints[n-1]
+		snapshot := h.snapshots.pop()
+		h.checkpoints = h.checkpoints[:n-1]
+		if at.instruction <= instruction || n == 1 {
+			h.push(at, snapshot)
+			return at, snapshot
+		}
+	}
+}
+
+// clear drops all checkpoints.
+func (h *reverseHistory) clear() {
+	for h.snapshots.len() > 0 {
+		h.snapshots.pop()
+	}
+	h.checkpoints = h.checkpoints[:0]
+}
+
+// checkpointReverse records the current machine in the reverse history.
+func (e *DMG) checkpointReverse() {
+	if e.reverse == nil {
+		return
+	}
+	snapshot, err := e.captureState()
+	if err != nil {
+		slog.Error("Failed to capture reverse checkpoint", "error", err)
+		return
+	}
+	e.reverse.push(checkpoint{
+		instruction: e.instructionCount,
+		frame:       e.frameCount,
+		calls:       e.bus.CPU.CallStack(),
+	}, snapshot)
+}
+
+// resetReverse starts a new reverse history at the current machine, after it
+// jumped in time, like when loading a state.
+func (e *DMG) resetReverse() {
+	if e.reverse == nil {
+		return
+	}
+	e.reverse.clear()
+	e.checkpointReverse()
+}
+
+// recordReverse takes a checkpoint after execution if a frame completed, as
+// replays can't tell where frames end, or if too many instructions ran since
+// the last one.
+func (e *DMG) recordReverse() {
+	if e.reverse == nil {
+		return
+	}
+	last := e.reverse.latest()
+	if e.frameCount != last.frame || e.instructionCount-last.instruction >= maxReplay {
+		e.checkpointReverse()
+	}
+}
+
+// restoreCheckpoint restores the machine to a checkpoint of the history.
+func (e *DMG) restoreCheckpoint(at checkpoint, snapshot []byte) bool {
+	if err := e.restoreState(snapshot); err != nil {
+		slog.Error("Failed to restore reverse checkpoint", "error", err)
+		return false
+	}
+	e.bus.CPU.RestoreCallStack(at.calls)
+	e.pendingBreak = nil
+	return true
+}
+
+// replay executes instructions until the instruction count reaches target.
+// If hit isn't nil, it is called for every breakpoint and watchpoint hit on
+// the way, at the instruction count where execution would have stopped.
+// Traces and profiles don't record replayed instructions again, and serial
+// devices don't repeat their effects outside the emulator.
+func (e *DMG) replay(target uint64, hit func(reason debug.BreakReason)) {
+	e.replaying = true
+	e.bus.MMU.SetReplaying(true)
+	defer func() {
+		e.replaying = false
+		e.bus.MMU.SetReplaying(false)
+	}()
+
+	for e.instructionCount < target {
+		if hit != nil {
+			if reason, ok := e.matchBreakpoint(); ok {
+				reason.PC = e.bus.CPU.GetPC()
+				hit(reason)
+			}
+		}
+		e.bus.TickInstruction()
+		e.instructionCount++
+		if e.pendingBreak != nil {
+			reason := *e.pendingBreak
+			e.pendingBreak = nil
+			if hit != nil {
+				reason.PC = e.bus.CPU.GetPC()
+				hit(reason)
+			}
+		}
+	}
+}
+
+// seekInstruction rewinds the machine to when the instruction count was
+// target, which must be in the history.
+func (e *DMG) seekInstruction(target uint64) bool {
+	at, snapshot := e.reverse.seek(target)
+	if !e.restoreCheckpoint(at, snapshot) {
+		return false
+	}
+	e.replay(target, nil)
+	return true
+}
+
+// canStepBack reports whether there is history before the current
+// instruction.
+func (e *DMG) canStepBack() bool {
+	return e.reverse != nil && e.instructionCount > e.reverse.oldest().instruction
+}
+
+// StepBack undoes the last instruction. It should only be called while
+// paused, and reports false when there is no history to go back to.
+func (e *DMG) StepBack() bool {
+	e.execMutex.Lock()
+	defer e.execMutex.Unlock()
+
+	if !e.canStepBack() {
+		return false
+	}
+	e.clearBreakReason()
+	if !e.seekInstruction(e.instructionCount - 1) {
+		return false
+	}
+	e.skipBreakpoint = true
+	return true
+}
+
+// StepBackFrame goes back to the start of the current frame, or of the
+// previous one when already at the start. Debugger changes to memory and
+// registers and joypad input also count as frame starts. It should only be
+// called while paused, and reports false when there is no history to go back
+// to.
+func (e *DMG) StepBackFrame() bool {
+	e.execMutex.Lock()
+	defer e.execMutex.Unlock()
+
+	if !e.canStepBack() {
+		return false
+	}
+	e.clearBreakReason()
+	if !e.restoreCheckpoint(e.reverse.seek(e.instructionCount - 1)) {
+		return false
+	}
+	e.skipBreakpoint = true
+	return true
+}
+
+// ReverseContinue runs backwards to the previous breakpoint or watchpoint
+// hit, which BreakReason then reports. Without one, it stops at the start of
+// the history and reports false. It should only be called while paused.
+func (e *DMG) ReverseContinue() bool {
+	e.execMutex.Lock()
+	defer e.execMutex.Unlock()
+
+	if !e.canStepBack() {
+		return false
+	}
+	e.clearBreakReason()
+
+	// replay the history one checkpoint at a time, newest first, looking for
+	// the last hit before where the search started
+	end := e.instructionCount
+	for end > e.reverse.oldest().instruction {
+		at, snapshot := e.reverse.seek(end - 1)
+		if !e.restoreCheckpoint(at, snapshot) {
+			return false
+		}
+		var last *debug.BreakReason
+		var lastAt uint64
+		e.replay(end, func(reason debug.BreakReason) {
+			if e.instructionCount < end {
+				last, lastAt = &reason, e.instructionCount
+			}
+		})
+		if last != nil {
+			e.restoreCheckpoint(at, snapshot)
+			e.replay(lastAt, nil)
+			e.stopAt(*last)
+			return true
+		}
+		end = at.instruction
+	}
+
+	e.restoreCheckpoint(e.reverse.seek(end))
+	e.skipBreakpoint = true
+	return false
+}
@@ -0,0 +1,207 @@
+package jeebie
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/valerio/go-jeebie/jeebie/debug"
+	"github.com/valerio/go-jeebie/jeebie/input/action"
+	"github.com/valerio/go-jeebie/jeebie/memory"
+)
+
+// newReverseDMG returns a paused emulator with reverse history running code,
+// which stores an incrementing A to 0xC000 in a loop.
+func newReverseDMG(t *testing.T) *DMG {
+	t.Helper()
+	dmg := newCodeDMG(t, 0x00, []byte{
+		0x3C,             // 0x150: INC A
+		0xEA, 0x00, 0xC0, // 0x151: LD (0xC000), A
+		0x18, 0xFA, // 0x154: JR -6
+	})
+	WithReverse(1 << 20)(dmg)
+	dmg.resetReverse()
+	dmg.Pause()
+	return dmg
+}
+
+func TestStepBack(t *testing.T) {
+	dmg := newReverseDMG(t)
+	assert.False(t, dmg.StepBack(), "no history yet")
+
+	var states []debug.CPUState
+	var stored []byte
+	for range 10 {
+		states = append(states, dmg.ReadRegisters())
+		stored = append(stored, dmg.ReadMemory(0xC000, 1)[0])
+		dmg.StepInstruction()
+	}
+	require.Equal(t, uint8(3), dmg.ReadRegisters().A-states[0].A)
+
+	for i := len(states) - 1; i >= 0; i-- {
+		require.True(t, dmg.StepBack())
+		assert.Equal(t, states[i], dmg.ReadRegisters())
+		assert.Equal(t, stored[i], dmg.ReadMemory(0xC000, 1)[0])
+	}
+	assert.False(t, dmg.StepBack(), "back at the start")
+	assert.Equal(t, states[0], dmg.ReadRegisters())
+
+	// debugger writes are kept when stepping back over later instructions
+	dmg.WriteMemory(0xD000, []byte{0x99})
+	dmg.StepInstruction()
+	require.True(t, dmg.StepBack())
+	assert.Equal(t, uint8(0x99), dmg.ReadMemory(0xD000, 1)[0])
+}
+
+func TestInputCheckpoint(t *testing.T) {
+	dmg := newReverseDMG(t)
+	for range 5 {
+		dmg.StepInstruction()
+	}
+
+	// the emulation loop is in the middle of a step
+	dmg.execMutex.Lock()
+	done := make(chan struct{})
+	go func() {
+		dmg.HandleAction(action.GBButtonA, true)
+		close(done)
+	}()
+	time.Sleep(50 * time.Millisecond)
+	pressed := dmg.bus.MMU.KeyPressed(memory.JoypadA)
+	dmg.execMutex.Unlock()
+	<-done
+	assert.False(t, pressed, "input waits for the step to end")
+
+	assert.True(t, dmg.bus.MMU.KeyPressed(memory.JoypadA))
+	assert.Equal(t, dmg.GetInstructionCount(), dmg.reverse.latest().instruction, "checkpoint taken with the input")
+	dmg.StepInstruction()
+	require.True(t, dmg.StepBack())
+	assert.True(t, dmg.bus.MMU.KeyPressed(memory.JoypadA), "input is kept when stepping back")
+}
+
+func TestStepBackFrame(t *testing.T) {
+	dmg := newReverseDMG(t)
+	dmg.Resume()
+	for range 3 {
+		require.NoError(t, dmg.RunUntilFrame())
+	}
+	dmg.Pause()
+	require.Equal(t, uint64(3), dmg.GetFrameCount())
+	end := dmg.GetInstructionCount()
+
+	dmg.StepInstruction()
+	require.True(t, dmg.StepBackFrame())
+	assert.Equal(t, end, dmg.GetInstructionCount(), "back to the start of the frame")
+	require.True(t, dmg.StepBackFrame())
+	assert.Equal(t, uint64(2), dmg.GetFrameCount())
+	assert.Less(t, dmg.GetInstructionCount(), end)
+
+	require.True(t, dmg.StepBackFrame())
+	require.True(t, dmg.StepBackFrame())
+	assert.Equal(t, uint64(0), dmg.GetFrameCount())
+	assert.False(t, dmg.StepBackFrame())
+}
+
+func TestReverseContinue(t *testing.T) {
+	dmg := newReverseDMG(t)
+	dmg.Resume()
+	for range 3 {
+		require.NoError(t, dmg.RunUntilFrame())
+	}
+	dmg.Pause()
+	start := dmg.GetInstructionCount()
+
+	id := dmg.SetWatchpoint(Watchpoint{Start: 0xC000, End: 0xC000, Access: memory.WatchWrite})
+	require.True(t, dmg.ReverseContinue())
+	reason := dmg.BreakReason()
+	require.NotNil(t, reason)
+	assert.Equal(t, debug.BreakReason{Kind: debug.BreakWrite, ID: id, Address: 0xC000, Value: dmg.ReadRegisters().A, PC: 0x154}, *reason)
+	assert.Equal(t, uint16(0x154), dmg.ReadRegisters().PC)
+	assert.Less(t, dmg.GetInstructionCount(), start)
+	assert.Equal(t, dmg.ReadRegisters().A, dmg.ReadMemory(0xC000, 1)[0])
+
+	// the previous hit is one loop earlier
+	a := dmg.ReadRegisters().A
+	require.True(t, dmg.ReverseContinue())
+	assert.Equal(t, a-1, dmg.ReadRegisters().A)
+
+	// the entry point only runs once, frames of history ago
+	dmg.DeleteBreakpoint(id)
+	dmg.AddBreakpoint(0x100)
+	require.True(t, dmg.ReverseContinue())
+	assert.Equal(t, uint16(0x100), dmg.ReadRegisters().PC)
+	assert.Equal(t, uint64(0), dmg.GetInstructionCount())
+
+	assert.False(t, dmg.ReverseContinue(), "back at the start")
+
+	// without hits, it stops at the start of the history
+	dmg.RemoveBreakpoint(0x100)
+	for range 5 {
+		dmg.StepInstruction()
+	}
+	assert.False(t, dmg.ReverseContinue())
+	assert.Equal(t, uint64(0), dmg.GetInstructionCount())
+}
+
+func TestReplayWithSerialDevice(t *testing.T) {
+	// stores the bytes received from the partner at 0xC000 on, sending each
+	// one back
+	code := []byte{
+		0x11, 0x00, 0xC0, // 0x150: LD DE, 0xC000
+		0x3E, 0x81, // 0x153: LD A, 0x81
+		0xE0, 0x02, // 0x155: LDH (SC), A
+		0xF0, 0x02, // 0x157: LDH A, (SC)
+		0xCB, 0x7F, // 0x159: BIT 7, A
+		0x20, 0xFA, // 0x15B: JR NZ, -6
+		0xF0, 0x01, // 0x15D: LDH A, (SB)
+		0x12,       // 0x15F: LD (DE), A
+		0x13,       // 0x160: INC DE
+		0xE0, 0x01, // 0x161: LDH (SB), A
+		0x18, 0xEE, // 0x163: JR -18
+	}
+	script := make([]byte, 255)
+	for i := range script {
+		script[i] = byte(i + 1)
+	}
+
+	run := func(dmg *DMG, steps int) {
+		for range 2 {
+			require.NoError(t, dmg.RunUntilFrame())
+		}
+		dmg.Pause()
+		for range steps {
+			dmg.StepInstruction()
+		}
+	}
+
+	var straightOut, reversedOut bytes.Buffer
+	straight := newCodeDMG(t, 0x00, code, WithSerialScript(script, &straightOut))
+	run(straight, 2999)
+	want, err := straight.captureState()
+	require.NoError(t, err)
+
+	// stepping back replays from the end of the last frame, across transfers
+	reversed := newCodeDMG(t, 0x00, code, WithSerialScript(script, &reversedOut), WithReverse(1<<20))
+	run(reversed, 3000)
+	require.True(t, reversed.StepBack())
+	require.Equal(t, straight.GetInstructionCount(), reversed.GetInstructionCount())
+	got, err := reversed.captureState()
+	require.NoError(t, err)
+	assert.Equal(t, want, got)
+
+	// the device state is restored too, so both keep running alike
+	for _, dmg := range []*DMG{straight, reversed} {
+		for range 20000 {
+			dmg.StepInstruction()
+		}
+	}
+	want, err = straight.captureState()
+	require.NoError(t, err)
+	got, err = reversed.captureState()
+	require.NoError(t, err)
+	assert.Equal(t, want, got)
+	assert.Equal(t, straight.ReadMemory(0xC000, 64), reversed.ReadMemory(0xC000, 64))
+	assert.Equal(t, straightOut.Bytes(), reversedOut.Bytes(), "replayed bytes are not recorded again")
+}
@@ -0,0 +1,177 @@
+package jeebie
+
+import (
+	"encoding/binary"
+	"log/slog"
+)
+
+// rewindBuffer keeps a history of machine snapshots within a memory budget.
+//
+// Only the newest snapshot is stored in full. Every older snapshot is stored
+// as a delta against the one taken after it, so stepping back means applying
+// deltas in reverse, and dropping the oldest history is just dropping the
+// first delta. Consecutive frames differ in few bytes, so deltas are tiny
+// compared to a full state.
+type rewindBuffer struct {
+	budget int      // max bytes used by latest + deltas
+	size   int      // bytes currently in use
+	latest []byte   // newest full snapshot
+	deltas [][]byte // deltas[i] turns snapshot i+1 into snapshot i, oldest first
+}
+
+func newRewindBuffer(budget int) *rewindBuffer {
+	return &rewindBuffer{budget: budget}
+}
+
+// push adds a snapshot as the newest entry, evicting the oldest history if
+// the budget is exceeded.
+func (b *rewindBuffer) push(snapshot []byte) {
+	if b.latest != nil {
+		d := encodeDelta(snapshot, b.latest)
+		b.deltas = append(b.deltas, d)
+		b.size += len(d)
+	}
+	b.size += len(snapshot) - len(b.latest)
+	b.latest = snapshot
+
+	for b.size > b.budget && len(b.deltas) > 0 {
+		b.size -= len(b.deltas[0])
+		b.deltas[0] = nil
+		b.deltas = b.deltas[1:]
+	}
+}
+
+// pop removes and returns the newest snapshot, or nil if the buffer is empty.
+func (b *rewindBuffer) pop() []byte {
+	snapshot := b.latest
+	if snapshot == nil {
+		return nil
+	}
+
+	b.size -= len(snapshot)
+	b.latest = nil
+	if n := len(b.deltas); n > 0 {
+		d := b.deltas[n-1]
+		b.deltas[n-1] = nil
+		b.deltas = b.deltas[:n-1]
+		b.size -= len(d)
+
+		b.latest = applyDelta(snapshot, d)
+		b.size += len(b.latest)
+	}
+
+	return snapshot
+}
+
+// len returns the number of snapshots in the buffer.
+func (b *rewindBuffer) len() int {
+	if b.latest == nil {
+		return 0
+	}
+	return len(b.deltas) + 1
+}
+
+// encodeDelta returns a compact encoding of the bytes that differ between
+// base and target: the target length, then runs of (unchanged count, changed
+// count, changed bytes XORed with base). Bytes past the end of base count as 0.
+func encodeDelta(base, target []byte) []byte {
+	out := binary.AppendUvarint(nil, uint64(len(target)))
+
+	at := func(i int) byte {
+		if i < len(base) {
+			return base[i]
+		}
+		return 0
+	}
+
+	i := 0
+	for i < len(target) {
+		start := i
+		for i < len(target) && target[i] == at(i) {
+			i++
+		}
+		if i == len(target) {
+			break
+		}
+		skip := i - start
+
+		start = i
+		for i < len(target) && target[i] != at(i) {
+			i++
+		}
+
+		out = binary.AppendUvarint(out, uint64(skip))
+		out = binary.AppendUvarint(out, uint64(i-start))
+		for j := start; j < i; j++ {
+			out = append(out, target[j]^at(j))
+		}
+	}
+
+	return out
+}
+
+// applyDelta rebuilds the target of encodeDelta from base.
+func applyDelta(base, delta []byte) []byte {
+	size, n := binary.Uvarint(delta)
+	delta = delta[n:]
+
+	out := make([]byte, size)
+	copy(out, base)
+
+	pos := 0
+	for len(delta) > 0 {
+		skip, n := binary.Uvarint(delta)
+		delta = delta[n:]
+		count, n := binary.Uvarint(delta)
+		delta = delta[n:]
+
+		pos += int(skip)
+		for j := 0; j < int(count); j++ {
+			out[pos] ^= delta[j]
+			pos++
+		}
+		delta = delta[count:]
+	}
+
+	return out
+}
+
+// captureRewind records a snapshot every rewindInterval frames.
+func (e *DMG) captureRewind() {
+	if e.rewind == nil || e.frameCount%uint64(e.rewindInterval) != 0 {
+		return
+	}
+
+	snapshot, err := e.captureState()
+	if err != nil {
+		slog.Error("Failed to capture rewind snapshot", "error", err)
+		return
+	}
+	e.rewind.push(snapshot)
+	e.rewindNewest = e.frameCount
+}
+
+// rewindFrame restores the newest snapshot in the rewind history, if any.
+func (e *DMG) rewindFrame() {
+	if e.rewindNewest == e.frameCount && e.rewind.len() > 1 {
+		// the newest snapshot is the current state, restoring it would
+		// waste a step
+		e.rewind.pop()
+	}
+	snapshot := e.rewind.pop()
+	if snapshot == nil {
+		return
+	}
+
+	if err := e.restoreState(snapshot); err != nil {
+		slog.Error("Failed to restore rewind snapshot", "error", err)
+		return
+	}
+	e.resetReverse()
+
+	// keep the restored point around, so rewinding stops here once history runs out
+	if e.rewind.len() == 0 {
+		e.rewind.push(snapshot)
+		e.rewindNewest = e.frameCount
+	}
+}
@@ -0,0 +1,78 @@
+package jeebie
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/valerio/go-jeebie/jeebie/input/action"
+)
+
+func TestDeltaRoundTrip(t *testing.T) {
+	base := bytes.Repeat([]byte{0xAA}, 1000)
+	target := bytes.Clone(base)
+	target[0] = 0x01
+	target[500], target[501] = 0x02, 0x03
+	target = append(target, 0x04, 0x05)
+
+	d := encodeDelta(base, target)
+	assert.Less(t, len(d), 20)
+	assert.Equal(t, target, applyDelta(base, d))
+	assert.Equal(t, base, applyDelta(target, encodeDelta(target, base)))
+}
+
+func TestRewindBufferBudget(t *testing.T) {
+	snapshot := func(v byte) []byte {
+		s := make([]byte, 1024)
+		s[int(v)] = v
+		return s
+	}
+
+	b := newRewindBuffer(1100)
+	for i := 1; i <= 20; i++ {
+		b.push(snapshot(byte(i)))
+	}
+	assert.LessOrEqual(t, b.size, 1100)
+	assert.Less(t, b.len(), 20, "oldest snapshots should be evicted")
+
+	n := b.len()
+	for i := 20; i > 20-n; i-- {
+		assert.Equal(t, snapshot(byte(i)), b.pop())
+	}
+	assert.Nil(t, b.pop())
+	assert.Equal(t, 0, b.size)
+}
+
+func TestRewindRestoresEarlierFrames(t *testing.T) {
+	dmg, err := NewWithFile(writeTestROM(t, t.TempDir(), 0x01, 0x00), WithRewind(1<<20, 1))
+	require.NoError(t, err)
+
+	for i := 0; i < 10; i++ {
+		dmg.bus.MMU.Write(0xC000, uint8(i))
+		require.NoError(t, dmg.RunUntilFrame())
+	}
+	require.Equal(t, uint64(10), dmg.frameCount)
+
+	// the snapshot taken at the current frame is skipped
+	dmg.HandleAction(action.EmulatorRewind, true)
+	require.NoError(t, dmg.RunUntilFrame())
+	assert.Equal(t, uint64(9), dmg.frameCount)
+	assert.Equal(t, uint8(8), dmg.bus.MMU.Read(0xC000))
+
+	for i := 0; i < 2; i++ {
+		require.NoError(t, dmg.RunUntilFrame())
+	}
+	assert.Equal(t, uint64(7), dmg.frameCount)
+	assert.Equal(t, uint8(6), dmg.bus.MMU.Read(0xC000))
+
+	// rewinding past the oldest snapshot stays there
+	for i := 0; i < 20; i++ {
+		require.NoError(t, dmg.RunUntilFrame())
+	}
+	assert.Equal(t, uint64(1), dmg.frameCount)
+
+	dmg.HandleAction(action.EmulatorRewind, false)
+	require.NoError(t, dmg.RunUntilFrame())
+	assert.Equal(t, uint64(2), dmg.frameCount)
+}
@@ -0,0 +1,166 @@
+package jeebie
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/valerio/go-jeebie/jeebie/state"
+)
+
+const (
+	saveStateMagic   uint32 = 0x5353424A // "JBSS", little-endian
+	saveStateVersion uint16 = 11
+
+	// SaveStateSlots is the number of numbered save state slots per ROM.
+	SaveStateSlots = 10
+)
+
+var (
+	ErrNotSaveState         = errors.New("not a save state file")
+	ErrSaveStateVersion     = errors.New("unsupported save state version")
+	ErrSaveStateROMMismatch = errors.New("save state was taken from a different ROM")
+)
+
+// SaveState writes a snapshot of the whole machine to w.
+//
+// Format: a header (magic, format version, CRC-32 of the ROM) followed by the
+// state of CPU, MMU (memory, timer, serial, cartridge, APU) and GPU, in that order.
+// It takes execMutex, so the snapshot is never taken mid-step.
+func (e *DMG) SaveState(w io.Writer) error {
+	e.execMutex.Lock()
+	defer e.execMutex.Unlock()
+
+	s := state.NewWriter(w)
+	e.serializeHeader(s)
+	e.serializeState(s)
+	return s.Err()
+}
+
+// LoadState restores a snapshot written by SaveState.
+// States from a different ROM or format version are rejected with an error,
+// and a failed load leaves the emulator untouched.
+func (e *DMG) LoadState(r io.Reader) error {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return err
+	}
+
+	e.execMutex.Lock()
+	defer e.execMutex.Unlock()
+
+	s := state.NewReader(bytes.NewReader(data))
+	if err := e.serializeHeader(s); err != nil {
+		return err
+	}
+
+	// keep a copy of the current state, to roll back a partially applied load
+	backup, err := e.captureState()
+	if err != nil {
+		return fmt.Errorf("backing up current state: %w", err)
+	}
+
+	e.serializeState(s)
+	if err := s.Err(); err != nil {
+		if rollbackErr := e.restoreState(backup); rollbackErr != nil {
+			slog.Error("Failed to roll back state after failed load", "error", rollbackErr)
+		}
+		return fmt.Errorf("loading save state: %w", err)
+	}
+
+	e.resetReverse()
+	return nil
+}
+
+// captureState returns the machine state without a header, for in-memory
+// snapshots that never leave this process.
+func (e *DMG) captureState() ([]byte, error) {
+	var buf bytes.Buffer
+	s := state.NewWriter(&buf)
+	e.serializeState(s)
+	return buf.Bytes(), s.Err()
+}
+
+// restoreState loads a snapshot returned by captureState.
+func (e *DMG) restoreState(data []byte) error {
+	s := state.NewReader(bytes.NewReader(data))
+	e.serializeState(s)
+	return s.Err()
+}
+
+// serializeHeader writes the header, or reads and validates it when loading.
+func (e *DMG) serializeHeader(s *state.Serializer) error {
+	magic, version, crc := saveStateMagic, saveStateVersion, e.bus.MMU.Cartridge().Checksum()
+	s.Uint32(&magic)
+	s.Uint16(&version)
+	s.Uint32(&crc)
+
+	if err := s.Err(); err != nil || !s.Loading() {
+		return err
+	}
+	if magic != saveStateMagic {
+		return ErrNotSaveState
+	}
+	if version != saveStateVersion {
+		return fmt.Errorf("%w: %d", ErrSaveStateVersion, version)
+	}
+	if crc != e.bus.MMU.Cartridge().Checksum() {
+		return ErrSaveStateROMMismatch
+	}
+	return nil
+}
+
+func (e *DMG) serializeState(s *state.Serializer) {
+	e.bus.CPU.SerializeState(s)
+	e.bus.MMU.SerializeState(s)
+	e.bus.GPU.SerializeState(s)
+	s.Uint64(&e.instructionCount)
+	s.Uint64(&e.frameCount)
+}
+
+// StatePath returns the file used for a numbered save state slot, stored
+// alongside the battery save.
+func (e *DMG) StatePath(slot int) string {
+	if e.savePath == "" {
+		return ""
+	}
+	return fmt.Sprintf("%s.ss%d", strings.TrimSuffix(e.savePath, filepath.Ext(e.savePath)), slot)
+}
+
+// SaveStateSlot saves the machine state to a numbered slot.
+func (e *DMG) SaveStateSlot(slot int) error {
+	path := e.StatePath(slot)
+	if path == "" {
+		return errors.New("save states need a ROM loaded from a file")
+	}
+
+	var buf bytes.Buffer
+	if err := e.SaveState(&buf); err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	return os.WriteFile(path, buf.Bytes(), 0644)
+}
+
+// LoadStateSlot restores the machine state from a numbered slot.
+func (e *DMG) LoadStateSlot(slot int) error {
+	path := e.StatePath(slot)
+	if path == "" {
+		return errors.New("save states need a ROM loaded from a file")
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	return e.LoadState(f)
+}
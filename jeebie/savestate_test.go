@@ -0,0 +1,113 @@
+package jeebie
+
+import (
+	"bytes"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSaveStateRoundTrip(t *testing.T) {
+	dir := t.TempDir()
+	dmg, err := NewWithFile(writeTestROM(t, dir, 0x03, 0x02))
+	require.NoError(t, err)
+
+	dmg.bus.MMU.Write(0x0000, 0x0A)
+	dmg.bus.MMU.Write(0xA000, 0x42)
+	dmg.bus.MMU.Write(0xC000, 0x99)
+	require.NoError(t, dmg.RunUntilFrame())
+
+	var buf bytes.Buffer
+	require.NoError(t, dmg.SaveState(&buf))
+	pc, cycles, line, frames := dmg.bus.CPU.GetPC(), dmg.bus.CPU.GetCycles(), dmg.bus.MMU.Read(0xFF44), dmg.frameCount
+
+	dmg.bus.MMU.Write(0xA000, 0x00)
+	dmg.bus.MMU.Write(0xC000, 0x00)
+	require.NoError(t, dmg.RunUntilFrame())
+
+	require.NoError(t, dmg.LoadState(&buf))
+	assert.Equal(t, pc, dmg.bus.CPU.GetPC())
+	assert.Equal(t, cycles, dmg.bus.CPU.GetCycles())
+	assert.Equal(t, line, dmg.bus.MMU.Read(0xFF44))
+	assert.Equal(t, frames, dmg.frameCount)
+	assert.Equal(t, uint8(0x42), dmg.bus.MMU.Read(0xA000))
+	assert.Equal(t, uint8(0x99), dmg.bus.MMU.Read(0xC000))
+}
+
+func TestLoadStateRejectsOtherROM(t *testing.T) {
+	dmg, err := NewWithFile(writeTestROM(t, t.TempDir(), 0x03, 0x02))
+	require.NoError(t, err)
+	other, err := NewWithFile(writeTestROM(t, t.TempDir(), 0x01, 0x00))
+	require.NoError(t, err)
+
+	var buf bytes.Buffer
+	require.NoError(t, other.SaveState(&buf))
+
+	assert.ErrorIs(t, dmg.LoadState(&buf), ErrSaveStateROMMismatch)
+}
+
+func TestLoadStateRejectsInvalidData(t *testing.T) {
+	dmg, err := NewWithFile(writeTestROM(t, t.TempDir(), 0x03, 0x02))
+	require.NoError(t, err)
+	dmg.bus.MMU.Write(0xC000, 0x99)
+
+	assert.ErrorIs(t, dmg.LoadState(bytes.NewReader([]byte("not a state file"))), ErrNotSaveState)
+
+	var buf bytes.Buffer
+	require.NoError(t, dmg.SaveState(&buf))
+	truncated := buf.Bytes()[:buf.Len()/2]
+	dmg.bus.MMU.Write(0xC000, 0x11)
+
+	assert.Error(t, dmg.LoadState(bytes.NewReader(truncated)))
+	assert.Equal(t, uint8(0x11), dmg.bus.MMU.Read(0xC000), "failed load should leave state untouched")
+}
+
+func TestSaveStateSlots(t *testing.T) {
+	dir := t.TempDir()
+	dmg, err := NewWithFile(writeTestROM(t, dir, 0x01, 0x00))
+	require.NoError(t, err)
+
+	assert.Equal(t, filepath.Join(dir, "test.ss3"), dmg.StatePath(3))
+
+	dmg.bus.MMU.Write(0xC000, 0x12)
+	require.NoError(t, dmg.SaveStateSlot(3))
+	dmg.bus.MMU.Write(0xC000, 0x34)
+	require.NoError(t, dmg.LoadStateSlot(3))
+	assert.Equal(t, uint8(0x12), dmg.bus.MMU.Read(0xC000))
+
+	assert.Error(t, dmg.LoadStateSlot(4))
+}
+
+func TestSaveStateWaitsForExecution(t *testing.T) {
+	dmg, err := NewWithFile(writeTestROM(t, t.TempDir(), 0x01, 0x00))
+	require.NoError(t, err)
+
+	var buf bytes.Buffer
+	require.NoError(t, dmg.SaveState(&buf))
+	saved := buf.Bytes()
+
+	for name, call := range map[string]func() error{
+		"save": func() error { return dmg.SaveState(&bytes.Buffer{}) },
+		"load": func() error { return dmg.LoadState(bytes.NewReader(saved)) },
+	} {
+		t.Run(name, func(t *testing.T) {
+			// a debugger or the emulation loop is in the middle of a step
+			dmg.execMutex.Lock()
+			done := make(chan error)
+			go func() { done <- call() }()
+
+			select {
+			case err := <-done:
+				dmg.execMutex.Unlock()
+				require.NoError(t, err)
+				t.Fatal("returned while the machine was running")
+			case <-time.After(50 * time.Millisecond):
+			}
+			dmg.execMutex.Unlock()
+			assert.NoError(t, <-done)
+		})
+	}
+}
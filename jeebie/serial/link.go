@@ -0,0 +1,430 @@
+package serial
+
+import (
+	"encoding/binary"
+	"errors"
+	"io"
+	"log/slog"
+	"net"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/valerio/go-jeebie/jeebie/addr"
+	"github.com/valerio/go-jeebie/jeebie/bit"
+)
+
+// Transfer lengths in cycles: 8 bits at 8192 Hz, or at 262144 Hz with the
+// CGB fast clock (SC bit 1).
+const (
+	transferCycles     = 4096
+	fastTransferCycles = 128
+)
+
+// Lockstep tuning, in cycles of link time.
+const (
+	// pollInterval is how often messages from the peer are looked at.
+	pollInterval = 256
+	// syncInterval is how often the link time is sent to the peer.
+	syncInterval = 1024
+	// window is how far ahead of the peer an emulator may run. Staying within
+	// a byte's transfer time, minus a poll, lets the slave see a transfer
+	// before it completes.
+	window = transferCycles - pollInterval
+)
+
+// maxLinkHistory is how many completed transfers are kept for replays, see
+// Link.SetReplaying.
+const maxLinkHistory = 1 << 16
+
+// stallTimeout is how long to wait for a peer that stopped sending, e.g. a
+// paused emulator, before running on alone until it sends again.
+const stallTimeout = time.Second
+
+// Link message kinds.
+const (
+	msgSync     byte = iota + 1 // the sender's link time
+	msgTransfer                 // the master clocked out data over length cycles
+	msgReply                    // the slave's byte for transfer seq
+)
+
+// messageSize is the size of a message on the wire:
+//
+//	offset  size  content
+//	0       1     kind
+//	1       1     data byte
+//	2       1     transfer sequence number
+//	3       1     reserved, zero
+//	4       4     transfer length in cycles (little endian)
+//	8       8     sender's link time in cycles (little endian)
+const messageSize = 16
+
+type message struct {
+	kind   byte
+	data   byte
+	seq    byte
+	length uint32
+	time   uint64
+}
+
+// completedTransfer is a transfer as seen by the game, for replays.
+type completedTransfer struct {
+	at   uint64 // Link.elapsed when it completed
+	data byte
+}
+
+// incomingTransfer is a transfer clocked by the peer, to be answered when
+// the link time reaches done.
+type incomingTransfer struct {
+	done uint64
+	data byte
+	seq  byte
+}
+
+// Link is a link cable connecting the serial port to another emulator, over
+// any reliable byte stream: net.Pipe for two emulators in the same process,
+// or a TCP connection.
+//
+// The side that starts a transfer with the internal clock (SC bit 0 set) is
+// the master and shifts a byte every 4096 cycles. The other side only
+// exchanges its byte if it is waiting with the external clock (SC = 0x80)
+// when the master's transfer completes, otherwise the master reads 0xFF.
+// Both sides request the serial interrupt at the same cycle.
+//
+// To get this timing, the two emulators run in lockstep: link time counts
+// the cycles emulated since the link was made, and Tick blocks while this
+// side is more than a few thousand cycles ahead of its peer. Each emulator
+// must then run on its own goroutine. A peer that stops answering, for
+// example paused in a debugger, is waited on for a second, then the link
+// acts unplugged until it sends again.
+//
+// Rewinding or loading a state only affects one side of the link. When the
+// debugger replays instructions that already ran, the link doesn't talk to
+// the peer again: transfers complete as they did the first time, from a
+// history of the latest ones.
+type Link struct {
+	irqHandler func()
+	sb, sc     byte
+
+	conn     io.ReadWriteCloser
+	incoming chan message
+	buf      [messageSize]byte
+	timer    *time.Timer
+	closing  sync.Once
+
+	online   bool   // false once the connection is lost
+	stalled  bool   // the peer stopped answering, link time is frozen
+	now      uint64 // link time
+	peerTime uint64 // the peer's link time, as last heard
+	lastSync uint64
+	nextPoll uint64
+
+	// transfer clocked by this side
+	transferActive bool
+	countdown      int
+	seq            byte
+	sent           bool // the peer was told about the transfer
+	replied        bool
+	reply          byte
+
+	// transfers clocked by the peer, oldest first
+	pending []incomingTransfer
+
+	// elapsed counts emulated cycles, unlike the link time also while
+	// unlinked, to find transfers in the history when replaying
+	elapsed   uint64
+	history   []completedTransfer
+	replaying bool
+	replayed  int // next transfer of the history to replay
+}
+
+// NewLink returns a link cable talking to a peer over conn, which is closed
+// by Close. The passed function is called when a transfer is completed,
+// should be wired to request the Serial interrupt.
+func NewLink(conn io.ReadWriteCloser, irq func()) *Link {
+	l := &Link{
+		irqHandler: irq,
+		conn:       conn,
+		incoming:   make(chan message, 256),
+		timer:      time.NewTimer(stallTimeout),
+		online:     true,
+	}
+	l.timer.Stop()
+	go l.receive()
+	return l
+}
+
+// Close disconnects the link.
+func (l *Link) Close() error {
+	var err error
+	l.closing.Do(func() { err = l.conn.Close() })
+	return err
+}
+
+// Online reports whether the peer is still connected.
+func (l *Link) Online() bool {
+	return l.online
+}
+
+func (l *Link) Write(address uint16, value byte) {
+	switch address {
+	case addr.SB:
+		l.sb = value
+	case addr.SC:
+		l.sc = value
+		if !bit.IsSet(7, l.sc) {
+			// clearing the start bit aborts a transfer, a late reply is ignored
+			l.transferActive = false
+			return
+		}
+		l.maybeStartTransfer()
+	default:
+		panic("serial.Link: invalid write address")
+	}
+}
+
+func (l *Link) Read(address uint16) byte {
+	switch address {
+	case addr.SB:
+		return l.sb
+	case addr.SC:
+		return l.sc
+	default:
+		panic("serial.Link: invalid read address")
+	}
+}
+
+func (l *Link) Tick(cycles int) {
+	l.elapsed += uint64(cycles)
+	if l.replaying {
+		l.replayTick(cycles)
+		return
+	}
+
+	if l.linked() {
+		l.now += uint64(cycles)
+		if l.now >= l.nextPoll {
+			l.poll()
+			l.nextPoll = l.now + pollInterval
+		}
+		if l.linked() && l.now-l.lastSync >= syncInterval {
+			l.send(message{kind: msgSync})
+		}
+	} else if l.online {
+		// a stalled peer wakes up by sending something
+		l.poll()
+	}
+
+	if l.transferActive {
+		l.countdown -= cycles
+	}
+	for {
+		l.answerTransfers()
+		if !l.linked() {
+			break
+		}
+		ahead := l.now > l.peerTime+window
+		waiting := l.transferActive && l.countdown <= 0 && l.sent && !l.replied
+		if !ahead && !waiting {
+			break
+		}
+		if l.lastSync != l.now {
+			l.send(message{kind: msgSync})
+		}
+		if !l.wait() {
+			slog.Warn("serial link: peer is not responding, running unlinked")
+			l.stalled = true
+		}
+	}
+
+	if l.transferActive && l.countdown <= 0 {
+		received := byte(0xFF)
+		if l.sent && l.replied {
+			received = l.reply
+		}
+		l.completeTransfer(received)
+	}
+}
+
+// SetReplaying stops talking to the peer while instructions that already ran
+// are executed again. Transfers then complete from the history instead.
+func (l *Link) SetReplaying(replaying bool) {
+	l.replaying = replaying
+	if replaying {
+		l.replayed = sort.Search(len(l.history), func(i int) bool {
+			return l.history[i].at > l.elapsed
+		})
+	}
+}
+
+// replayTick completes the transfers that completed by now the first time.
+func (l *Link) replayTick(cycles int) {
+	if l.transferActive {
+		l.countdown -= cycles
+	}
+	for l.replayed < len(l.history) && l.history[l.replayed].at <= l.elapsed {
+		l.completeTransfer(l.history[l.replayed].data)
+		l.replayed++
+	}
+}
+
+func (l *Link) Reset() {
+	l.sb = 0x00
+	l.sc = 0x00
+	l.transferActive = false
+	l.countdown = 0
+}
+
+// linked reports whether the two emulators are running in lockstep.
+func (l *Link) linked() bool {
+	return l.online && !l.stalled
+}
+
+func (l *Link) maybeStartTransfer() {
+	if l.transferActive || !bit.IsSet(0, l.sc) {
+		// with the external clock, the peer starts the transfer
+		return
+	}
+	l.transferActive = true
+	l.countdown = transferCycles
+	if bit.IsSet(1, l.sc) {
+		l.countdown = fastTransferCycles
+	}
+	l.seq++
+	l.replied = false
+	l.sent = l.linked() && !l.replaying
+	if l.sent {
+		l.send(message{kind: msgTransfer, data: l.sb, seq: l.seq, length: uint32(l.countdown)})
+	}
+}
+
+func (l *Link) completeTransfer(received byte) {
+	if !l.replaying {
+		l.record(received)
+	}
+	l.sb = received
+	l.sc = bit.Clear(7, l.sc)
+	l.transferActive = false
+	if l.irqHandler != nil {
+		l.irqHandler()
+	}
+}
+
+// record adds a completed transfer to the history. Transfers recorded after
+// the current cycle are from before a rewind, and are dropped.
+func (l *Link) record(received byte) {
+	for n := len(l.history); n > 0 && l.history[n-1].at > l.elapsed; n-- {
+		l.history = l.history[:n-1]
+	}
+	if len(l.history) == 2*maxLinkHistory {
+		// drop the oldest half, so trimming is rare
+		l.history = append(l.history[:0], l.history[maxLinkHistory:]...)
+	}
+	l.history = append(l.history, completedTransfer{at: l.elapsed, data: received})
+}
+
+// answerTransfers completes the peer's transfers that are due, as the slave
+// if SC is waiting for the external clock.
+func (l *Link) answerTransfers() {
+	for len(l.pending) > 0 && (l.pending[0].done <= l.now || !l.linked()) {
+		t := l.pending[0]
+		l.pending = l.pending[1:]
+
+		answer := byte(0xFF)
+		if bit.IsSet(7, l.sc) && !bit.IsSet(0, l.sc) {
+			answer = l.sb
+			l.completeTransfer(t.data)
+		}
+		if l.online {
+			l.send(message{kind: msgReply, data: answer, seq: t.seq})
+		}
+	}
+}
+
+// poll handles the messages received so far, without waiting.
+func (l *Link) poll() {
+	for {
+		select {
+		case m, ok := <-l.incoming:
+			l.handle(m, ok)
+			if !ok {
+				return
+			}
+		default:
+			return
+		}
+	}
+}
+
+// wait handles the next message, returning false if none came in time.
+func (l *Link) wait() bool {
+	l.timer.Reset(stallTimeout)
+	defer l.timer.Stop()
+	select {
+	case m, ok := <-l.incoming:
+		l.handle(m, ok)
+		return true
+	case <-l.timer.C:
+		return false
+	}
+}
+
+func (l *Link) handle(m message, ok bool) {
+	if !ok {
+		if l.online {
+			slog.Info("serial link: disconnected")
+		}
+		l.online = false
+		return
+	}
+	l.stalled = false
+	l.peerTime = max(l.peerTime, m.time)
+
+	switch m.kind {
+	case msgTransfer:
+		l.pending = append(l.pending, incomingTransfer{
+			done: m.time + uint64(m.length),
+			data: m.data,
+			seq:  m.seq,
+		})
+	case msgReply:
+		if l.transferActive && m.seq == l.seq {
+			l.replied = true
+			l.reply = m.data
+		}
+	}
+}
+
+func (l *Link) send(m message) {
+	m.time = l.now
+	l.buf = [messageSize]byte{m.kind, m.data, m.seq}
+	binary.LittleEndian.PutUint32(l.buf[4:], m.length)
+	binary.LittleEndian.PutUint64(l.buf[8:], m.time)
+	if _, err := l.conn.Write(l.buf[:]); err != nil {
+		// the receiving side notices too and closes incoming
+		l.Close()
+		return
+	}
+	l.lastSync = l.now
+}
+
+// receive reads messages from the peer until the connection is closed.
+func (l *Link) receive() {
+	defer close(l.incoming)
+	buf := make([]byte, messageSize)
+	for {
+		if _, err := io.ReadFull(l.conn, buf); err != nil {
+			if !errors.Is(err, io.EOF) && !errors.Is(err, net.ErrClosed) && !errors.Is(err, io.ErrClosedPipe) {
+				slog.Warn("serial link: receiving", "error", err)
+			}
+			return
+		}
+		l.incoming <- message{
+			kind:   buf[0],
+			data:   buf[1],
+			seq:    buf[2],
+			length: binary.LittleEndian.Uint32(buf[4:]),
+			time:   binary.LittleEndian.Uint64(buf[8:]),
+		}
+	}
+}
@@ -0,0 +1,209 @@
+package serial
+
+import (
+	"bytes"
+	"io"
+	"net"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/valerio/go-jeebie/jeebie/addr"
+	"github.com/valerio/go-jeebie/jeebie/state"
+)
+
+// linkSide is one end of a link cable, recording when interrupts happen.
+type linkSide struct {
+	*Link
+	cycles int
+	irqs   []int
+}
+
+func newLinkSide(conn io.ReadWriteCloser) *linkSide {
+	s := &linkSide{}
+	s.Link = NewLink(conn, func() { s.irqs = append(s.irqs, s.cycles) })
+	return s
+}
+
+// run ticks both sides for cycles on their own goroutines, like two
+// emulators would.
+func run(a, b *linkSide, cycles int) {
+	var wg sync.WaitGroup
+	for _, s := range []*linkSide{a, b} {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for range cycles / 4 {
+				s.cycles += 4
+				s.Tick(4)
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+func newPipeSides(t *testing.T) (*linkSide, *linkSide) {
+	c1, c2 := net.Pipe()
+	a, b := newLinkSide(c1), newLinkSide(c2)
+	t.Cleanup(func() {
+		a.Close()
+		b.Close()
+	})
+	return a, b
+}
+
+func TestLinkTransfer(t *testing.T) {
+	master, slave := newPipeSides(t)
+	master.Write(addr.SB, 0x42)
+	slave.Write(addr.SB, 0x99)
+	slave.Write(addr.SC, 0x80)
+	master.Write(addr.SC, 0x81)
+
+	run(master, slave, 3*transferCycles)
+
+	assert.Equal(t, byte(0x99), master.Read(addr.SB))
+	assert.Equal(t, byte(0x42), slave.Read(addr.SB))
+	assert.Equal(t, byte(0x01), master.Read(addr.SC))
+	assert.Equal(t, byte(0x00), slave.Read(addr.SC))
+	assert.Equal(t, []int{transferCycles}, master.irqs)
+	assert.Equal(t, []int{transferCycles}, slave.irqs, "both sides complete at the same cycle")
+}
+
+func TestLinkSlaveNotReady(t *testing.T) {
+	master, slave := newPipeSides(t)
+	master.Write(addr.SB, 0x42)
+	slave.Write(addr.SB, 0x99)
+	master.Write(addr.SC, 0x81)
+
+	run(master, slave, 2*transferCycles)
+
+	assert.Equal(t, byte(0xFF), master.Read(addr.SB))
+	assert.Equal(t, []int{transferCycles}, master.irqs)
+	assert.Equal(t, byte(0x99), slave.Read(addr.SB))
+	assert.Empty(t, slave.irqs)
+}
+
+func TestLinkFastClock(t *testing.T) {
+	master, slave := newPipeSides(t)
+	master.Write(addr.SB, 0x12)
+	slave.Write(addr.SB, 0x34)
+	slave.Write(addr.SC, 0x80)
+	master.Write(addr.SC, 0x83)
+
+	run(master, slave, transferCycles)
+
+	assert.Equal(t, byte(0x34), master.Read(addr.SB))
+	assert.Equal(t, byte(0x12), slave.Read(addr.SB))
+	assert.Equal(t, []int{fastTransferCycles}, master.irqs)
+	require.Len(t, slave.irqs, 1)
+}
+
+func TestLinkTCP(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer ln.Close()
+
+	accepted := make(chan net.Conn)
+	go func() {
+		conn, err := ln.Accept()
+		assert.NoError(t, err)
+		accepted <- conn
+	}()
+	dialed, err := net.Dial("tcp", ln.Addr().String())
+	require.NoError(t, err)
+	master, slave := newLinkSide(dialed), newLinkSide(<-accepted)
+	defer master.Close()
+	defer slave.Close()
+
+	// the slave answers each byte with the previous one it received
+	slave.Write(addr.SC, 0x80)
+	for _, b := range []byte{1, 2, 3} {
+		master.Write(addr.SB, b)
+		master.Write(addr.SC, 0x81)
+		run(master, slave, transferCycles)
+		assert.Equal(t, b-1, master.Read(addr.SB))
+		assert.Equal(t, b, slave.Read(addr.SB))
+		slave.Write(addr.SC, 0x80)
+	}
+	assert.Len(t, master.irqs, 3)
+	assert.Len(t, slave.irqs, 3)
+}
+
+func TestLinkDisconnect(t *testing.T) {
+	master, slave := newPipeSides(t)
+	require.NoError(t, slave.Close())
+
+	master.Write(addr.SB, 0x42)
+	master.Write(addr.SC, 0x81)
+	for range transferCycles / 4 {
+		master.cycles += 4
+		master.Tick(4)
+	}
+
+	assert.False(t, master.Online())
+	assert.Equal(t, byte(0xFF), master.Read(addr.SB))
+	assert.Equal(t, []int{transferCycles}, master.irqs)
+}
+
+func TestLinkReplay(t *testing.T) {
+	master, slave := newPipeSides(t)
+	master.Write(addr.SB, 0x42)
+	slave.Write(addr.SB, 0x99)
+	slave.Write(addr.SC, 0x80)
+
+	var buf bytes.Buffer
+	w := state.NewWriter(&buf)
+	master.SerializeState(w)
+	require.NoError(t, w.Err())
+	master.Write(addr.SC, 0x81)
+	run(master, slave, 2*transferCycles)
+	require.Equal(t, byte(0x99), master.Read(addr.SB))
+
+	// back to before the transfer, which completes again without the peer
+	r := state.NewReader(&buf)
+	master.SerializeState(r)
+	require.NoError(t, r.Err())
+	assert.Equal(t, byte(0x42), master.Read(addr.SB))
+	master.SetReplaying(true)
+	master.Write(addr.SC, 0x81)
+	for range transferCycles / 4 {
+		master.cycles += 4
+		master.Tick(4)
+	}
+	master.SetReplaying(false)
+	assert.Equal(t, byte(0x99), master.Read(addr.SB))
+	assert.Equal(t, byte(0x01), master.Read(addr.SC))
+	assert.Len(t, master.irqs, 2)
+
+	// the peer didn't see the replayed transfer
+	slave.Write(addr.SC, 0x80)
+	run(master, slave, 2*transferCycles)
+	assert.Equal(t, byte(0x80), slave.Read(addr.SC))
+	assert.Len(t, slave.irqs, 1)
+}
+
+func TestLinkLoadMidTransfer(t *testing.T) {
+	master, slave := newPipeSides(t)
+	master.Write(addr.SB, 0x42)
+	slave.Write(addr.SB, 0x99)
+	slave.Write(addr.SC, 0x80)
+	master.Write(addr.SC, 0x81)
+	run(master, slave, transferCycles/2)
+
+	var buf bytes.Buffer
+	w := state.NewWriter(&buf)
+	master.SerializeState(w)
+	require.NoError(t, w.Err())
+	run(master, slave, transferCycles)
+	require.Equal(t, byte(0x99), master.Read(addr.SB))
+
+	// the peer never heard of the restored transfer, the reply to the one
+	// that completed since doesn't answer it
+	r := state.NewReader(&buf)
+	master.SerializeState(r)
+	require.NoError(t, r.Err())
+	run(master, slave, transferCycles)
+	assert.Equal(t, byte(0x01), master.Read(addr.SC))
+	assert.Equal(t, byte(0xFF), master.Read(addr.SB))
+}
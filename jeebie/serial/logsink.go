@@ -7,6 +7,15 @@ import (
 	"github.com/valerio/go-jeebie/jeebie/bit"
 )
 
+// Limits on what the sink keeps, so a game streaming bytes forever doesn't
+// grow memory without bound.
+const (
+	// maxOutput is how many of the latest bytes Output returns.
+	maxOutput = 64 << 10
+	// maxLine is the longest line logged, longer ones are logged in pieces.
+	maxLine = 256
+)
+
 // LogSink implements a dummy serial device that just logs outgoing bytes as text.
 // Handy for debugging test roms that output to serial.
 type LogSink struct {
@@ -22,6 +31,10 @@ type LogSink struct {
 
 	// Optional line buffer for readable output
 	line []byte
+	// The latest bytes sent, see Output
+	output []byte
+	// bytes sent again by a replay were already logged and recorded
+	replaying bool
 }
 
 type LogSinkOption func(*LogSink)
@@ -87,6 +100,17 @@ func (s *LogSink) Reset() {
 	s.transferActive = false
 	s.countdown = 0
 	s.line = s.line[:0]
+	s.output = s.output[:0]
+}
+
+// Output returns the bytes sent through the sink since the last reset, up to
+// the latest 64KB.
+func (s *LogSink) Output() []byte {
+	out := s.output
+	if len(out) > maxOutput {
+		out = out[len(out)-maxOutput:]
+	}
+	return append([]byte(nil), out...)
 }
 
 func (s *LogSink) maybeStartTransfer() {
@@ -98,15 +122,8 @@ func (s *LogSink) maybeStartTransfer() {
 		return
 	}
 
-	// log the outgoing byte as text; buffer until newline for readability
-	b := s.sb
-	if b == 0 || b == '\n' || b == '\r' {
-		if len(s.line) > 0 {
-			s.logger.Info("serial", "line", string(s.line))
-			s.line = s.line[:0]
-		}
-	} else {
-		s.line = append(s.line, b)
+	if !s.replaying {
+		s.record(s.sb)
 	}
 
 	if s.immediate {
@@ -119,6 +136,37 @@ func (s *LogSink) maybeStartTransfer() {
 	s.countdown = 4096
 }
 
+// SetReplaying stops recording and logging bytes while instructions that
+// already ran are executed again.
+func (s *LogSink) SetReplaying(replaying bool) {
+	s.replaying = replaying
+}
+
+// record logs the outgoing byte as text, buffered until newline for
+// readability, and keeps it for Output.
+func (s *LogSink) record(b byte) {
+	if len(s.output) == 2*maxOutput {
+		// drop the oldest half, so trimming is rare
+		s.output = append(s.output[:0], s.output[maxOutput:]...)
+	}
+	s.output = append(s.output, b)
+	if b == 0 || b == '\n' || b == '\r' {
+		s.flushLine()
+	} else {
+		s.line = append(s.line, b)
+		if len(s.line) == maxLine {
+			s.flushLine()
+		}
+	}
+}
+
+func (s *LogSink) flushLine() {
+	if len(s.line) > 0 {
+		s.logger.Info("serial", "line", string(s.line))
+		s.line = s.line[:0]
+	}
+}
+
 func (s *LogSink) completeTransfer() {
 	s.sb = s.defaultRX
 	// Clear start bit (bit7) to indicate completion
@@ -0,0 +1,48 @@
+package serial
+
+import (
+	"io"
+	"log/slog"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/valerio/go-jeebie/jeebie/addr"
+)
+
+func TestLogSinkOutputLimit(t *testing.T) {
+	s := NewLogSink(nil)
+	s.logger = slog.New(slog.NewTextHandler(io.Discard, nil))
+	send := func(b byte) {
+		s.Write(addr.SB, b)
+		s.Write(addr.SC, 0x81)
+	}
+
+	for i := range 3 * maxOutput {
+		send(byte(i))
+	}
+	send('!')
+
+	out := s.Output()
+	assert.Len(t, out, maxOutput, "only the latest bytes are kept")
+	assert.Equal(t, byte('!'), out[len(out)-1])
+	assert.Equal(t, byte((2*maxOutput+1)%256), out[0])
+	assert.LessOrEqual(t, cap(s.output), 4*maxOutput)
+	assert.Less(t, len(s.line), maxLine)
+}
+
+func TestLogSinkReplaying(t *testing.T) {
+	irqs := 0
+	s := NewLogSink(func() { irqs++ })
+	send := func(b byte) {
+		s.Write(addr.SB, b)
+		s.Write(addr.SC, 0x81)
+	}
+
+	send('a')
+	s.SetReplaying(true)
+	send('a')
+	s.SetReplaying(false)
+	send('b')
+	assert.Equal(t, []byte("ab"), s.Output(), "replayed bytes were already recorded")
+	assert.Equal(t, 3, irqs)
+}
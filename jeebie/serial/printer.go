@@ -0,0 +1,437 @@
+package serial
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"log/slog"
+	"os"
+	"path/filepath"
+
+	"github.com/valerio/go-jeebie/jeebie/addr"
+	"github.com/valerio/go-jeebie/jeebie/bit"
+)
+
+// Printer commands.
+const (
+	printerInit   byte = 0x01
+	printerPrint  byte = 0x02
+	printerData   byte = 0x04
+	printerStatus byte = 0x0F
+)
+
+// Printer status bits.
+const (
+	PrinterChecksumError byte = 1 << iota
+	PrinterBusy
+	PrinterImageFull
+	PrinterUnprocessed
+	PrinterPacketError
+	PrinterPaperJam
+	PrinterOtherError
+	PrinterLowBattery
+)
+
+const (
+	printerWidth    = 160
+	printerBandSize = 0x280 // 160x16 pixels, the data of a full packet
+	printerMaxData  = 9 * printerBandSize
+	// printerAlive is the device ID the printer answers after a checksum.
+	printerAlive = 0x81
+	// printerLineCycles is how long printing a line of pixels takes, about
+	// 2.3 seconds for a full screen.
+	printerLineCycles = 4194304 / 64
+	// printerFeedLines is the height of a line feed in the margins.
+	printerFeedLines = 8
+)
+
+// Packet byte positions, data bytes are between length and checksum.
+const (
+	packetMagic1 = iota
+	packetMagic2
+	packetCommand
+	packetCompression
+	packetLengthLow
+	packetLengthHigh
+	packetData
+	packetChecksumLow
+	packetChecksumHigh
+	packetAlive
+	packetStatus
+)
+
+// Printer emulates the Game Boy Printer, saving each printed strip as a PNG
+// image in a directory.
+//
+// The game sends packets of:
+//
+//	0x88 0x33 command compression length(2) data(length) checksum(2) 0x00 0x00
+//
+// with 16 bit values little endian and the checksum adding up the bytes from
+// command to data. The printer answers 0x81 to the first trailing 0x00 and
+// its status to the second. Data packets append up to 640 bytes of tiles,
+// run length encoded if compression is 1, and print packets print them with
+// 4 bytes of settings: copies, margins (line feeds before in the upper
+// nibble, after in the lower one), palette and exposure.
+type Printer struct {
+	irqHandler     func()
+	sb, sc         byte
+	transferActive bool
+	countdown      int
+	response       byte
+	logger         *slog.Logger
+
+	dir     string
+	printed int
+	// prints made again by a replay were already saved
+	replaying bool
+
+	// packet being received
+	position    int
+	command     byte
+	compression byte
+	length      int
+	data        []byte
+	sum         uint16
+	checksum    uint16
+
+	status   byte
+	image    []byte // tile data received since the last print
+	printing int    // cycles left until printing ends
+}
+
+// NewPrinter creates a printer saving images to dir, or the current
+// directory if empty. The passed function is called when a transfer is
+// completed, should be wired to request the Serial interrupt.
+func NewPrinter(dir string, irq func()) *Printer {
+	p := &Printer{
+		irqHandler: irq,
+		dir:        dir,
+		logger:     slog.Default(),
+	}
+	p.Reset()
+	return p
+}
+
+// SetReplaying stops saving images while instructions that already ran are
+// executed again.
+func (p *Printer) SetReplaying(replaying bool) {
+	p.replaying = replaying
+}
+
+// Status returns the status byte, see the Printer* constants.
+func (p *Printer) Status() byte {
+	return p.status
+}
+
+func (p *Printer) Write(address uint16, value byte) {
+	switch address {
+	case addr.SB:
+		p.sb = value
+	case addr.SC:
+		p.sc = value
+		p.maybeStartTransfer()
+	default:
+		panic("serial.Printer: invalid write address")
+	}
+}
+
+func (p *Printer) Read(address uint16) byte {
+	switch address {
+	case addr.SB:
+		return p.sb
+	case addr.SC:
+		return p.sc
+	default:
+		panic("serial.Printer: invalid read address")
+	}
+}
+
+func (p *Printer) Tick(cycles int) {
+	if p.printing > 0 {
+		p.printing -= cycles
+		if p.printing <= 0 {
+			p.status &^= PrinterBusy
+		}
+	}
+	if !p.transferActive {
+		return
+	}
+	p.countdown -= cycles
+	if p.countdown <= 0 {
+		p.completeTransfer()
+		p.countdown = 0
+	}
+}
+
+func (p *Printer) Reset() {
+	p.sb = 0x00
+	p.sc = 0x00
+	p.transferActive = false
+	p.countdown = 0
+	p.position = packetMagic1
+	p.status = 0
+	p.image = p.image[:0]
+	p.printing = 0
+}
+
+func (p *Printer) maybeStartTransfer() {
+	// the printer has no clock, the game must drive the transfer
+	if p.transferActive || !bit.IsSet(7, p.sc) || !bit.IsSet(0, p.sc) {
+		return
+	}
+	p.transferActive = true
+	p.countdown = transferCycles
+	if bit.IsSet(1, p.sc) {
+		p.countdown = fastTransferCycles
+	}
+	switch p.position {
+	case packetAlive:
+		p.response = printerAlive
+	case packetStatus:
+		p.response = p.status
+	default:
+		p.response = 0x00
+	}
+}
+
+func (p *Printer) completeTransfer() {
+	received := p.sb
+	p.sb = p.response
+	p.sc = bit.Clear(7, p.sc)
+	p.transferActive = false
+	p.receive(received)
+	if p.irqHandler != nil {
+		p.irqHandler()
+	}
+}
+
+// receive handles a byte of a packet.
+func (p *Printer) receive(b byte) {
+	if p.position >= packetCommand && p.position < packetChecksumLow {
+		p.sum += uint16(b)
+	}
+
+	switch p.position {
+	case packetMagic1:
+		if b == 0x88 {
+			p.position = packetMagic2
+		}
+	case packetMagic2:
+		p.position = packetMagic1
+		if b == 0x33 {
+			p.position = packetCommand
+			p.sum = 0
+			p.data = p.data[:0]
+		} else if b == 0x88 {
+			p.position = packetMagic2
+		}
+	case packetCommand:
+		p.command = b
+		p.position = packetCompression
+	case packetCompression:
+		p.compression = b
+		p.position = packetLengthLow
+	case packetLengthLow:
+		p.length = int(b)
+		p.position = packetLengthHigh
+	case packetLengthHigh:
+		p.length |= int(b) << 8
+		p.position = packetData
+		if p.length == 0 {
+			p.position = packetChecksumLow
+		}
+	case packetData:
+		p.data = append(p.data, b)
+		if len(p.data) == p.length {
+			p.position = packetChecksumLow
+		}
+	case packetChecksumLow:
+		p.checksum = uint16(b)
+		p.position = packetChecksumHigh
+	case packetChecksumHigh:
+		p.checksum |= uint16(b) << 8
+		p.position = packetAlive
+		p.process()
+	case packetAlive:
+		p.position = packetStatus
+	case packetStatus:
+		p.position = packetMagic1
+		p.statusSent()
+	}
+}
+
+// process runs the command of a packet once its checksum is received, so the
+// status answered at the end of the packet reflects it.
+func (p *Printer) process() {
+	p.status &^= PrinterChecksumError | PrinterPacketError
+	if p.checksum != p.sum {
+		p.status |= PrinterChecksumError
+		return
+	}
+
+	switch p.command {
+	case printerInit:
+		if p.status&PrinterBusy == 0 {
+			p.status = 0
+			p.image = p.image[:0]
+		}
+	case printerData:
+		if p.length > printerBandSize {
+			p.status |= PrinterPacketError
+			return
+		}
+		if p.status&PrinterBusy != 0 || p.length == 0 {
+			// an empty packet ends the data
+			return
+		}
+		data := p.data
+		if p.compression != 0 {
+			data = decompress(p.data)
+		}
+		p.image = append(p.image, data[:min(len(data), printerMaxData-len(p.image))]...)
+		p.status |= PrinterUnprocessed
+		if len(p.image) >= printerMaxData {
+			p.status |= PrinterImageFull
+		}
+	case printerPrint:
+		if p.length != 4 {
+			p.status |= PrinterPacketError
+		}
+	case printerStatus:
+	default:
+		p.status |= PrinterPacketError
+	}
+}
+
+// statusSent finishes a packet after its status byte went out.
+func (p *Printer) statusSent() {
+	if p.status&(PrinterChecksumError|PrinterPacketError) != 0 {
+		return
+	}
+	switch p.command {
+	case printerPrint:
+		if p.status&PrinterBusy != 0 || p.status&PrinterUnprocessed == 0 {
+			return
+		}
+		p.print(p.data[0], p.data[1], p.data[2], p.data[3])
+	case printerStatus:
+		if p.status&PrinterBusy == 0 {
+			// the end of a print is reported once
+			p.status &^= PrinterImageFull
+		}
+	}
+}
+
+// print prints the image data received so far.
+func (p *Printer) print(copies, margins, palette, exposure byte) {
+	strip := p.image
+	p.image = p.image[:0]
+	p.status = p.status&^PrinterUnprocessed | PrinterBusy | PrinterImageFull
+
+	img := renderPrint(strip, int(copies), margins, palette, exposure)
+	p.printing = max(img.Rect.Dy(), 1) * printerLineCycles
+	if copies == 0 || p.replaying {
+		// only feeds paper, or was already saved
+		return
+	}
+	if err := p.save(img); err != nil {
+		p.logger.Error("printer: saving image", "error", err)
+	}
+}
+
+// save writes img to the next free printNNNN.png file.
+func (p *Printer) save(img image.Image) error {
+	var path string
+	var f *os.File
+	for {
+		p.printed++
+		path = filepath.Join(p.dir, fmt.Sprintf("print%04d.png", p.printed))
+		var err error
+		f, err = os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+		if err == nil {
+			break
+		}
+		if !os.IsExist(err) {
+			return err
+		}
+	}
+	if err := png.Encode(f, img); err != nil {
+		f.Close()
+		return err
+	}
+	p.logger.Info("printer: printed", "path", path, "size", fmt.Sprintf("%dx%d", img.Bounds().Dx(), img.Bounds().Dy()))
+	return f.Close()
+}
+
+// decompress expands run length encoded data: a control byte with bit 7 set
+// repeats the next byte (control & 0x7F) + 2 times, otherwise the next
+// control + 1 bytes are copied.
+func decompress(data []byte) []byte {
+	var out []byte
+	for i := 0; i < len(data); {
+		control := data[i]
+		i++
+		if control&0x80 != 0 {
+			if i < len(data) {
+				for range int(control&0x7F) + 2 {
+					out = append(out, data[i])
+				}
+			}
+			i++
+			continue
+		}
+		n := min(int(control)+1, len(data)-i)
+		out = append(out, data[i:i+n]...)
+		i += n
+	}
+	return out
+}
+
+// renderPrint draws tile data 20 tiles wide, copies times, with margins of
+// line feeds above (upper nibble) and below (lower nibble). The palette maps
+// color numbers to shades like BGP does. Exposure darkens or lightens by up
+// to 25% around 0x40.
+func renderPrint(tiles []byte, copies int, margins, palette, exposure byte) *image.Paletted {
+	if palette == 0 {
+		// some games leave the palette unset
+		palette = 0xE4
+	}
+	tileRows := len(tiles) / (printerWidth / 8 * 16)
+	stripHeight := tileRows * 8
+	top := int(margins>>4) * printerFeedLines
+	bottom := int(margins&0x0F) * printerFeedLines
+	height := top + copies*stripHeight + bottom
+
+	img := image.NewPaletted(image.Rect(0, 0, printerWidth, height), printerPalette(exposure))
+	for n := range copies {
+		for row := range tileRows {
+			for col := range printerWidth / 8 {
+				tile := tiles[(row*printerWidth/8+col)*16:]
+				for y := range 8 {
+					low, high := tile[y*2], tile[y*2+1]
+					for x := range 8 {
+						shift := 7 - x
+						c := (low>>shift)&1 | (high>>shift)&1<<1
+						shade := palette >> (c * 2) & 0x03
+						img.SetColorIndex(col*8+x, top+n*stripHeight+row*8+y, shade)
+					}
+				}
+			}
+		}
+	}
+	return img
+}
+
+// printerPalette returns the shades of the thermal paper for exposure.
+func printerPalette(exposure byte) color.Palette {
+	darkness := 0.75 + 0.5*float64(exposure&0x7F)/0x80
+	palette := make(color.Palette, 4)
+	for shade := range palette {
+		ink := min(float64(shade)/3*darkness, 1)
+		gray := uint8(255 - ink*255 + 0.5)
+		palette[shade] = color.Gray{Y: gray}
+	}
+	return palette
+}
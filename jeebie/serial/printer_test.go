@@ -0,0 +1,148 @@
+package serial
+
+import (
+	"image"
+	"image/color"
+	"image/png"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/valerio/go-jeebie/jeebie/addr"
+)
+
+// sendPacket sends a printer packet as a game would, returning the alive and
+// status bytes answered at its end.
+func sendPacket(t *testing.T, p *Printer, command, compression byte, data []byte) (alive, status byte) {
+	t.Helper()
+	packet := []byte{0x88, 0x33, command, compression, byte(len(data)), byte(len(data) >> 8)}
+	packet = append(packet, data...)
+	sum := uint16(0)
+	for _, b := range packet[2:] {
+		sum += uint16(b)
+	}
+	packet = append(packet, byte(sum), byte(sum>>8), 0x00, 0x00)
+
+	var answers []byte
+	for _, b := range packet {
+		p.Write(addr.SB, b)
+		p.Write(addr.SC, 0x81)
+		p.Tick(transferCycles)
+		require.Equal(t, byte(0x01), p.Read(addr.SC), "transfer done")
+		answers = append(answers, p.Read(addr.SB))
+	}
+	return answers[len(answers)-2], answers[len(answers)-1]
+}
+
+func TestPrinterPrint(t *testing.T) {
+	dir := t.TempDir()
+	irqs := 0
+	p := NewPrinter(dir, func() { irqs++ })
+
+	alive, status := sendPacket(t, p, printerInit, 0, nil)
+	assert.Equal(t, byte(printerAlive), alive)
+	assert.Equal(t, byte(0x00), status)
+	assert.Equal(t, 10, irqs, "one interrupt per byte")
+
+	// two rows of 20 tiles: color 1 on the left half, color 3 on the right
+	band := make([]byte, printerBandSize)
+	for tile := range 40 {
+		for row := range 8 {
+			if tile%20 < 10 {
+				band[tile*16+row*2] = 0xFF
+			} else {
+				band[tile*16+row*2] = 0xFF
+				band[tile*16+row*2+1] = 0xFF
+			}
+		}
+	}
+	_, status = sendPacket(t, p, printerData, 0, band)
+	assert.Equal(t, PrinterUnprocessed, status)
+	_, status = sendPacket(t, p, printerData, 0, nil)
+	assert.Equal(t, PrinterUnprocessed, status)
+
+	_, status = sendPacket(t, p, printerPrint, 0, []byte{1, 0x12, 0xE4, 0x40})
+	assert.Equal(t, PrinterUnprocessed, status)
+	_, status = sendPacket(t, p, printerStatus, 0, nil)
+	assert.Equal(t, PrinterBusy|PrinterImageFull, status)
+
+	p.Tick(40 * printerLineCycles)
+	_, status = sendPacket(t, p, printerStatus, 0, nil)
+	assert.Equal(t, PrinterImageFull, status, "the end of the print is reported")
+	_, status = sendPacket(t, p, printerStatus, 0, nil)
+	assert.Equal(t, byte(0x00), status)
+
+	f, err := os.Open(filepath.Join(dir, "print0001.png"))
+	require.NoError(t, err)
+	defer f.Close()
+	img, err := png.Decode(f)
+	require.NoError(t, err)
+
+	// one line feed above, 16 lines of image, two line feeds below
+	assert.Equal(t, image.Rect(0, 0, 160, 8+16+16), img.Bounds())
+	gray := func(x, y int) uint8 { return color.GrayModel.Convert(img.At(x, y)).(color.Gray).Y }
+	assert.Equal(t, uint8(0xFF), gray(0, 0), "margin")
+	assert.Equal(t, uint8(0xAA), gray(0, 8), "color 1")
+	assert.Equal(t, uint8(0x00), gray(159, 23), "color 3")
+	assert.Equal(t, uint8(0xFF), gray(159, 24), "margin")
+}
+
+func TestPrinterChecksumError(t *testing.T) {
+	p := NewPrinter(t.TempDir(), nil)
+	for _, b := range []byte{0x88, 0x33, printerInit, 0, 0, 0, 0xFF, 0xFF, 0, 0} {
+		p.Write(addr.SB, b)
+		p.Write(addr.SC, 0x81)
+		p.Tick(transferCycles)
+	}
+	assert.Equal(t, PrinterChecksumError, p.Read(addr.SB))
+
+	_, status := sendPacket(t, p, printerStatus, 0, nil)
+	assert.Equal(t, byte(0x00), status, "cleared by a valid packet")
+
+	_, status = sendPacket(t, p, 0x7E, 0, nil)
+	assert.Equal(t, PrinterPacketError, status)
+}
+
+func TestPrinterExternalClock(t *testing.T) {
+	p := NewPrinter(t.TempDir(), nil)
+	p.Write(addr.SB, 0x88)
+	p.Write(addr.SC, 0x80)
+	p.Tick(2 * transferCycles)
+	assert.Equal(t, byte(0x80), p.Read(addr.SC), "the printer doesn't drive the clock")
+}
+
+func TestPrinterDecompress(t *testing.T) {
+	assert.Equal(t,
+		[]byte{1, 2, 3, 7, 7, 7, 7, 9},
+		decompress([]byte{0x02, 1, 2, 3, 0x82, 7, 0x00, 9}))
+}
+
+func TestPrinterReplaying(t *testing.T) {
+	dir := t.TempDir()
+	p := NewPrinter(dir, nil)
+	p.SetReplaying(true)
+
+	sendPacket(t, p, printerInit, 0, nil)
+	sendPacket(t, p, printerData, 0, make([]byte, printerBandSize))
+	sendPacket(t, p, printerPrint, 0, []byte{1, 0x00, 0xE4, 0x40})
+	_, status := sendPacket(t, p, printerStatus, 0, nil)
+	assert.Equal(t, PrinterBusy|PrinterImageFull, status, "printing is still emulated")
+
+	entries, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	assert.Empty(t, entries, "the print was already saved")
+}
+
+func TestPrinterSaveError(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "file")
+	require.NoError(t, os.WriteFile(dir, nil, 0644))
+	p := NewPrinter(dir, nil)
+
+	sendPacket(t, p, printerData, 0, make([]byte, printerBandSize))
+	sendPacket(t, p, printerPrint, 0, []byte{1, 0x00, 0xE4, 0x40})
+	_, status := sendPacket(t, p, printerStatus, 0, nil)
+	assert.Equal(t, PrinterBusy|PrinterImageFull, status, "printing goes on without saving")
+	assert.Equal(t, 1, p.printed, "the first file name is tried once")
+}
@@ -0,0 +1,152 @@
+package serial
+
+import (
+	"io"
+	"log/slog"
+
+	"github.com/valerio/go-jeebie/jeebie/addr"
+	"github.com/valerio/go-jeebie/jeebie/bit"
+)
+
+// Script is a scripted link cable partner: it answers each transfer with
+// the next byte of a script and records the bytes it receives, to test
+// multiplayer code without a second emulator.
+//
+// When the game clocks a transfer (SC = 0x81), the script answers with its
+// next byte, or 0xFF once it runs out. When the game waits for the external
+// clock (SC = 0x80), the script clocks its next byte after a byte's transfer
+// time, and never does once it runs out.
+type Script struct {
+	irqHandler     func()
+	sb, sc         byte
+	transferActive bool
+	countdown      int
+
+	script   []byte
+	next     int
+	received []byte
+	out      io.Writer
+	outErr   error
+	// bytes received again by a replay were already recorded
+	replaying bool
+}
+
+// NewScript creates a partner replaying script, writing the bytes it
+// receives to out if not nil. The passed function is called when a transfer
+// is completed, should be wired to request the Serial interrupt.
+func NewScript(script []byte, out io.Writer, irq func()) *Script {
+	s := &Script{
+		irqHandler: irq,
+		script:     script,
+		out:        out,
+	}
+	s.Reset()
+	return s
+}
+
+func (s *Script) Write(address uint16, value byte) {
+	switch address {
+	case addr.SB:
+		s.sb = value
+	case addr.SC:
+		s.sc = value
+		if !bit.IsSet(7, s.sc) {
+			s.transferActive = false
+			return
+		}
+		s.maybeStartTransfer()
+	default:
+		panic("serial.Script: invalid write address")
+	}
+}
+
+func (s *Script) Read(address uint16) byte {
+	switch address {
+	case addr.SB:
+		return s.sb
+	case addr.SC:
+		return s.sc
+	default:
+		panic("serial.Script: invalid read address")
+	}
+}
+
+func (s *Script) Tick(cycles int) {
+	if !s.transferActive {
+		return
+	}
+	s.countdown -= cycles
+	if s.countdown <= 0 {
+		s.completeTransfer()
+		s.countdown = 0
+	}
+}
+
+// Reset clears the registers. The script keeps its position and the bytes
+// received so far.
+func (s *Script) Reset() {
+	s.sb = 0x00
+	s.sc = 0x00
+	s.transferActive = false
+	s.countdown = 0
+}
+
+// Output returns all bytes received from the game.
+func (s *Script) Output() []byte {
+	return append([]byte(nil), s.received...)
+}
+
+// Remaining returns how many bytes of the script are left.
+func (s *Script) Remaining() int {
+	return len(s.script) - s.next
+}
+
+// SetReplaying stops recording received bytes while instructions that
+// already ran are executed again.
+func (s *Script) SetReplaying(replaying bool) {
+	s.replaying = replaying
+}
+
+func (s *Script) maybeStartTransfer() {
+	if s.transferActive {
+		return
+	}
+	internal := bit.IsSet(0, s.sc)
+	if !internal && s.Remaining() == 0 {
+		// nothing left to clock
+		return
+	}
+	s.transferActive = true
+	s.countdown = transferCycles
+	if internal && bit.IsSet(1, s.sc) {
+		s.countdown = fastTransferCycles
+	}
+}
+
+func (s *Script) completeTransfer() {
+	sent := s.sb
+	s.sb = 0xFF
+	if s.Remaining() > 0 {
+		s.sb = s.script[s.next]
+		s.next++
+	}
+	s.sc = bit.Clear(7, s.sc)
+	s.transferActive = false
+
+	if !s.replaying {
+		s.record(sent)
+	}
+	if s.irqHandler != nil {
+		s.irqHandler()
+	}
+}
+
+// record keeps a byte received from the game and writes it to out.
+func (s *Script) record(b byte) {
+	s.received = append(s.received, b)
+	if s.out != nil && s.outErr == nil {
+		if _, s.outErr = s.out.Write([]byte{b}); s.outErr != nil {
+			slog.Error("serial script: recording", "error", s.outErr)
+		}
+	}
+}
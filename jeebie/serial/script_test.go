@@ -0,0 +1,71 @@
+package serial
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/valerio/go-jeebie/jeebie/addr"
+	"github.com/valerio/go-jeebie/jeebie/state"
+)
+
+func TestScriptInternalClock(t *testing.T) {
+	var out bytes.Buffer
+	irqs := 0
+	s := NewScript([]byte{0x10, 0x20}, &out, func() { irqs++ })
+
+	for i, want := range []byte{0x10, 0x20, 0xFF} {
+		s.Write(addr.SB, byte(i))
+		s.Write(addr.SC, 0x81)
+		s.Tick(transferCycles - 4)
+		assert.Equal(t, byte(0x81), s.Read(addr.SC), "still shifting")
+		s.Tick(4)
+		assert.Equal(t, byte(0x01), s.Read(addr.SC))
+		assert.Equal(t, want, s.Read(addr.SB))
+	}
+	assert.Equal(t, 3, irqs)
+	assert.Equal(t, []byte{0, 1, 2}, out.Bytes())
+	assert.Equal(t, []byte{0, 1, 2}, s.Output())
+}
+
+func TestScriptExternalClock(t *testing.T) {
+	irqs := 0
+	s := NewScript([]byte{0x10}, nil, func() { irqs++ })
+
+	s.Write(addr.SB, 0xAA)
+	s.Write(addr.SC, 0x80)
+	s.Tick(transferCycles)
+	assert.Equal(t, byte(0x10), s.Read(addr.SB))
+	assert.Equal(t, byte(0x00), s.Read(addr.SC))
+	assert.Equal(t, 0, s.Remaining())
+
+	s.Write(addr.SC, 0x80)
+	s.Tick(4 * transferCycles)
+	assert.Equal(t, byte(0x80), s.Read(addr.SC), "no more bytes to clock")
+	assert.Equal(t, 1, irqs)
+	assert.Equal(t, []byte{0xAA}, s.Output())
+}
+
+func TestScriptState(t *testing.T) {
+	s := NewScript([]byte{0x10, 0x20, 0x30}, nil, nil)
+	transfer := func() byte {
+		s.Write(addr.SC, 0x81)
+		s.Tick(transferCycles)
+		return s.Read(addr.SB)
+	}
+	require.Equal(t, byte(0x10), transfer())
+
+	var buf bytes.Buffer
+	w := state.NewWriter(&buf)
+	s.SerializeState(w)
+	require.NoError(t, w.Err())
+	require.Equal(t, byte(0x20), transfer())
+
+	r := state.NewReader(&buf)
+	s.SerializeState(r)
+	require.NoError(t, r.Err())
+	assert.Equal(t, 2, s.Remaining(), "the script is rewound")
+	assert.Equal(t, byte(0x20), transfer())
+	assert.Equal(t, byte(0x30), transfer())
+}
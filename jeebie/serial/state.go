@@ -0,0 +1,61 @@
+package serial
+
+import "github.com/valerio/go-jeebie/jeebie/state"
+
+// SerializeState saves or restores the transfer registers and progress.
+// The pending log line is not part of the machine state and is left alone.
+func (s *LogSink) SerializeState(st *state.Serializer) {
+	st.Uint8(&s.sb)
+	st.Uint8(&s.sc)
+	st.Bool(&s.transferActive)
+	st.Int(&s.countdown)
+}
+
+// SerializeState saves or restores the transfer clocked by this side and the
+// emulated cycle count replays go by. The link time, the peer's transfers
+// and the history belong to the connection and are left alone. The peer was
+// never told about a restored transfer, so it completes as if unlinked.
+func (l *Link) SerializeState(st *state.Serializer) {
+	st.Uint8(&l.sb)
+	st.Uint8(&l.sc)
+	st.Bool(&l.transferActive)
+	st.Int(&l.countdown)
+	st.Uint64(&l.elapsed)
+	if st.Loading() {
+		l.sent = false
+		l.replied = false
+	}
+}
+
+// SerializeState saves or restores the transfer, the packet being received,
+// the status and the image data waiting to be printed.
+func (p *Printer) SerializeState(st *state.Serializer) {
+	st.Uint8(&p.sb)
+	st.Uint8(&p.sc)
+	st.Bool(&p.transferActive)
+	st.Int(&p.countdown)
+	st.Uint8(&p.response)
+
+	st.Int(&p.position)
+	st.Uint8(&p.command)
+	st.Uint8(&p.compression)
+	st.Int(&p.length)
+	st.ByteSlice(&p.data)
+	st.Uint16(&p.sum)
+	st.Uint16(&p.checksum)
+
+	st.Uint8(&p.status)
+	st.ByteSlice(&p.image)
+	st.Int(&p.printing)
+}
+
+// SerializeState saves or restores the transfer and the position in the
+// script. The bytes received are a record of the session, not machine state,
+// and are left alone.
+func (s *Script) SerializeState(st *state.Serializer) {
+	st.Uint8(&s.sb)
+	st.Uint8(&s.sc)
+	st.Bool(&s.transferActive)
+	st.Int(&s.countdown)
+	st.Int(&s.next)
+}
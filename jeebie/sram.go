@@ -0,0 +1,131 @@
+package jeebie
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// sramFlushInterval is how often (in frames) dirty battery RAM is written to disk,
+// for games that never disable RAM after saving. ~5 seconds at 60fps.
+const sramFlushInterval = 300
+
+// savePathFor returns the .sav path for a ROM: same name with a .sav extension,
+// next to the ROM unless a save directory is given.
+func savePathFor(romPath, saveDir string) string {
+	base := filepath.Base(romPath)
+	name := strings.TrimSuffix(base, filepath.Ext(base)) + ".sav"
+	if saveDir == "" {
+		return filepath.Join(filepath.Dir(romPath), name)
+	}
+	return filepath.Join(saveDir, name)
+}
+
+// SavePath returns the path of the battery save file used for this ROM.
+func (e *DMG) SavePath() string {
+	return e.savePath
+}
+
+// loadSRAM loads the battery save file into cartridge RAM and clock, if the
+// cartridge has a battery and the file exists.
+func (e *DMG) loadSRAM() error {
+	sram, rtc := e.bus.MMU.BatteryRAM(), e.bus.MMU.BatteryRTC()
+	if (sram == nil && rtc == nil) || e.savePath == "" {
+		return nil
+	}
+
+	data, err := os.ReadFile(e.savePath)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("reading save file: %w", err)
+	}
+
+	ramSize := 0
+	if sram != nil {
+		ramSize = len(sram.RAM())
+	}
+	if rtc != nil && len(data) > ramSize {
+		// the clock is saved after the RAM
+		if err := rtc.LoadRTCFooter(data[ramSize:]); err != nil {
+			slog.Warn("Ignoring clock in save file", "path", e.savePath, "error", err)
+		}
+		data = data[:ramSize]
+	}
+	if sram != nil {
+		if len(data) != ramSize {
+			slog.Warn("Save file size does not match cartridge RAM", "path", e.savePath, "size", len(data), "expected", ramSize)
+		}
+		sram.LoadRAM(data)
+	}
+	slog.Info("Save file loaded", "path", e.savePath)
+
+	return nil
+}
+
+// FlushSRAM writes the cartridge battery RAM and clock to the save file.
+// It does nothing if the cartridge has neither.
+func (e *DMG) FlushSRAM() error {
+	sram, rtc := e.bus.MMU.BatteryRAM(), e.bus.MMU.BatteryRTC()
+	if (sram == nil && rtc == nil) || e.savePath == "" {
+		return nil
+	}
+
+	if err := os.MkdirAll(filepath.Dir(e.savePath), 0755); err != nil {
+		return fmt.Errorf("creating save directory: %w", err)
+	}
+
+	var data []byte
+	if sram != nil {
+		data = append(data, sram.RAM()...)
+	}
+	if rtc != nil {
+		data = append(data, rtc.RTCFooter()...)
+	}
+
+	// write to a temporary file first, so a crash mid-write can't corrupt the save
+	tmpPath := e.savePath + ".tmp"
+	if err := os.WriteFile(tmpPath, data, 0644); err != nil {
+		return fmt.Errorf("writing save file: %w", err)
+	}
+	if err := os.Rename(tmpPath, e.savePath); err != nil {
+		return fmt.Errorf("writing save file: %w", err)
+	}
+
+	e.bus.MMU.MarkSRAMSaved()
+	slog.Debug("Save file written", "path", e.savePath)
+
+	return nil
+}
+
+// maybeFlushSRAM persists battery RAM when the game has finished writing to it
+// (RAM disabled after a write), or periodically while it stays dirty.
+func (e *DMG) maybeFlushSRAM() {
+	dirty, committed := e.bus.MMU.SRAMStatus()
+	if !dirty {
+		return
+	}
+	if !committed && e.frameCount%sramFlushInterval != 0 {
+		return
+	}
+	if err := e.FlushSRAM(); err != nil {
+		slog.Error("Failed to write save file", "path", e.savePath, "error", err)
+	}
+}
+
+// closeSRAM flushes any unsaved battery RAM to disk. The clock is always
+// saved, to catch up with the time spent off when loaded again.
+func (e *DMG) closeSRAM() error {
+	if e.bus == nil || e.bus.MMU == nil {
+		return nil
+	}
+	if dirty, _ := e.bus.MMU.SRAMStatus(); !dirty && e.bus.MMU.BatteryRTC() == nil {
+		return nil
+	}
+	return e.FlushSRAM()
+}
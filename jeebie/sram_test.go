@@ -0,0 +1,99 @@
+package jeebie
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/valerio/go-jeebie/jeebie/internal/testutil"
+)
+
+// writeTestROM writes a minimal ROM with a valid header to dir, looping forever at 0x100.
+func writeTestROM(t *testing.T, dir string, cartType, ramSize uint8) string {
+	t.Helper()
+
+	return testutil.WriteROM(t, filepath.Join(dir, "test.gb"), cartType, ramSize, map[uint16][]byte{
+		0x100: {0x18, 0xFE}, // JR -2
+	})
+}
+
+func TestSavePathFor(t *testing.T) {
+	assert.Equal(t, filepath.Join("roms", "game.sav"), savePathFor(filepath.Join("roms", "game.gb"), ""))
+	assert.Equal(t, filepath.Join("saves", "game.sav"), savePathFor(filepath.Join("roms", "game.gbc"), "saves"))
+}
+
+func TestBatterySaveRoundTrip(t *testing.T) {
+	dir := t.TempDir()
+	romPath := writeTestROM(t, dir, 0x03, 0x02) // MBC1+RAM+BATTERY, 8KB
+	saveDir := filepath.Join(dir, "saves")
+
+	dmg, err := NewWithFile(romPath, WithSaveDir(saveDir))
+	require.NoError(t, err)
+
+	dmg.bus.MMU.Write(0x0000, 0x0A)
+	dmg.bus.MMU.Write(0xA000, 0x5A)
+	dmg.bus.MMU.Write(0x0000, 0x00)
+	require.NoError(t, dmg.RunUntilFrame())
+
+	data, err := os.ReadFile(filepath.Join(saveDir, "test.sav"))
+	require.NoError(t, err, "save should be flushed once the game disables RAM")
+	assert.Len(t, data, 0x2000)
+	assert.Equal(t, byte(0x5A), data[0])
+
+	dmg, err = NewWithFile(romPath, WithSaveDir(saveDir))
+	require.NoError(t, err)
+	dmg.bus.MMU.Write(0x0000, 0x0A)
+	assert.Equal(t, byte(0x5A), dmg.bus.MMU.Read(0xA000))
+
+	dmg.bus.MMU.Write(0xA001, 0x77)
+	require.NoError(t, dmg.Close())
+	data, err = os.ReadFile(filepath.Join(saveDir, "test.sav"))
+	require.NoError(t, err)
+	assert.Equal(t, byte(0x77), data[1], "Close should flush unsaved RAM")
+}
+
+func TestRTCSave(t *testing.T) {
+	dir := t.TempDir()
+	savePath := filepath.Join(dir, "test.sav")
+
+	t.Run("clock only", func(t *testing.T) {
+		romPath := writeTestROM(t, dir, 0x0F, 0x00) // MBC3+TIMER+BATTERY
+		dmg, err := NewWithFile(romPath)
+		require.NoError(t, err)
+		require.NoError(t, dmg.Close())
+
+		data, err := os.ReadFile(savePath)
+		require.NoError(t, err, "the clock is saved on Close")
+		assert.Len(t, data, 48)
+	})
+
+	t.Run("RAM and clock", func(t *testing.T) {
+		require.NoError(t, os.Remove(savePath))
+		romPath := writeTestROM(t, dir, 0x10, 0x02) // MBC3+TIMER+RAM+BATTERY, 8KB
+		dmg, err := NewWithFile(romPath)
+		require.NoError(t, err)
+		mmu := dmg.bus.MMU
+		mmu.Write(0x0000, 0x0A)
+		mmu.Write(0xA000, 0x5A)
+		mmu.Write(0x4000, 0x09) // RTC minutes
+		mmu.Write(0xA000, 42)
+		require.NoError(t, dmg.Close())
+
+		data, err := os.ReadFile(savePath)
+		require.NoError(t, err)
+		assert.Len(t, data, 0x2000+48)
+
+		dmg, err = NewWithFile(romPath)
+		require.NoError(t, err)
+		mmu = dmg.bus.MMU
+		mmu.Write(0x0000, 0x0A)
+		mmu.Write(0x4000, 0x00)
+		assert.Equal(t, byte(0x5A), mmu.Read(0xA000))
+		mmu.Write(0x6000, 0x00)
+		mmu.Write(0x6000, 0x01)
+		mmu.Write(0x4000, 0x09)
+		assert.Equal(t, byte(42), mmu.Read(0xA000))
+	})
+}
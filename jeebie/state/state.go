@@ -0,0 +1,242 @@
+// Package state implements the binary encoding used for emulator save states.
+//
+// Components describe their state once, through a Serializer that either
+// writes values out or reads them back in place, so the save and load paths
+// can't drift apart:
+//
+//	func (t *Timer) SerializeState(s *state.Serializer) {
+//		s.Uint16(&t.systemCounter)
+//		s.Uint8(&t.tima)
+//	}
+//
+// All values are little-endian. Errors are sticky: after the first failure
+// every call is a no-op, and the error is reported by Err.
+package state
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+)
+
+// ErrSizeMismatch is returned when a byte block in the stream does not match
+// the size of the buffer it is being loaded into.
+var ErrSizeMismatch = errors.New("state: block size mismatch")
+
+// Serializable is implemented by components that can be saved and restored.
+type Serializable interface {
+	SerializeState(s *Serializer)
+}
+
+// Serializer reads or writes component state, depending on how it was created.
+type Serializer struct {
+	w   io.Writer
+	r   io.Reader
+	err error
+	buf [8]byte
+}
+
+// NewWriter returns a Serializer that writes state to w.
+func NewWriter(w io.Writer) *Serializer {
+	return &Serializer{w: w}
+}
+
+// NewReader returns a Serializer that loads state from r.
+func NewReader(r io.Reader) *Serializer {
+	return &Serializer{r: r}
+}
+
+// Loading reports whether the serializer is restoring state (true) or saving it.
+func (s *Serializer) Loading() bool {
+	return s.r != nil
+}
+
+// Err returns the first error encountered, if any.
+func (s *Serializer) Err() error {
+	return s.err
+}
+
+// Fail records err, unless an earlier error was already recorded.
+func (s *Serializer) Fail(err error) {
+	if s.err == nil {
+		s.err = err
+	}
+}
+
+func (s *Serializer) sync(n int) []byte {
+	if s.err != nil {
+		return nil
+	}
+	b := s.buf[:n]
+	if s.r != nil {
+		if _, err := io.ReadFull(s.r, b); err != nil {
+			s.err = err
+			return nil
+		}
+		return b
+	}
+	if _, err := s.w.Write(b); err != nil {
+		s.err = err
+		return nil
+	}
+	return b
+}
+
+func (s *Serializer) Uint8(v *uint8) {
+	if s.Loading() {
+		if b := s.sync(1); b != nil {
+			*v = b[0]
+		}
+		return
+	}
+	s.buf[0] = *v
+	s.sync(1)
+}
+
+func (s *Serializer) Uint16(v *uint16) {
+	if s.Loading() {
+		if b := s.sync(2); b != nil {
+			*v = binary.LittleEndian.Uint16(b)
+		}
+		return
+	}
+	binary.LittleEndian.PutUint16(s.buf[:], *v)
+	s.sync(2)
+}
+
+func (s *Serializer) Uint32(v *uint32) {
+	if s.Loading() {
+		if b := s.sync(4); b != nil {
+			*v = binary.LittleEndian.Uint32(b)
+		}
+		return
+	}
+	binary.LittleEndian.PutUint32(s.buf[:], *v)
+	s.sync(4)
+}
+
+func (s *Serializer) Uint64(v *uint64) {
+	if s.Loading() {
+		if b := s.sync(8); b != nil {
+			*v = binary.LittleEndian.Uint64(b)
+		}
+		return
+	}
+	binary.LittleEndian.PutUint64(s.buf[:], *v)
+	s.sync(8)
+}
+
+func (s *Serializer) Bool(v *bool) {
+	b := uint8(0)
+	if *v {
+		b = 1
+	}
+	s.Uint8(&b)
+	*v = b != 0
+}
+
+func (s *Serializer) Int16(v *int16) {
+	u := uint16(*v)
+	s.Uint16(&u)
+	*v = int16(u)
+}
+
+func (s *Serializer) Int64(v *int64) {
+	u := uint64(*v)
+	s.Uint64(&u)
+	*v = int64(u)
+}
+
+// Int stores an int as 64 bits, regardless of the platform int size.
+func (s *Serializer) Int(v *int) {
+	i := int64(*v)
+	s.Int64(&i)
+	*v = int(i)
+}
+
+func (s *Serializer) Float64(v *float64) {
+	u := math.Float64bits(*v)
+	s.Uint64(&u)
+	*v = math.Float64frombits(u)
+}
+
+// Bytes stores a fixed-size block, prefixed by its length. When loading, the
+// stored length must match len(b) exactly.
+func (s *Serializer) Bytes(b []byte) {
+	n := uint32(len(b))
+	s.Uint32(&n)
+	if s.err != nil {
+		return
+	}
+	if int(n) != len(b) {
+		s.err = fmt.Errorf("%w: got %d bytes, want %d", ErrSizeMismatch, n, len(b))
+		return
+	}
+	s.raw(b)
+}
+
+// maxByteSlice bounds the blocks loaded by ByteSlice, so a corrupt stream
+// doesn't allocate gigabytes.
+const maxByteSlice = 1 << 24
+
+// ByteSlice stores a variable-size block, prefixed by its length. When
+// loading, *b is resized to the stored length.
+func (s *Serializer) ByteSlice(b *[]byte) {
+	n := uint32(len(*b))
+	s.Uint32(&n)
+	if s.err != nil {
+		return
+	}
+	if s.Loading() {
+		if n > maxByteSlice {
+			s.err = fmt.Errorf("%w: %d bytes is too large", ErrSizeMismatch, n)
+			return
+		}
+		*b = append((*b)[:0], make([]byte, n)...)
+	}
+	s.raw(*b)
+}
+
+// raw reads or writes b as is, without a length prefix.
+func (s *Serializer) raw(b []byte) {
+	if s.err != nil {
+		return
+	}
+	if s.Loading() {
+		if _, err := io.ReadFull(s.r, b); err != nil {
+			s.err = err
+		}
+		return
+	}
+	if _, err := s.w.Write(b); err != nil {
+		s.err = err
+	}
+}
+
+// Uint32s stores a fixed-size slice of uint32 values, prefixed by its length.
+func (s *Serializer) Uint32s(v []uint32) {
+	n := uint32(len(v))
+	s.Uint32(&n)
+	if s.err != nil {
+		return
+	}
+	if int(n) != len(v) {
+		s.err = fmt.Errorf("%w: got %d values, want %d", ErrSizeMismatch, n, len(v))
+		return
+	}
+
+	b := make([]byte, 4*len(v))
+	if !s.Loading() {
+		for i, x := range v {
+			binary.LittleEndian.PutUint32(b[4*i:], x)
+		}
+	}
+	s.raw(b)
+	if s.Loading() && s.err == nil {
+		for i := range v {
+			v[i] = binary.LittleEndian.Uint32(b[4*i:])
+		}
+	}
+}
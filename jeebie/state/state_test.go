@@ -0,0 +1,66 @@
+package state
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type sample struct {
+	a  uint8
+	b  uint16
+	c  bool
+	d  int
+	e  float64
+	fb []byte
+	vb []byte
+	px []uint32
+}
+
+func (v *sample) SerializeState(s *Serializer) {
+	s.Uint8(&v.a)
+	s.Uint16(&v.b)
+	s.Bool(&v.c)
+	s.Int(&v.d)
+	s.Float64(&v.e)
+	s.Bytes(v.fb)
+	s.ByteSlice(&v.vb)
+	s.Uint32s(v.px)
+}
+
+func TestRoundTrip(t *testing.T) {
+	in := &sample{a: 1, b: 0xBEEF, c: true, d: -42, e: 1.5, fb: []byte{1, 2, 3}, vb: []byte{4, 5}, px: []uint32{0xFF00FF00, 7}}
+
+	var buf bytes.Buffer
+	w := NewWriter(&buf)
+	in.SerializeState(w)
+	assert.NoError(t, w.Err())
+
+	out := &sample{fb: make([]byte, 3), vb: []byte{9}, px: make([]uint32, 2)}
+	r := NewReader(&buf)
+	out.SerializeState(r)
+	assert.NoError(t, r.Err())
+	assert.Equal(t, in, out)
+}
+
+func TestSizeMismatch(t *testing.T) {
+	var buf bytes.Buffer
+	NewWriter(&buf).Bytes([]byte{1, 2, 3})
+
+	r := NewReader(&buf)
+	r.Bytes(make([]byte, 4))
+	assert.ErrorIs(t, r.Err(), ErrSizeMismatch)
+}
+
+func TestByteSliceTooLarge(t *testing.T) {
+	var buf bytes.Buffer
+	n := uint32(maxByteSlice + 1)
+	NewWriter(&buf).Uint32(&n)
+
+	var b []byte
+	r := NewReader(&buf)
+	r.ByteSlice(&b)
+	assert.ErrorIs(t, r.Err(), ErrSizeMismatch)
+	assert.Empty(t, b)
+}
@@ -0,0 +1,63 @@
+package jeebie
+
+import (
+	"errors"
+	"io/fs"
+	"log/slog"
+	"path/filepath"
+	"strings"
+
+	"github.com/valerio/go-jeebie/jeebie/symbols"
+)
+
+// symbolPathsFor returns where to look for the symbol file of a ROM: next to
+// it with the extension replaced by .sym, as rgblink -n is usually invoked,
+// or with .sym appended.
+func symbolPathsFor(romPath string) []string {
+	return []string{
+		strings.TrimSuffix(romPath, filepath.Ext(romPath)) + ".sym",
+		romPath + ".sym",
+	}
+}
+
+// loadSymbols loads the symbol file set with WithSymbols, or the one next to
+// the ROM if there is one.
+func (e *DMG) loadSymbols(romPath string) error {
+	if e.symbolPath != "" {
+		table, err := symbols.Load(e.symbolPath)
+		if err != nil {
+			return err
+		}
+		e.symbols = table
+		return nil
+	}
+
+	for _, path := range symbolPathsFor(romPath) {
+		table, err := symbols.Load(path)
+		if errors.Is(err, fs.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			// a broken symbol file shouldn't stop the game from running
+			slog.Warn("Ignoring symbol file", "error", err)
+			return nil
+		}
+		slog.Info("Loaded symbols", "path", path, "count", table.Len())
+		e.symbolPath = path
+		e.symbols = table
+		return nil
+	}
+	return nil
+}
+
+// Symbols returns the symbols of the loaded ROM, or nil if there are none.
+func (e *DMG) Symbols() *symbols.Table {
+	return e.symbols
+}
+
+// ROMBank returns the ROM bank mapped at 0x4000-0x7FFF.
+func (e *DMG) ROMBank() int {
+	e.execMutex.Lock()
+	defer e.execMutex.Unlock()
+	return e.bus.MMU.ROMBank()
+}
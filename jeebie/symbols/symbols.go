@@ -0,0 +1,216 @@
+// Package symbols loads symbol files (.sym) written by rgblink, the RGBDS
+// linker, so debug tools can show labels instead of raw addresses.
+//
+// Each line of a symbol file holds a bank, an address and a label:
+//
+//	; comments start with a semicolon
+//	00:0150 Main
+//	00:0158 Main.loop
+//	02:4000 LoadLevel
+//
+// Banks only matter in the switchable ROM bank at 0x4000-0x7FFF, where the
+// same address holds different code depending on the mapped bank. Elsewhere
+// labels are looked up by address alone.
+package symbols
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Symbol is a label from a symbol file.
+type Symbol struct {
+	Bank    int
+	Address uint16
+	Name    string
+	Line    int // line of the symbol file it was read from
+}
+
+// Local reports whether the symbol is a local label, like "Main.loop".
+func (s Symbol) Local() bool {
+	return strings.Contains(s.Name, ".")
+}
+
+type location struct {
+	bank    int
+	address uint16
+}
+
+// Table is a set of symbols, indexed by name and by location.
+type Table struct {
+	byName     map[string]Symbol
+	byLocation map[location]Symbol
+	// sorted by bank, then address, for function lookups
+	sorted []Symbol
+}
+
+// Load reads a symbol file.
+func Load(path string) (*Table, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	t, err := Parse(f)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return t, nil
+}
+
+// Parse reads symbols in rgblink's format.
+func Parse(r io.Reader) (*Table, error) {
+	t := &Table{
+		byName:     make(map[string]Symbol),
+		byLocation: make(map[location]Symbol),
+	}
+
+	scanner := bufio.NewScanner(r)
+	for lineNum := 1; scanner.Scan(); lineNum++ {
+		line := scanner.Text()
+		if i := strings.IndexByte(line, ';'); i >= 0 {
+			line = line[:i]
+		}
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+		if len(fields) != 2 {
+			return nil, fmt.Errorf("line %d: expected \"bank:address label\"", lineNum)
+		}
+
+		bank, address, ok := strings.Cut(fields[0], ":")
+		b, err := strconv.ParseUint(bank, 16, 16)
+		if !ok || err != nil {
+			return nil, fmt.Errorf("line %d: invalid bank in %q", lineNum, fields[0])
+		}
+		a, err := strconv.ParseUint(address, 16, 16)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: invalid address in %q", lineNum, fields[0])
+		}
+		t.add(Symbol{Bank: int(b), Address: uint16(a), Name: fields[1], Line: lineNum})
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	sort.SliceStable(t.sorted, func(i, j int) bool {
+		a, b := t.locationOf(t.sorted[i]), t.locationOf(t.sorted[j])
+		if a.bank != b.bank {
+			return a.bank < b.bank
+		}
+		return a.address < b.address
+	})
+	return t, nil
+}
+
+func (t *Table) add(s Symbol) {
+	t.byName[s.Name] = s
+	loc := t.locationOf(s)
+	// several labels can share an address, prefer the first global one
+	if existing, ok := t.byLocation[loc]; !ok || (existing.Local() && !s.Local()) {
+		t.byLocation[loc] = s
+	}
+	t.sorted = append(t.sorted, s)
+}
+
+// locationOf returns where a symbol is indexed, dropping the bank outside the
+// switchable ROM bank.
+func (t *Table) locationOf(s Symbol) location {
+	return lookupLocation(s.Address, s.Bank)
+}
+
+func lookupLocation(address uint16, romBank int) location {
+	if address >= 0x4000 && address <= 0x7FFF {
+		return location{romBank, address}
+	}
+	return location{-1, address}
+}
+
+// Len returns the number of symbols.
+func (t *Table) Len() int {
+	return len(t.sorted)
+}
+
+// Symbols returns all symbols: those outside 0x4000-0x7FFF by address, then
+// the switchable bank ones by bank and address.
+func (t *Table) Symbols() []Symbol {
+	return append([]Symbol(nil), t.sorted...)
+}
+
+// Lookup returns the symbol with the given name.
+func (t *Table) Lookup(name string) (Symbol, bool) {
+	s, ok := t.byName[name]
+	return s, ok
+}
+
+// Label returns the label at address, with romBank mapped at 0x4000-0x7FFF.
+func (t *Table) Label(address uint16, romBank int) (string, bool) {
+	s, ok := t.byLocation[lookupLocation(address, romBank)]
+	return s.Name, ok
+}
+
+// Function returns the closest global label at or before address, in the
+// same bank, which is usually the function containing address.
+func (t *Table) Function(address uint16, romBank int) (Symbol, bool) {
+	loc := lookupLocation(address, romBank)
+	// first symbol past address, in bank order
+	i := sort.Search(len(t.sorted), func(i int) bool {
+		l := t.locationOf(t.sorted[i])
+		return l.bank > loc.bank || (l.bank == loc.bank && l.address > loc.address)
+	})
+	for i--; i >= 0; i-- {
+		s := t.sorted[i]
+		if t.locationOf(s).bank != loc.bank || !sameRegion(s.Address, address) {
+			break
+		}
+		if !s.Local() {
+			return s, true
+		}
+	}
+	return Symbol{}, false
+}
+
+// Describe formats address as "label" or "label+offset" relative to its
+// function, or an empty string if there is no label before it.
+func (t *Table) Describe(address uint16, romBank int) string {
+	if name, ok := t.Label(address, romBank); ok {
+		return name
+	}
+	fn, ok := t.Function(address, romBank)
+	if !ok {
+		return ""
+	}
+	return fmt.Sprintf("%s+0x%X", fn.Name, address-fn.Address)
+}
+
+// sameRegion reports whether two addresses are in the same memory region, so
+// a label in ROM isn't taken as the function containing a WRAM address.
+func sameRegion(a, b uint16) bool {
+	return region(a) == region(b)
+}
+
+func region(address uint16) int {
+	switch {
+	case address < 0x4000:
+		return 0 // ROM0
+	case address < 0x8000:
+		return 1 // ROMX
+	case address < 0xA000:
+		return 2 // VRAM
+	case address < 0xC000:
+		return 3 // SRAM
+	case address < 0xE000:
+		return 4 // WRAM
+	case address >= 0xFF80 && address < 0xFFFF:
+		return 5 // HRAM
+	default:
+		return 6
+	}
+}
@@ -0,0 +1,100 @@
+package symbols
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testSymbols = `; File generated by rgblink
+00:0150 Main
+00:0150 Main.start
+00:0158 Main.loop
+00:0160 ReadInput
+01:4000 LoadLevel
+01:4010 LoadLevel.copy
+02:4000 PlaySound
+00:C000 wPlayerX
+00:FF80 hFrameCounter
+`
+
+func TestParse(t *testing.T) {
+	table, err := Parse(strings.NewReader(testSymbols))
+	require.NoError(t, err)
+	assert.Equal(t, 9, table.Len())
+
+	sym, ok := table.Lookup("LoadLevel.copy")
+	require.True(t, ok)
+	assert.Equal(t, Symbol{Bank: 1, Address: 0x4010, Name: "LoadLevel.copy", Line: 7}, sym)
+	assert.True(t, sym.Local())
+	_, ok = table.Lookup("Missing")
+	assert.False(t, ok)
+
+	var names []string
+	for _, s := range table.Symbols() {
+		names = append(names, s.Name)
+	}
+	assert.Equal(t, []string{
+		"Main", "Main.start", "Main.loop", "ReadInput", "wPlayerX", "hFrameCounter",
+		"LoadLevel", "LoadLevel.copy", "PlaySound",
+	}, names)
+}
+
+func TestLabel(t *testing.T) {
+	table, err := Parse(strings.NewReader(testSymbols))
+	require.NoError(t, err)
+
+	tests := []struct {
+		address uint16
+		bank    int
+		want    string
+	}{
+		{0x0150, 1, "Main"}, // global labels win over local ones
+		{0x0158, 5, "Main.loop"},
+		{0x4000, 1, "LoadLevel"},
+		{0x4000, 2, "PlaySound"},
+		{0x4000, 3, ""},
+		{0xC000, 1, "wPlayerX"}, // banks only matter at 0x4000-0x7FFF
+		{0x0151, 1, ""},
+	}
+	for _, tt := range tests {
+		label, _ := table.Label(tt.address, tt.bank)
+		assert.Equal(t, tt.want, label, "0x%04X bank %d", tt.address, tt.bank)
+	}
+}
+
+func TestFunction(t *testing.T) {
+	table, err := Parse(strings.NewReader(testSymbols))
+	require.NoError(t, err)
+
+	tests := []struct {
+		address uint16
+		bank    int
+		want    string
+	}{
+		{0x015A, 1, "Main+0xA"},
+		{0x0160, 1, "ReadInput"},
+		{0x3FFF, 1, "ReadInput+0x3E9F"},
+		{0x4010, 1, "LoadLevel.copy"},
+		{0x4012, 1, "LoadLevel+0x12"},
+		{0x4013, 1, "LoadLevel+0x13"},
+		{0x4013, 2, "PlaySound+0x13"},
+		{0x4013, 3, ""},
+		{0xC001, 1, "wPlayerX+0x1"},
+		{0xFF81, 1, "hFrameCounter+0x1"},
+		{0x8000, 1, ""}, // no labels in VRAM
+		{0x0100, 1, ""},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, table.Describe(tt.address, tt.bank), "0x%04X bank %d", tt.address, tt.bank)
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	for _, input := range []string{"0150 Main", "00:0150", "zz:0150 Main", "00:10000 Main", "00:0150 Main extra"} {
+		_, err := Parse(strings.NewReader(input))
+		assert.Error(t, err, input)
+	}
+}
@@ -0,0 +1,124 @@
+package testrunner
+
+import (
+	"bufio"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// Discover finds the test ROMs at path. If path is a directory, every .gb and
+// .gbc file below it is a test case, named after its path relative to the
+// directory. A single ROM file is a test case on its own. Any other file is
+// read as a manifest, see ParseManifest.
+func Discover(path string) ([]Case, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+
+	if !info.IsDir() {
+		if isROM(path) {
+			return []Case{{Name: caseName(filepath.Base(path)), Path: path}}, nil
+		}
+		return ParseManifest(path)
+	}
+
+	var cases []Case
+	err = filepath.WalkDir(path, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() || !isROM(p) {
+			return nil
+		}
+		rel, err := filepath.Rel(path, p)
+		if err != nil {
+			return err
+		}
+		cases = append(cases, Case{Name: caseName(rel), Path: p})
+		return nil
+	})
+	return cases, err
+}
+
+// ParseManifest reads a list of test ROMs from a file with one ROM per line,
+// optionally followed by a frame limit:
+//
+//	# comments and blank lines are ignored
+//	blargg/cpu_instrs/individual/01-special.gb 500
+//	mooneye/acceptance/di_timing-GS.gb
+//
+// Paths are relative to the manifest's directory. Paths containing spaces can
+// be quoted.
+func ParseManifest(path string) ([]Case, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	base := filepath.Dir(path)
+	var cases []Case
+	scanner := bufio.NewScanner(f)
+	for lineNum := 1; scanner.Scan(); lineNum++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		romPath, rest, err := splitManifestPath(line)
+		if err != nil {
+			return nil, fmt.Errorf("%s:%d: %w", path, lineNum, err)
+		}
+
+		c := Case{Name: caseName(romPath), Path: romPath}
+		if !filepath.IsAbs(romPath) {
+			c.Path = filepath.Join(base, romPath)
+		}
+		if rest != "" {
+			frames, err := strconv.ParseUint(rest, 10, 64)
+			if err != nil {
+				return nil, fmt.Errorf("%s:%d: invalid frame limit %q", path, lineNum, rest)
+			}
+			c.MaxFrames = frames
+		}
+		cases = append(cases, c)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return cases, nil
+}
+
+// splitManifestPath splits a manifest line into the ROM path and whatever
+// follows it.
+func splitManifestPath(line string) (path, rest string, err error) {
+	if strings.HasPrefix(line, `"`) {
+		end := strings.Index(line[1:], `"`)
+		if end < 0 {
+			return "", "", fmt.Errorf("unterminated quote")
+		}
+		return line[1 : end+1], strings.TrimSpace(line[end+2:]), nil
+	}
+	if i := strings.IndexAny(line, " \t"); i >= 0 {
+		return line[:i], strings.TrimSpace(line[i:]), nil
+	}
+	return line, "", nil
+}
+
+func isROM(path string) bool {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".gb", ".gbc":
+		return true
+	}
+	return false
+}
+
+// caseName is the ROM path without its extension, with forward slashes.
+func caseName(path string) string {
+	return filepath.ToSlash(strings.TrimSuffix(path, filepath.Ext(path)))
+}
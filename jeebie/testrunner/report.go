@@ -0,0 +1,131 @@
+package testrunner
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"time"
+)
+
+// Summary counts results by status.
+type Summary struct {
+	Total   int `json:"total"`
+	Passed  int `json:"passed"`
+	Failed  int `json:"failed"`
+	Unknown int `json:"unknown"`
+	Timeout int `json:"timeout"`
+	Errors  int `json:"errors"`
+}
+
+// Summarize counts the results by status.
+func Summarize(results []Result) Summary {
+	s := Summary{Total: len(results)}
+	for _, r := range results {
+		switch r.Status {
+		case StatusPassed:
+			s.Passed++
+		case StatusFailed:
+			s.Failed++
+		case StatusUnknown:
+			s.Unknown++
+		case StatusTimeout:
+			s.Timeout++
+		case StatusError:
+			s.Errors++
+		}
+	}
+	return s
+}
+
+// WriteJSON writes the results and their summary as indented JSON.
+func WriteJSON(w io.Writer, results []Result) error {
+	report := struct {
+		Summary Summary  `json:"summary"`
+		Results []Result `json:"results"`
+	}{Summarize(results), results}
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(report)
+}
+
+type junitSuites struct {
+	XMLName xml.Name     `xml:"testsuites"`
+	Suites  []junitSuite `xml:"testsuite"`
+}
+
+type junitSuite struct {
+	Name     string      `xml:"name,attr"`
+	Tests    int         `xml:"tests,attr"`
+	Failures int         `xml:"failures,attr"`
+	Errors   int         `xml:"errors,attr"`
+	Skipped  int         `xml:"skipped,attr"`
+	Time     string      `xml:"time,attr"`
+	Cases    []junitCase `xml:"testcase"`
+}
+
+type junitCase struct {
+	Name      string        `xml:"name,attr"`
+	Classname string        `xml:"classname,attr"`
+	Time      string        `xml:"time,attr"`
+	Failure   *junitMessage `xml:"failure,omitempty"`
+	Error     *junitMessage `xml:"error,omitempty"`
+	Skipped   *junitMessage `xml:"skipped,omitempty"`
+	SystemOut string        `xml:"system-out,omitempty"`
+}
+
+type junitMessage struct {
+	Message string `xml:"message,attr"`
+}
+
+// WriteJUnit writes the results as a JUnit XML report. Timeouts are reported
+// as failures, and tests that finished without a result as skipped.
+func WriteJUnit(w io.Writer, results []Result) error {
+	summary := Summarize(results)
+	suite := junitSuite{
+		Name:     "jeebie",
+		Tests:    summary.Total,
+		Failures: summary.Failed + summary.Timeout,
+		Errors:   summary.Errors,
+		Skipped:  summary.Unknown,
+	}
+
+	var total time.Duration
+	for _, r := range results {
+		total += r.Duration
+		tc := junitCase{
+			Name:      r.Name,
+			Classname: "jeebie",
+			Time:      junitTime(r.Duration),
+			SystemOut: r.Serial,
+		}
+		switch r.Status {
+		case StatusFailed:
+			tc.Failure = &junitMessage{fmt.Sprintf("failed (%s) after %d frames", r.Detector, r.Frames)}
+		case StatusTimeout:
+			tc.Failure = &junitMessage{r.Error}
+		case StatusError:
+			tc.Error = &junitMessage{r.Error}
+		case StatusUnknown:
+			tc.Skipped = &junitMessage{fmt.Sprintf("finished without a result after %d frames", r.Frames)}
+		}
+		suite.Cases = append(suite.Cases, tc)
+	}
+	suite.Time = junitTime(total)
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(junitSuites{Suites: []junitSuite{suite}}); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
+
+func junitTime(d time.Duration) string {
+	return fmt.Sprintf("%.3f", d.Seconds())
+}
@@ -0,0 +1,221 @@
+// Package testrunner runs test ROMs headless, in parallel, and reports their
+// results as JUnit XML or JSON.
+package testrunner
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/valerio/go-jeebie/jeebie"
+	"github.com/valerio/go-jeebie/jeebie/debug"
+)
+
+const (
+	// DefaultMaxFrames is how long a test may run when neither the case nor the
+	// options set a limit, about one minute of emulated time.
+	DefaultMaxFrames = 3600
+	// loopFrames is how many frames the CPU must spend in a JR -2 loop before
+	// the test is considered finished.
+	loopFrames = 10
+)
+
+// Status is the outcome of a test ROM run.
+type Status string
+
+const (
+	StatusPassed  Status = "passed"
+	StatusFailed  Status = "failed"
+	StatusUnknown Status = "unknown" // finished without reporting a result
+	StatusTimeout Status = "timeout"
+	StatusError   Status = "error" // the ROM could not be loaded
+)
+
+// Detector names the signal that ended a test run: the name of the
+// jeebie.CompletionDetector that saw the result, or DetectorLoop.
+type Detector string
+
+// DetectorLoop means the test ended in a JR -2 loop without reporting a result.
+const DetectorLoop Detector = "loop"
+
+// Case is a single test ROM to run.
+type Case struct {
+	Name      string
+	Path      string
+	MaxFrames uint64 // 0 uses Options.MaxFrames
+}
+
+// Options configures a test run.
+type Options struct {
+	// Parallel is the number of ROMs run at once, defaults to the number of CPUs.
+	Parallel int
+	// MaxFrames is the frame limit for cases that don't set one.
+	MaxFrames uint64
+	// ScreenshotDir, if set, receives a PNG of the last frame of every test.
+	ScreenshotDir string
+	// EmulatorOptions are passed to every emulator instance.
+	EmulatorOptions []jeebie.Option
+}
+
+// Result is the outcome of running a single Case.
+type Result struct {
+	Name       string        `json:"name"`
+	Path       string        `json:"path"`
+	Status     Status        `json:"status"`
+	Detector   Detector      `json:"detector,omitempty"`
+	Frames     uint64        `json:"frames"`
+	Duration   time.Duration `json:"duration_ns"`
+	Serial     string        `json:"serial"`
+	Screenshot string        `json:"screenshot,omitempty"`
+	Error      string        `json:"error,omitempty"`
+}
+
+// Passed reports whether the test passed.
+func (r Result) Passed() bool {
+	return r.Status == StatusPassed
+}
+
+// Run runs all cases and returns their results in the same order. Each case
+// gets its own emulator, so cases run in parallel without sharing state.
+func Run(cases []Case, opts Options) ([]Result, error) {
+	if opts.Parallel < 1 {
+		opts.Parallel = runtime.NumCPU()
+	}
+	if opts.MaxFrames == 0 {
+		opts.MaxFrames = DefaultMaxFrames
+	}
+	if opts.ScreenshotDir != "" {
+		if err := os.MkdirAll(opts.ScreenshotDir, 0755); err != nil {
+			return nil, err
+		}
+	}
+
+	// battery saves written by the tests must not end up next to the ROMs
+	saveDir, err := os.MkdirTemp("", "jeebie-test-")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(saveDir)
+
+	results := make([]Result, len(cases))
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	for range min(opts.Parallel, len(cases)) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				results[i] = runCase(cases[i], opts, saveDir)
+			}
+		}()
+	}
+	for i := range cases {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+
+	return results, nil
+}
+
+func runCase(c Case, opts Options, saveDir string) (result Result) {
+	start := time.Now()
+	result = Result{Name: c.Name, Path: c.Path}
+	defer func() { result.Duration = time.Since(start) }()
+	// a ROM crashing the emulator fails its case, not the whole run
+	defer func() {
+		if r := recover(); r != nil {
+			result.Status = StatusError
+			result.Error = fmt.Sprintf("emulator panic: %v", r)
+		}
+	}()
+
+	emuOpts := append([]jeebie.Option{jeebie.WithSaveDir(saveDir)}, opts.EmulatorOptions...)
+	dmg, err := jeebie.NewWithFile(c.Path, emuOpts...)
+	if err != nil {
+		result.Status = StatusError
+		result.Error = err.Error()
+		return result
+	}
+	defer func() {
+		if err := dmg.Close(); err != nil {
+			result.Error = joinErrors(result.Error, "closing: "+err.Error())
+		}
+	}()
+
+	maxFrames := c.MaxFrames
+	if maxFrames == 0 {
+		maxFrames = opts.MaxFrames
+	}
+	dmg.SetFrameLimiter(nil)
+	dmg.ConfigureCompletionDetection(maxFrames, loopFrames)
+	for _, d := range jeebie.DefaultCompletionDetectors() {
+		dmg.AddCompletionDetector(d)
+	}
+
+	result.Status = StatusTimeout
+	for dmg.GetFrameCount() < maxFrames {
+		if err := dmg.RunUntilFrame(); err != nil {
+			result.Status = StatusError
+			result.Error = err.Error()
+			break
+		}
+		dmg.UpdateCompletionDetection()
+
+		if status, detector, ok := detect(dmg); ok {
+			result.Status = status
+			result.Detector = detector
+			break
+		}
+	}
+
+	result.Frames = dmg.GetFrameCount()
+	result.Serial = string(dmg.SerialOutput())
+	if result.Status == StatusTimeout {
+		result.Error = fmt.Sprintf("no result after %d frames", maxFrames)
+	}
+
+	if opts.ScreenshotDir != "" {
+		path := filepath.Join(opts.ScreenshotDir, screenshotName(c.Name))
+		if err := debug.SaveFramePNG(dmg.GetCurrentFrame(), path); err != nil {
+			result.Error = joinErrors(result.Error, "screenshot: "+err.Error())
+		} else {
+			result.Screenshot = path
+		}
+	}
+
+	return result
+}
+
+// detect checks whether the test has finished, and if so how.
+func detect(dmg *jeebie.DMG) (Status, Detector, bool) {
+	switch result := dmg.TestResult(); result.Status {
+	case jeebie.TestPassed:
+		return StatusPassed, Detector(result.Detector), true
+	case jeebie.TestFailed:
+		return StatusFailed, Detector(result.Detector), true
+	}
+
+	if dmg.LoopDetected() {
+		return StatusUnknown, DetectorLoop, true
+	}
+	return "", "", false
+}
+
+// screenshotName turns a case name, which may contain path separators, into a
+// file name.
+func screenshotName(name string) string {
+	r := strings.NewReplacer("/", "_", "\\", "_", " ", "_")
+	return r.Replace(name) + ".png"
+}
+
+func joinErrors(a, b string) string {
+	if a == "" {
+		return b
+	}
+	return a + "; " + b
+}
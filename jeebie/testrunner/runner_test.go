@@ -0,0 +1,176 @@
+package testrunner
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/valerio/go-jeebie/jeebie"
+	"github.com/valerio/go-jeebie/jeebie/internal/testutil"
+)
+
+// writeROM writes a ROM that runs code from 0x150, after the header, to dir/name.
+func writeROM(t *testing.T, dir, name string, code []byte) string {
+	t.Helper()
+	return testutil.WriteROM(t, filepath.Join(dir, name), 0x00, 0x00, map[uint16][]byte{0x150: code})
+}
+
+// serialCode prints text over serial, then loops forever.
+func serialCode(text string) []byte {
+	var code []byte
+	for _, c := range []byte(text) {
+		code = append(code,
+			0x3E, c, // LD A, c
+			0xE0, 0x01, // LDH (SB), A
+			0x3E, 0x81, // LD A, 0x81
+			0xE0, 0x02, // LDH (SC), A
+		)
+	}
+	return append(code, 0x18, 0xFE) // JR -2
+}
+
+// mooneyeCode loads the given values into B, C, D, E, H, L, then loops forever.
+func mooneyeCode(b, c, d, e, h, l uint8) []byte {
+	return []byte{
+		0x06, b, 0x0E, c, 0x16, d, 0x1E, e, 0x26, h, 0x2E, l,
+		0x40,       // LD B, B
+		0x18, 0xFE, // JR -2
+	}
+}
+
+func TestRun(t *testing.T) {
+	dir := t.TempDir()
+	cases := []Case{
+		{Name: "serial-pass", Path: writeROM(t, dir, "serial-pass.gb", serialCode("test\n\nPassed\n"))},
+		{Name: "serial-fail", Path: writeROM(t, dir, "serial-fail.gb", serialCode("Failed #2\n"))},
+		{Name: "mooneye-pass", Path: writeROM(t, dir, "mooneye-pass.gb", mooneyeCode(3, 5, 8, 13, 21, 34))},
+		{Name: "mooneye-fail", Path: writeROM(t, dir, "mooneye-fail.gb", mooneyeCode(0x42, 0x42, 0x42, 0x42, 0x42, 0x42))},
+		{Name: "loop", Path: writeROM(t, dir, "loop.gb", []byte{0x18, 0xFE})},
+		{Name: "timeout", Path: writeROM(t, dir, "timeout.gb", []byte{0xC3, 0x50, 0x01}), MaxFrames: 5}, // JP 0x150
+		{Name: "missing", Path: filepath.Join(dir, "missing.gb")},
+	}
+
+	screenshots := filepath.Join(dir, "screenshots")
+	results, err := Run(cases, Options{Parallel: 3, ScreenshotDir: screenshots})
+	require.NoError(t, err)
+	require.Len(t, results, len(cases))
+
+	expected := []struct {
+		status   Status
+		detector Detector
+	}{
+		{StatusPassed, "blargg-serial"},
+		{StatusFailed, "blargg-serial"},
+		{StatusPassed, "mooneye"},
+		{StatusFailed, "mooneye"},
+		{StatusUnknown, DetectorLoop},
+		{StatusTimeout, ""},
+		{StatusError, ""},
+	}
+	for i, want := range expected {
+		r := results[i]
+		assert.Equal(t, cases[i].Name, r.Name)
+		assert.Equal(t, want.status, r.Status, r.Name)
+		assert.Equal(t, want.detector, r.Detector, r.Name)
+	}
+
+	assert.Equal(t, "test\n\nPassed\n", results[0].Serial)
+	assert.Equal(t, uint64(5), results[5].Frames)
+	assert.NotEmpty(t, results[6].Error)
+
+	assert.FileExists(t, filepath.Join(screenshots, "serial-pass.png"))
+	assert.Equal(t, filepath.Join(screenshots, "loop.png"), results[4].Screenshot)
+	assert.Empty(t, results[6].Screenshot)
+}
+
+func TestRunRecoversPanic(t *testing.T) {
+	dir := t.TempDir()
+	cases := []Case{
+		{Name: "crash", Path: writeROM(t, dir, "crash.gb", []byte{0x18, 0xFE})},
+	}
+	crash := func(*jeebie.DMG) { panic("boom") }
+
+	results, err := Run(cases, Options{Parallel: 1, MaxFrames: 5, EmulatorOptions: []jeebie.Option{crash}})
+	require.NoError(t, err)
+	require.Len(t, results, 1)
+	assert.Equal(t, StatusError, results[0].Status)
+	assert.Equal(t, "emulator panic: boom", results[0].Error)
+}
+
+func TestDiscoverDirectory(t *testing.T) {
+	dir := t.TempDir()
+	writeROM(t, dir, "b.gb", nil)
+	writeROM(t, dir, filepath.Join("sub", "a.gbc"), nil)
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "readme.txt"), nil, 0644))
+
+	cases, err := Discover(dir)
+	require.NoError(t, err)
+	require.Len(t, cases, 2)
+	assert.Equal(t, "b", cases[0].Name)
+	assert.Equal(t, "sub/a", cases[1].Name)
+	assert.Equal(t, filepath.Join(dir, "sub", "a.gbc"), cases[1].Path)
+}
+
+func TestDiscoverManifest(t *testing.T) {
+	dir := t.TempDir()
+	manifest := filepath.Join(dir, "tests.txt")
+	require.NoError(t, os.WriteFile(manifest, []byte(`# blargg
+cpu_instrs/01-special.gb 500
+
+"mem timing/01-read_timing.gb" 1200
+`), 0644))
+
+	cases, err := Discover(manifest)
+	require.NoError(t, err)
+	assert.Equal(t, []Case{
+		{Name: "cpu_instrs/01-special", Path: filepath.Join(dir, "cpu_instrs", "01-special.gb"), MaxFrames: 500},
+		{Name: "mem timing/01-read_timing", Path: filepath.Join(dir, "mem timing", "01-read_timing.gb"), MaxFrames: 1200},
+	}, cases)
+
+	require.NoError(t, os.WriteFile(manifest, []byte("rom.gb lots\n"), 0644))
+	_, err = Discover(manifest)
+	assert.ErrorContains(t, err, "tests.txt:1")
+}
+
+func TestReports(t *testing.T) {
+	results := []Result{
+		{Name: "pass", Status: StatusPassed, Detector: "blargg-serial", Frames: 10, Serial: "Passed\n"},
+		{Name: "fail", Status: StatusFailed, Detector: "mooneye", Frames: 20},
+		{Name: "timeout", Status: StatusTimeout, Frames: 30, Error: "no result after 30 frames"},
+		{Name: "unknown", Status: StatusUnknown, Detector: DetectorLoop, Frames: 40},
+		{Name: "error", Status: StatusError, Error: "boom"},
+	}
+
+	var buf bytes.Buffer
+	require.NoError(t, WriteJUnit(&buf, results))
+	var suites junitSuites
+	require.NoError(t, xml.Unmarshal(buf.Bytes(), &suites))
+	require.Len(t, suites.Suites, 1)
+	suite := suites.Suites[0]
+	assert.Equal(t, 5, suite.Tests)
+	assert.Equal(t, 2, suite.Failures)
+	assert.Equal(t, 1, suite.Errors)
+	assert.Equal(t, 1, suite.Skipped)
+	require.Len(t, suite.Cases, 5)
+	assert.Nil(t, suite.Cases[0].Failure)
+	assert.Equal(t, "Passed\n", suite.Cases[0].SystemOut)
+	assert.NotNil(t, suite.Cases[1].Failure)
+	assert.Equal(t, "no result after 30 frames", suite.Cases[2].Failure.Message)
+	assert.NotNil(t, suite.Cases[3].Skipped)
+	assert.Equal(t, "boom", suite.Cases[4].Error.Message)
+
+	buf.Reset()
+	require.NoError(t, WriteJSON(&buf, results))
+	var report struct {
+		Summary Summary  `json:"summary"`
+		Results []Result `json:"results"`
+	}
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &report))
+	assert.Equal(t, Summary{Total: 5, Passed: 1, Failed: 1, Unknown: 1, Timeout: 1, Errors: 1}, report.Summary)
+	assert.Equal(t, results, report.Results)
+}
@@ -0,0 +1,42 @@
+package jeebie
+
+import (
+	"github.com/valerio/go-jeebie/jeebie/cpu"
+	"github.com/valerio/go-jeebie/jeebie/trace"
+)
+
+// startTrace installs the CPU trace hook when WithTrace was given.
+func (e *DMG) startTrace() {
+	if e.tracer == nil {
+		return
+	}
+	e.bus.CPU.SetTraceHook(e.traceInstruction)
+}
+
+// traceInstruction logs the instruction about to run, if the filter selects it.
+func (e *DMG) traceInstruction(c *cpu.CPU) {
+	if e.replaying {
+		return
+	}
+	pc := c.GetPC()
+	if !e.traceFilter.Match(pc, e.bus.MMU.ROMBank(), e.frameCount) {
+		return
+	}
+	s := trace.State{
+		A: c.GetA(), F: c.GetF(), B: c.GetB(), C: c.GetC(),
+		D: c.GetD(), E: c.GetE(), H: c.GetH(), L: c.GetL(),
+		SP: c.GetSP(), PC: pc,
+	}
+	for i := range s.PCMem {
+		s.PCMem[i] = e.bus.MMU.Peek(pc + uint16(i))
+	}
+	e.tracer.Write(&s)
+}
+
+// flushTrace writes any buffered trace lines.
+func (e *DMG) flushTrace() error {
+	if e.tracer == nil {
+		return nil
+	}
+	return e.tracer.Flush()
+}
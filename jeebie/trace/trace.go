@@ -0,0 +1,157 @@
+// Package trace writes CPU execution traces in the format used by
+// gameboy-doctor (https://github.com/robert/gameboy-doctor), one line per
+// instruction with the registers before it executes:
+//
+//	A:01 F:B0 B:00 C:13 D:00 E:D8 H:01 L:4D SP:FFFE PC:0100 PCMEM:00,C3,13,02
+//
+// Traces can be compared line by line against other emulators to find the
+// first instruction where execution diverges.
+package trace
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// AnyBank matches instructions regardless of the mapped ROM bank.
+const AnyBank = -1
+
+// Filter selects which instructions are traced. All bounds are inclusive.
+type Filter struct {
+	PCStart, PCEnd uint16
+	// Bank only traces code running from this ROM bank: bank 0 at
+	// 0x0000-0x3FFF, the mapped bank at 0x4000-0x7FFF. Code outside ROM
+	// isn't traced unless Bank is AnyBank.
+	Bank                  int
+	FirstFrame, LastFrame uint64
+}
+
+// All is the filter tracing every instruction.
+var All = Filter{PCEnd: 0xFFFF, Bank: AnyBank, LastFrame: math.MaxUint64}
+
+// Match reports whether the instruction at pc is traced, with romBank mapped
+// at 0x4000-0x7FFF during frame.
+func (f *Filter) Match(pc uint16, romBank int, frame uint64) bool {
+	if pc < f.PCStart || pc > f.PCEnd || frame < f.FirstFrame || frame > f.LastFrame {
+		return false
+	}
+	switch {
+	case f.Bank == AnyBank:
+		return true
+	case pc < 0x4000:
+		return f.Bank == 0
+	case pc < 0x8000:
+		return f.Bank == romBank
+	default:
+		return false
+	}
+}
+
+// State is the machine state logged for one instruction.
+type State struct {
+	A, F, B, C, D, E, H, L uint8
+	SP, PC                 uint16
+	// PCMem holds the 4 bytes at PC
+	PCMem [4]uint8
+}
+
+// Writer writes trace lines to a buffered output.
+type Writer struct {
+	out  *bufio.Writer
+	line []byte
+	err  error
+}
+
+// NewWriter returns a writer tracing to w. Call Flush when done.
+func NewWriter(w io.Writer) *Writer {
+	return &Writer{out: bufio.NewWriterSize(w, 64*1024), line: make([]byte, 0, 80)}
+}
+
+// Write logs one instruction. Errors are kept and returned by Flush, so a
+// failing output doesn't slow down emulation with error handling.
+func (w *Writer) Write(s *State) {
+	if w.err != nil {
+		return
+	}
+	w.line = AppendLine(w.line[:0], s)
+	_, w.err = w.out.Write(w.line)
+}
+
+// Flush writes buffered lines, returning the first error since the writer
+// was created.
+func (w *Writer) Flush() error {
+	if w.err != nil {
+		return w.err
+	}
+	return w.out.Flush()
+}
+
+// AppendLine appends the trace line for s, newline included, to dst.
+func AppendLine(dst []byte, s *State) []byte {
+	dst = appendReg(dst, "A:", s.A)
+	dst = appendReg(dst, " F:", s.F)
+	dst = appendReg(dst, " B:", s.B)
+	dst = appendReg(dst, " C:", s.C)
+	dst = appendReg(dst, " D:", s.D)
+	dst = appendReg(dst, " E:", s.E)
+	dst = appendReg(dst, " H:", s.H)
+	dst = appendReg(dst, " L:", s.L)
+	dst = appendReg(dst, " SP:", uint8(s.SP>>8))
+	dst = appendHex(dst, uint8(s.SP))
+	dst = appendReg(dst, " PC:", uint8(s.PC>>8))
+	dst = appendHex(dst, uint8(s.PC))
+	dst = append(dst, " PCMEM:"...)
+	for i, b := range s.PCMem {
+		if i > 0 {
+			dst = append(dst, ',')
+		}
+		dst = appendHex(dst, b)
+	}
+	return append(dst, '\n')
+}
+
+const hexDigits = "0123456789ABCDEF"
+
+func appendReg(dst []byte, label string, value uint8) []byte {
+	return appendHex(append(dst, label...), value)
+}
+
+func appendHex(dst []byte, value uint8) []byte {
+	return append(dst, hexDigits[value>>4], hexDigits[value&0xF])
+}
+
+// ParseRange parses an inclusive range like "0x150-0x7FFF", "100-" or "42",
+// with decimal or 0x prefixed hex bounds. An open end extends to max.
+func ParseRange(s string, max uint64) (first, last uint64, err error) {
+	start, end, isRange := strings.Cut(s, "-")
+	first, err = parseBound(start, 0)
+	if err != nil {
+		return 0, 0, err
+	}
+	last = first
+	if isRange {
+		if last, err = parseBound(end, max); err != nil {
+			return 0, 0, err
+		}
+	}
+	if first > last || last > max {
+		return 0, 0, fmt.Errorf("invalid range %q", s)
+	}
+	return first, last, nil
+}
+
+func parseBound(s string, empty uint64) (uint64, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return empty, nil
+	}
+	v, err := strconv.ParseUint(s, 0, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid number %q", s)
+	}
+	return v, nil
+}
@@ -0,0 +1,92 @@
+package trace
+
+import (
+	"bytes"
+	"errors"
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAppendLine(t *testing.T) {
+	s := State{A: 0x01, F: 0xB0, C: 0x13, E: 0xD8, H: 0x01, L: 0x4D, SP: 0xFFFE, PC: 0x0100, PCMem: [4]uint8{0x00, 0xC3, 0x13, 0x02}}
+	assert.Equal(t, "A:01 F:B0 B:00 C:13 D:00 E:D8 H:01 L:4D SP:FFFE PC:0100 PCMEM:00,C3,13,02\n", string(AppendLine(nil, &s)))
+}
+
+func TestFilterMatch(t *testing.T) {
+	f := All
+	assert.True(t, f.Match(0xC000, 1, 0))
+
+	f.PCStart, f.PCEnd = 0x150, 0x7FFF
+	assert.False(t, f.Match(0x100, 1, 0))
+	assert.True(t, f.Match(0x150, 1, 0))
+	assert.False(t, f.Match(0x8000, 1, 0))
+
+	f.Bank = 2
+	assert.False(t, f.Match(0x150, 2, 0), "bank 0 code")
+	assert.True(t, f.Match(0x4000, 2, 0))
+	assert.False(t, f.Match(0x4000, 3, 0))
+
+	f = All
+	f.Bank = 0
+	assert.True(t, f.Match(0x150, 3, 0))
+	assert.False(t, f.Match(0xFF80, 0, 0), "HRAM code isn't in a bank")
+
+	f = All
+	f.FirstFrame, f.LastFrame = 10, 20
+	assert.False(t, f.Match(0x150, 1, 9))
+	assert.True(t, f.Match(0x150, 1, 10))
+	assert.True(t, f.Match(0x150, 1, 20))
+	assert.False(t, f.Match(0x150, 1, 21))
+}
+
+type failingWriter struct{}
+
+func (failingWriter) Write([]byte) (int, error) { return 0, errors.New("disk full") }
+
+func TestWriter(t *testing.T) {
+	var out bytes.Buffer
+	w := NewWriter(&out)
+	w.Write(&State{PC: 0x100})
+	assert.Zero(t, out.Len(), "lines are buffered")
+	require.NoError(t, w.Flush())
+	assert.Equal(t, "A:00 F:00 B:00 C:00 D:00 E:00 H:00 L:00 SP:0000 PC:0100 PCMEM:00,00,00,00\n", out.String())
+
+	w = NewWriter(failingWriter{})
+	for range 2000 {
+		w.Write(&State{})
+	}
+	assert.EqualError(t, w.Flush(), "disk full")
+}
+
+func TestParseRange(t *testing.T) {
+	tests := []struct {
+		in          string
+		max         uint64
+		first, last uint64
+		err         bool
+	}{
+		{"0x150-0x7FFF", 0xFFFF, 0x150, 0x7FFF, false},
+		{"42", 0xFFFF, 42, 42, false},
+		{"100-", math.MaxUint64, 100, math.MaxUint64, false},
+		{"-0x200", 0xFFFF, 0, 0x200, false},
+		{"0x200-0x100", 0xFFFF, 0, 0, true},
+		{"0x10000", 0xFFFF, 0, 0, true},
+		{"abc", 0xFFFF, 0, 0, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			first, last, err := ParseRange(tt.in, tt.max)
+			if tt.err {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.first, first)
+			assert.Equal(t, tt.last, last)
+		})
+	}
+}
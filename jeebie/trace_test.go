@@ -0,0 +1,55 @@
+package jeebie
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/valerio/go-jeebie/jeebie/trace"
+)
+
+func TestTrace(t *testing.T) {
+	dir := t.TempDir()
+	var out bytes.Buffer
+	dmg, err := NewWithFile(writeTestROM(t, dir, 0x00, 0x00), WithSaveDir(dir), WithTrace(&out, trace.All))
+	require.NoError(t, err)
+
+	dmg.Pause()
+	dmg.StepInstruction()
+	dmg.StepInstruction()
+	require.NoError(t, dmg.Close())
+
+	line := "A:01 F:B0 B:00 C:13 D:00 E:D8 H:01 L:4D SP:FFFE PC:0100 PCMEM:18,FE,00,00\n"
+	assert.Equal(t, line+line, out.String())
+}
+
+func TestTraceFilter(t *testing.T) {
+	tests := []struct {
+		name   string
+		filter func(f *trace.Filter)
+		lines  bool
+	}{
+		{"pc in range", func(f *trace.Filter) { f.PCStart, f.PCEnd = 0x100, 0x100 }, true},
+		{"pc out of range", func(f *trace.Filter) { f.PCStart = 0x101 }, false},
+		{"bank 0", func(f *trace.Filter) { f.Bank = 0 }, true},
+		{"other bank", func(f *trace.Filter) { f.Bank = 1 }, false},
+		{"later frames", func(f *trace.Filter) { f.FirstFrame = 1 }, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			filter := trace.All
+			tt.filter(&filter)
+			var out bytes.Buffer
+			dmg, err := NewWithFile(writeTestROM(t, dir, 0x00, 0x00), WithSaveDir(dir), WithTrace(&out, filter))
+			require.NoError(t, err)
+
+			dmg.Pause()
+			dmg.StepInstruction()
+			require.NoError(t, dmg.Close())
+			assert.Equal(t, tt.lines, out.Len() > 0)
+		})
+	}
+}
@@ -0,0 +1,90 @@
+package video
+
+import (
+	"github.com/valerio/go-jeebie/jeebie/addr"
+	"github.com/valerio/go-jeebie/jeebie/bit"
+)
+
+// ColorBus is implemented by buses with Game Boy Color hardware. When the bus
+// provides it and CGB mode is enabled, the GPU renders with VRAM bank 1 tile
+// attributes and color palettes.
+type ColorBus interface {
+	CGBMode() bool
+	// ReadVRAM reads a VRAM bank directly, regardless of the bank selected by the CPU.
+	ReadVRAM(bank uint8, address uint16) byte
+	// BGPaletteColor and OBJPaletteColor return palette colors as RGB555.
+	BGPaletteColor(palette, index uint8) uint16
+	OBJPaletteColor(palette, index uint8) uint16
+	// HBlank is called at the start of each HBlank period (used by HDMA).
+	HBlank()
+}
+
+// BG map attributes, stored in VRAM bank 1 at the same address as the tile index.
+// Reference: https://gbdev.io/pandocs/Tile_Maps.html#bg-map-attributes-cgb-mode-only
+const (
+	bgAttrPalette  = 0x07
+	bgAttrBank     = 3
+	bgAttrFlipX    = 5
+	bgAttrFlipY    = 6
+	bgAttrPriority = 7
+)
+
+// bgPriorityFlag is set in bgPixelBuffer (next to the color index) for CGB
+// BG pixels whose attributes give them priority over sprites.
+const bgPriorityFlag = 0x80
+
+// fetchMapPixelCGB returns the color index and attributes of a pixel of the
+// background/window map at (x, y).
+func (g *GPU) fetchMapPixelCGB(x, y int, tileDataAddr, tileMapAddr uint16, useSigned bool) (int, uint8) {
+	mapAddr := tileMapAddr + uint16((y/8)*32+x/8)
+	tileIndex := g.color.ReadVRAM(0, mapAddr)
+	attr := g.color.ReadVRAM(1, mapAddr)
+
+	row := y % 8
+	if bit.IsSet(bgAttrFlipY, attr) {
+		row = 7 - row
+	}
+
+	tileAddr := tileRowAddress(tileIndex, row, tileDataAddr, useSigned)
+	bank := bit.GetBitValue(bgAttrBank, attr)
+	tileRow := TileRow{
+		Low:  g.color.ReadVRAM(bank, tileAddr),
+		High: g.color.ReadVRAM(bank, tileAddr+1),
+	}
+
+	if bit.IsSet(bgAttrFlipX, attr) {
+		return tileRow.GetPixelFlipped(x % 8), attr
+	}
+	return tileRow.GetPixel(x % 8), attr
+}
+
+func (g *GPU) drawMapPixelCGB(position int, pixelColor int, attr uint8) {
+	color := g.color.BGPaletteColor(attr&bgAttrPalette, uint8(pixelColor))
+	g.framebuffer.buffer[position] = uint32(RGB555ToColor(color))
+
+	g.bgPixelBuffer[position] = uint8(pixelColor)
+	if bit.IsSet(bgAttrPriority, attr) {
+		g.bgPixelBuffer[position] |= bgPriorityFlag
+	}
+}
+
+// spriteHiddenCGB reports whether the background covers a sprite pixel.
+// LCDC bit 0 acts as a master switch: when clear, sprites are always on top.
+// Otherwise BG colors 1-3 cover the sprite if either the BG attributes or
+// the sprite request it.
+func (g *GPU) spriteHiddenCGB(position int, sprite Sprite) bool {
+	if !g.isBackgroundEnabled() {
+		return false
+	}
+	bg := g.bgPixelBuffer[position]
+	if bg&0x03 == 0 {
+		return false
+	}
+	return sprite.BehindBG || bg&bgPriorityFlag != 0
+}
+
+// spritePriorityByIndex reports whether overlapping sprites are resolved by
+// OAM index only (the CGB default) rather than by X coordinate.
+func (g *GPU) spritePriorityByIndex() bool {
+	return g.cgb && !g.bus.ReadBit(0, addr.OPRI)
+}
@@ -0,0 +1,499 @@
+package video
+
+import (
+	"github.com/valerio/go-jeebie/jeebie/addr"
+	"github.com/valerio/go-jeebie/jeebie/bit"
+)
+
+// Renderer selects how the GPU produces pixels.
+type Renderer int
+
+const (
+	// RendererScanline draws a whole scanline when entering mode 3, with fixed
+	// mode lengths. Fast, but blind to register writes during mode 3.
+	RendererScanline Renderer = iota
+	// RendererFIFO emulates the background/sprite pixel FIFOs dot by dot: mid
+	// scanline register writes take effect, and mode 3 lasts as long as the
+	// fetch work for the line (SCX fine scroll, window, sprites).
+	RendererFIFO
+)
+
+// SetRenderer selects the rendering implementation. It should be called
+// before emulation starts, as the two renderers track timing differently.
+func (g *GPU) SetRenderer(r Renderer) {
+	g.renderer = r
+	g.fifo.active = false
+}
+
+const (
+	lineDots         = scanlineCycles // 456
+	fifoStartupDots  = 6              // the first tile of each line is fetched twice
+	spriteFetchDots  = 6
+	vblankStartLine  = 144
+	lastLine         = 153
+	fifoBGCapacity   = 16
+	fifoObjCapacity  = 8
+	fetcherStepDots  = 2
+	fetcherPushStep  = 3
+	maxSpritesOnLine = 10
+)
+
+// fifoPixel is a pixel waiting in one of the FIFOs.
+type fifoPixel struct {
+	color    uint8 // color index, 0-3
+	palette  uint8 // BG: CGB palette. OBJ: CGB palette, or OBP0/OBP1 (0/1) on DMG
+	priority bool  // BG: CGB attribute priority. OBJ: behind-BG flag
+	oamIndex int   // OBJ only, for CGB priority
+}
+
+type pixelQueue struct {
+	buf  [fifoBGCapacity]fifoPixel
+	head int
+	size int
+}
+
+func (q *pixelQueue) push(p fifoPixel) {
+	q.buf[(q.head+q.size)%len(q.buf)] = p
+	q.size++
+}
+
+func (q *pixelQueue) pop() fifoPixel {
+	p := q.buf[q.head]
+	q.head = (q.head + 1) % len(q.buf)
+	q.size--
+	return p
+}
+
+func (q *pixelQueue) at(i int) *fifoPixel {
+	return &q.buf[(q.head+i)%len(q.buf)]
+}
+
+func (q *pixelQueue) clear() {
+	q.head, q.size = 0, 0
+}
+
+// bgFetcher fetches one tile row in 4 steps: tile index, low byte, high
+// byte (2 dots each), then push, which waits until the BG FIFO has room.
+type bgFetcher struct {
+	step   int
+	dots   int
+	tileX  int // tiles fetched so far on this line (or in the window)
+	window bool
+	tile   uint8
+	attr   uint8
+	low    uint8
+	high   uint8
+}
+
+// fifoState is the per-line state of the FIFO renderer.
+type fifoState struct {
+	active  bool // set up for the current line
+	bg      pixelQueue
+	obj     pixelQueue
+	fetcher bgFetcher
+
+	lx      int // next pixel to be output (0-159)
+	discard int // pixels dropped at line start for SCX fine scroll
+	startup int // dots left before the fetcher starts
+
+	sprites        [maxSpritesOnLine]Sprite
+	spriteCount    int
+	spriteFetched  [maxSpritesOnLine]bool
+	spriteFetching int // index of the sprite being fetched, -1 if none
+	spriteDots     int // dots left in the current sprite fetch
+
+	windowTriggered bool // WY matched LY at some point in this frame
+	windowDrawn     bool // the window was drawn on the current line
+}
+
+// tickFIFO advances the FIFO renderer dot by dot. g.cycles counts dots within
+// the current line.
+func (g *GPU) tickFIFO(cycles int) {
+	for range cycles {
+		g.stepDot()
+	}
+}
+
+func (g *GPU) stepDot() {
+	g.cycles++
+
+	switch g.mode {
+	case oamReadMode:
+		if g.cycles >= oamScanlineCycles {
+			g.setMode(vramReadMode)
+			g.startFIFOLine()
+		}
+	case vramReadMode:
+		if !g.fifo.active {
+			g.startFIFOLine()
+		}
+		if g.stepFIFO() {
+			g.setMode(hblankMode)
+			if g.bus.ReadBit(uint8(statHblankIrq), addr.STAT) {
+				g.requestInterrupt(addr.LCDSTATInterrupt)
+			}
+			if g.color != nil && g.readLCDCVariable(lcdDisplayEnable) == 1 {
+				g.color.HBlank()
+			}
+		}
+	case hblankMode:
+		if g.cycles < lineDots {
+			return
+		}
+		g.cycles = 0
+		g.fifo.active = false
+		if g.fifo.windowDrawn {
+			g.windowLine++
+		}
+		if g.Layers.Enabled && g.line == 0 {
+			g.updateLayerFramebuffers()
+		}
+
+		g.setLY(g.line + 1)
+		if g.line == vblankStartLine {
+			g.setMode(vblankMode)
+			g.requestInterrupt(addr.VBlankInterrupt)
+			if g.bus.ReadBit(uint8(statVblankIrq), addr.STAT) {
+				g.requestInterrupt(addr.LCDSTATInterrupt)
+			}
+			return
+		}
+		g.startOAMScan()
+	case vblankMode:
+		if g.cycles < lineDots {
+			return
+		}
+		g.cycles = 0
+		if g.line < lastLine {
+			g.setLY(g.line + 1)
+			return
+		}
+
+		// new frame
+		g.windowLine = 0
+		g.fifo.windowTriggered = false
+		g.setLY(0)
+		g.startOAMScan()
+	}
+}
+
+func (g *GPU) startOAMScan() {
+	g.setMode(oamReadMode)
+	if g.bus.ReadBit(uint8(statOamIrq), addr.STAT) {
+		g.requestInterrupt(addr.LCDSTATInterrupt)
+	}
+	if g.isWindowEnabled() && int(g.bus.Read(addr.WY)) == g.line {
+		g.fifo.windowTriggered = true
+	}
+}
+
+// startFIFOLine sets up the FIFOs and fetcher when entering mode 3.
+func (g *GPU) startFIFOLine() {
+	f := &g.fifo
+	f.active = true
+	f.bg.clear()
+	f.obj.clear()
+	f.fetcher = bgFetcher{}
+	f.lx = 0
+	f.discard = int(g.bus.Read(addr.SCX) & 0x07)
+	f.startup = fifoStartupDots
+	f.spriteFetching = -1
+	f.windowDrawn = false
+
+	g.cgb = g.color != nil && g.color.CGBMode()
+
+	f.spriteCount = 0
+	if g.readLCDCVariable(spriteDisplayEnable) == 1 {
+		g.oam.priorityByIndex = g.spritePriorityByIndex()
+		f.spriteCount = copy(f.sprites[:], g.oam.GetSpritesForScanline(g.line))
+	}
+	for i := range f.spriteFetched {
+		f.spriteFetched[i] = false
+	}
+}
+
+// stepFIFO advances mode 3 by one dot. Returns true when the line is complete.
+func (g *GPU) stepFIFO() bool {
+	f := &g.fifo
+
+	if g.readLCDCVariable(lcdDisplayEnable) == 0 {
+		// nothing to draw, keep the fixed mode 3 length
+		return g.cycles >= oamScanlineCycles+vramScanlineCycles
+	}
+
+	if f.startup > 0 {
+		f.startup--
+		return false
+	}
+
+	// an ongoing sprite fetch stalls everything else
+	if f.spriteFetching >= 0 {
+		if f.spriteDots > 0 {
+			f.spriteDots--
+			if f.spriteDots == 0 {
+				g.mixSprite(f.sprites[f.spriteFetching])
+				f.spriteFetched[f.spriteFetching] = true
+				f.spriteFetching = -1
+			}
+			return false
+		}
+
+		// the sprite fetch waits for the BG fetcher to have a tile ready
+		g.stepFetcher()
+		if f.bg.size > 0 && (f.fetcher.step == fetcherPushStep || (f.fetcher.step == 0 && f.fetcher.dots == 0)) {
+			f.spriteDots = spriteFetchDots
+		}
+		return false
+	}
+
+	g.checkWindowTrigger()
+
+	if f.discard == 0 {
+		if i := g.pendingSprite(); i >= 0 {
+			f.spriteFetching = i
+			f.spriteDots = 0
+			return false
+		}
+	}
+
+	g.stepFetcher()
+
+	if f.bg.size == 0 {
+		return false
+	}
+
+	bg := f.bg.pop()
+	obj, hasObj := fifoPixel{}, f.obj.size > 0
+	if hasObj {
+		obj = f.obj.pop()
+	}
+
+	if f.discard > 0 {
+		f.discard--
+		return false
+	}
+
+	g.framebuffer.buffer[g.line*FramebufferWidth+f.lx] = g.mixPixel(bg, obj, hasObj)
+	f.lx++
+
+	return f.lx == FramebufferWidth
+}
+
+// checkWindowTrigger switches the fetcher to the window when the output
+// reaches WX-7 on a line where the window is visible.
+func (g *GPU) checkWindowTrigger() {
+	f := &g.fifo
+	if f.fetcher.window || !f.windowTriggered || !g.isWindowEnabled() {
+		return
+	}
+
+	start := int(g.bus.Read(addr.WX)) - 7
+	if start < 0 {
+		start = 0
+	}
+	if f.lx != start || start >= FramebufferWidth {
+		return
+	}
+
+	f.bg.clear()
+	f.fetcher = bgFetcher{window: true}
+	f.discard = 0
+	f.windowDrawn = true
+}
+
+// pendingSprite returns the index of a sprite starting at the current output
+// position that still has to be fetched, or -1.
+func (g *GPU) pendingSprite() int {
+	f := &g.fifo
+	for i := range f.spriteCount {
+		if f.spriteFetched[i] {
+			continue
+		}
+		if spriteScreenX(f.sprites[i]) <= f.lx {
+			return i
+		}
+	}
+	return -1
+}
+
+// spriteScreenX returns the X coordinate of the leftmost sprite pixel, which
+// is negative for sprites partially hidden past the left edge.
+func spriteScreenX(s Sprite) int {
+	return int(uint8(s.X+8)) - 8
+}
+
+// stepFetcher advances the background/window fetcher by one dot.
+func (g *GPU) stepFetcher() {
+	fe := &g.fifo.fetcher
+
+	if fe.step == fetcherPushStep {
+		if g.fifo.bg.size <= fifoBGCapacity-8 {
+			g.pushTileRow()
+			fe.step = 0
+			fe.dots = 0
+			fe.tileX++
+		}
+		return
+	}
+
+	fe.dots++
+	if fe.dots < fetcherStepDots {
+		return
+	}
+	fe.dots = 0
+
+	switch fe.step {
+	case 0:
+		g.fetchTileIndex()
+	case 1:
+		addr := g.fetcherTileAddress()
+		fe.low = g.readVRAM(g.fetcherBank(), addr)
+	case 2:
+		addr := g.fetcherTileAddress()
+		fe.high = g.readVRAM(g.fetcherBank(), addr+1)
+	}
+	fe.step++
+}
+
+func (g *GPU) fetchTileIndex() {
+	fe := &g.fifo.fetcher
+
+	var mapAddr uint16
+	if fe.window {
+		mapAddr = g.getWindowTileMapAddress() + uint16((g.windowLine/8)*32+fe.tileX&31)
+	} else {
+		scrollX, scrollY := g.getBackgroundScroll()
+		x := (scrollX/8 + fe.tileX) & 31
+		y := ((g.line + scrollY) & 0xFF) / 8
+		mapAddr = g.getBackgroundTileMapAddress() + uint16(y*32+x)
+	}
+
+	fe.tile = g.readVRAM(0, mapAddr)
+	fe.attr = 0
+	if g.cgb {
+		fe.attr = g.color.ReadVRAM(1, mapAddr)
+	}
+}
+
+// fetcherTileAddress returns the address of the tile row being fetched.
+func (g *GPU) fetcherTileAddress() uint16 {
+	fe := &g.fifo.fetcher
+
+	var row int
+	if fe.window {
+		row = g.windowLine % 8
+	} else {
+		_, scrollY := g.getBackgroundScroll()
+		row = ((g.line + scrollY) & 0xFF) % 8
+	}
+	if bit.IsSet(bgAttrFlipY, fe.attr) {
+		row = 7 - row
+	}
+
+	useSigned := g.readLCDCVariable(bgWindowTileDataSelect) == 0
+	return tileRowAddress(fe.tile, row, g.getTileDataAddress(), useSigned)
+}
+
+func (g *GPU) fetcherBank() uint8 {
+	return bit.GetBitValue(bgAttrBank, g.fifo.fetcher.attr)
+}
+
+func (g *GPU) pushTileRow() {
+	fe := &g.fifo.fetcher
+	row := TileRow{Low: fe.low, High: fe.high}
+	flip := bit.IsSet(bgAttrFlipX, fe.attr)
+
+	for i := range 8 {
+		color := row.GetPixel(i)
+		if flip {
+			color = row.GetPixelFlipped(i)
+		}
+		g.fifo.bg.push(fifoPixel{
+			color:    uint8(color),
+			palette:  fe.attr & bgAttrPalette,
+			priority: bit.IsSet(bgAttrPriority, fe.attr),
+		})
+	}
+}
+
+// mixSprite merges a fetched sprite row into the OBJ FIFO. Pixels already in
+// the FIFO keep priority, except on CGB where a lower OAM index wins.
+func (g *GPU) mixSprite(sprite Sprite) {
+	f := &g.fifo
+	for f.obj.size < fifoObjCapacity {
+		f.obj.push(fifoPixel{})
+	}
+
+	row := g.fetchSpriteTileRow(sprite)
+	skip := 0
+	if x := spriteScreenX(sprite); x < f.lx {
+		skip = f.lx - x
+	}
+	byIndex := g.oam.priorityByIndex
+
+	palette := sprite.CGBPalette
+	if !g.cgb && sprite.PaletteOBP1 {
+		palette = 1
+	} else if !g.cgb {
+		palette = 0
+	}
+
+	for i := skip; i < 8; i++ {
+		color := row.GetPixel(i)
+		if sprite.FlipX {
+			color = row.GetPixelFlipped(i)
+		}
+		if color == 0 {
+			continue
+		}
+
+		existing := f.obj.at(i - skip)
+		if existing.color != 0 && !(byIndex && sprite.OAMIndex < existing.oamIndex) {
+			continue
+		}
+		*existing = fifoPixel{
+			color:    uint8(color),
+			palette:  palette,
+			priority: sprite.BehindBG,
+			oamIndex: sprite.OAMIndex,
+		}
+	}
+}
+
+// mixPixel resolves the final color of a pixel from the BG and OBJ FIFOs,
+// reading palettes at output time.
+func (g *GPU) mixPixel(bg, obj fifoPixel, hasObj bool) uint32 {
+	objVisible := hasObj && obj.color != 0 && g.readLCDCVariable(spriteDisplayEnable) == 1
+
+	if g.cgb {
+		if objVisible && (!g.isBackgroundEnabled() || bg.color == 0 || (!obj.priority && !bg.priority)) {
+			return uint32(RGB555ToColor(g.color.OBJPaletteColor(obj.palette, obj.color)))
+		}
+		return uint32(RGB555ToColor(g.color.BGPaletteColor(bg.palette, bg.color)))
+	}
+
+	bgColor := bg.color
+	if !g.isBackgroundEnabled() {
+		bgColor = 0
+	}
+	if objVisible && !(obj.priority && bgColor != 0) {
+		paletteAddr := addr.OBP0
+		if obj.palette == 1 {
+			paletteAddr = addr.OBP1
+		}
+		palette := g.bus.Read(paletteAddr)
+		return uint32(ByteToColor((palette >> (obj.color * 2)) & 0x03))
+	}
+
+	palette := g.bus.Read(addr.BGP)
+	return uint32(ByteToColor((palette >> (bgColor * 2)) & 0x03))
+}
+
+// readVRAM reads a VRAM bank directly when the bus allows it, so the GPU is
+// not affected by the bank selected by the CPU.
+func (g *GPU) readVRAM(bank uint8, address uint16) byte {
+	if g.color != nil {
+		return g.color.ReadVRAM(bank, address)
+	}
+	return g.mem.Read(address)
+}
@@ -0,0 +1,246 @@
+package video
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/valerio/go-jeebie/jeebie/addr"
+	"github.com/valerio/go-jeebie/jeebie/memory"
+	"github.com/valerio/go-jeebie/jeebie/state"
+)
+
+func newFIFOGpu(t *testing.T) (*GPU, *memory.MMU) {
+	t.Helper()
+	mmu := memory.New()
+	gpu := NewGpu(mmu)
+	gpu.SetRenderer(RendererFIFO)
+	mmu.Write(addr.LCDC, 0x93) // LCD on, sprites on, BG on, unsigned tiles
+	mmu.Write(addr.BGP, 0xE4)
+	mmu.Write(addr.OBP0, 0xE4)
+	return gpu, mmu
+}
+
+// runToMode ticks the GPU one dot at a time until it enters mode on line.
+func runToMode(t *testing.T, gpu *GPU, line int, mode GpuMode) {
+	t.Helper()
+	for range 2 * 70224 {
+		if gpu.line == line && gpu.mode == mode {
+			return
+		}
+		gpu.Tick(1)
+	}
+	t.Fatalf("GPU never reached mode %d on line %d", mode, line)
+}
+
+// mode3Length returns how many dots mode 3 lasts on the given line.
+func mode3Length(t *testing.T, gpu *GPU, line int) int {
+	t.Helper()
+	runToMode(t, gpu, line, vramReadMode)
+	dots := 0
+	for gpu.mode == vramReadMode {
+		gpu.Tick(1)
+		dots++
+	}
+	return dots
+}
+
+func TestFIFOMode3Length(t *testing.T) {
+	tests := []struct {
+		name  string
+		setup func(mmu *memory.MMU)
+		min   int
+		max   int
+	}{
+		{"no scroll", func(mmu *memory.MMU) {}, 172, 172},
+		{"SCX fine scroll", func(mmu *memory.MMU) { mmu.Write(addr.SCX, 0x03) }, 175, 175},
+		{"window", func(mmu *memory.MMU) {
+			mmu.Write(addr.LCDC, 0xB3)
+			mmu.Write(addr.WY, 0)
+			mmu.Write(addr.WX, 7+80)
+		}, 178, 178},
+		{"one sprite", func(mmu *memory.MMU) {
+			mmu.Write(addr.OAMStart, 16)
+			mmu.Write(addr.OAMStart+1, 8+40)
+		}, 178, 183},
+		{"sprites disabled", func(mmu *memory.MMU) {
+			mmu.Write(addr.LCDC, 0x91)
+			mmu.Write(addr.OAMStart, 16)
+			mmu.Write(addr.OAMStart+1, 8+40)
+		}, 172, 172},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gpu, mmu := newFIFOGpu(t)
+			tt.setup(mmu)
+
+			length := mode3Length(t, gpu, 0)
+			assert.GreaterOrEqual(t, length, tt.min)
+			assert.LessOrEqual(t, length, tt.max)
+		})
+	}
+}
+
+func TestFIFOLineLengthIsConstant(t *testing.T) {
+	gpu, mmu := newFIFOGpu(t)
+	mmu.Write(addr.SCX, 0x05)
+	mmu.Write(addr.OAMStart, 16)
+	mmu.Write(addr.OAMStart+1, 20)
+
+	runToMode(t, gpu, 0, oamReadMode)
+	dots := 0
+	for gpu.line == 0 {
+		gpu.Tick(1)
+		dots++
+	}
+	assert.Equal(t, scanlineCycles, dots)
+
+	// line 1 to line 1 of the next frame
+	runToMode(t, gpu, 1, oamReadMode)
+	frame := 0
+	for gpu.line == 1 {
+		gpu.Tick(1)
+		frame++
+	}
+	for gpu.line != 1 || gpu.mode != oamReadMode {
+		gpu.Tick(1)
+		frame++
+	}
+	assert.Equal(t, 70224, frame)
+}
+
+func TestFIFOMatchesScanlineRenderer(t *testing.T) {
+	render := func(renderer Renderer) []uint32 {
+		mmu := memory.New()
+		gpu := NewGpu(mmu)
+		gpu.SetRenderer(renderer)
+
+		mmu.Write(addr.LCDC, 0xF3) // LCD, window (map 1), sprites, BG
+		mmu.Write(addr.BGP, 0xE4)
+		mmu.Write(addr.OBP0, 0xE4)
+		mmu.Write(addr.OBP1, 0x1B)
+		mmu.Write(addr.SCX, 13)
+		mmu.Write(addr.SCY, 5)
+		mmu.Write(addr.WY, 100)
+		mmu.Write(addr.WX, 7+120)
+
+		for tile := range 4 {
+			data := createColorTile(tile)
+			for i, b := range data {
+				mmu.Write(0x8000+uint16(tile*16+i), b)
+			}
+		}
+		// a tile with distinct rows and columns, to catch fine scroll errors
+		for i := range uint16(16) {
+			mmu.Write(0x8040+i, uint8(0x5A+i*7))
+		}
+		for i := range uint16(1024) {
+			mmu.Write(addr.TileMap0+i, uint8(i%5))
+			mmu.Write(addr.TileMap1+i, uint8((i+2)%5))
+		}
+
+		sprites := [][4]uint8{
+			{16 + 10, 8 + 30, 3, 0x00},
+			{16 + 12, 8 + 34, 4, 0x10 | 0x20}, // OBP1, X flip
+			{16 + 60, 8 + 70, 2, 0x80},        // behind BG
+		}
+		for i, s := range sprites {
+			for j, b := range s {
+				mmu.Write(addr.OAMStart+uint16(i*4+j), b)
+			}
+		}
+
+		runToMode(t, gpu, 0, oamReadMode)
+		runToMode(t, gpu, 144, vblankMode)
+		return append([]uint32(nil), gpu.framebuffer.ToSlice()...)
+	}
+
+	scanline := render(RendererScanline)
+	fifo := render(RendererFIFO)
+	require.Len(t, fifo, len(scanline))
+	for i := range scanline {
+		if scanline[i] != fifo[i] {
+			t.Fatalf("pixel (%d, %d) differs: scanline %08X, fifo %08X",
+				i%FramebufferWidth, i/FramebufferWidth, scanline[i], fifo[i])
+		}
+	}
+}
+
+func TestFIFOMidScanlinePaletteChange(t *testing.T) {
+	gpu, mmu := newFIFOGpu(t)
+
+	data := createColorTile(3)
+	for i, b := range data {
+		mmu.Write(0x8000+uint16(i), b)
+	}
+
+	runToMode(t, gpu, 0, vramReadMode)
+	gpu.Tick(12 + 40) // startup, then 40 pixels
+	mmu.Write(addr.BGP, 0x00)
+	runToMode(t, gpu, 0, hblankMode)
+
+	assert.Equal(t, uint32(BlackColor), gpu.framebuffer.GetPixel(0, 0))
+	assert.Equal(t, uint32(BlackColor), gpu.framebuffer.GetPixel(39, 0))
+	assert.Equal(t, uint32(WhiteColor), gpu.framebuffer.GetPixel(50, 0))
+	assert.Equal(t, uint32(WhiteColor), gpu.framebuffer.GetPixel(159, 0))
+}
+
+func TestFIFOSpriteClippedAtLeftEdge(t *testing.T) {
+	gpu, mmu := newFIFOGpu(t)
+
+	// sprite tile 1: leftmost column color 1, the rest color 3
+	for i := range uint16(8) {
+		mmu.Write(0x8010+i*2, 0xFF)
+		mmu.Write(0x8010+i*2+1, 0x7F)
+	}
+	mmu.Write(addr.OAMStart, 16)
+	mmu.Write(addr.OAMStart+1, 4) // 4 of 8 pixels visible
+	mmu.Write(addr.OAMStart+2, 1)
+
+	runToMode(t, gpu, 0, hblankMode)
+
+	for x := range uint(4) {
+		assert.Equal(t, uint32(BlackColor), gpu.framebuffer.GetPixel(x, 0), "pixel %d", x)
+	}
+	assert.Equal(t, uint32(WhiteColor), gpu.framebuffer.GetPixel(4, 0))
+}
+
+func TestFIFOStateMidLine(t *testing.T) {
+	gpu, mmu := newFIFOGpu(t)
+	for i := range uint16(16) {
+		mmu.Write(0x8000+i, uint8(0x3C^i))
+	}
+	mmu.Write(addr.SCX, 0x03)
+	mmu.Write(addr.OAMStart, 16)
+	mmu.Write(addr.OAMStart+1, 8+40)
+
+	runToMode(t, gpu, 0, vramReadMode)
+	gpu.Tick(12 + 40)
+
+	var saved bytes.Buffer
+	w := state.NewWriter(&saved)
+	mmu.SerializeState(w)
+	gpu.SerializeState(w)
+	require.NoError(t, w.Err())
+
+	// finish the line with a palette change, then again from the saved state
+	finishLine := func() ([]uint32, int) {
+		mmu.Write(addr.BGP, 0x1B)
+		runToMode(t, gpu, 0, hblankMode)
+		line := make([]uint32, FramebufferWidth)
+		copy(line, gpu.framebuffer.buffer[:FramebufferWidth])
+		return line, gpu.cycles
+	}
+	want, wantDots := finishLine()
+
+	r := state.NewReader(bytes.NewReader(saved.Bytes()))
+	mmu.SerializeState(r)
+	gpu.SerializeState(r)
+	require.NoError(t, r.Err())
+
+	got, gotDots := finishLine()
+	assert.Equal(t, want, got, "the line resumes where it was saved")
+	assert.Equal(t, wantDots, gotDots)
+}
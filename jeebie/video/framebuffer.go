@@ -30,6 +30,17 @@ func ByteToColor(value byte) GBColor {
 	return 0
 }
 
+// RGB555ToColor converts a CGB palette color (5 bits per channel, red in the
+// low bits) to an RGBA framebuffer color.
+func RGB555ToColor(value uint16) GBColor {
+	scale := func(c uint16) uint32 {
+		c &= 0x1F
+		return uint32(c<<3 | c>>2)
+	}
+	r, g, b := scale(value), scale(value>>5), scale(value>>10)
+	return GBColor(r<<24 | g<<16 | b<<8 | 0xFF)
+}
+
 type FrameBuffer struct {
 	width  uint
 	height uint
@@ -17,6 +17,27 @@ type Bus interface {
 	RequestInterrupt(interrupt addr.Interrupt)
 }
 
+// PeekBus is implemented by buses that block CPU access to VRAM and OAM while
+// the PPU is using them. The GPU reads through Peek, which is never blocked.
+type PeekBus interface {
+	Peek(address uint16) byte
+}
+
+// StatusBus is implemented by buses that keep the mode and LYC match bits of
+// STAT read-only for the CPU. The GPU sets them through SetPPUStatus.
+type StatusBus interface {
+	SetPPUStatus(status byte)
+}
+
+// peekReader adapts a PeekBus to MemoryReader.
+type peekReader struct {
+	bus PeekBus
+}
+
+func (r peekReader) Read(address uint16) byte {
+	return r.bus.Peek(address)
+}
+
 // GpuMode represents the PPU's current rendering stage.
 // These values match the STAT register bits 1-0.
 type GpuMode int
@@ -41,10 +62,15 @@ const (
 
 type GPU struct {
 	bus           Bus
+	mem           MemoryReader // VRAM and OAM reads, not subject to CPU access blocking
 	framebuffer   *FrameBuffer
 	bgPixelBuffer []byte        // stores background/window pixel colors for sprite priority
 	oam           *OAM          // OAM scanner for sprite management
 	Layers        *RenderLayers // separate layer framebuffers for debug visualization
+	color         ColorBus      // Game Boy Color hardware, nil if the bus doesn't provide it
+	cgb           bool          // whether the current scanline is rendered in CGB mode
+	renderer      Renderer      // scanline or pixel FIFO renderer
+	fifo          fifoState     // pixel FIFO renderer state, see fifo.go
 
 	// PPU state - these map to Game Boy hardware registers/behavior
 	mode                 GpuMode // current PPU mode (matches STAT bits 1-0)
@@ -65,16 +91,25 @@ func NewGpu(memory *memory.MMU) *GPU {
 func New(bus Bus) *GPU {
 	fb := NewFrameBuffer()
 
+	var mem MemoryReader = bus
+	if pb, ok := bus.(PeekBus); ok {
+		mem = peekReader{pb}
+	}
+
 	gpu := &GPU{
 		framebuffer:   fb,
 		bus:           bus,
+		mem:           mem,
 		mode:          vblankMode,
 		bgPixelBuffer: make([]byte, FramebufferSize),
-		oam:           NewOAM(bus),
+		oam:           NewOAM(mem),
 		Layers:        NewRenderLayers(),
 
 		line: 144,
 	}
+	if cb, ok := bus.(ColorBus); ok {
+		gpu.color = cb
+	}
 
 	// Log initial LCD state if bus is available
 	if bus != nil {
@@ -99,6 +134,11 @@ func (g *GPU) SetLayerRenderingEnabled(enabled bool) {
 
 // Tick simulates gpu behaviour for a certain amount of clock cycles.
 func (g *GPU) Tick(cycles int) {
+	if g.renderer == RendererFIFO {
+		g.tickFIFO(cycles)
+		return
+	}
+
 	g.cycles += cycles
 
 	switch g.mode {
@@ -174,6 +214,9 @@ func (g *GPU) Tick(cycles int) {
 			g.cycles -= vramScanlineCycles
 			g.tileCycleCounter = 0
 			g.setMode(hblankMode)
+			if g.color != nil && g.readLCDCVariable(lcdDisplayEnable) == 1 {
+				g.color.HBlank()
+			}
 
 			// We're switching to HBlank Mode
 			// if enabled on STAT, trigger the LCDStat interrupt
@@ -200,6 +243,8 @@ func (g *GPU) drawScanline() {
 		return
 	}
 
+	g.cgb = g.color != nil && g.color.CGBMode()
+
 	// Draw all layers in correct order: Background -> Window -> Sprites
 	g.drawBackground()
 	g.drawWindow()
@@ -212,7 +257,8 @@ func (g *GPU) drawScanline() {
 }
 
 func (g *GPU) drawBackground() {
-	if !g.isBackgroundEnabled() {
+	// on CGB, LCDC bit 0 only controls BG priority, the background is always drawn
+	if !g.isBackgroundEnabled() && !g.cgb {
 		g.clearBackground()
 		return
 	}
@@ -254,10 +300,15 @@ func (g *GPU) renderBackgroundTiles() {
 		// calculate position in background map (with wrapping)
 		bgX := (screenX + scrollX) & 0xFF
 		bgY := scrolledY
+		position := lineWidth + screenX
 
-		pixelColor := g.fetchBackgroundPixel(bgX, bgY, tileDataAddr, tileMapAddr, useSigned)
+		if g.cgb {
+			pixelColor, attr := g.fetchMapPixelCGB(bgX, bgY, tileDataAddr, tileMapAddr, useSigned)
+			g.drawMapPixelCGB(position, pixelColor, attr)
+			continue
+		}
 
-		position := lineWidth + screenX
+		pixelColor := g.fetchBackgroundPixel(bgX, bgY, tileDataAddr, tileMapAddr, useSigned)
 		g.drawBackgroundPixel(position, pixelColor)
 	}
 }
@@ -289,28 +340,30 @@ func (g *GPU) fetchBackgroundPixel(bgX, bgY int, tileDataAddr, tileMapAddr uint1
 
 	// fetch tile index from tilemap
 	tileMapOffset := tileY*32 + tileX
-	tileIndex := g.bus.Read(tileMapAddr + uint16(tileMapOffset))
+	tileIndex := g.mem.Read(tileMapAddr + uint16(tileMapOffset))
 
 	tileRow := g.fetchTileRow(tileIndex, pixelYInTile, tileDataAddr, useSigned)
 	return tileRow.GetPixel(pixelXInTile)
 }
 
 func (g *GPU) fetchTileRow(tileIndex byte, row int, baseAddr uint16, signed bool) TileRow {
-	var tileAddr uint16
+	tileAddr := tileRowAddress(tileIndex, row, baseAddr, signed)
+
+	return TileRow{
+		Low:  g.mem.Read(tileAddr),
+		High: g.mem.Read(tileAddr + 1),
+	}
+}
 
+// tileRowAddress returns the address of a row of a BG/window tile.
+func tileRowAddress(tileIndex byte, row int, baseAddr uint16, signed bool) uint16 {
 	if signed {
 		// signed addressing: interpret as -128 to 127
 		signedIndex := int8(tileIndex)
-		tileAddr = uint16(int(baseAddr) + int(signedIndex)*16 + row*2)
-	} else {
-		// unsigned addressing: 0 to 255
-		tileAddr = baseAddr + uint16(tileIndex)*16 + uint16(row*2)
-	}
-
-	return TileRow{
-		Low:  g.bus.Read(tileAddr),
-		High: g.bus.Read(tileAddr + 1),
+		return uint16(int(baseAddr) + int(signedIndex)*16 + row*2)
 	}
+	// unsigned addressing: 0 to 255
+	return baseAddr + uint16(tileIndex)*16 + uint16(row*2)
 }
 
 func (g *GPU) drawBackgroundPixel(position int, pixelColor int) {
@@ -375,12 +428,18 @@ func (g *GPU) renderWindowTiles() {
 		// calculate window-relative coordinates
 		windowX := screenX - wx
 		windowY := g.windowLine
+		position := lineWidth + screenX
+
+		if g.cgb {
+			pixelColor, attr := g.fetchMapPixelCGB(windowX, windowY, tileDataAddr, tileMapAddr, useSigned)
+			g.drawMapPixelCGB(position, pixelColor, attr)
+			continue
+		}
 
 		// fetch and decode the pixel
 		pixelColor := g.fetchWindowPixel(windowX, windowY, tileDataAddr, tileMapAddr, useSigned)
 
 		// apply palette and write to buffers
-		position := lineWidth + screenX
 		g.drawWindowPixel(position, pixelColor)
 	}
 }
@@ -401,7 +460,7 @@ func (g *GPU) fetchWindowPixel(windowX, windowY int, tileDataAddr, tileMapAddr u
 
 	// fetch tile index from tilemap
 	tileMapOffset := tileY*32 + tileX
-	tileIndex := g.bus.Read(tileMapAddr + uint16(tileMapOffset))
+	tileIndex := g.mem.Read(tileMapAddr + uint16(tileMapOffset))
 
 	// fetch tile data (reuse background tile fetching)
 	tileRow := g.fetchTileRow(tileIndex, pixelYInTile, tileDataAddr, useSigned)
@@ -424,6 +483,7 @@ func (g *GPU) drawSprites() {
 		return
 	}
 
+	g.oam.priorityByIndex = g.spritePriorityByIndex()
 	sprites := g.oam.GetSpritesForScanline(g.line)
 	for _, sprite := range sprites {
 		if !sprite.HasPriorityForAnyPixel() {
@@ -462,6 +522,13 @@ func (g *GPU) renderSprite(sprite Sprite) {
 
 		// check background priority
 		position := lineWidth + bufferX
+		if g.cgb {
+			if !g.spriteHiddenCGB(position, sprite) {
+				color := g.color.OBJPaletteColor(sprite.CGBPalette, uint8(pixelColor))
+				g.framebuffer.buffer[position] = uint32(RGB555ToColor(color))
+			}
+			continue
+		}
 		if sprite.BehindBG && g.bgPixelBuffer[position] != 0 {
 			continue
 		}
@@ -494,9 +561,16 @@ func (g *GPU) fetchSpriteTileRow(sprite Sprite) TileRow {
 	// sprites always use unsigned addressing from 0x8000
 	tileAddr := addr.TileData0 + uint16(int(tileIndex)*16+tileRowOffset)
 
+	if g.cgb {
+		return TileRow{
+			Low:  g.color.ReadVRAM(sprite.VRAMBank, tileAddr),
+			High: g.color.ReadVRAM(sprite.VRAMBank, tileAddr+1),
+		}
+	}
+
 	return TileRow{
-		Low:  g.bus.Read(tileAddr),
-		High: g.bus.Read(tileAddr + 1),
+		Low:  g.mem.Read(tileAddr),
+		High: g.mem.Read(tileAddr + 1),
 	}
 }
 
@@ -581,7 +655,7 @@ func (g *GPU) compareLYToLYC() {
 		stat = bit.Reset(uint8(statLycCondition), stat)
 	}
 
-	g.bus.Write(addr.STAT, stat)
+	g.writeSTAT(stat)
 }
 
 // requestInterrupt requests an interrupt via bus
@@ -597,6 +671,16 @@ func (g *GPU) setMode(mode GpuMode) {
 	g.mode = mode
 	stat := g.bus.Read(addr.STAT)
 	stat = stat&0xFC | byte(g.mode)
+	g.writeSTAT(stat)
+}
+
+// writeSTAT updates STAT, through StatusBus if the bus has it as CPU writes
+// can't change the PPU bits there.
+func (g *GPU) writeSTAT(stat byte) {
+	if sb, ok := g.bus.(StatusBus); ok {
+		sb.SetPPUStatus(stat)
+		return
+	}
 	g.bus.Write(addr.STAT, stat)
 }
 
@@ -624,7 +708,7 @@ func (g *GPU) updateLayerFramebuffers() {
 		tileDataAddr := g.getTileDataAddress()
 		tileMapAddr := g.getBackgroundTileMapAddress()
 		useSigned := g.readLCDCVariable(bgWindowTileDataSelect) == 0
-		RenderTilemapToBuffer(g.bus, tileMapAddr, tileDataAddr,
+		RenderTilemapToBuffer(g.mem, tileMapAddr, tileDataAddr,
 			g.Layers.Background.Buffer, bgPalette, useSigned)
 	}
 
@@ -633,7 +717,7 @@ func (g *GPU) updateLayerFramebuffers() {
 		tileDataAddr := g.getTileDataAddress()
 		tileMapAddr := g.getWindowTileMapAddress()
 		useSigned := g.readLCDCVariable(bgWindowTileDataSelect) == 0
-		RenderTilemapToBuffer(g.bus, tileMapAddr, tileDataAddr,
+		RenderTilemapToBuffer(g.mem, tileMapAddr, tileDataAddr,
 			g.Layers.Window.Buffer, bgPalette, useSigned)
 	}
 
@@ -658,7 +742,7 @@ func (g *GPU) updateLayerFramebuffers() {
 			sprites = append(sprites, sprite)
 		}
 
-		RenderSpritesToBuffer(sprites, g.bus, g.Layers.Sprites.Buffer,
+		RenderSpritesToBuffer(sprites, g.mem, g.Layers.Sprites.Buffer,
 			obp0Palette, obp1Palette, 160, 144)
 	}
 }
@@ -0,0 +1,78 @@
+package video
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/valerio/go-jeebie/jeebie/addr"
+	"github.com/valerio/go-jeebie/jeebie/memory"
+)
+
+func writeCGBPalette(mmu *memory.MMU, spec uint16, palette int, colors ...uint16) {
+	mmu.Write(spec, 0x80|uint8(palette*8))
+	for _, c := range colors {
+		mmu.Write(spec+1, uint8(c))
+		mmu.Write(spec+1, uint8(c>>8))
+	}
+}
+
+func TestRGB555ToColor(t *testing.T) {
+	assert.Equal(t, WhiteColor, RGB555ToColor(0x7FFF))
+	assert.Equal(t, BlackColor, RGB555ToColor(0x0000))
+	assert.Equal(t, GBColor(0xFF0000FF), RGB555ToColor(0x001F))
+	assert.Equal(t, GBColor(0x0000FFFF), RGB555ToColor(0x7C00))
+}
+
+func TestGPUCGBBackgroundAttributes(t *testing.T) {
+	mmu := memory.New()
+	mmu.SetCGBMode(true)
+	gpu := NewGpu(mmu)
+
+	mmu.Write(addr.LCDC, 0x91) // LCD on, unsigned tiles, BG on
+	writeCGBPalette(mmu, addr.BCPS, 3, 0x7FFF, 0x001F, 0x03E0, 0x7C00)
+
+	// tile 1 in bank 1: first row is colors 1,1,1,1,2,2,2,2
+	mmu.Write(addr.VBK, 1)
+	mmu.Write(0x8010, 0xF0)
+	mmu.Write(0x8011, 0x0F)
+	// map entry 0: tile 1, palette 3, bank 1, flipped horizontally
+	mmu.Write(addr.TileMap0, 0x03|0x08|0x20)
+	mmu.Write(addr.VBK, 0)
+	mmu.Write(addr.TileMap0, 0x01)
+
+	gpu.line = 0
+	gpu.drawScanline()
+
+	green := uint32(RGB555ToColor(0x03E0))
+	red := uint32(RGB555ToColor(0x001F))
+	assert.Equal(t, green, gpu.framebuffer.GetPixel(0, 0), "flipped: color 2 on the left")
+	assert.Equal(t, red, gpu.framebuffer.GetPixel(7, 0))
+}
+
+func TestGPUCGBSpritePalette(t *testing.T) {
+	mmu := memory.New()
+	mmu.SetCGBMode(true)
+	gpu := NewGpu(mmu)
+
+	mmu.Write(addr.LCDC, 0x93) // LCD on, sprites on, BG on
+	writeCGBPalette(mmu, addr.BCPS, 0, 0x7FFF, 0x7FFF, 0x7FFF, 0x7FFF)
+	writeCGBPalette(mmu, addr.OCPS, 5, 0x0000, 0x001F, 0x03E0, 0x7C00)
+
+	// sprite tile 2 in bank 1, solid color 3
+	mmu.Write(addr.VBK, 1)
+	for i := range uint16(16) {
+		mmu.Write(0x8020+i, 0xFF)
+	}
+	mmu.Write(addr.VBK, 0)
+
+	mmu.Write(addr.OAMStart, 16)
+	mmu.Write(addr.OAMStart+1, 8)
+	mmu.Write(addr.OAMStart+2, 2)
+	mmu.Write(addr.OAMStart+3, 0x08|0x05) // bank 1, palette 5
+
+	gpu.line = 0
+	gpu.drawScanline()
+
+	assert.Equal(t, uint32(RGB555ToColor(0x7C00)), gpu.framebuffer.GetPixel(0, 0))
+	assert.Equal(t, uint32(WhiteColor), gpu.framebuffer.GetPixel(8, 0))
+}
@@ -16,10 +16,12 @@ type Sprite struct {
 	Height    int   // Sprite height (8 or 16 pixels, from LCDC bit 2)
 
 	// parsed attribute flags for convenience
-	PaletteOBP1 bool // false = OBP0, true = OBP1
-	FlipX       bool // horizontally flip the sprite
-	FlipY       bool // vertically flip the sprite
-	BehindBG    bool // true = sprite is behind background (priority flag)
+	PaletteOBP1 bool  // false = OBP0, true = OBP1
+	FlipX       bool  // horizontally flip the sprite
+	FlipY       bool  // vertically flip the sprite
+	BehindBG    bool  // true = sprite is behind background (priority flag)
+	VRAMBank    uint8 // CGB only: VRAM bank of the tile data
+	CGBPalette  uint8 // CGB only: object color palette (0-7)
 
 	// pixel priority mask - bit 7 is leftmost pixel, bit 0 is rightmost
 	// a bit is set if this sprite has priority for that pixel after sprite-to-sprite priority resolution
@@ -31,6 +33,8 @@ func (s *Sprite) parseFlags() {
 	s.FlipX = bit.IsSet(5, s.Flags)
 	s.FlipY = bit.IsSet(6, s.Flags)
 	s.BehindBG = bit.IsSet(7, s.Flags)
+	s.VRAMBank = bit.GetBitValue(3, s.Flags)
+	s.CGBPalette = s.Flags & 0x07
 }
 
 func (s *Sprite) HasPriorityForAnyPixel() bool {
@@ -57,6 +61,10 @@ type OAM struct {
 	bus            OAMBus
 	priorityBuffer SpritePriorityBuffer
 	spriteBuffer   [10]Sprite // scanline sprites (hardware limit is 10)
+
+	// priorityByIndex resolves overlapping sprites by OAM index only (CGB),
+	// instead of X coordinate first (DMG)
+	priorityByIndex bool
 }
 
 func NewOAM(bus OAMBus) *OAM {
@@ -110,9 +118,13 @@ func (o *OAM) GetSpritesForScanline(scanline int) []Sprite {
 			sprites = append(sprites, sprite)
 
 			// resolve priority for this sprite's pixels
+			priorityX := int(sprite.X)
+			if o.priorityByIndex {
+				priorityX = 0 // equal X for all sprites: OAM index decides
+			}
 			for pixelX := range 8 {
 				bufferX := int(sprite.X) + pixelX
-				o.priorityBuffer.TryClaimPixel(bufferX, sprite.OAMIndex, int(sprite.X))
+				o.priorityBuffer.TryClaimPixel(bufferX, sprite.OAMIndex, priorityX)
 			}
 
 			// hardware limit: maximum 10 sprites per scanline
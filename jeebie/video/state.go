@@ -0,0 +1,84 @@
+package video
+
+import "github.com/valerio/go-jeebie/jeebie/state"
+
+// SerializeState saves or restores the PPU counters, the pixel FIFO and the
+// last rendered frame, so a restored state shows the right picture before
+// the next frame.
+// LCD registers live in memory and are saved by the MMU.
+func (g *GPU) SerializeState(s *state.Serializer) {
+	mode := int(g.mode)
+	s.Int(&mode)
+	g.mode = GpuMode(mode)
+
+	s.Int(&g.line)
+	s.Int(&g.cycles)
+	s.Int(&g.modeCounterAux)
+	s.Int(&g.vBlankLine)
+	s.Int(&g.pixelCounter)
+	s.Int(&g.tileCycleCounter)
+	s.Bool(&g.isScanLineTransfered)
+	s.Int(&g.windowLine)
+	s.Bool(&g.cgb)
+	s.Bool(&g.oam.priorityByIndex)
+	g.fifo.serializeState(s)
+
+	s.Uint32s(g.framebuffer.buffer)
+	s.Bytes(g.bgPixelBuffer)
+}
+
+// serializeState saves or restores the FIFO renderer mid line: the pixels
+// queued, the fetcher and the sprites of the line, so a state saved in mode
+// 3 resumes at the dot it was saved at.
+func (f *fifoState) serializeState(s *state.Serializer) {
+	s.Bool(&f.active)
+	f.bg.serializeState(s)
+	f.obj.serializeState(s)
+
+	fe := &f.fetcher
+	s.Int(&fe.step)
+	s.Int(&fe.dots)
+	s.Int(&fe.tileX)
+	s.Bool(&fe.window)
+	s.Uint8(&fe.tile)
+	s.Uint8(&fe.attr)
+	s.Uint8(&fe.low)
+	s.Uint8(&fe.high)
+
+	s.Int(&f.lx)
+	s.Int(&f.discard)
+	s.Int(&f.startup)
+
+	s.Int(&f.spriteCount)
+	for i := range f.sprites {
+		sp := &f.sprites[i]
+		s.Uint8(&sp.Y)
+		s.Uint8(&sp.X)
+		s.Uint8(&sp.TileIndex)
+		s.Uint8(&sp.Flags)
+		s.Int(&sp.OAMIndex)
+		s.Int(&sp.Height)
+		s.Uint8(&sp.PixelMask)
+		if s.Loading() {
+			sp.parseFlags()
+		}
+		s.Bool(&f.spriteFetched[i])
+	}
+	s.Int(&f.spriteFetching)
+	s.Int(&f.spriteDots)
+
+	s.Bool(&f.windowTriggered)
+	s.Bool(&f.windowDrawn)
+}
+
+func (q *pixelQueue) serializeState(s *state.Serializer) {
+	for i := range q.buf {
+		p := &q.buf[i]
+		s.Uint8(&p.color)
+		s.Uint8(&p.palette)
+		s.Bool(&p.priority)
+		s.Int(&p.oamIndex)
+	}
+	s.Int(&q.head)
+	s.Int(&q.size)
+}
@@ -192,6 +192,14 @@ func runIntegrationTest(t *testing.T, testCase IntegrationTestCase) {
 
 	emu.RunUntilComplete()
 
+	// Test ROMs that report their result are also checked semantically
+	result := jeebie.DetectTestResult(emu, jeebie.DefaultCompletionDetectors()...)
+	if result.Status == jeebie.TestFailed {
+		t.Errorf("Test ROM reported a failure (%s): %s", result.Detector, result.Message)
+	} else if result.Status == jeebie.TestPassed {
+		t.Logf("Test ROM reported a pass (%s)", result.Detector)
+	}
+
 	fb := emu.GetCurrentFrame()
 
 	testName := testCase.Name
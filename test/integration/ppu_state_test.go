@@ -0,0 +1,95 @@
+package integration
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/valerio/go-jeebie/jeebie"
+	"github.com/valerio/go-jeebie/jeebie/addr"
+	"github.com/valerio/go-jeebie/jeebie/video"
+)
+
+// Mooneye PPU timing tests, sensitive to where mode 3 ends on each line.
+var ppuStateROMs = []string{
+	"hblank_ly_scx_timing-GS.gb",
+	"intr_2_mode0_timing.gb",
+	"intr_2_mode3_timing.gb",
+	"intr_2_mode0_timing_sprites.gb",
+}
+
+// ppuStateInstructions is how far each ROM runs, enough for the tests to
+// report their result.
+const ppuStateInstructions = 2_000_000
+
+// TestPPUStateMidLine checks that a state saved during mode 3 with the FIFO
+// renderer resumes exactly: the run continued from the state must end like
+// an uninterrupted one.
+func TestPPUStateMidLine(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Skipping integration tests in short mode")
+	}
+
+	ppuDir := "../../test-roms/game-boy-test-roms/mooneye-test-suite/acceptance/ppu"
+	for _, name := range ppuStateROMs {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			path := filepath.Join(ppuDir, name)
+			if _, err := os.Stat(path); os.IsNotExist(err) {
+				t.Skipf("Test ROM not found: %s, run 'make test-roms-download'", path)
+			}
+
+			straight := newFIFOEmulator(t, path)
+			stepUntil(straight, ppuStateInstructions)
+
+			// save on the first mode 3 after a few frames
+			saved := newFIFOEmulator(t, path)
+			stepUntil(saved, 5*17556)
+			for saved.ReadMemory(addr.STAT, 1)[0]&0x03 != 3 {
+				saved.StepInstruction()
+			}
+			var buf bytes.Buffer
+			if err := saved.SaveState(&buf); err != nil {
+				t.Fatalf("Failed to save state: %v", err)
+			}
+
+			resumed := newFIFOEmulator(t, path)
+			if err := resumed.LoadState(&buf); err != nil {
+				t.Fatalf("Failed to load state: %v", err)
+			}
+			stepUntil(resumed, ppuStateInstructions)
+
+			want, got := straight.ReadRegisters(), resumed.ReadRegisters()
+			if got != want {
+				t.Errorf("CPU state differs after resuming\n  straight: %+v\n  resumed:  %+v", want, got)
+			}
+			if !bytes.Equal(straight.GetCurrentFrame().ToGrayscale(), resumed.GetCurrentFrame().ToGrayscale()) {
+				t.Errorf("Screen differs after resuming")
+			}
+
+			if want.B == 3 && want.C == 5 && want.D == 8 && want.E == 13 && want.H == 21 && want.L == 34 {
+				t.Logf("Test ROM reported a pass")
+			} else {
+				t.Logf("Test ROM did not report a pass: %+v", want)
+			}
+		})
+	}
+}
+
+func newFIFOEmulator(t *testing.T, path string) *jeebie.DMG {
+	t.Helper()
+	emu, err := jeebie.NewWithFile(path, jeebie.WithRenderer(video.RendererFIFO), jeebie.WithSaveDir(t.TempDir()))
+	if err != nil {
+		t.Fatalf("Failed to create emulator: %v", err)
+	}
+	t.Cleanup(func() { emu.Close() })
+	return emu
+}
+
+// stepUntil executes instructions until count have run since power on.
+func stepUntil(emu *jeebie.DMG, count uint64) {
+	for emu.GetInstructionCount() < count {
+		emu.StepInstruction()
+	}
+}